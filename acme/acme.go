@@ -0,0 +1,213 @@
+// ACME certificate management
+//
+// Automatic TLS certificates from Let's Encrypt or any other ACME CA, for standalone deployments without a
+// fronting proxy. Built on golang.org/x/crypto/acme/autocert, adding renewal logging and OCSP stapling.
+//
+// Both HTTP-01 and TLS-ALPN-01 challenges are supported. TLS-ALPN-01 is answered automatically by TLSConfig,
+// HTTP-01 requires port 80 to be served by HTTPHandler, or ChallengeHandler mounted on an existing engine:
+//
+//	m := acme.New([]string{"example.com"}, acme.WithEmail("ops@example.com"), acme.WithCache(acme.DirCache("/var/cache/certs")))
+//	go http.ListenAndServe(":80", m.HTTPHandler(nil))
+//	m.Server(":443", e).ListenAndServeTLS("", "")
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/fips"
+	"github.com/redmapletech/ginx/zlog"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// LetsEncryptStaging is the Let's Encrypt staging directory, for testing without hitting production rate limits
+const LetsEncryptStaging = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// Cache stores certificates and account keys between restarts
+type Cache = autocert.Cache
+
+// DirCache returns a cache storing certificates in a local directory
+func DirCache(dir string) Cache {
+	return autocert.DirCache(dir)
+}
+
+type managerOpts struct {
+	email       string        // Contact email for the ACME account
+	cache       Cache         // Certificate cache, certificates are lost on restart if nil
+	directory   string        // ACME directory URL, defaults to Let's Encrypt production
+	renewBefore time.Duration // Renew certificates this long before expiry
+	ocsp        bool          // Staple OCSP responses
+}
+
+// Modifier function for customising certificate manager behaviour
+type ManagerOpts func(*managerOpts) *managerOpts
+
+// Manager obtains, renews and serves certificates for a fixed set of domains
+type Manager struct {
+	opts    *managerOpts
+	m       *autocert.Manager
+	getCert func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	client  *http.Client
+
+	mu      sync.Mutex
+	serials map[string]string      // Host -> serial of the last certificate served, for renewal logging
+	staples map[string]*ocspStaple // Leaf serial -> stapled response
+}
+
+// New creates a certificate manager for the domains. The CA's terms of service are accepted automatically.
+func New(domains []string, opts ...ManagerOpts) *Manager {
+	mo := &managerOpts{
+		ocsp: true,
+	}
+	for _, f := range opts {
+		mo = f(mo)
+	}
+
+	am := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		HostPolicy:  autocert.HostWhitelist(domains...),
+		Cache:       mo.cache,
+		Email:       mo.email,
+		RenewBefore: mo.renewBefore,
+	}
+	if mo.directory != "" {
+		am.Client = &acme.Client{DirectoryURL: mo.directory}
+	}
+
+	return &Manager{
+		opts:    mo,
+		m:       am,
+		getCert: am.GetCertificate,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		serials: map[string]string{},
+		staples: map[string]*ocspStaple{},
+	}
+}
+
+// GetCertificate returns the certificate for the handshake, obtaining or renewing it if necessary
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	ctx := hello.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cert, err := m.getCert(hello)
+	if err != nil {
+		zlog.GetLogger(ctx).Error().Err(err).Str("host", hello.ServerName).Msg("Failed to get certificate")
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		// Challenge certificates have no parsed leaf
+		return cert, nil
+	}
+
+	m.logIssued(ctx, hello.ServerName, cert)
+	if m.opts.ocsp {
+		cert = m.staple(ctx, cert)
+	}
+	return cert, nil
+}
+
+// Log when the certificate served for a host changes, i.e. on first issue or load, and after each renewal
+func (m *Manager) logIssued(ctx context.Context, host string, cert *tls.Certificate) {
+	serial := cert.Leaf.SerialNumber.String()
+
+	m.mu.Lock()
+	previous, ok := m.serials[host]
+	m.serials[host] = serial
+	m.mu.Unlock()
+
+	if previous == serial {
+		return
+	}
+	event := zlog.GetLogger(ctx).Info().
+		Str("host", host).
+		Str("serial", serial).
+		Time("expires", cert.Leaf.NotAfter)
+	if ok {
+		event.Str("previous", previous).Msg("Certificate renewed")
+	} else {
+		event.Msg("Certificate loaded")
+	}
+}
+
+// TLSConfig returns a TLS configuration serving managed certificates and answering TLS-ALPN-01 challenges,
+// restricted in FIPS mode
+func (m *Manager) TLSConfig() *tls.Config {
+	return fips.TLSConfig("acme", &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+		MinVersion:     tls.VersionTLS12,
+	})
+}
+
+// HTTPHandler returns a handler answering HTTP-01 challenges, passing other requests to fallback.
+// If fallback is nil, other requests are redirected to https.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.m.HTTPHandler(fallback)
+}
+
+// ChallengeHandler returns a handler answering HTTP-01 challenges on an existing engine, to be mounted at
+// /.well-known/acme-challenge/*token
+func (m *Manager) ChallengeHandler() gin.HandlerFunc {
+	h := m.m.HTTPHandler(http.NotFoundHandler())
+	return func(ctx *gin.Context) {
+		h.ServeHTTP(ctx.Writer, ctx.Request)
+		ctx.Abort()
+	}
+}
+
+// Server returns an HTTP server using the managed certificates. Start it with ListenAndServeTLS("", "").
+func (m *Manager) Server(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		TLSConfig:         m.TLSConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// WithEmail sets the contact email for the ACME account, used by the CA for expiry notices
+func WithEmail(email string) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.email = email
+		return mo
+	}
+}
+
+// WithCache sets the certificate cache. Without a cache certificates are requested again on every restart,
+// which will quickly hit CA rate limits.
+func WithCache(cache Cache) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.cache = cache
+		return mo
+	}
+}
+
+// WithDirectory sets the ACME directory URL of the CA, e.g. LetsEncryptStaging
+func WithDirectory(url string) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.directory = url
+		return mo
+	}
+}
+
+// WithRenewBefore sets how long before expiry certificates are renewed, defaults to 30 days
+func WithRenewBefore(d time.Duration) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.renewBefore = d
+		return mo
+	}
+}
+
+// WithOCSPStapling enables or disables OCSP stapling, enabled by default
+func WithOCSPStapling(enabled bool) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.ocsp = enabled
+		return mo
+	}
+}
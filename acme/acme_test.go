@@ -0,0 +1,205 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert, key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, ocspURL string) *tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{ocspURL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	assert.NoError(t, err)
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
+
+func (ca *testCA) responder(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		assert.NoError(t, err)
+		res, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, ca.key)
+		assert.NoError(t, err)
+		w.Write(res)
+	}))
+}
+
+func TestStapling(t *testing.T) {
+	ca := newCA(t)
+	var calls int32
+	srv := ca.responder(t, &calls)
+	defer srv.Close()
+
+	cert := ca.issue(t, 10, srv.URL)
+	m := New([]string{"example.com"})
+	m.getCert = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	res, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, res.OCSPStaple)
+	assert.Empty(t, cert.OCSPStaple)
+
+	parsed, err := ocsp.ParseResponse(res.OCSPStaple, ca.cert)
+	assert.NoError(t, err)
+	assert.Equal(t, ocsp.Good, parsed.Status)
+
+	// Cached until halfway through validity
+	m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestStaplingFailure(t *testing.T) {
+	ca := newCA(t)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cert := ca.issue(t, 10, srv.URL)
+	m := New([]string{"example.com"})
+	m.getCert = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	res, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	assert.NoError(t, err)
+	assert.Same(t, cert, res)
+}
+
+func TestStaplingConcurrent(t *testing.T) {
+	ca := newCA(t)
+	var calls int32
+	inner := ca.responder(t, &calls)
+	defer inner.Close()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	cert := ca.issue(t, 10, srv.URL)
+	m := New([]string{"example.com"})
+	m.getCert = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	done := make(chan *tls.Certificate)
+	go func() {
+		res, _ := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		done <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Served unstapled, without waiting for the fetch in progress
+	res, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	assert.NoError(t, err)
+	assert.Same(t, cert, res)
+
+	close(release)
+	assert.NotEmpty(t, (<-done).OCSPStaple)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRenewalLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	ca := newCA(t)
+	cert := ca.issue(t, 10, "")
+	m := New([]string{"example.com"}, WithOCSPStapling(false))
+	m.getCert = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+	hello := &tls.ClientHelloInfo{ServerName: "example.com"}
+
+	m.GetCertificate(hello)
+	m.GetCertificate(hello)
+	cert = ca.issue(t, 11, "")
+	m.GetCertificate(hello)
+
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+	assert.Contains(t, buf.String(), `"message":"Certificate loaded"`)
+	assert.Contains(t, buf.String(), `"serial":"11","expires"`)
+	assert.Contains(t, buf.String(), `"previous":"10","message":"Certificate renewed"`)
+}
+
+func TestTLSConfig(t *testing.T) {
+	cfg := New([]string{"example.com"}).TLSConfig()
+
+	assert.Contains(t, cfg.NextProtos, "acme-tls/1")
+	assert.NotNil(t, cfg.GetCertificate)
+}
+
+func TestChallengeHandler(t *testing.T) {
+	m := New([]string{"example.com"})
+	e := gin.New()
+	e.GET("/.well-known/acme-challenge/*token", m.ChallengeHandler())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/.well-known/acme-challenge/unknown", nil)
+	e.ServeHTTP(w, req)
+
+	// No pending challenge with this token
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,110 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/redmapletech/ginx/zlog"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Retry interval after a failed OCSP fetch
+var ocspRetry = 10 * time.Minute
+
+type ocspStaple struct {
+	raw      []byte
+	refresh  time.Time // Halfway through the response validity, or the retry time after a failure
+	fetching bool      // A handshake is fetching a new response
+}
+
+// Return a copy of the certificate with an OCSP response stapled, fetching or refreshing it if necessary.
+// One handshake fetches without holding the lock, while concurrent handshakes use the current response, if any.
+// Failures are logged and the certificate is served unstapled.
+func (m *Manager) staple(ctx context.Context, cert *tls.Certificate) *tls.Certificate {
+	if len(cert.Leaf.OCSPServer) == 0 || len(cert.Certificate) < 2 {
+		return cert
+	}
+	serial := cert.Leaf.SerialNumber.String()
+	now := time.Now()
+
+	m.mu.Lock()
+	s := m.staples[serial]
+	if s == nil {
+		s = &ocspStaple{}
+		m.staples[serial] = s
+	}
+	fetch := !s.fetching && !now.Before(s.refresh)
+	if fetch {
+		s.fetching = true
+	}
+	raw := s.raw
+	m.mu.Unlock()
+
+	if fetch {
+		next := &ocspStaple{raw: raw, refresh: now.Add(ocspRetry)}
+		if r, update, err := m.fetchOCSP(ctx, cert); err != nil {
+			zlog.GetLogger(ctx).Warn().Err(err).Str("serial", serial).Msg("Failed to fetch OCSP response")
+		} else {
+			next = &ocspStaple{raw: r, refresh: now.Add(update.Sub(now) / 2)}
+		}
+		m.mu.Lock()
+		m.staples[serial] = next
+		m.mu.Unlock()
+		raw = next.raw
+	}
+
+	if raw == nil {
+		return cert
+	}
+	stapled := *cert
+	stapled.OCSPStaple = raw
+	return &stapled
+}
+
+func (m *Manager) fetchOCSP(ctx context.Context, cert *tls.Certificate) ([]byte, time.Time, error) {
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	body, err := ocsp.CreateRequest(cert.Leaf, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.Leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("acme: OCSP responder returned %d", res.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(raw, cert.Leaf, issuer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if resp.Status != ocsp.Good {
+		return nil, time.Time{}, fmt.Errorf("acme: OCSP status %d for certificate", resp.Status)
+	}
+	next := resp.NextUpdate
+	if next.IsZero() {
+		next = time.Now().Add(24 * time.Hour)
+	}
+	return raw, next, nil
+}
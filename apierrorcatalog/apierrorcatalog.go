@@ -0,0 +1,152 @@
+// API error catalogue
+//
+// Lists the error codes an API can respond with, their status, description and an example response body, for
+// client SDK and documentation generation. Examples are rendered by the errors package itself, so they reflect the
+// real response format.
+//
+//	c := apierrorcatalog.New()
+//	c.FromRegistry()
+//	c.Add(ErrUserNotFound, "The user does not exist or is not visible to the caller")
+//	c.Describe("not_found", "The requested resource does not exist")
+//	e.GET("/errors", c.Handler())
+//
+// The catalogue can also be written as Markdown, e.g. from a go:generate command, with WriteMarkdown.
+package apierrorcatalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+)
+
+// Entry is a documented error code
+type Entry struct {
+	Code        string          `json:"code"`
+	Status      int             `json:"status"`
+	Description string          `json:"description,omitempty"`
+	Example     json.RawMessage `json:"example"` // Example response body
+	ContentType string          `json:"content_type"`
+}
+
+// Catalog is a set of documented error codes
+type Catalog struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// New creates an empty catalogue
+func New() *Catalog {
+	return &Catalog{entries: map[string]*Entry{}}
+}
+
+// Codes responded with by errors.Handler for errors without a mapping
+var handlerCodes = []struct {
+	status      int
+	code        string
+	description string
+}{
+	{http.StatusBadRequest, "validation_error", "The request failed validation, with the failed fields in errors"},
+	{http.StatusBadRequest, "binding_error", "The request body or parameters could not be decoded"},
+	{http.StatusInternalServerError, "internal_error", "An unexpected error occurred"},
+}
+
+// FromRegistry adds the mappings registered with errors.Register, and the codes used by errors.Handler
+func (c *Catalog) FromRegistry() {
+	for _, h := range handlerCodes {
+		c.Add(ginxerrors.New(h.code).Status(h.status), h.description)
+	}
+	for _, m := range ginxerrors.Mappings() {
+		c.Add(ginxerrors.New(m.Code).Status(m.Status), "")
+	}
+}
+
+// Add documents the response to an error, e.g. an errors.E or an error registered with errors.Register. Other
+// errors are documented as the internal_error response they produce. An existing description of the code is kept
+// if description is empty.
+func (c *Catalog) Add(err error, description string) {
+	status, example, contentType := render(err)
+	var body struct{ Code string }
+	json.Unmarshal(example, &body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[body.Code]; ok && description == "" {
+		description = old.Description
+	}
+	c.entries[body.Code] = &Entry{
+		Code:        body.Code,
+		Status:      status,
+		Description: description,
+		Example:     example,
+		ContentType: contentType,
+	}
+}
+
+// Describe sets the description of a documented code, returning false if the code isn't documented
+func (c *Catalog) Describe(code, description string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[code]; ok {
+		e.Description = description
+		return true
+	}
+	return false
+}
+
+// Entries returns the documented codes sorted by status then code
+func (c *Catalog) Entries() []Entry {
+	c.mu.Lock()
+	res := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		res = append(res, *e)
+	}
+	c.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Status != res[j].Status {
+			return res[i].Status < res[j].Status
+		}
+		return res[i].Code < res[j].Code
+	})
+	return res
+}
+
+// Render the response to an error, as AbortWithError would respond
+func render(err error) (int, json.RawMessage, string) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/example", nil)
+	ginxerrors.WithConfig(ginxerrors.Config{Detail: false})(ctx)
+	ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "internal_error")
+	return w.Code, json.RawMessage(w.Body.Bytes()), w.Header().Get("Content-Type")
+}
+
+// Handler responds with the catalogue
+func (c *Catalog) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"errors": c.Entries()})
+	}
+}
+
+// WriteMarkdown writes the catalogue as a Markdown table
+func (c *Catalog) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Status | Code | Description | Example |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, e := range c.Entries() {
+		fmt.Fprintf(&b, "| %d | `%s` | %s | `%s` |\n", e.Status, e.Code, escape(e.Description), escape(string(e.Example)))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
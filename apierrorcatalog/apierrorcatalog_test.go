@@ -0,0 +1,47 @@
+package apierrorcatalog
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	c := New()
+	c.FromRegistry()
+	c.Add(ginxerrors.New("user_not_found").Status(404).Msg("User not found"), "The user does not exist")
+	c.Add(sql.ErrNoRows, "")
+	c.Add(errors.New("unmapped"), "")
+	assert.True(t, c.Describe("not_found", "The resource does not exist"))
+	assert.False(t, c.Describe("missing", ""))
+
+	entries := map[string]Entry{}
+	for _, e := range c.Entries() {
+		entries[e.Code] = e
+	}
+	assert.Equal(t, 404, entries["user_not_found"].Status)
+	assert.JSONEq(t, `{"code":"user_not_found","message":"User not found"}`, string(entries["user_not_found"].Example))
+	assert.Equal(t, "The resource does not exist", entries["not_found"].Description)
+	assert.Equal(t, 504, entries["timeout"].Status)
+	assert.Equal(t, "An unexpected error occurred", entries["internal_error"].Description)
+	assert.Equal(t, 400, c.Entries()[0].Status)
+
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/errors", c.Handler())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/errors", nil)
+	e.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"code":"user_not_found","status":404,"description":"The user does not exist"`)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteMarkdown(buf))
+	assert.Contains(t, buf.String(), "| 404 | `user_not_found` | The user does not exist | `{\"code\":\"user_not_found\",\"message\":\"User not found\"}` |")
+}
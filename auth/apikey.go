@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Key the principal of a valid API key is stored under by default
+const DefaultPrincipalKey = "auth.principal"
+
+// Context key recording the key APIKey stored the principal under, so GetPrincipal follows WithPrincipalKey
+const principalKeyKey = "auth.principalKey"
+
+// ErrKeyNotFound is returned by a KeyStore for an unknown or revoked key
+var ErrKeyNotFound = errors.New("api key not found")
+
+// Principal is the owner of an API key
+type Principal struct {
+	ID         string
+	Scopes     []string
+	Attributes map[string]string
+}
+
+// HasScope reports whether the principal has the scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore looks up the principal of an API key, returning ErrKeyNotFound if the key isn't valid
+type KeyStore interface {
+	Lookup(ctx context.Context, key string) (*Principal, error)
+}
+
+// GetPrincipal returns the principal stored by APIKey, under the key set by WithPrincipalKey or the
+// DefaultPrincipalKey, or nil if not authenticated
+func GetPrincipal(ctx *gin.Context) *Principal {
+	key := DefaultPrincipalKey
+	if k := ctx.GetString(principalKeyKey); k != "" {
+		key = k
+	}
+	v, _ := ctx.Get(key)
+	p, _ := v.(*Principal)
+	return p
+}
+
+// Keys are held as hashes so the stores don't keep them in memory, and lookups don't depend on the key contents
+type keyHash [sha256.Size]byte
+
+func hashKey(key string) keyHash {
+	return sha256.Sum256([]byte(key))
+}
+
+// MemoryStore is a KeyStore holding keys in memory
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[keyHash]*Principal
+}
+
+// NewMemoryStore creates a MemoryStore with the keys and their principals
+func NewMemoryStore(keys map[string]*Principal) *MemoryStore {
+	s := &MemoryStore{keys: map[keyHash]*Principal{}}
+	for k, p := range keys {
+		s.keys[hashKey(k)] = p
+	}
+	return s
+}
+
+// Add adds or replaces a key
+func (s *MemoryStore) Add(key string, p *Principal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[hashKey(key)] = p
+}
+
+// Remove revokes a key
+func (s *MemoryStore) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, hashKey(key))
+}
+
+// Lookup implements KeyStore
+func (s *MemoryStore) Lookup(ctx context.Context, key string) (*Principal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if p, ok := s.keys[hashKey(key)]; ok {
+		return p, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+type cached struct {
+	principal *Principal // Nil if not found
+	expires   time.Time
+}
+
+// Default maximum number of found, and separately not found, keys held by a CachingStore
+const DefaultCacheSize = 10000
+
+// CachingStore decorates a KeyStore, caching found and not found results for the TTL. Other errors aren't cached.
+// Found and not found keys are bounded separately, so a flood of invalid keys can't evict valid ones.
+type CachingStore struct {
+	store KeyStore
+	ttl   time.Duration
+	size  int
+	clock func() time.Time
+
+	mu       sync.Mutex
+	found    map[keyHash]cached
+	notFound map[keyHash]cached
+}
+
+type cacheOpts struct {
+	size int
+}
+
+// Modifier function for customising the caching store
+type CacheOpts func(*cacheOpts) *cacheOpts
+
+// WithCacheSize sets the maximum number of found, and separately not found, keys cached (default DefaultCacheSize).
+// When full, an arbitrary entry is evicted.
+func WithCacheSize(size int) CacheOpts {
+	return func(co *cacheOpts) *cacheOpts {
+		co.size = size
+		return co
+	}
+}
+
+// NewCachingStore creates a CachingStore in front of the store
+func NewCachingStore(store KeyStore, ttl time.Duration, opts ...CacheOpts) *CachingStore {
+	co := &cacheOpts{size: DefaultCacheSize}
+	for _, f := range opts {
+		co = f(co)
+	}
+	return &CachingStore{
+		store:    store,
+		ttl:      ttl,
+		size:     co.size,
+		clock:    time.Now,
+		found:    map[keyHash]cached{},
+		notFound: map[keyHash]cached{},
+	}
+}
+
+// Lookup implements KeyStore
+func (s *CachingStore) Lookup(ctx context.Context, key string) (*Principal, error) {
+	h := hashKey(key)
+	now := s.clock()
+	if e, ok := s.get(h, now); ok {
+		if e.principal == nil {
+			return nil, ErrKeyNotFound
+		}
+		return e.principal, nil
+	}
+
+	p, err := s.store.Lookup(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.found
+	if p == nil {
+		entries = s.notFound
+	}
+	if _, ok := entries[h]; !ok && len(entries) >= s.size {
+		// Map iteration order is random, so this evicts an arbitrary entry
+		for k := range entries {
+			delete(entries, k)
+			break
+		}
+	}
+	entries[h] = cached{principal: p, expires: now.Add(s.ttl)}
+	return p, err
+}
+
+// Return the unexpired cache entry for the key, dropping it if expired
+func (s *CachingStore) get(h keyHash, now time.Time) (cached, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entries := range []map[keyHash]cached{s.found, s.notFound} {
+		if e, ok := entries[h]; ok {
+			if now.Before(e.expires) {
+				return e, true
+			}
+			delete(entries, h)
+		}
+	}
+	return cached{}, false
+}
+
+// Invalidate removes a key from the cache, such as after revoking it
+func (s *CachingStore) Invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.found, hashKey(key))
+	delete(s.notFound, hashKey(key))
+}
+
+type apiKeyOpts struct {
+	header       string
+	query        string
+	cookie       string
+	principalKey string
+}
+
+// Modifier function for customising API key authentication
+type APIKeyOpts func(*apiKeyOpts) *apiKeyOpts
+
+// WithKeyHeader reads the key from the header (default X-API-Key), or not from a header if empty
+func WithKeyHeader(name string) APIKeyOpts {
+	return func(ao *apiKeyOpts) *apiKeyOpts {
+		ao.header = name
+		return ao
+	}
+}
+
+// WithKeyQuery also reads the key from the query parameter
+func WithKeyQuery(param string) APIKeyOpts {
+	return func(ao *apiKeyOpts) *apiKeyOpts {
+		ao.query = param
+		return ao
+	}
+}
+
+// WithKeyCookie also reads the key from the cookie
+func WithKeyCookie(name string) APIKeyOpts {
+	return func(ao *apiKeyOpts) *apiKeyOpts {
+		ao.cookie = name
+		return ao
+	}
+}
+
+// WithPrincipalKey sets the context key the principal is stored under (default DefaultPrincipalKey)
+func WithPrincipalKey(key string) APIKeyOpts {
+	return func(ao *apiKeyOpts) *apiKeyOpts {
+		ao.principalKey = key
+		return ao
+	}
+}
+
+// Return the key and where it was found, checking the header, query and cookie in turn
+func (ao *apiKeyOpts) key(ctx *gin.Context) (string, string) {
+	if ao.header != "" {
+		if k := ctx.GetHeader(ao.header); k != "" {
+			return k, "header"
+		}
+	}
+	if ao.query != "" {
+		if k := ctx.Query(ao.query); k != "" {
+			return k, "query"
+		}
+	}
+	if ao.cookie != "" {
+		if k, err := ctx.Cookie(ao.cookie); err == nil && k != "" {
+			return k, "cookie"
+		}
+	}
+	return "", ""
+}
+
+// APIKey middleware looks up the API key of each request in the store, storing its principal on the context and
+// adding its ID to the request logger as principal. Requests without a key are aborted with 401 "missing_api_key",
+// with an unknown key 401 "invalid_api_key", and if the store fails 503 "auth_unavailable".
+//
+// Failures are logged at warn level through the request logger with the reason and key source, never the key.
+func APIKey(store KeyStore, opts ...APIKeyOpts) gin.HandlerFunc {
+	ao := &apiKeyOpts{header: "X-API-Key", principalKey: DefaultPrincipalKey}
+	for _, f := range opts {
+		ao = f(ao)
+	}
+
+	return func(ctx *gin.Context) {
+		key, source := ao.key(ctx)
+		if key == "" {
+			zlog.GetLogger(ctx).Warn().Str("reason", "missing").Msg("API key rejected")
+			ginxerrors.AbortWith(ctx, http.StatusUnauthorized, "missing_api_key")
+			return
+		}
+
+		p, err := store.Lookup(ctx.Request.Context(), key)
+		switch {
+		case errors.Is(err, ErrKeyNotFound) || (err == nil && p == nil):
+			zlog.GetLogger(ctx).Warn().Str("reason", "invalid").Str("source", source).Msg("API key rejected")
+			ginxerrors.AbortWith(ctx, http.StatusUnauthorized, "invalid_api_key")
+			return
+		case err != nil:
+			zlog.GetLogger(ctx).Error().Err(err).Str("reason", "store").Str("source", source).Msg("API key lookup failed")
+			ginxerrors.AbortWithError(ctx, err, http.StatusServiceUnavailable, "auth_unavailable")
+			return
+		}
+
+		ctx.Set(ao.principalKey, p)
+		if ao.principalKey != DefaultPrincipalKey {
+			ctx.Set(principalKeyKey, ao.principalKey)
+		}
+		zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("principal", p.ID)
+		})
+	}
+}
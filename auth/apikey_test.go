@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryStore(map[string]*Principal{"k1": {ID: "svc-a", Scopes: []string{"read"}}})
+	r := gin.New()
+	r.GET("/", APIKey(store, WithKeyQuery("api_key"), WithKeyCookie("key")), func(ctx *gin.Context) {
+		p := GetPrincipal(ctx)
+		ctx.String(http.StatusOK, "%s %v", p.ID, p.HasScope("read"))
+	})
+
+	for name, c := range map[string]struct {
+		setup func(*http.Request)
+		code  int
+		body  string
+	}{
+		"header":  {func(r *http.Request) { r.Header.Set("X-API-Key", "k1") }, 200, "svc-a true"},
+		"query":   {func(r *http.Request) { r.URL.RawQuery = "api_key=k1" }, 200, "svc-a true"},
+		"cookie":  {func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "key", Value: "k1"}) }, 200, "svc-a true"},
+		"missing": {func(r *http.Request) {}, 401, "missing_api_key"},
+		"invalid": {func(r *http.Request) { r.Header.Set("X-API-Key", "k2") }, 401, "invalid_api_key"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		c.setup(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, c.code, w.Code, name)
+		assert.Contains(t, w.Body.String(), c.body, name)
+	}
+
+	store.Remove("k1")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "k1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// GetPrincipal follows a custom key
+	store.Add("k1", &Principal{ID: "svc-a"})
+	r = gin.New()
+	r.GET("/", APIKey(store, WithPrincipalKey("caller")), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, GetPrincipal(ctx).ID)
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "svc-a", w.Body.String())
+}
+
+type countingStore struct {
+	calls int
+	err   error
+	store KeyStore
+}
+
+func (s *countingStore) Lookup(ctx context.Context, key string) (*Principal, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.store.Lookup(ctx, key)
+}
+
+func TestCachingStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	inner := &countingStore{store: NewMemoryStore(map[string]*Principal{"k1": {ID: "a"}})}
+	s := NewCachingStore(inner, time.Minute)
+	s.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		p, err := s.Lookup(ctx, "k1")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", p.ID)
+		_, err = s.Lookup(ctx, "nope")
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	assert.Equal(t, 2, inner.calls)
+
+	s.Invalidate("k1")
+	_, _ = s.Lookup(ctx, "k1")
+	assert.Equal(t, 3, inner.calls)
+
+	now = now.Add(2 * time.Minute)
+	inner.err = errors.New("db down")
+	_, err := s.Lookup(ctx, "k1")
+	assert.EqualError(t, err, "db down")
+	_, err = s.Lookup(ctx, "k1")
+	assert.EqualError(t, err, "db down") // Not cached
+	assert.Equal(t, 5, inner.calls)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", APIKey(s), func(ctx *gin.Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "k1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "auth_unavailable")
+}
+
+func TestCachingStoreBounded(t *testing.T) {
+	inner := &countingStore{store: NewMemoryStore(map[string]*Principal{"k1": {ID: "a"}})}
+	s := NewCachingStore(inner, time.Minute, WithCacheSize(2))
+	ctx := context.Background()
+
+	_, _ = s.Lookup(ctx, "k1")
+	for i := 0; i < 100; i++ {
+		_, err := s.Lookup(ctx, fmt.Sprintf("bad%d", i))
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	assert.Len(t, s.notFound, 2)
+
+	// Invalid keys don't evict valid ones
+	p, err := s.Lookup(ctx, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", p.ID)
+	assert.Equal(t, 101, inner.calls)
+
+	// Expired entries are dropped when next looked up
+	s.clock = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, _ = s.Lookup(ctx, "k1")
+	assert.Equal(t, 102, inner.calls)
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Minimum time between JWKS fetches triggered by unknown key IDs
+const jwksMinRefresh = 10 * time.Second
+
+// Cached keys of a JWKS URL, refreshed after the TTL or when a token has an unknown key ID.
+// One fetch runs at a time, without holding the lock, and known keys are served from the cache meanwhile.
+type jwks struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time     // Last successful fetch
+	tried    time.Time     // Last fetch attempt
+	fetching chan struct{} // Closed when the fetch in progress completes, nil if none
+}
+
+func (j *jwks) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	fresh := time.Since(j.fetched) <= j.ttl
+	wait := j.fetching
+	if wait == nil && !(ok && fresh) && time.Since(j.tried) >= jwksMinRefresh {
+		wait = make(chan struct{})
+		j.fetching = wait
+		j.tried = time.Now()
+		go j.refresh(wait)
+	}
+	j.mu.Unlock()
+
+	// Serve cached keys, even if stale, rather than waiting for the refresh
+	if ok {
+		return key, nil
+	}
+	if wait == nil {
+		return nil, fmt.Errorf("auth: unknown key ID %q", kid)
+	}
+
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	j.mu.Lock()
+	key, ok = j.keys[kid]
+	j.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("auth: unknown key ID %q", kid)
+	}
+	return key, nil
+}
+
+// Fetch the keys and replace the cache, then close done. Not bound to a request context, as requests share the
+// result; the client timeout applies.
+func (j *jwks) refresh(done chan struct{}) {
+	keys, err := fetchJWKS(context.Background(), j.client, j.url)
+	if err != nil {
+		// Keep using the cached keys until the next successful fetch
+		log.Error().Err(err).Str("url", j.url).Msg("Failed to fetch JWKS")
+	}
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys, j.fetched = keys, time.Now()
+	}
+	j.fetching = nil
+	j.mu.Unlock()
+	close(done)
+}
+
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: JWKS responded %d", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth: decoding JWKS: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Warn().Err(err).Str("kid", k.Kid).Msg("Skipping JWKS key")
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+// JSON web key, RSA and EC public keys only
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := decodeInt(k.N)
+		e, err2 := decodeInt(k.E)
+		if err1 != nil || err2 != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("auth: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("auth: unsupported curve %s", k.Crv)
+		}
+		x, err1 := decodeInt(k.X)
+		y, err2 := decodeInt(k.Y)
+		if err1 != nil || err2 != nil || !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("auth: invalid EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("auth: unsupported key type %s", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("auth: invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,395 @@
+// Authentication middleware
+//
+// JWT verifies Bearer tokens signed with HMAC, RSA or ECDSA keys, given directly or fetched from a JWKS URL, checking
+// expiry, issuer and audience, and stores the claims on the context.
+//
+//	api := e.Group("/api", auth.JWT(
+//		auth.WithJWKS("https://login.example.com/.well-known/jwks.json", time.Hour),
+//		auth.WithIssuer("https://login.example.com/"),
+//		auth.WithAudience("api"),
+//	))
+//	...
+//	claims := auth.GetClaims(ctx)
+//
+// APIKey looks up a key read from a header, query parameter or cookie in a KeyStore, and stores its principal on the
+// context. MemoryStore holds keys in memory, and CachingStore caches lookups from a slower store.
+//
+//	api.Use(auth.APIKey(auth.NewCachingStore(dbKeys, time.Minute), auth.WithKeyQuery("api_key")))
+//	...
+//	principal := auth.GetPrincipal(ctx)
+//
+// Rejected requests respond 401 through the errors package, so the response follows its configuration, and the
+// reason is logged through the request logger.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register hashes
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/fips"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Key the claims of a verified token are stored under by default
+const DefaultClaimsKey = "auth.claims"
+
+// Context key recording the key JWT stored the claims under, so GetClaims follows WithClaimsKey
+const claimsKeyKey = "auth.claimsKey"
+
+// Claims are the claims of a verified token
+type Claims map[string]interface{}
+
+// Str returns a string claim, or an empty string if missing or not a string
+func (c Claims) Str(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject returns the sub claim
+func (c Claims) Subject() string {
+	return c.Str("sub")
+}
+
+// Audience returns the aud claim, which may be a string or an array
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		res := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// Return a numeric date claim, and whether it is present
+func (c Claims) time(name string) (time.Time, bool) {
+	v, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// GetClaims returns the claims stored by JWT, under the key set by WithClaimsKey or the DefaultClaimsKey, or nil if
+// not authenticated
+func GetClaims(ctx *gin.Context) Claims {
+	key := DefaultClaimsKey
+	if k := ctx.GetString(claimsKeyKey); k != "" {
+		key = k
+	}
+	v, _ := ctx.Get(key)
+	c, _ := v.(Claims)
+	return c
+}
+
+type jwtOpts struct {
+	secret     []byte
+	keys       map[string]crypto.PublicKey
+	jwks       *jwks
+	audience   []string
+	issuer     string
+	leeway     time.Duration
+	claimsKey  string
+	algorithms map[string]bool
+	clock      func() time.Time
+}
+
+// Modifier function for customising JWT verification
+type JWTOpts func(*jwtOpts) *jwtOpts
+
+// WithHMACSecret verifies HS256, HS384 and HS512 tokens with the shared secret
+func WithHMACSecret(secret []byte) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.secret = secret
+		return jo
+	}
+}
+
+// WithPublicKey verifies RS and ES tokens with the *rsa.PublicKey or *ecdsa.PublicKey. Tokens without a kid header
+// use the key added with an empty key ID.
+func WithPublicKey(kid string, key crypto.PublicKey) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.keys[kid] = key
+		return jo
+	}
+}
+
+// WithJWKS verifies RS and ES tokens with the keys published at the JWKS URL, cached for the TTL and refetched
+// early when a token has an unknown key ID
+func WithJWKS(url string, ttl time.Duration) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.jwks = &jwks{url: url, ttl: ttl, client: &http.Client{Timeout: 10 * time.Second}}
+		return jo
+	}
+}
+
+// WithAudience requires the aud claim to contain one of the audiences
+func WithAudience(aud ...string) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.audience = aud
+		return jo
+	}
+}
+
+// WithIssuer requires the iss claim to equal the issuer
+func WithIssuer(iss string) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.issuer = iss
+		return jo
+	}
+}
+
+// WithLeeway allows for clock skew when checking the exp and nbf claims (default 1 minute)
+func WithLeeway(d time.Duration) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.leeway = d
+		return jo
+	}
+}
+
+// WithClaimsKey sets the context key the claims are stored under (default DefaultClaimsKey)
+func WithClaimsKey(key string) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.claimsKey = key
+		return jo
+	}
+}
+
+// WithAlgorithms restricts the accepted signing algorithms, e.g. RS256
+func WithAlgorithms(algs ...string) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.algorithms = map[string]bool{}
+		for _, a := range algs {
+			jo.algorithms[a] = true
+		}
+		return jo
+	}
+}
+
+// WithJWTClock sets the time source for checking expiry, for testing
+func WithJWTClock(clock func() time.Time) JWTOpts {
+	return func(jo *jwtOpts) *jwtOpts {
+		jo.clock = clock
+		return jo
+	}
+}
+
+// JWT middleware verifies the Bearer token of each request, storing its claims on the context and adding the
+// subject to the request logger as sub. Requests without a valid token are aborted with 401 "invalid_token" through
+// errors.AbortWithError, and a WWW-Authenticate challenge.
+//
+// The signing algorithm must match the type of the verifying key, so a public key can't be used as an HMAC secret,
+// and unsigned tokens are always rejected. In FIPS mode, panics if configured with an unapproved algorithm or a weak
+// key, and rejects tokens signed with weak keys from the JWKS.
+func JWT(opts ...JWTOpts) gin.HandlerFunc {
+	jo := &jwtOpts{keys: map[string]crypto.PublicKey{}, leeway: time.Minute, claimsKey: DefaultClaimsKey, clock: time.Now}
+	for _, f := range opts {
+		jo = f(jo)
+	}
+	if jo.secret != nil {
+		fips.RequireKey("auth.JWT", jo.secret)
+	}
+	for _, k := range jo.keys {
+		fips.RequireKey("auth.JWT", k)
+	}
+	for a := range jo.algorithms {
+		fips.Require("auth.JWT", a)
+	}
+
+	return func(ctx *gin.Context) {
+		token := bearer(ctx.GetHeader("Authorization"))
+		if token == "" {
+			ctx.Header("WWW-Authenticate", `Bearer`)
+			ginxerrors.AbortWith(ctx, http.StatusUnauthorized, "missing_token")
+			return
+		}
+		claims, err := jo.verify(ctx.Request.Context(), token)
+		if err != nil {
+			zlog.GetLogger(ctx).Debug().Err(err).Msg("Token rejected")
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ginxerrors.AbortWithError(ctx, err, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+
+		ctx.Set(jo.claimsKey, claims)
+		if jo.claimsKey != DefaultClaimsKey {
+			ctx.Set(claimsKeyKey, jo.claimsKey)
+		}
+		if sub := claims.Subject(); sub != "" {
+			zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+				return c.Str("sub", sub)
+			})
+		}
+	}
+}
+
+func bearer(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// Verify the signature and standard claims of a compact JWS token
+func (jo *jwtOpts) verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("auth: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if jo.algorithms != nil && !jo.algorithms[header.Alg] {
+		return nil, fmt.Errorf("auth: algorithm %s not allowed", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("auth: malformed signature")
+	}
+	if err := jo.verifySignature(ctx, header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, jo.validate(claims)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("auth: malformed token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("auth: malformed token")
+	}
+	return nil
+}
+
+// Signing algorithms, by JWS alg header
+var algorithms = map[string]struct {
+	hash crypto.Hash
+	bits int // Curve size of ES algorithms
+}{
+	"HS256": {crypto.SHA256, 0}, "HS384": {crypto.SHA384, 0}, "HS512": {crypto.SHA512, 0},
+	"RS256": {crypto.SHA256, 0}, "RS384": {crypto.SHA384, 0}, "RS512": {crypto.SHA512, 0},
+	"ES256": {crypto.SHA256, 256}, "ES384": {crypto.SHA384, 384}, "ES512": {crypto.SHA512, 521},
+}
+
+func (jo *jwtOpts) verifySignature(ctx context.Context, alg, kid string, signed, sig []byte) error {
+	a, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("auth: unsupported algorithm %q", alg)
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		if jo.secret == nil {
+			return fmt.Errorf("auth: algorithm %s not configured", alg)
+		}
+		mac := hmac.New(a.hash.New, jo.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("auth: invalid signature")
+		}
+		return nil
+	}
+
+	key, err := jo.key(ctx, kid)
+	if err != nil {
+		return err
+	}
+	h := a.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("auth: algorithm %s doesn't match RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, a.hash, digest, sig); err != nil {
+			return fmt.Errorf("auth: invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().BitSize != a.bits {
+			return fmt.Errorf("auth: algorithm %s doesn't match EC key", alg)
+		}
+		size := (a.bits + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("auth: invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("auth: invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("auth: unsupported key type %T", key)
+}
+
+func (jo *jwtOpts) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if k, ok := jo.keys[kid]; ok {
+		return k, nil
+	}
+	if jo.jwks != nil {
+		k, err := jo.jwks.key(ctx, kid)
+		if err == nil {
+			err = fips.CheckKey("auth.JWT", k)
+		}
+		return k, err
+	}
+	return nil, fmt.Errorf("auth: unknown key ID %q", kid)
+}
+
+// Check the time, issuer and audience claims
+func (jo *jwtOpts) validate(c Claims) error {
+	now := jo.clock()
+	if exp, ok := c.time("exp"); ok && !now.Before(exp.Add(jo.leeway)) {
+		return fmt.Errorf("auth: token expired")
+	}
+	if nbf, ok := c.time("nbf"); ok && now.Add(jo.leeway).Before(nbf) {
+		return fmt.Errorf("auth: token not yet valid")
+	}
+	if jo.issuer != "" && c.Str("iss") != jo.issuer {
+		return fmt.Errorf("auth: unexpected issuer")
+	}
+	if len(jo.audience) > 0 {
+		for _, a := range c.Audience() {
+			for _, want := range jo.audience {
+				if a == want {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("auth: unexpected audience")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func segment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		assert.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func request(h gin.HandlerFunc, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/", h, func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, GetClaims(ctx).Subject())
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestHMAC(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	h := JWT(WithHMACSecret(secret), WithIssuer("issuer"), WithAudience("api"), WithJWTClock(func() time.Time { return now }))
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": []string{"other", "api"}, "exp": now.Unix() + 60}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	w := request(h, sign(t, "HS256", "", secret, claims(nil)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	w = request(h, "")
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"code":"missing_token"}`, w.Body.String())
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	for name, token := range map[string]string{
+		"expired":      sign(t, "HS256", "", secret, claims(map[string]interface{}{"exp": now.Unix() - 120})),
+		"not before":   sign(t, "HS256", "", secret, claims(map[string]interface{}{"nbf": now.Unix() + 120})),
+		"issuer":       sign(t, "HS256", "", secret, claims(map[string]interface{}{"iss": "evil"})),
+		"audience":     sign(t, "HS256", "", secret, claims(map[string]interface{}{"aud": "web"})),
+		"wrong secret": sign(t, "HS256", "", []byte("guess"), claims(nil)),
+		"none":         segment(map[string]string{"alg": "none"}) + "." + segment(claims(nil)) + ".",
+		"malformed":    "abc",
+	} {
+		w := request(h, token)
+		assert.Equal(t, 401, w.Code, name)
+		assert.JSONEq(t, `{"code":"invalid_token"}`, w.Body.String(), name)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"), name)
+	}
+
+	// Leeway allows a recently expired token
+	assert.Equal(t, 200, request(h, sign(t, "HS256", "", secret, claims(map[string]interface{}{"exp": now.Unix() - 30}))).Code)
+}
+
+func TestPublicKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	h := JWT(WithPublicKey("", &rsaKey.PublicKey), WithPublicKey("ec", &ecKey.PublicKey))
+	assert.Equal(t, 200, request(h, sign(t, "RS256", "", rsaKey, map[string]interface{}{"sub": "a"})).Code)
+	assert.Equal(t, 200, request(h, sign(t, "ES256", "ec", ecKey, map[string]interface{}{"sub": "a"})).Code)
+
+	// Algorithm confusion, HMAC with the public key as secret
+	pub, _ := json.Marshal(rsaKey.PublicKey)
+	assert.Equal(t, 401, request(h, sign(t, "HS256", "", pub, map[string]interface{}{"sub": "a"})).Code)
+	// ES token verified with the RSA key
+	assert.Equal(t, 401, request(h, sign(t, "ES256", "", ecKey, map[string]interface{}{"sub": "a"})).Code)
+
+	restricted := JWT(WithPublicKey("", &rsaKey.PublicKey), WithAlgorithms("RS512"))
+	assert.Equal(t, 401, request(restricted, sign(t, "RS256", "", rsaKey, map[string]interface{}{"sub": "a"})).Code)
+}
+
+func TestCustomClaimsKey(t *testing.T) {
+	secret := []byte("secret")
+	h := JWT(WithHMACSecret(secret), WithClaimsKey("user"))
+
+	w := request(h, sign(t, "HS256", "", secret, map[string]interface{}{"sub": "alice"}))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+}
+
+func TestJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "EC", "kid": "k1", "use": "sig", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+			{"kty": "oct", "kid": "k2"},
+		}})
+	}))
+	defer srv.Close()
+
+	h := JWT(WithJWKS(srv.URL, time.Hour), WithClaimsKey(DefaultClaimsKey))
+	assert.Equal(t, 200, request(h, sign(t, "ES256", "k1", ecKey, map[string]interface{}{"sub": "a"})).Code)
+	assert.Equal(t, 200, request(h, sign(t, "ES256", "k1", ecKey, map[string]interface{}{"sub": "a"})).Code)
+	assert.Equal(t, 401, request(h, sign(t, "ES256", "k9", ecKey, map[string]interface{}{"sub": "a"})).Code)
+	assert.Equal(t, 401, request(h, sign(t, "ES256", "k9", ecKey, map[string]interface{}{"sub": "a"})).Code)
+	// Unknown key IDs only refetch after the minimum interval
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestJWKSConcurrentRefresh(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+	var fetches int32
+	var release atomic.Value
+	release.Store(make(chan struct{}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release.Load().(chan struct{})
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "EC", "kid": "k1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		}})
+	}))
+	defer srv.Close()
+
+	j := &jwks{url: srv.URL, ttl: time.Hour, client: srv.Client()}
+
+	// Concurrent lookups share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := j.key(context.Background(), "k1")
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release.Load().(chan struct{}))
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// Stale keys are served while the refresh is blocked
+	blocked := make(chan struct{})
+	release.Store(blocked)
+	j.mu.Lock()
+	j.fetched = time.Now().Add(-2 * time.Hour)
+	j.tried = time.Time{}
+	j.mu.Unlock()
+	_, err = j.key(context.Background(), "k1")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, 10*time.Millisecond)
+	_, err = j.key(context.Background(), "k1")
+	assert.NoError(t, err)
+	close(blocked)
+	assert.Eventually(t, func() bool {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return j.fetching == nil && time.Since(j.fetched) < time.Minute
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,229 @@
+// Antivirus scanning
+//
+// Scans uploaded files before they reach the handler, rejecting infected uploads with 422. Scanners are pluggable,
+// with a clamd adapter included; ICAP or cloud scanning services can be added by implementing Scanner.
+//
+//	scanner := &avscan.Clamd{Network: "unix", Address: "/run/clamav/clamd.ctl"}
+//	e.POST("/upload", avscan.Middleware(scanner,
+//		avscan.WithQuarantine(avscan.DirQuarantine("/var/quarantine")),
+//		avscan.WithAudit(func(ctx *gin.Context, ev avscan.Event) { ... }),
+//	), handler)
+//
+// Scan failures reject the upload with 503 unless WithFailOpen is set.
+package avscan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Result of scanning a file
+type Result struct {
+	Infected  bool
+	Signature string // Detected signature name, empty if clean
+}
+
+// Scanner scans file content
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// ScannerFunc adapts a function to a Scanner
+type ScannerFunc func(ctx context.Context, r io.Reader) (Result, error)
+
+// Scan calls f(ctx, r)
+func (f ScannerFunc) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return f(ctx, r)
+}
+
+// Quarantine stores infected files for later inspection
+type Quarantine interface {
+	Quarantine(ctx context.Context, ev Event, r io.Reader) error
+}
+
+// Event describes a scanned upload, passed to audit and metrics hooks
+type Event struct {
+	Field    string // Form field name
+	Filename string // Client supplied filename
+	Size     int64
+	Result   Result
+	Elapsed  time.Duration // Scan duration
+	Err      error         // Scan error, if the scan failed
+}
+
+type scanOpts struct {
+	quarantine Quarantine
+	audit      func(*gin.Context, Event) // Called on detection
+	observe    func(Event)               // Called for every scan, e.g. latency metrics
+	failOpen   bool                      // Accept uploads if scanning fails
+	maxMemory  int64                     // Multipart form memory limit
+}
+
+// Modifier function for customising scan middleware behaviour
+type ScanOpts func(*scanOpts) *scanOpts
+
+// WithQuarantine stores infected uploads before rejecting them
+func WithQuarantine(q Quarantine) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.quarantine = q
+		return so
+	}
+}
+
+// WithAudit sets a hook called when an infected upload is detected, in addition to the warning log line
+func WithAudit(fn func(ctx *gin.Context, ev Event)) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.audit = fn
+		return so
+	}
+}
+
+// WithObserver sets a hook called after every scan, e.g. to record scan latency and outcome metrics
+func WithObserver(fn func(ev Event)) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.observe = fn
+		return so
+	}
+}
+
+// WithFailOpen accepts uploads when the scanner is unavailable, rather than rejecting them with 503
+func WithFailOpen(failOpen bool) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.failOpen = failOpen
+		return so
+	}
+}
+
+// WithMaxMemory sets the multipart form memory limit, defaults to 32MB as for gin
+func WithMaxMemory(n int64) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.maxMemory = n
+		return so
+	}
+}
+
+// Middleware scans all files in a multipart form request. Requests without a multipart body are passed through.
+func Middleware(s Scanner, opts ...ScanOpts) gin.HandlerFunc {
+	so := &scanOpts{
+		maxMemory: 32 << 20,
+	}
+	for _, f := range opts {
+		so = f(so)
+	}
+
+	return func(ctx *gin.Context) {
+		if ctx.ContentType() != gin.MIMEMultipartPOSTForm {
+			return
+		}
+		if err := ctx.Request.ParseMultipartForm(so.maxMemory); err != nil {
+			ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_multipart")
+			return
+		}
+
+		for field, files := range ctx.Request.MultipartForm.File {
+			for _, fh := range files {
+				if !so.scan(ctx, s, field, fh) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Scan a single file, returning false if the request was aborted
+func (so *scanOpts) scan(ctx *gin.Context, s Scanner, field string, fh *multipart.FileHeader) bool {
+	logger := zlog.GetLogger(ctx)
+	ev := Event{Field: field, Filename: fh.Filename, Size: fh.Size}
+
+	f, err := fh.Open()
+	if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_file") {
+		return false
+	}
+	defer f.Close()
+
+	start := time.Now()
+	ev.Result, ev.Err = s.Scan(ctx, f)
+	ev.Elapsed = time.Since(start)
+	if so.observe != nil {
+		so.observe(ev)
+	}
+
+	if ev.Err != nil {
+		if so.failOpen {
+			logger.Error().Err(ev.Err).Str("filename", fh.Filename).Msg("Upload scan failed, accepting unscanned")
+			return true
+		}
+		logger.Error().Err(ev.Err).Str("filename", fh.Filename).Msg("Upload scan failed")
+		ginxerrors.AbortWithError(ctx, ev.Err, http.StatusServiceUnavailable, "scan_unavailable")
+		return false
+	}
+	if !ev.Result.Infected {
+		return true
+	}
+
+	logger.Warn().
+		Str("field", field).
+		Str("filename", fh.Filename).
+		Int64("size", fh.Size).
+		Str("signature", ev.Result.Signature).
+		Str("ip", ctx.ClientIP()).
+		Msg("Infected upload rejected")
+	if so.audit != nil {
+		so.audit(ctx, ev)
+	}
+	if so.quarantine != nil {
+		if _, err := f.Seek(0, io.SeekStart); err == nil {
+			err = so.quarantine.Quarantine(ctx, ev, f)
+		}
+		if err != nil {
+			logger.Error().Err(err).Str("filename", fh.Filename).Msg("Failed to quarantine upload")
+		}
+	}
+	ginxerrors.AbortWith(ctx, http.StatusUnprocessableEntity, "malware_detected")
+	return false
+}
+
+// DirQuarantine stores infected files in a directory, named by detection time and signature.
+// The directory should not be served or executable.
+type DirQuarantine string
+
+// Quarantine writes the file with owner-only permissions
+func (d DirQuarantine) Quarantine(ctx context.Context, ev Event, r io.Reader) error {
+	if err := os.MkdirAll(string(d), 0o700); err != nil {
+		return err
+	}
+	// Never trust the client filename for the path
+	name := fmt.Sprintf("%d-%s.quarantine", time.Now().UnixNano(), sanitize(ev.Result.Signature))
+	f, err := os.OpenFile(filepath.Join(string(d), name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func sanitize(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	return string(b)
+}
@@ -0,0 +1,133 @@
+package avscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Minimal clamd INSTREAM implementation detecting the EICAR test string
+func fakeClamd(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, _ := r.ReadString(0); cmd != "zINSTREAM\x00" {
+					return
+				}
+				var data []byte
+				for {
+					var n uint32
+					if binary.Read(r, binary.BigEndian, &n) != nil || n == 0 {
+						break
+					}
+					chunk := make([]byte, n)
+					_, _ = io.ReadFull(r, chunk)
+					data = append(data, chunk...)
+				}
+				if bytes.Contains(data, []byte("EICAR")) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func upload(e *gin.Engine, content string) *httptest.ResponseRecorder {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	fw, _ := mw.CreateFormFile("file", "test.txt")
+	_, _ = fw.Write([]byte(content))
+	_ = mw.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestClamd(t *testing.T) {
+	c := &Clamd{Network: "tcp", Address: fakeClamd(t), ChunkSize: 16}
+
+	res, err := c.Scan(context.Background(), strings.NewReader("hello world"))
+	assert.NoError(t, err)
+	assert.False(t, res.Infected)
+
+	res, err = c.Scan(context.Background(), strings.NewReader(eicar))
+	assert.NoError(t, err)
+	assert.True(t, res.Infected)
+	assert.Equal(t, "Eicar-Test-Signature", res.Signature)
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	dir := t.TempDir()
+	var audited []Event
+	var observed int
+
+	e := gin.New()
+	e.POST("/", Middleware(&Clamd{Network: "tcp", Address: fakeClamd(t)},
+		WithQuarantine(DirQuarantine(dir)),
+		WithAudit(func(ctx *gin.Context, ev Event) { audited = append(audited, ev) }),
+		WithObserver(func(ev Event) { observed++ }),
+	), func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+
+	assert.Equal(t, 201, upload(e, "clean").Code)
+
+	w := upload(e, eicar)
+	assert.Equal(t, 422, w.Code)
+	assert.Contains(t, w.Body.String(), "malware_detected")
+	assert.Equal(t, 2, observed)
+	assert.Len(t, audited, 1)
+	assert.Equal(t, "test.txt", audited[0].Filename)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	assert.True(t, strings.HasSuffix(entries[0].Name(), "-Eicar-Test-Signature.quarantine"))
+}
+
+func TestScanFailure(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	failing := ScannerFunc(func(ctx context.Context, r io.Reader) (Result, error) {
+		return Result{}, errors.New("unavailable")
+	})
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusCreated) }
+
+	e := gin.New()
+	e.POST("/", Middleware(failing), ok)
+	assert.Equal(t, 503, upload(e, "x").Code)
+
+	e = gin.New()
+	e.POST("/", Middleware(failing, WithFailOpen(true)), ok)
+	assert.Equal(t, 201, upload(e, "x").Code)
+}
@@ -0,0 +1,146 @@
+// Middleware overhead benchmarks
+//
+// Measures the per-request time and allocation overhead of middleware, relative to an engine with a bare handler,
+// for each ginx middleware in isolation and as a typical stack. Reports can be saved as JSON and compared across
+// releases to catch performance regressions.
+//
+//	report := benchkit.Run(benchkit.Standard()...)
+//	report.WriteTo(os.Stdout)
+//
+// Cases can also be run as Go benchmarks, for use with benchstat:
+//
+//	func BenchmarkMiddleware(b *testing.B) {
+//		for _, c := range benchkit.Standard() {
+//			b.Run(c.Name, c.Bench)
+//		}
+//	}
+package benchkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Name of the case without middleware, which overheads are relative to
+const Baseline = "baseline"
+
+// Case is a middleware configuration to benchmark
+type Case struct {
+	Name       string
+	Middleware func() []gin.HandlerFunc // Creates the middleware, called once per run
+	Request    func() *http.Request     // Creates each request, GET /bench/1?q=test if nil
+}
+
+func (c Case) engine() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	if c.Middleware != nil {
+		e.Use(c.Middleware()...)
+	}
+	e.GET("/bench/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	return e
+}
+
+func (c Case) request() *http.Request {
+	if c.Request != nil {
+		return c.Request()
+	}
+	req := httptest.NewRequest("GET", "/bench/1?q=test", nil)
+	req.Header.Set("User-Agent", "benchkit")
+	return req
+}
+
+// Bench runs the case as a Go benchmark
+func (c Case) Bench(b *testing.B) {
+	e := c.engine()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.ServeHTTP(httptest.NewRecorder(), c.request())
+	}
+}
+
+// Result is the measured cost per request of a case
+type Result struct {
+	Name           string  `json:"name"`
+	NsPerOp        float64 `json:"ns_per_op"`
+	AllocsPerOp    float64 `json:"allocs_per_op"`
+	BytesPerOp     float64 `json:"bytes_per_op"`
+	OverheadNs     float64 `json:"overhead_ns"`     // Relative to the baseline
+	OverheadAllocs float64 `json:"overhead_allocs"` // Relative to the baseline
+}
+
+type runOpts struct {
+	iterations int
+	warmup     int
+}
+
+// Modifier function for customising benchmark runs
+type RunOpts func(*runOpts) *runOpts
+
+// WithIterations sets the number of measured requests per case (default 20000)
+func WithIterations(n int) RunOpts {
+	return func(ro *runOpts) *runOpts {
+		ro.iterations = n
+		return ro
+	}
+}
+
+// WithWarmup sets the number of unmeasured requests per case before measuring (default 1000)
+func WithWarmup(n int) RunOpts {
+	return func(ro *runOpts) *runOpts {
+		ro.warmup = n
+		return ro
+	}
+}
+
+// Run measures each case sequentially, with a baseline case first
+func Run(cases []Case, opts ...RunOpts) *Report {
+	ro := &runOpts{iterations: 20000, warmup: 1000}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+
+	r := &Report{}
+	base := measure(Case{Name: Baseline}, ro)
+	r.Results = append(r.Results, base)
+	for _, c := range cases {
+		res := measure(c, ro)
+		res.OverheadNs = res.NsPerOp - base.NsPerOp
+		res.OverheadAllocs = res.AllocsPerOp - base.AllocsPerOp
+		r.Results = append(r.Results, res)
+	}
+	return r
+}
+
+func measure(c Case, ro *runOpts) Result {
+	e := c.engine()
+	for i := 0; i < ro.warmup; i++ {
+		e.ServeHTTP(httptest.NewRecorder(), c.request())
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < ro.iterations; i++ {
+		e.ServeHTTP(httptest.NewRecorder(), c.request())
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	n := float64(ro.iterations)
+	return Result{
+		Name:        c.Name,
+		NsPerOp:     float64(elapsed.Nanoseconds()) / n,
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / n,
+		BytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / n,
+	}
+}
@@ -0,0 +1,53 @@
+package benchkit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func BenchmarkStandard(b *testing.B) {
+	for _, c := range Standard() {
+		b.Run(c.Name, c.Bench)
+	}
+}
+
+func TestRun(t *testing.T) {
+	alloc := Case{Name: "alloc", Middleware: func() []gin.HandlerFunc {
+		return []gin.HandlerFunc{func(ctx *gin.Context) {
+			ctx.Set("a", make([]byte, 1024))
+		}}
+	}}
+	report := Run(append(Standard(), alloc), WithIterations(200), WithWarmup(10))
+
+	assert.Equal(t, Baseline, report.Results[0].Name)
+	assert.Len(t, report.Results, len(Standard())+2)
+	last := report.Results[len(report.Results)-1]
+	assert.GreaterOrEqual(t, last.OverheadAllocs, 1.0)
+	assert.GreaterOrEqual(t, last.BytesPerOp, 1024.0)
+
+	buf := &bytes.Buffer{}
+	_, err := report.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "zlog/metrics")
+}
+
+func TestRegressions(t *testing.T) {
+	baseline := &Report{Results: []Result{
+		{Name: Baseline, NsPerOp: 1000},
+		{Name: "a", OverheadNs: 500, OverheadAllocs: 2},
+		{Name: "b", OverheadNs: 500, OverheadAllocs: 2},
+	}}
+	current := &Report{Results: []Result{
+		{Name: Baseline, NsPerOp: 1000},
+		{Name: "a", OverheadNs: 650, OverheadAllocs: 2},
+		{Name: "b", OverheadNs: 900, OverheadAllocs: 3},
+		{Name: "c", OverheadNs: 5000},
+	}}
+	regs := current.Regressions(baseline, 0.1)
+	assert.Len(t, regs, 2)
+	assert.Equal(t, "b overhead_ns 500.0 -> 900.0", regs[0].String())
+	assert.Equal(t, "overhead_allocs", regs[1].Metric)
+}
@@ -0,0 +1,66 @@
+package benchkit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Report holds the per-request overhead of each case measured by Run. Marshal it to JSON and commit it, so a later
+// release can be checked against it with Regressions.
+type Report struct {
+	Results []Result `json:"results"` // Baseline first, then cases in order
+}
+
+// Regression is a case whose overhead grew beyond the tolerance
+type Regression struct {
+	Name     string
+	Metric   string // overhead_ns or overhead_allocs
+	Baseline float64
+	Current  float64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s %.1f -> %.1f", r.Name, r.Metric, r.Baseline, r.Current)
+}
+
+// Regressions compares overheads to a previous report, returning cases whose time overhead grew by more than the
+// tolerance, e.g. 0.2 for 20%, or which allocate more. Cases missing from either report are ignored.
+func (r *Report) Regressions(baseline *Report, tolerance float64) []Regression {
+	prev := map[string]Result{}
+	for _, res := range baseline.Results {
+		prev[res.Name] = res
+	}
+	var regs []Regression
+	for _, res := range r.Results {
+		b, ok := prev[res.Name]
+		if !ok || res.Name == Baseline {
+			continue
+		}
+		// Overheads near zero are dominated by noise, so allow at least the tolerance of the baseline request
+		limit := b.OverheadNs*(1+tolerance) + prev[Baseline].NsPerOp*tolerance
+		if res.OverheadNs > limit {
+			regs = append(regs, Regression{Name: res.Name, Metric: "overhead_ns", Baseline: b.OverheadNs, Current: res.OverheadNs})
+		}
+		// Allocations are deterministic, allow for rounding
+		if res.OverheadAllocs > b.OverheadAllocs+0.5 {
+			regs = append(regs, Regression{Name: res.Name, Metric: "overhead_allocs", Baseline: b.OverheadAllocs, Current: res.OverheadAllocs})
+		}
+	}
+	return regs
+}
+
+// WriteTo writes the report as a table
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "CASE\tNS/OP\tALLOCS/OP\tB/OP\tOVERHEAD NS\tOVERHEAD ALLOCS\t")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%.0f\t%.1f\t%.0f\t%+.0f\t%+.1f\t\n",
+			res.Name, res.NsPerOp, res.AllocsPerOp, res.BytesPerOp, res.OverheadNs, res.OverheadAllocs)
+	}
+	tw.Flush()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,63 @@
+package benchkit
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/redmapletech/ginx/connlimit"
+	"github.com/redmapletech/ginx/crashdump"
+	"github.com/redmapletech/ginx/dlp"
+	"github.com/redmapletech/ginx/goroutineleak"
+	"github.com/redmapletech/ginx/sqlguard"
+	"github.com/redmapletech/ginx/uptime"
+	"github.com/redmapletech/ginx/watchdog"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+type query struct {
+	Q string `form:"q"`
+}
+
+// Loggers write to io.Discard, so serialisation is included but output isn't
+func logger(opts ...zlog.LoggerOpts) gin.HandlerFunc {
+	base := zerolog.New(io.Discard)
+	return zlog.Logger(zerolog.DebugLevel, append([]zlog.LoggerOpts{zlog.WithBaseLogger(base)}, opts...)...)
+}
+
+// Standard returns cases for each ginx middleware in isolation, and a typical stack
+func Standard() []Case {
+	return []Case{
+		{Name: "zlog", Middleware: func() []gin.HandlerFunc { return []gin.HandlerFunc{logger()} }},
+		{Name: "zlog/metrics", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{logger(zlog.WithMetrics(uptime.NewCounter(zlog.NewPrometheus())))}
+		}},
+		{Name: "zlog/recovery", Middleware: func() []gin.HandlerFunc { return []gin.HandlerFunc{zlog.Recovery()} }},
+		{Name: "bind", Middleware: func() []gin.HandlerFunc { return []gin.HandlerFunc{bind.To(query{})} }},
+		{Name: "sqlguard", Middleware: func() []gin.HandlerFunc { return []gin.HandlerFunc{sqlguard.Guard()} }},
+		{Name: "connlimit", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{connlimit.New(1000).Middleware()}
+		}},
+		{Name: "dlp", Middleware: func() []gin.HandlerFunc { return []gin.HandlerFunc{dlp.New().Middleware()} }},
+		{Name: "watchdog", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{watchdog.New(time.Minute).Middleware()}
+		}},
+		{Name: "goroutineleak", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{goroutineleak.New(time.Minute).Middleware()}
+		}},
+		{Name: "crashdump/ring", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{crashdump.NewRing(100).Middleware()}
+		}},
+		{Name: "stack", Middleware: func() []gin.HandlerFunc {
+			return []gin.HandlerFunc{
+				logger(),
+				zlog.Recovery(),
+				connlimit.New(1000).Middleware(),
+				bind.To(query{}),
+				sqlguard.Guard(),
+			}
+		}},
+	}
+}
@@ -5,9 +5,12 @@
 //
 // By default, any errors are silently ignored and the handler chain is not interrupted.
 // This behaviour can be overridden globally for all requests, and locally within each handler to either:
-//   - Abort request and set ctx.Error() for deferred handling in a higher level error middleware
+//   - Abort request and set ctx.Error() with an ErrValidation or ErrDecode for deferred handling in a higher level error middleware
 //   - Abort request and send a 400 error
 //   - Abort request and send a 400 error with specific validation error detail
+//
+// Each route served is recorded in Registry(), from which OpenAPI schemas for request bodies and
+// parameters can be generated, including constraints from binding tags.
 package bind
 
 import (
@@ -17,8 +20,11 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	v "github.com/go-playground/validator/v10"
 )
 
@@ -28,6 +34,8 @@ var (
 	defaultResponse = false
 	defaultDetail   = false
 	defaultCode     = http.StatusBadRequest
+
+	pools sync.Map // reflect.Type -> *sync.Pool
 )
 
 type bindOpts struct {
@@ -36,6 +44,13 @@ type bindOpts struct {
 	response bool   // Abort request and send response immediately
 	detail   bool   // Send error detail as JSON in response
 	code     int    // HTTP status code if sending a response
+
+	condition func(*gin.Context) bool // Only bind when the condition returns true
+	pool      bool                    // Reuse target structs from a per-type sync.Pool
+	binding   binding.Binding         // Force a specific binder instead of detecting from the request
+
+	snapshotKey   string // Context key to attach the raw request body, disabled if empty
+	snapshotLimit int    // Maximum number of snapshot bytes, unlimited if zero
 }
 
 // Modifier function for customising bind handler behaviour
@@ -63,6 +78,25 @@ func To(s interface{}, opts ...BindOpts) gin.HandlerFunc {
 		panic(fmt.Errorf("BindTo() must be given a struct, received %s", t.Kind()))
 	}
 
+	// Warm the metadata cache so the first request doesn't pay for it
+	getTypeInfo(t)
+
+	if bo.pool {
+		pool := getPool(t)
+
+		return func(ctx *gin.Context) {
+			// Pooled instance of pointer target struct
+			v := pool.Get()
+			bindHandler(ctx, v, bo)
+
+			// Process remaining handlers before reclaiming the instance
+			ctx.Next()
+
+			reflect.ValueOf(v).Elem().Set(reflect.Zero(t))
+			pool.Put(v)
+		}
+	}
+
 	return func(ctx *gin.Context) {
 		// New instance of pointer target struct
 		v := reflect.New(t).Interface()
@@ -70,9 +104,29 @@ func To(s interface{}, opts ...BindOpts) gin.HandlerFunc {
 	}
 }
 
+func getPool(t reflect.Type) *sync.Pool {
+	if p, ok := pools.Load(t); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := pools.LoadOrStore(t, &sync.Pool{
+		New: func() interface{} {
+			return reflect.New(t).Interface()
+		},
+	})
+	return p.(*sync.Pool)
+}
+
 func bindHandler(ctx *gin.Context, target interface{}, opts *bindOpts) {
 	var body []byte
 
+	// Skip binding entirely if the condition is not met
+	if opts.condition != nil && !opts.condition(ctx) {
+		return
+	}
+
+	// Record the route to target mapping for documentation
+	registry.record(ctx, target, opts)
+
 	// Check if request has a body
 	if ctx.Request.Body != nil {
 		var err error
@@ -87,32 +141,36 @@ func bindHandler(ctx *gin.Context, target interface{}, opts *bindOpts) {
 		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
 	}
 
+	// Expose the raw body regardless of the binding outcome, for auditing
+	if opts.snapshotKey != "" {
+		snapshot := body
+		if opts.snapshotLimit > 0 && len(snapshot) > opts.snapshotLimit {
+			snapshot = snapshot[:opts.snapshotLimit]
+		}
+		ctx.Set(opts.snapshotKey, snapshot)
+	}
+
 	// Handle binding
-	if err := ctx.ShouldBind(target); err != nil {
+	if err := shouldBind(ctx, target, opts); err != nil {
+		err = wrapError(target, err)
+
 		if opts.abort {
 			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
 			ctx.Abort()
-			ctx.Error(err)
+			ctx.Error(err).SetType(gin.ErrorTypeBind)
 			return
 		} else if opts.response {
-			if vErr := (v.ValidationErrors{}); errors.As(err, &vErr) && opts.detail {
-				errs := []gin.H{}
-				for _, fe := range vErr {
-					errs = append(errs, gin.H{
-						"field": fe.Field(),
-						"rule":  fe.Tag(),
-					})
-				}
+			if vErr := (ErrValidation{}); errors.As(err, &vErr) && opts.detail {
 				res := gin.H{
 					"code":   "validation_error",
-					"errors": errs,
+					"errors": vErr.Fields,
 				}
 				ctx.AbortWithStatusJSON(opts.code, res)
 			} else if opts.detail {
 				// Not a validation error but detail still requested
 				ctx.AbortWithStatusJSON(opts.code, gin.H{
 					"code":  "binding_error",
-					"error": err.Error(),
+					"error": errors.Unwrap(err).Error(),
 				})
 			} else {
 				// Error other than validation error or detail response disabled
@@ -132,6 +190,133 @@ func bindHandler(ctx *gin.Context, target interface{}, opts *bindOpts) {
 	ctx.Set(opts.key, target)
 }
 
+func shouldBind(ctx *gin.Context, target interface{}, opts *bindOpts) error {
+	if opts.binding != nil {
+		return ctx.ShouldBindWith(target, opts.binding)
+	}
+	return ctx.ShouldBind(target)
+}
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field string `json:"field"` // Struct field name
+	Path  string `json:"path"`  // Path of the field in the request, using json names
+	Rule  string `json:"rule"`  // Failed validation tag, e.g. "required"
+}
+
+// ErrValidation is the error set on the context by WithAbort when the target fails validation
+type ErrValidation struct {
+	Fields []FieldError
+	Cause  error // Underlying validator.ValidationErrors
+}
+
+func (e ErrValidation) Error() string {
+	return fmt.Sprintf("bind: validation failed on %d field(s): %s", len(e.Fields), e.Cause)
+}
+
+func (e ErrValidation) Unwrap() error {
+	return e.Cause
+}
+
+// ErrDecode is the error set on the context by WithAbort when the request cannot be decoded,
+// e.g. malformed JSON or a type mismatch
+type ErrDecode struct {
+	Cause error
+}
+
+func (e ErrDecode) Error() string {
+	return "bind: decode failed: " + e.Cause.Error()
+}
+
+func (e ErrDecode) Unwrap() error {
+	return e.Cause
+}
+
+func wrapError(target interface{}, err error) error {
+	if vErr := (v.ValidationErrors{}); errors.As(err, &vErr) {
+		fields := make([]FieldError, 0, len(vErr))
+		for _, fe := range vErr {
+			fields = append(fields, FieldError{
+				Field: fe.Field(),
+				Path:  fieldPath(reflect.TypeOf(target), fe.StructNamespace()),
+				Rule:  fe.Tag(),
+			})
+		}
+		return ErrValidation{Fields: fields, Cause: err}
+	}
+	return ErrDecode{Cause: err}
+}
+
+// fieldPath maps a validator struct namespace such as "Order.Items[2].Price" to the
+// path of the offending input as the client sent it, e.g. "items[2].price", using json tags.
+// Embedded structs without a json name are flattened, matching encoding/json.
+func fieldPath(t reflect.Type, ns string) string {
+	segs := splitNamespace(ns)
+	if len(segs) < 2 {
+		return ns
+	}
+
+	var path strings.Builder
+	for _, seg := range segs[1:] {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		name, index := seg, ""
+		if i := strings.IndexByte(seg, '['); i >= 0 {
+			name, index = seg[:i], seg[i:]
+		}
+
+		out := name
+		if t.Kind() == reflect.Struct {
+			if f, ok := getTypeInfo(t).byName[name]; ok {
+				out = f.json
+				t = f.Type
+			}
+		}
+
+		// Descend into element types for each index suffix
+		for n := strings.Count(index, "["); n > 0; n-- {
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+
+		if out == "" {
+			// Flattened embedded struct
+			continue
+		}
+		if path.Len() > 0 {
+			path.WriteByte('.')
+		}
+		path.WriteString(out + index)
+	}
+	return path.String()
+}
+
+// splitNamespace splits on dots outside of map key brackets
+func splitNamespace(ns string) []string {
+	var segs []string
+	depth, start := 0, 0
+	for i := 0; i < len(ns); i++ {
+		switch ns[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segs = append(segs, ns[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segs, ns[start:])
+}
+
 func getBindOpts(opts ...BindOpts) *bindOpts {
 	bo := defaultBindOpts()
 	for _, f := range opts {
@@ -219,3 +404,71 @@ func WithDetail(detail bool) BindOpts {
 func SetDefaultDetail(detail bool) {
 	defaultDetail = detail
 }
+
+// WithCondition sets the middleware to only bind when cond returns true for the current handler.
+// When the condition is not met the handler chain continues without a value attached to the context.
+func WithCondition(cond func(*gin.Context) bool) BindOpts {
+	return func(bo *bindOpts) *bindOpts {
+		bo.condition = cond
+		return bo
+	}
+}
+
+// MethodIs returns a condition matching any of the given request methods
+func MethodIs(methods ...string) func(*gin.Context) bool {
+	return func(ctx *gin.Context) bool {
+		for _, m := range methods {
+			if ctx.Request.Method == m {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasHeader returns a condition matching requests with a non-empty value for the given header
+func HasHeader(name string) func(*gin.Context) bool {
+	return func(ctx *gin.Context) bool {
+		return ctx.GetHeader(name) != ""
+	}
+}
+
+// WithPool sets the middleware to reuse bound structs from a sync.Pool for the current handler.
+// Only applies to To(), as providers passed to As() may set defaults which a reset would discard.
+//
+// The instance is zeroed and returned to the pool once the remaining handler chain completes,
+// so handlers must not retain the pointer (or anything referencing it) beyond the request,
+// including in goroutines or copies of the context made with ctx.Copy().
+func WithPool() BindOpts {
+	return func(bo *bindOpts) *bindOpts {
+		bo.pool = true
+		return bo
+	}
+}
+
+// WithBinding forces the binder used for the current handler, e.g. binding.JSON or binding.Form,
+// regardless of the request method and Content-Type.
+func WithBinding(b binding.Binding) BindOpts {
+	return func(bo *bindOpts) *bindOpts {
+		bo.binding = b
+		return bo
+	}
+}
+
+// WithBodySnapshot attaches the raw request body bytes to the context under key for the current handler,
+// so later middleware can access the exact payload without re-reading the body.
+// The snapshot is attached whether or not binding succeeds, and must not be modified.
+func WithBodySnapshot(key string) BindOpts {
+	return func(bo *bindOpts) *bindOpts {
+		bo.snapshotKey = key
+		return bo
+	}
+}
+
+// WithSnapshotLimit caps the body snapshot at n bytes for the current handler
+func WithSnapshotLimit(n int) BindOpts {
+	return func(bo *bindOpts) *bindOpts {
+		bo.snapshotLimit = n
+		return bo
+	}
+}
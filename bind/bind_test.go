@@ -3,13 +3,16 @@ package bind
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -150,6 +153,34 @@ func TestBindAbort(t *testing.T) {
 	assert.Equal(t, 200, w.Result().StatusCode)
 }
 
+func TestBindAbortTypedErrors(t *testing.T) {
+	e := gin.New()
+
+	var last error
+	e.POST("", func(ctx *gin.Context) {
+		ctx.Next()
+		last = ctx.Errors.Last().Err
+	}, To(TestingBody{}, WithAbort(true)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", body(map[string]interface{}{}))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+
+	vErr := ErrValidation{}
+	assert.True(t, errors.As(last, &vErr))
+	assert.Equal(t, []FieldError{{Field: "Test", Path: "test", Rule: "required"}}, vErr.Fields)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"test":`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+
+	dErr := ErrDecode{}
+	assert.True(t, errors.As(last, &dErr))
+	assert.ErrorIs(t, dErr.Cause, io.ErrUnexpectedEOF)
+}
+
 func TestBindResponse(t *testing.T) {
 	w := httptest.NewRecorder()
 	e := gin.New()
@@ -191,7 +222,32 @@ func TestBindResponseDetail(t *testing.T) {
 
 	e.ServeHTTP(w, req)
 	assert.Equal(t, 400, w.Result().StatusCode)
-	assert.Equal(t, `{"code":"validation_error","errors":[{"field":"ID","rule":"uuid4"}]}`, w.Body.String())
+	assert.Equal(t, `{"code":"validation_error","errors":[{"field":"ID","path":"id","rule":"uuid4"}]}`, w.Body.String())
+}
+
+func TestBindResponseDetailNestedPath(t *testing.T) {
+	type item struct {
+		Price int `json:"price" binding:"gte=0"`
+	}
+	type meta struct {
+		Ref string `json:"ref" binding:"required"`
+	}
+	type order struct {
+		meta
+		Items []item `json:"items" binding:"dive"`
+	}
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.POST("", To(order{}, WithDetail(true)))
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"items":[{"price":1},{"price":2},{"price":-1}]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Result().StatusCode)
+	assert.Equal(t, `{"code":"validation_error","errors":[{"field":"Ref","path":"ref","rule":"required"},{"field":"Price","path":"items[2].price","rule":"gte"}]}`, w.Body.String())
 }
 
 func TestBindResponseDetailNotValidation(t *testing.T) {
@@ -212,6 +268,289 @@ func TestBindResponseDetailNotValidation(t *testing.T) {
 	assert.Equal(t, `{"code":"binding_error","error":"unexpected EOF"}`, w.Body.String())
 }
 
+func TestBindCondition(t *testing.T) {
+	e := gin.New()
+
+	e.Any("", To(TestingBody{}, WithResponse(true), WithCondition(MethodIs(http.MethodPost, http.MethodPut))), func(ctx *gin.Context) {
+		_, exists := ctx.Get("body")
+		assert.Equal(t, ctx.Request.Method != http.MethodGet, exists)
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", body(map[string]interface{}{
+		"test": "asdf",
+	}))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestBindConditionHeader(t *testing.T) {
+	e := gin.New()
+
+	e.POST("", To(TestingBody{}, WithResponse(true), WithCondition(HasHeader("X-Feature"))), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Feature", "1")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Result().StatusCode)
+}
+
+func TestBindPool(t *testing.T) {
+	type pooledBody struct {
+		Test     string `json:"test" binding:"required"`
+		Optional string `json:"optional"`
+	}
+
+	e := gin.New()
+
+	var seen []string
+	e.POST("", To(pooledBody{}, WithPool()), func(ctx *gin.Context) {
+		b := ctx.MustGet("body").(*pooledBody)
+		seen = append(seen, b.Optional)
+		ctx.Status(http.StatusOK)
+	})
+
+	for _, payload := range []map[string]interface{}{
+		{"test": "a", "optional": "set"},
+		{"test": "b"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", body(payload))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+	}
+
+	// Second request must not observe values from the first
+	assert.Equal(t, []string{"set", ""}, seen)
+}
+
+func TestBindWithBindingForm(t *testing.T) {
+	type formBody struct {
+		Name string `form:"name" binding:"required"`
+	}
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.PATCH("", To(formBody{}, WithBinding(binding.Form), WithResponse(true)), func(ctx *gin.Context) {
+		b := ctx.MustGet("body").(*formBody)
+		assert.Equal(t, "asdf", b.Name)
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("PATCH", "/", strings.NewReader("name=asdf"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestBindWithBindingJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.PUT("", To(TestingBody{}, WithBinding(binding.JSON), WithResponse(true)), func(ctx *gin.Context) {
+		b := ctx.MustGet("body").(*TestingBody)
+		assert.Equal(t, "asdf", b.Test)
+		ctx.Status(http.StatusOK)
+	})
+
+	// No Content-Type, so detection would otherwise fall back to form binding
+	req, _ := http.NewRequest("PUT", "/", body(map[string]interface{}{
+		"test": "asdf",
+	}))
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestBindBodySnapshot(t *testing.T) {
+	e := gin.New()
+
+	e.POST("/full", To(TestingBody{}, WithBodySnapshot("raw")), func(ctx *gin.Context) {
+		assert.Equal(t, []byte(`{"test":"asdf"}`), ctx.MustGet("raw"))
+		ctx.Status(http.StatusOK)
+	})
+	e.POST("/capped", func(ctx *gin.Context) {
+		ctx.Next()
+		assert.Equal(t, []byte(`{"te`), ctx.MustGet("raw"))
+	}, To(TestingBody{}, WithAbort(true), WithBodySnapshot("raw"), WithSnapshotLimit(4)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/full", strings.NewReader(`{"test":"asdf"}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/capped", strings.NewReader(`{"test":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+}
+
+func TestRegistry(t *testing.T) {
+	type createUser struct {
+		Name  string   `json:"name" binding:"required,min=2,max=32"`
+		Email string   `json:"email" binding:"required,email"`
+		Role  string   `json:"role,omitempty" binding:"omitempty,oneof=admin user"`
+		Tags  []string `json:"tags" binding:"max=5,dive,min=1"`
+		Age   *int     `json:"age" binding:"omitempty,gte=0"`
+	}
+	type listUsers struct {
+		ID    string `uri:"id" binding:"required"`
+		Limit int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	}
+
+	e := gin.New()
+	e.POST("/users", To(createUser{}), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	Registry().Add(http.MethodGet, "/users/:id", listUsers{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/users", body(map[string]interface{}{
+		"name":  "test",
+		"email": "test@example.com",
+	}))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	doc := Registry().OpenAPI("Test", "1.0")
+	paths := doc["paths"].(map[string]interface{})
+	components := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	post := paths["/users"].(map[string]interface{})["post"].(map[string]interface{})
+	ref := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Equal(t, "#/components/schemas/createUser", ref["$ref"])
+	schema := components["createUser"].(map[string]interface{})
+	props := schema["properties"].(map[string]interface{})
+
+	assert.Equal(t, []string{"name", "email"}, schema["required"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "minLength": 2.0, "maxLength": 32.0}, props["name"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "email"}, props["email"])
+	assert.Equal(t, []interface{}{"admin", "user"}, props["role"].(map[string]interface{})["enum"])
+	assert.Equal(t, 5.0, props["tags"].(map[string]interface{})["maxItems"])
+	assert.Equal(t, 1.0, props["tags"].(map[string]interface{})["items"].(map[string]interface{})["minLength"])
+	assert.Equal(t, []string{"integer", "null"}, props["age"].(map[string]interface{})["type"])
+
+	get := paths["/users/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	params := get["parameters"].([]interface{})
+
+	assert.Len(t, params, 2)
+	assert.Equal(t, "path", params[0].(map[string]interface{})["in"])
+	assert.Equal(t, "query", params[1].(map[string]interface{})["in"])
+	assert.Equal(t, 100.0, params[1].(map[string]interface{})["schema"].(map[string]interface{})["maximum"])
+}
+
+type treeNode struct {
+	Name     string              `json:"name" binding:"required"`
+	Parent   *treeNode           `json:"parent"`
+	Children []treeNode          `json:"children"`
+	Links    map[string]treeNode `json:"links"`
+}
+
+type selfEmbedding struct {
+	*selfEmbedding
+	Name string `json:"name"`
+}
+
+func TestRegistryRecursiveType(t *testing.T) {
+	e := gin.New()
+	e.POST("/tree", To(treeNode{}), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	Registry().Add(http.MethodPost, "/embedded", selfEmbedding{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/tree", strings.NewReader(`{"name":"root","children":[{"name":"leaf"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	doc := Registry().OpenAPI("Test", "1.0")
+	components := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	props := components["treeNode"].(map[string]interface{})["properties"].(map[string]interface{})
+
+	ref := map[string]interface{}{"$ref": "#/components/schemas/treeNode"}
+	assert.Equal(t, ref, props["children"].(map[string]interface{})["items"])
+	assert.Equal(t, ref, props["links"].(map[string]interface{})["additionalProperties"])
+	assert.Equal(t, map[string]interface{}{"anyOf": []interface{}{ref, map[string]interface{}{"type": "null"}}}, props["parent"])
+
+	embedded := components["selfEmbedding"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Len(t, embedded, 1)
+	assert.Contains(t, embedded, "name")
+}
+
+func TestTypeInfoCache(t *testing.T) {
+	type inner struct {
+		Ref string `json:"ref" binding:"required"`
+	}
+	type outer struct {
+		inner
+		Name    string `json:"name,omitempty"`
+		Ignored string `json:"-"`
+		private string
+	}
+
+	ti := getTypeInfo(reflect.TypeOf(outer{}))
+
+	assert.Same(t, ti, getTypeInfo(reflect.TypeOf(&outer{})))
+	assert.Len(t, ti.flat, 2)
+	assert.Equal(t, "ref", ti.flat[0].json)
+	assert.True(t, ti.flat[0].required)
+	assert.Equal(t, "name", ti.flat[1].json)
+	assert.Equal(t, "", ti.byName["inner"].json)
+}
+
+func BenchmarkBindTo(b *testing.B) {
+	benchmarkBind(b, To(TestingBody{}))
+}
+
+func BenchmarkBindToPool(b *testing.B) {
+	benchmarkBind(b, To(TestingBody{}, WithPool()))
+}
+
+func benchmarkBind(b *testing.B, h gin.HandlerFunc) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("", h, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	buf, _ := json.Marshal(map[string]interface{}{"test": "asdf"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(w, req)
+	}
+}
+
 func body(i interface{}) io.Reader {
 	buf, _ := json.Marshal(i)
 	return bytes.NewReader(buf)
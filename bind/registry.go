@@ -0,0 +1,407 @@
+package bind
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var registry = &TypeRegistry{}
+
+// Registry returns the global registry of route to bind target mappings
+func Registry() *TypeRegistry {
+	return registry
+}
+
+// TypeRegistry records the target type bound for each route, for generating API documentation.
+//
+// Routes are recorded automatically the first time the bind middleware serves them, using the
+// route pattern from ctx.FullPath(). Routes can also be added explicitly at startup with Add, so
+// documentation is complete before any traffic has been served.
+type TypeRegistry struct {
+	entries sync.Map // routeKey -> *RouteEntry
+}
+
+type routeKey struct {
+	method string
+	path   string
+}
+
+// RouteEntry describes the bind target of a single route
+type RouteEntry struct {
+	Method string       // HTTP method
+	Path   string       // Gin route pattern, e.g. /users/:id
+	Type   reflect.Type // Bind target struct type
+	Key    string       // Context key the target is attached to
+}
+
+// Add explicitly records the bind target for a route
+func (r *TypeRegistry) Add(method, path string, target interface{}) {
+	t := reflect.TypeOf(target)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r.entries.Store(routeKey{method, path}, &RouteEntry{
+		Method: method,
+		Path:   path,
+		Type:   t,
+		Key:    defaultKey,
+	})
+}
+
+// Entries returns all recorded routes sorted by path then method
+func (r *TypeRegistry) Entries() []*RouteEntry {
+	var res []*RouteEntry
+	r.entries.Range(func(_, v interface{}) bool {
+		res = append(res, v.(*RouteEntry))
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// Paths returns an OpenAPI 3.1 paths object describing the request body and parameters of all recorded routes.
+// Named struct types are referenced from components/schemas, see OpenAPI.
+func (r *TypeRegistry) Paths() map[string]interface{} {
+	paths, _ := r.document()
+	return paths
+}
+
+// OpenAPI returns a complete OpenAPI 3.1 document containing the recorded routes
+func (r *TypeRegistry) OpenAPI(title, version string) map[string]interface{} {
+	paths, schemas := r.document()
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.Components()},
+	}
+}
+
+func (r *TypeRegistry) document() (map[string]interface{}, *Schemas) {
+	schemas := NewSchemas()
+	paths := map[string]interface{}{}
+	for _, e := range r.Entries() {
+		path := OpenAPIPath(e.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(e.Method)] = e.Operation(schemas)
+	}
+	return paths, schemas
+}
+
+// Handler returns a handler serving the OpenAPI document as JSON
+func (r *TypeRegistry) Handler(title, version string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, r.OpenAPI(title, version))
+	}
+}
+
+func (r *TypeRegistry) record(ctx *gin.Context, target interface{}, opts *bindOpts) {
+	path := ctx.FullPath()
+	if path == "" {
+		// No matching route, e.g. NoRoute handlers
+		return
+	}
+	k := routeKey{ctx.Request.Method, path}
+	if _, ok := r.entries.Load(k); ok {
+		return
+	}
+	r.entries.Store(k, &RouteEntry{
+		Method: k.method,
+		Path:   k.path,
+		Type:   reflect.TypeOf(target).Elem(),
+		Key:    opts.key,
+	})
+}
+
+// Operation returns the OpenAPI operation object for the route, adding named struct types to the schemas
+func (e *RouteEntry) Operation(schemas *Schemas) map[string]interface{} {
+	op := map[string]interface{}{}
+
+	var params []interface{}
+
+	// Path parameters from uri tags, query parameters from form tags on body-less methods
+	for _, f := range getTypeInfo(e.Type).flat {
+		in := ""
+		name := ""
+		if n := tagName(f.StructField, "uri"); n != "" {
+			in, name = "path", n
+		} else if n := tagName(f.StructField, "form"); n != "" && !hasBody(e.Method) {
+			in, name = "query", n
+		}
+		if in == "" {
+			continue
+		}
+		p := map[string]interface{}{
+			"name":   name,
+			"in":     in,
+			"schema": schemas.schemaFor(f.Type, f.Tag.Get("binding")),
+		}
+		if in == "path" || f.required {
+			p["required"] = true
+		}
+		params = append(params, p)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if hasBody(e.Method) {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": schemas.schemaFor(e.Type, ""),
+				},
+			},
+		}
+	}
+	return op
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// OpenAPIPath converts gin :param and *param segments to OpenAPI {param}
+func OpenAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segs[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schemas collects the component schemas of named struct types referenced by operations. Named structs are
+// described once and referenced with $ref, so recursive types such as trees are supported.
+type Schemas struct {
+	names map[reflect.Type]string
+	defs  map[string]interface{}
+}
+
+// NewSchemas creates an empty schema collection
+func NewSchemas() *Schemas {
+	return &Schemas{names: map[reflect.Type]string{}, defs: map[string]interface{}{}}
+}
+
+// Components returns the collected schemas by name, for the components/schemas object
+func (s *Schemas) Components() map[string]interface{} {
+	return s.defs
+}
+
+// Return the reference to the component schema of a named struct type, describing it on first use
+func (s *Schemas) ref(t reflect.Type) map[string]interface{} {
+	name, ok := s.names[t]
+	if !ok {
+		name = componentName(t)
+		for i := 2; s.defs[name] != nil; i++ {
+			name = componentName(t) + strconv.Itoa(i)
+		}
+		// Registered before describing the fields, so fields of the same type resolve to the reference
+		s.names[t] = name
+		s.defs[name] = map[string]interface{}{}
+		s.defs[name] = s.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// Component names may only contain letters, digits, '.', '-' and '_', so generic type arguments are replaced
+func componentName(t reflect.Type) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, t.Name())
+}
+
+func (s *Schemas) object(t reflect.Type) map[string]interface{} {
+	obj := map[string]interface{}{"type": "object"}
+	props := map[string]interface{}{}
+	var required []string
+	for _, f := range getTypeInfo(t).flat {
+		props[f.json] = s.schemaFor(f.Type, f.Tag.Get("binding"))
+		if f.required {
+			required = append(required, f.json)
+		}
+	}
+	obj["properties"] = props
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// Build a JSON schema for the type, applying constraints from the binding tag
+func (s *Schemas) schemaFor(t reflect.Type, binding string) map[string]interface{} {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	res := map[string]interface{}{}
+	switch {
+	case t == timeType:
+		res["type"] = "string"
+		res["format"] = "date-time"
+	case t.Kind() == reflect.Bool:
+		res["type"] = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		res["type"] = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		res["type"] = "number"
+	case t.Kind() == reflect.String:
+		res["type"] = "string"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		res["type"] = "string"
+		res["contentEncoding"] = "base64"
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		res["type"] = "array"
+		res["items"] = s.schemaFor(t.Elem(), diveRules(binding))
+	case t.Kind() == reflect.Map:
+		res["type"] = "object"
+		res["additionalProperties"] = s.schemaFor(t.Elem(), "")
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		res = s.ref(t)
+		if nullable {
+			res = map[string]interface{}{"anyOf": []interface{}{res, map[string]interface{}{"type": "null"}}}
+		}
+		return res
+	case t.Kind() == reflect.Struct:
+		res = s.object(t)
+	}
+
+	applyConstraints(res, binding)
+
+	if nullable {
+		if typ, ok := res["type"].(string); ok {
+			res["type"] = []string{typ, "null"}
+		}
+	}
+	return res
+}
+
+// Rules after "dive" apply to slice elements rather than the slice itself
+func diveRules(binding string) string {
+	if i := strings.Index(binding, "dive"); i >= 0 {
+		return strings.TrimPrefix(binding[i+len("dive"):], ",")
+	}
+	return ""
+}
+
+func applyConstraints(s map[string]interface{}, binding string) {
+	if i := strings.Index(binding, "dive"); i >= 0 {
+		binding = binding[:i]
+	}
+
+	typ, _ := s["type"].(string)
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min", "gte", "max", "lte", "len", "gt", "lt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(s, typ, name, n)
+		case "oneof":
+			var enum []interface{}
+			for _, v := range strings.Fields(param) {
+				enum = append(enum, enumValue(typ, v))
+			}
+			s["enum"] = enum
+		case "email":
+			s["format"] = "email"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			s["format"] = "uuid"
+		case "url", "uri", "http_url":
+			s["format"] = "uri"
+		case "ip", "ipv4":
+			s["format"] = "ipv4"
+		case "ipv6":
+			s["format"] = "ipv6"
+		case "hostname", "hostname_rfc1123", "fqdn":
+			s["format"] = "hostname"
+		case "datetime":
+			s["format"] = "date-time"
+		}
+	}
+}
+
+func applyBound(s map[string]interface{}, typ, rule string, n float64) {
+	var min, max, exMin, exMax string
+	switch typ {
+	case "string":
+		min, max = "minLength", "maxLength"
+	case "array":
+		min, max = "minItems", "maxItems"
+	case "object":
+		min, max = "minProperties", "maxProperties"
+	default:
+		min, max, exMin, exMax = "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"
+	}
+
+	switch rule {
+	case "min", "gte":
+		s[min] = n
+	case "max", "lte":
+		s[max] = n
+	case "len":
+		s[min] = n
+		s[max] = n
+	case "gt":
+		if exMin != "" {
+			s[exMin] = n
+		} else {
+			s[min] = n + 1
+		}
+	case "lt":
+		if exMax != "" {
+			s[exMax] = n
+		} else {
+			s[max] = n - 1
+		}
+	}
+}
+
+func enumValue(typ, v string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,140 @@
+package bind
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// Per-type metadata cache, shared by all bind options needing struct field information.
+// Reads are a single atomic load; new types are added by copy-on-write, which is cheap as the
+// set of bound types is small and fixed shortly after startup.
+var typeCache atomic.Pointer[map[reflect.Type]*typeInfo]
+
+// typeInfo holds metadata for a bound struct type
+type typeInfo struct {
+	typ    reflect.Type
+	byName map[string]*fieldInfo // Direct fields by Go name, including embedded structs
+	flat   []*fieldInfo          // Fields as seen by encoding/json, with untagged embedded structs flattened
+}
+
+// fieldInfo holds metadata for a single struct field
+type fieldInfo struct {
+	reflect.StructField
+	json     string // Encoded name, empty for flattened embedded structs
+	required bool   // Has the "required" binding rule
+}
+
+// getTypeInfo returns the cached metadata for t, which must be a struct or pointer to struct
+func getTypeInfo(t reflect.Type) *typeInfo {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if m := typeCache.Load(); m != nil {
+		if ti, ok := (*m)[t]; ok {
+			return ti
+		}
+	}
+
+	ti := newTypeInfo(t, map[reflect.Type]bool{})
+	for {
+		old := typeCache.Load()
+		next := map[reflect.Type]*typeInfo{}
+		if old != nil {
+			if existing, ok := (*old)[t]; ok {
+				// Lost a race with another goroutine adding the same type
+				return existing
+			}
+			for k, v := range *old {
+				next[k] = v
+			}
+		}
+		next[t] = ti
+		if typeCache.CompareAndSwap(old, &next) {
+			return ti
+		}
+	}
+}
+
+// Build the metadata for t. Types being built are tracked in visiting, so embedded structs which embed the
+// outer type again aren't flattened into it endlessly.
+func newTypeInfo(t reflect.Type, visiting map[reflect.Type]bool) *typeInfo {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	ti := &typeInfo{
+		typ:    t,
+		byName: map[string]*fieldInfo{},
+	}
+	if t.Kind() != reflect.Struct {
+		return ti
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := &fieldInfo{
+			StructField: f,
+			json:        jsonName(f),
+			required:    hasRule(f.Tag.Get("binding"), "required"),
+		}
+		ti.byName[f.Name] = fi
+
+		if f.Anonymous && fi.json == "" {
+			// Promote fields of untagged embedded structs
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !visiting[ft] {
+				ti.flat = append(ti.flat, flatFields(ft, visiting)...)
+			}
+			continue
+		}
+		if !f.IsExported() || tagName(f, "json") == "-" {
+			continue
+		}
+		ti.flat = append(ti.flat, fi)
+	}
+	return ti
+}
+
+// Return the flattened fields of an embedded struct, from the cache if present
+func flatFields(t reflect.Type, visiting map[reflect.Type]bool) []*fieldInfo {
+	if m := typeCache.Load(); m != nil {
+		if ti, ok := (*m)[t]; ok {
+			return ti.flat
+		}
+	}
+	return newTypeInfo(t, visiting).flat
+}
+
+// jsonName returns the encoded name of a field, or empty for untagged embedded structs
+func jsonName(f reflect.StructField) string {
+	tag := tagName(f, "json")
+	if tag != "" && tag != "-" {
+		return tag
+	}
+	if f.Anonymous {
+		return ""
+	}
+	return f.Name
+}
+
+// tagName returns the name portion of a struct tag, before any options
+func tagName(f reflect.StructField, key string) string {
+	tag := f.Tag.Get(key)
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
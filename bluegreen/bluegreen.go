@@ -0,0 +1,196 @@
+// Blue/green deploy coordination
+//
+// Registers the instance with a service registry on startup and deregisters it on shutdown, flipping readiness in
+// step so the load balancer and registry agree: the instance reports ready only once registered, and on shutdown
+// reports not ready and deregisters, then waits a grace period for clients and proxies to stop routing to it before
+// the server drains.
+//
+//	bg := bluegreen.New(consulRegistry, bluegreen.Instance{ID: host, Service: "api", Address: ip, Port: 8080, Color: "green"},
+//		bluegreen.WithDeregisterGrace(15*time.Second))
+//	h := echoexport.New(routes, echoexport.WithStartHook(bg.Start))
+//	admin.GET("/readyz", bg.ReadyHandler())
+//	if err := h.Start(ctx); err != nil { ... }
+//
+//	// On SIGTERM, deregister and wait out the grace period while still serving, then drain
+//	_ = bg.Stop(ctx)
+//	_ = h.Shutdown(ctx)
+//
+// Stop is called before the handler shuts down rather than as an echoexport stop hook, as those run only after the
+// handler has stopped accepting requests, when clients still routed to the instance would be rejected.
+//
+// Registries such as Consul, etcd or a cloud provider's target groups implement Registry.
+package bluegreen
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Instance describes this instance to the registry
+type Instance struct {
+	ID      string            `json:"id"`
+	Service string            `json:"service"`
+	Address string            `json:"address"`
+	Port    int               `json:"port"`
+	Color   string            `json:"color,omitempty"` // Deployment slot, e.g. blue or green
+	Tags    []string          `json:"tags,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// Registry is a service registry instances are registered with
+type Registry interface {
+	Register(ctx context.Context, inst Instance) error
+	Deregister(ctx context.Context, id string) error
+}
+
+// State of the instance
+type State string
+
+const (
+	StateStarting     State = "starting"
+	StateReady        State = "ready"
+	StateDraining     State = "draining"
+	StateDeregistered State = "deregistered"
+)
+
+type coordOpts struct {
+	grace     time.Duration
+	readyWait time.Duration
+	gate      func(ctx context.Context) error
+}
+
+// Modifier function for customising deploy coordination
+type CoordOpts func(*coordOpts) *coordOpts
+
+// WithDeregisterGrace sets how long Stop waits after deregistering before returning (default 10 seconds)
+func WithDeregisterGrace(d time.Duration) CoordOpts {
+	return func(co *coordOpts) *coordOpts {
+		co.grace = d
+		return co
+	}
+}
+
+// WithReadyDelay sets how long Start waits after registering before reporting ready, e.g. for registry health
+// checks to pass (default none)
+func WithReadyDelay(d time.Duration) CoordOpts {
+	return func(co *coordOpts) *coordOpts {
+		co.readyWait = d
+		return co
+	}
+}
+
+// WithGate runs the check before registering, e.g. to warm caches, failing Start if it returns an error
+func WithGate(gate func(ctx context.Context) error) CoordOpts {
+	return func(co *coordOpts) *coordOpts {
+		co.gate = gate
+		return co
+	}
+}
+
+// Coordinator registers and deregisters the instance, tracking readiness
+type Coordinator struct {
+	registry Registry
+	instance Instance
+	opts     *coordOpts
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	mu    sync.Mutex
+	state State
+}
+
+// New creates a coordinator registering the instance with the registry
+func New(registry Registry, inst Instance, opts ...CoordOpts) *Coordinator {
+	co := &coordOpts{grace: 10 * time.Second}
+	for _, f := range opts {
+		co = f(co)
+	}
+	return &Coordinator{registry: registry, instance: inst, opts: co, sleep: sleep, state: StateStarting}
+}
+
+// Start registers the instance, then reports ready
+func (c *Coordinator) Start(ctx context.Context) error {
+	if c.opts.gate != nil {
+		if err := c.opts.gate(ctx); err != nil {
+			return fmt.Errorf("bluegreen: gate failed: %w", err)
+		}
+	}
+	if err := c.registry.Register(ctx, c.instance); err != nil {
+		return fmt.Errorf("bluegreen: register %s: %w", c.instance.ID, err)
+	}
+	log.Info().Str("instance", c.instance.ID).Str("service", c.instance.Service).Str("color", c.instance.Color).Msg("Instance registered")
+
+	if err := c.sleep(ctx, c.opts.readyWait); err != nil {
+		return err
+	}
+	c.setState(StateReady)
+	return nil
+}
+
+// Stop reports not ready, deregisters the instance, then waits the grace period. A deregistration error is logged
+// and returned after the grace period, as the instance is shutting down regardless.
+func (c *Coordinator) Stop(ctx context.Context) error {
+	c.setState(StateDraining)
+	err := c.registry.Deregister(ctx, c.instance.ID)
+	if err != nil {
+		err = fmt.Errorf("bluegreen: deregister %s: %w", c.instance.ID, err)
+		log.Error().Err(err).Str("instance", c.instance.ID).Msg("Deregistration failed")
+	} else {
+		log.Info().Str("instance", c.instance.ID).Dur("grace", c.opts.grace).Msg("Instance deregistered")
+	}
+
+	if serr := c.sleep(ctx, c.opts.grace); serr != nil && err == nil {
+		err = serr
+	}
+	c.setState(StateDeregistered)
+	return err
+}
+
+// State returns the state of the instance
+func (c *Coordinator) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Ready returns true while the instance is registered and not draining
+func (c *Coordinator) Ready() bool {
+	return c.State() == StateReady
+}
+
+// ReadyHandler responds 200 while ready, otherwise 503, with the state and instance
+func (c *Coordinator) ReadyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s := c.State()
+		code := http.StatusOK
+		if s != StateReady {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.JSON(code, gin.H{"state": s, "instance": c.instance.ID, "color": c.instance.Color})
+	}
+}
+
+func (c *Coordinator) setState(s State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = s
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
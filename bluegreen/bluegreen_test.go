@@ -0,0 +1,74 @@
+package bluegreen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRegistry struct {
+	events []string
+	err    error
+}
+
+func (r *fakeRegistry) Register(ctx context.Context, inst Instance) error {
+	r.events = append(r.events, "register "+inst.ID+" "+inst.Color)
+	return r.err
+}
+
+func (r *fakeRegistry) Deregister(ctx context.Context, id string) error {
+	r.events = append(r.events, "deregister "+id)
+	return r.err
+}
+
+func ready(c *Coordinator) int {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	c.ReadyHandler()(ctx)
+	return w.Code
+}
+
+func TestCoordinator(t *testing.T) {
+	reg := &fakeRegistry{}
+	c := New(reg, Instance{ID: "i1", Color: "green"}, WithReadyDelay(time.Second), WithDeregisterGrace(5*time.Second))
+	var slept []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		reg.events = append(reg.events, "sleep while "+string(c.State()))
+		return nil
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, ready(c))
+
+	assert.NoError(t, c.Start(context.Background()))
+	assert.True(t, c.Ready())
+	assert.Equal(t, http.StatusOK, ready(c))
+
+	assert.NoError(t, c.Stop(context.Background()))
+	assert.Equal(t, StateDeregistered, c.State())
+	assert.Equal(t, http.StatusServiceUnavailable, ready(c))
+	assert.Equal(t, []string{"register i1 green", "sleep while starting", "deregister i1", "sleep while draining"}, reg.events)
+	assert.Equal(t, []time.Duration{time.Second, 5 * time.Second}, slept)
+}
+
+func TestCoordinatorErrors(t *testing.T) {
+	reg := &fakeRegistry{err: errors.New("unavailable")}
+	c := New(reg, Instance{ID: "i1"}, WithDeregisterGrace(0))
+	assert.EqualError(t, c.Start(context.Background()), "bluegreen: register i1: unavailable")
+	assert.False(t, c.Ready())
+	assert.EqualError(t, c.Stop(context.Background()), "bluegreen: deregister i1: unavailable")
+
+	c = New(&fakeRegistry{}, Instance{ID: "i1"}, WithGate(func(ctx context.Context) error { return errors.New("cold") }))
+	assert.EqualError(t, c.Start(context.Background()), "bluegreen: gate failed: cold")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = New(&fakeRegistry{}, Instance{ID: "i1"})
+	assert.ErrorIs(t, c.Stop(ctx), context.Canceled)
+}
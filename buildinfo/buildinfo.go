@@ -0,0 +1,115 @@
+// Build information
+//
+// Exposes the version, commit and build date of the running binary, from linker flags where set, falling back to
+// the module and VCS information embedded by the Go toolchain:
+//
+//	go build -ldflags "-X github.com/redmapletech/ginx/buildinfo.Version=1.2.3"
+//
+// The information can be served from a version endpoint, returned in a response header, and added to every log line:
+//
+//	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithFields(buildinfo.Fields())), buildinfo.Header())
+//	e.GET("/version", buildinfo.Handler())
+package buildinfo
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Set with -ldflags "-X github.com/redmapletech/ginx/buildinfo.<Name>=<value>", overriding embedded VCS information
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// HeaderName is the response header set by Header
+var HeaderName = "X-App-Version"
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	Module    string `json:"module,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information, computed once
+func Get() Info {
+	once.Do(func() {
+		info = read(debug.ReadBuildInfo)
+	})
+	return info
+}
+
+func read(readBuildInfo func() (*debug.BuildInfo, bool)) Info {
+	i := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := readBuildInfo(); ok {
+		i.Module = bi.Main.Path
+		if i.Version == "" && bi.Main.Version != "(devel)" {
+			i.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if i.Commit == "" {
+					i.Commit = s.Value
+				}
+			case "vcs.time":
+				if i.Date == "" {
+					i.Date = s.Value
+				}
+			case "vcs.modified":
+				i.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	return i
+}
+
+// Fields returns the version and commit as log fields, e.g. for zlog.WithFields
+func Fields() map[string]interface{} {
+	i := Get()
+	f := map[string]interface{}{"version": i.Version}
+	if i.Commit != "" {
+		f["commit"] = i.Commit
+	}
+	return f
+}
+
+// Handler serves the build information as JSON
+func Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Get())
+	}
+}
+
+// Header adds the version to every response in the HeaderName header
+func Header() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header(HeaderName, Get().Version)
+	}
+}
@@ -0,0 +1,58 @@
+package buildinfo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead(t *testing.T) {
+	bi := func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.time", Value: "2022-10-01T00:00:00Z"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, true
+	}
+
+	i := read(bi)
+	assert.Equal(t, "v1.2.3", i.Version)
+	assert.Equal(t, "abc123", i.Commit)
+	assert.Equal(t, "2022-10-01T00:00:00Z", i.Date)
+	assert.True(t, i.Modified)
+	assert.Equal(t, "example.com/app", i.Module)
+
+	Version, Commit = "2.0.0", "def456"
+	defer func() { Version, Commit = "", "" }()
+
+	i = read(bi)
+	assert.Equal(t, "2.0.0", i.Version)
+	assert.Equal(t, "def456", i.Commit)
+
+	Version, Commit = "", ""
+	i = read(func() (*debug.BuildInfo, bool) { return nil, false })
+	assert.Equal(t, "dev", i.Version)
+}
+
+func TestHandlers(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(Header())
+	e.GET("/version", Handler())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, Get().Version, w.Header().Get("X-App-Version"))
+	assert.Contains(t, w.Body.String(), `"go_version"`)
+	assert.Equal(t, Get().Version, Fields()["version"])
+}
@@ -0,0 +1,71 @@
+// net/http middleware adapters
+//
+// Converts standard func(http.Handler) http.Handler middleware, as used by chi and most of the net/http ecosystem,
+// into gin middleware, and gin middleware into standard middleware, so either can be reused in the other's stack.
+//
+//	e.Use(zlog.Logger(zerolog.InfoLevel), compat.FromStd(chimw.Compress(5)), handler)
+//	...
+//	mux.Handle("/", compat.ToStd(zlog.Logger(zerolog.InfoLevel), errors.Handler())(legacy))
+//
+// Values added to the request context on either side, such as the zlog logger and request ID, are visible to the
+// other, as both share the request.
+package compat
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FromStd converts standard middleware into gin middleware. The remaining gin handlers run as the next handler of
+// the middleware, with the request it passes on, writing through the response writer it passes on. If the
+// middleware doesn't call the next handler, e.g. to reject the request, the gin chain is aborted.
+//
+// The middleware must call the next handler synchronously, before returning. gin reuses the context once the request
+// completes, so middleware calling it from another goroutine or afterwards, such as http.TimeoutHandler, races with
+// later requests and isn't supported.
+func FromStd(mw func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		orig := ctx.Writer
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			ctx.Request = r
+			if w == http.ResponseWriter(orig) {
+				ctx.Next()
+				return
+			}
+			sw := newStdWriter(w, orig)
+			ctx.Writer = sw
+			ctx.Next()
+			// Headers of responses without a body must also pass through the middleware writer
+			sw.WriteHeaderNow()
+		})
+
+		mw(next).ServeHTTP(orig, ctx.Request)
+		ctx.Writer = orig
+		if !called {
+			ctx.Abort()
+		}
+	}
+}
+
+// ToStd converts gin middleware into standard middleware, running the handlers in order before the next handler.
+// If a handler aborts, the next handler isn't called, and a 404 is written unless the handler wrote a response. The
+// gin context falls back to the request context for values, so ctx.Value works as in standard middleware.
+func ToStd(handlers ...gin.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		e := gin.New()
+		e.ContextWithFallback = true
+		e.HandleMethodNotAllowed = false
+		e.RedirectTrailingSlash = false
+		e.RedirectFixedPath = false
+		e.Use(handlers...)
+		// Every request is unrouted, so the handlers run in the 404 chain, with the status reset for next
+		e.NoRoute(func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+			next.ServeHTTP(ctx.Writer, ctx.Request)
+		})
+		return e
+	}
+}
@@ -0,0 +1,100 @@
+package compat
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+// Standard middleware adding a context value and upper casing the response through its own writer
+func upper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, "std"))
+		next.ServeHTTP(&upperWriter{w}, r)
+	})
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w *upperWriter) Write(p []byte) (int, error) {
+	return w.ResponseWriter.Write(bytes.ToUpper(p))
+}
+
+func TestFromStd(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.TraceLevel), FromStd(upper))
+	e.GET("/", func(ctx *gin.Context) {
+		// Both the zlog request ID and the standard middleware value are available
+		assert.NotEmpty(t, zlog.GetRequestID(ctx))
+		assert.Equal(t, "std", ctx.Request.Context().Value(ctxKey{}))
+		ctx.String(http.StatusCreated, "hello")
+	})
+	e.GET("/empty", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "x")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "HELLO", w.Body.String())
+	assert.Contains(t, buf.String(), `"response":201`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/empty", nil)
+	req.Header.Set("Authorization", "x")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestToStd(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.Nop()
+
+	auth := func(ctx *gin.Context) {
+		if ctx.GetHeader("Authorization") == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": "unauthorized"})
+		}
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(zlog.GetRequestID(r.Context())))
+	})
+	h := ToStd(zlog.Logger(zerolog.InfoLevel), auth)(next)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/any/path", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "x")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, w.Header().Get("X-Request-ID"), w.Body.String())
+	assert.NotEmpty(t, w.Body.String())
+}
@@ -0,0 +1,97 @@
+package compat
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const noWritten = -1
+
+// A gin.ResponseWriter writing through the writer passed to the next handler by net/http middleware, e.g. a
+// compressing writer, tracking status and size as gin does
+type stdWriter struct {
+	w      http.ResponseWriter
+	gin    gin.ResponseWriter // Original writer, for CloseNotify
+	size   int
+	status int
+}
+
+func newStdWriter(w http.ResponseWriter, orig gin.ResponseWriter) *stdWriter {
+	return &stdWriter{w: w, gin: orig, size: noWritten, status: orig.Status()}
+}
+
+func (w *stdWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *stdWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *stdWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.w.WriteHeader(w.status)
+	}
+}
+
+func (w *stdWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.w.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *stdWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	n, err := io.WriteString(w.w, s)
+	w.size += n
+	return n, err
+}
+
+func (w *stdWriter) Status() int {
+	return w.status
+}
+
+func (w *stdWriter) Size() int {
+	return w.size
+}
+
+func (w *stdWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *stdWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return h.Hijack()
+}
+
+func (w *stdWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *stdWriter) CloseNotify() <-chan bool {
+	return w.gin.CloseNotify()
+}
+
+func (w *stdWriter) Pusher() http.Pusher {
+	if p, ok := w.w.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Compressed multipart uploads
+//
+// Reads multipart bodies whose parts are individually gzip-compressed, declared by a Content-Encoding: gzip part
+// header, transparently decompressing them. Decompressed sizes are limited per part and across the whole request,
+// and parts expanding beyond a maximum compression ratio are rejected to guard against decompression bombs.
+//
+//	e.POST("/upload", compressupload.Handle(func(ctx *gin.Context, p *compressupload.Part) error {
+//		_, err := io.Copy(dst, p)
+//		return err
+//	}))
+//
+// Uncompressed parts are passed through, subject to the same size limits.
+package compressupload
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+var (
+	// ErrTooLarge is returned when a part or the request exceeds a decompressed size limit
+	ErrTooLarge = errors.New("compressupload: decompressed size limit exceeded")
+
+	// ErrRatio is returned when a part exceeds the maximum compression ratio
+	ErrRatio = errors.New("compressupload: compression ratio limit exceeded")
+
+	// ErrEncoding is returned for parts with an unsupported or invalid content encoding
+	ErrEncoding = errors.New("compressupload: invalid part encoding")
+)
+
+type readerOpts struct {
+	maxPart  int64 // Maximum decompressed bytes per part
+	maxTotal int64 // Maximum decompressed bytes across all parts
+	maxRatio int64 // Maximum decompressed:compressed ratio, 0 for no limit
+}
+
+// Modifier function for customising reader behaviour
+type ReaderOpts func(*readerOpts) *readerOpts
+
+// WithMaxPartSize sets the maximum decompressed size of a single part, defaults to 32MB
+func WithMaxPartSize(n int64) ReaderOpts {
+	return func(ro *readerOpts) *readerOpts {
+		ro.maxPart = n
+		return ro
+	}
+}
+
+// WithMaxTotalSize sets the maximum decompressed size of all parts together, defaults to 128MB
+func WithMaxTotalSize(n int64) ReaderOpts {
+	return func(ro *readerOpts) *readerOpts {
+		ro.maxTotal = n
+		return ro
+	}
+}
+
+// WithMaxRatio sets the maximum ratio of decompressed to compressed bytes for a part, defaults to 100.
+// Checked once a part has decompressed to at least 1MB, so small highly compressible parts are allowed.
+func WithMaxRatio(n int64) ReaderOpts {
+	return func(ro *readerOpts) *readerOpts {
+		ro.maxRatio = n
+		return ro
+	}
+}
+
+// Reader iterates the parts of a multipart body, decompressing them as required
+type Reader struct {
+	mr    *multipart.Reader
+	opts  *readerOpts
+	total int64
+	part  *Part
+}
+
+// Part is a single decompressed part. Reads return the decompressed content.
+type Part struct {
+	*multipart.Part
+	Encoding string // Original Content-Encoding of the part, empty if uncompressed
+
+	r          *Reader
+	src        *countingReader
+	body       io.Reader
+	gz         *gzip.Reader
+	n          int64
+	err        error
+	ratioCheck int64
+}
+
+// Bytes after which the compression ratio is enforced
+const ratioThreshold = 1 << 20
+
+// NewReader wraps a multipart reader
+func NewReader(mr *multipart.Reader, opts ...ReaderOpts) *Reader {
+	ro := &readerOpts{
+		maxPart:  32 << 20,
+		maxTotal: 128 << 20,
+		maxRatio: 100,
+	}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+	return &Reader{mr: mr, opts: ro}
+}
+
+// FromRequest creates a reader for a multipart/form-data or multipart/mixed request body
+func FromRequest(r *http.Request, opts ...ReaderOpts) (*Reader, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(mr, opts...), nil
+}
+
+// NextPart returns the next part, or io.EOF when there are no more parts
+func (r *Reader) NextPart() (*Part, error) {
+	if r.part != nil {
+		r.part.Close()
+	}
+	mp, err := r.mr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+
+	src := &countingReader{r: mp}
+	p := &Part{Part: mp, r: r, src: src, body: src}
+
+	switch enc := strings.ToLower(strings.TrimSpace(mp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		p.Encoding = enc
+		if p.gz, err = gzip.NewReader(src); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+		// Reject concatenated streams, which would allow unbounded content behind a small first member
+		p.gz.Multistream(false)
+		p.body = p.gz
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrEncoding, enc)
+	}
+	r.part = p
+	return p, nil
+}
+
+// Read reads decompressed content, enforcing the size and ratio limits
+func (p *Part) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.body.Read(b)
+	p.n += int64(n)
+	p.r.total += int64(n)
+
+	switch {
+	case p.n > p.r.opts.maxPart, p.r.total > p.r.opts.maxTotal:
+		p.err = ErrTooLarge
+	case p.gz != nil && p.r.opts.maxRatio > 0 && p.n >= ratioThreshold && p.n > p.src.n*p.r.opts.maxRatio:
+		p.err = ErrRatio
+	case err != nil && err != io.EOF:
+		// Body limit errors are kept, to respond 413 rather than 400
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			p.err = err
+		} else {
+			p.err = fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+	}
+	if p.err != nil {
+		return n, p.err
+	}
+	return n, err
+}
+
+// Close closes the part, discarding any unread content
+func (p *Part) Close() error {
+	if p.gz != nil {
+		p.gz.Close()
+	}
+	return p.Part.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Handle reads all parts of the request, calling fn for each. Limit errors abort with 413, and encoding and malformed
+// multipart errors with 400. Other errors returned by fn, e.g. from storage, abort with 500.
+func Handle(fn func(ctx *gin.Context, p *Part) error, opts ...ReaderOpts) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		r, err := FromRequest(ctx.Request, opts...)
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_multipart") {
+			return
+		}
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				abort(ctx, err, http.StatusBadRequest, "invalid_multipart")
+				return
+			}
+			if err := fn(ctx, p); err != nil {
+				abort(ctx, err, http.StatusInternalServerError, "upload_failed")
+				return
+			}
+		}
+	}
+}
+
+// Abort with the status of a limit or encoding error, or else the given status and code
+func abort(ctx *gin.Context, err error, status int, code string) {
+	switch {
+	case errors.Is(err, ErrTooLarge), errors.Is(err, ErrRatio):
+		zlog.GetLogger(ctx).Warn().Err(err).Msg("Rejected compressed upload")
+		ginxerrors.AbortWithError(ctx, err, http.StatusRequestEntityTooLarge, "upload_too_large")
+	case errors.Is(err, ErrEncoding):
+		ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_encoding")
+	default:
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			ginxerrors.AbortWithError(ctx, err, http.StatusRequestEntityTooLarge, "upload_too_large")
+			return
+		}
+		if status >= http.StatusInternalServerError {
+			zlog.GetLogger(ctx).Error().Err(err).Msg("Upload part handler failed")
+		}
+		ginxerrors.AbortWithError(ctx, err, status, code)
+	}
+}
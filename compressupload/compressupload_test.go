@@ -0,0 +1,161 @@
+package compressupload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type part struct {
+	name     string
+	content  []byte
+	compress bool
+}
+
+func body(t *testing.T, parts ...part) (*bytes.Buffer, string) {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="`+p.name+`"; filename="`+p.name+`"`)
+		content := p.content
+		if p.compress {
+			h.Set("Content-Encoding", "gzip")
+			zb := &bytes.Buffer{}
+			zw := gzip.NewWriter(zb)
+			_, _ = zw.Write(content)
+			_ = zw.Close()
+			content = zb.Bytes()
+		}
+		w, err := mw.CreatePart(h)
+		assert.NoError(t, err)
+		_, _ = w.Write(content)
+	}
+	_ = mw.Close()
+	return buf, mw.FormDataContentType()
+}
+
+func serve(t *testing.T, opts []ReaderOpts, parts ...part) (*httptest.ResponseRecorder, map[string]string) {
+	gin.SetMode(gin.ReleaseMode)
+	got := map[string]string{}
+
+	e := gin.New()
+	e.POST("/", Handle(func(ctx *gin.Context, p *Part) error {
+		b, err := io.ReadAll(p)
+		got[p.FormName()] = string(b)
+		return err
+	}, opts...))
+
+	b, ct := body(t, parts...)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", b)
+	req.Header.Set("Content-Type", ct)
+	e.ServeHTTP(w, req)
+	return w, got
+}
+
+func TestDecompress(t *testing.T) {
+	w, got := serve(t, nil,
+		part{name: "a", content: []byte("plain")},
+		part{name: "b", content: []byte("compressed"), compress: true},
+	)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, map[string]string{"a": "plain", "b": "compressed"}, got)
+}
+
+func TestPartLimit(t *testing.T) {
+	w, _ := serve(t, []ReaderOpts{WithMaxPartSize(10)},
+		part{name: "a", content: []byte(strings.Repeat("x", 100)), compress: true},
+	)
+
+	assert.Equal(t, 413, w.Code)
+	assert.Contains(t, w.Body.String(), "upload_too_large")
+}
+
+func TestTotalLimit(t *testing.T) {
+	w, got := serve(t, []ReaderOpts{WithMaxTotalSize(15)},
+		part{name: "a", content: []byte("0123456789")},
+		part{name: "b", content: []byte("0123456789"), compress: true},
+	)
+
+	assert.Equal(t, 413, w.Code)
+	assert.Equal(t, "0123456789", got["a"])
+}
+
+func TestRatioLimit(t *testing.T) {
+	w, _ := serve(t, []ReaderOpts{WithMaxRatio(10)},
+		part{name: "a", content: bytes.Repeat([]byte{0}, 4<<20), compress: true},
+	)
+
+	assert.Equal(t, 413, w.Code)
+}
+
+func TestInvalidEncoding(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("/", Handle(func(ctx *gin.Context, p *Part) error {
+		_, err := io.ReadAll(p)
+		return err
+	}))
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", `form-data; name="a"`)
+	h.Set("Content-Encoding", "br")
+	pw, _ := mw.CreatePart(h)
+	_, _ = pw.Write([]byte("x"))
+	_ = mw.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_encoding")
+}
+
+func TestErrorStatus(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("/", Handle(func(ctx *gin.Context, p *Part) error {
+		if _, err := io.ReadAll(p); err != nil {
+			return err
+		}
+		return errors.New("storage unavailable")
+	}))
+	send := func(b io.Reader, ct string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", b)
+		req.Header.Set("Content-Type", ct)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// Truncated multipart body
+	w := send(strings.NewReader("--b\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nx"), "multipart/form-data; boundary=b")
+	assert.Equal(t, 400, w.Code)
+
+	// Malformed part headers
+	w = send(strings.NewReader("--b\r\nnot a header\r\n\r\nx\r\n--b--\r\n"), "multipart/form-data; boundary=b")
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_multipart")
+
+	// Handler failure
+	b, ct := body(t, part{name: "a", content: []byte("x")})
+	w = send(b, ct)
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), "upload_failed")
+}
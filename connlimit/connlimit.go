@@ -0,0 +1,237 @@
+// Per-client concurrency limits
+//
+// Caps the number of concurrent connections and in-flight requests per client IP, protecting against
+// slowloris style resource exhaustion where a few clients hold open many slow connections.
+//
+// A Limiter can be enforced at the listener, closing excess connections as they are accepted, and/or as
+// middleware, rejecting excess requests with 429:
+//
+//	conns := connlimit.New(20)
+//	srv.Serve(conns.Listener(l))
+//
+//	e.Use(connlimit.New(10, connlimit.WithBurst(5, time.Second)).Middleware())
+//
+// Note the listener sees the immediate peer address, which is the load balancer when behind one. The
+// middleware uses ctx.ClientIP(), so respects gin's trusted proxy configuration.
+package connlimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/internal/ipnets"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+type limitOpts struct {
+	burst       int           // Additional concurrency allowed for short periods
+	burstWindow time.Duration // How long a client may stay above the limit
+	status      int           // Status code for rejected requests
+	exempt      []*net.IPNet  // Clients not subject to the limit
+}
+
+// Modifier function for customising limiter behaviour
+type LimitOpts func(*limitOpts) *limitOpts
+
+type client struct {
+	active    int
+	overSince time.Time // When the client went above the base limit, zero if not above
+}
+
+// Limiter tracks concurrent use per client IP
+type Limiter struct {
+	max  int
+	opts *limitOpts
+	now  func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+// New creates a limiter allowing max concurrent connections or requests per client IP
+func New(max int, opts ...LimitOpts) *Limiter {
+	lo := &limitOpts{
+		status: http.StatusTooManyRequests,
+	}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+	return &Limiter{
+		max:     max,
+		opts:    lo,
+		now:     time.Now,
+		clients: map[string]*client{},
+	}
+}
+
+// Acquire takes a slot for the IP, returning false if the client is at its limit. The release function must
+// be called exactly once when the slot is no longer in use.
+func (l *Limiter) Acquire(ip string) (release func(), ok bool) {
+	if l.isExempt(ip) {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.clients[ip]
+	if c == nil {
+		c = &client{}
+		l.clients[ip] = c
+	}
+
+	if c.active >= l.max {
+		now := l.now()
+		inWindow := c.overSince.IsZero() || now.Sub(c.overSince) < l.opts.burstWindow
+		if c.active >= l.max+l.opts.burst || !inWindow {
+			if c.active == 0 {
+				delete(l.clients, ip)
+			}
+			return nil, false
+		}
+		if c.overSince.IsZero() {
+			c.overSince = now
+		}
+	}
+	c.active++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(ip) })
+	}, true
+}
+
+func (l *Limiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.clients[ip]
+	if c == nil {
+		return
+	}
+	c.active--
+	if c.active <= l.max {
+		c.overSince = time.Time{}
+	}
+	if c.active <= 0 {
+		delete(l.clients, ip)
+	}
+}
+
+// Active returns the number of slots currently held by the IP
+func (l *Limiter) Active(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c := l.clients[ip]; c != nil {
+		return c.active
+	}
+	return 0
+}
+
+func (l *Limiter) isExempt(ip string) bool {
+	return len(l.opts.exempt) > 0 && ipnets.Contains(l.opts.exempt, ip)
+}
+
+// Middleware limits concurrent in-flight requests per client IP, aborting excess requests
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip := ctx.ClientIP()
+		release, ok := l.Acquire(ip)
+		if !ok {
+			zlog.GetLogger(ctx).Warn().Str("ip", ip).Int("limit", l.max).Msg("Concurrent request limit reached")
+			errors.AbortWith(ctx, l.opts.status, "too_many_requests")
+			return
+		}
+		defer release()
+		ctx.Next()
+	}
+}
+
+// Listener wraps a listener to limit concurrent connections per peer IP. Excess connections are closed
+// immediately after being accepted.
+func (l *Limiter) Listener(nl net.Listener) net.Listener {
+	return &listener{Listener: nl, limiter: l}
+}
+
+type listener struct {
+	net.Listener
+	limiter *Limiter
+}
+
+func (ln *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := peerIP(conn.RemoteAddr())
+		release, ok := ln.limiter.Acquire(ip)
+		if !ok {
+			zlog.GetLogger(context.Background()).Warn().Str("ip", ip).Int("limit", ln.limiter.max).Msg("Concurrent connection limit reached")
+			conn.Close()
+			continue
+		}
+		return &limitedConn{Conn: conn, release: release}, nil
+	}
+}
+
+func peerIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	c.release()
+	return c.Conn.Close()
+}
+
+// WithBurst allows clients to exceed the limit by up to burst for at most window. Once a client has been
+// above the limit for the window, further excess is refused until it drops back to the limit.
+func WithBurst(burst int, window time.Duration) LimitOpts {
+	return func(lo *limitOpts) *limitOpts {
+		lo.burst = burst
+		lo.burstWindow = window
+		return lo
+	}
+}
+
+// WithStatus sets the status code of rejected requests, defaults to 429
+func WithStatus(status int) LimitOpts {
+	return func(lo *limitOpts) *limitOpts {
+		lo.status = status
+		return lo
+	}
+}
+
+// WithExempt excludes clients in the IPs or CIDR ranges from the limit, e.g. internal health checkers.
+// Panics on an entry that isn't an IP or CIDR range, such as a hostname.
+func WithExempt(cidrs ...string) LimitOpts {
+	nets, err := ipnets.Parse(cidrs...)
+	if err != nil {
+		panic("connlimit: invalid exempt range: " + err.Error())
+	}
+	return func(lo *limitOpts) *limitOpts {
+		lo.exempt = append(lo.exempt, nets...)
+		return lo
+	}
+}
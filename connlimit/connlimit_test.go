@@ -0,0 +1,144 @@
+package connlimit
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	l := New(2)
+
+	r1, ok := l.Acquire("1.2.3.4")
+	assert.True(t, ok)
+	r2, ok := l.Acquire("1.2.3.4")
+	assert.True(t, ok)
+	_, ok = l.Acquire("1.2.3.4")
+	assert.False(t, ok)
+
+	// Other clients unaffected
+	_, ok = l.Acquire("5.6.7.8")
+	assert.True(t, ok)
+
+	r1()
+	r1()
+	assert.Equal(t, 1, l.Active("1.2.3.4"))
+	_, ok = l.Acquire("1.2.3.4")
+	assert.True(t, ok)
+	r2()
+}
+
+func TestBurst(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(1, WithBurst(2, time.Second))
+	l.now = func() time.Time { return now }
+
+	l.Acquire("ip")
+	r2, ok := l.Acquire("ip")
+	assert.True(t, ok)
+
+	// Burst window expires while still above the limit
+	now = now.Add(2 * time.Second)
+	_, ok = l.Acquire("ip")
+	assert.False(t, ok)
+
+	// Window resets once back at the limit
+	r2()
+	r2, ok = l.Acquire("ip")
+	assert.True(t, ok)
+	_, ok = l.Acquire("ip")
+	assert.True(t, ok)
+	_, ok = l.Acquire("ip")
+	assert.False(t, ok)
+}
+
+func TestExempt(t *testing.T) {
+	l := New(0, WithExempt("10.0.0.0/8"))
+
+	_, ok := l.Acquire("10.1.1.1")
+	assert.True(t, ok)
+	_, ok = l.Acquire("11.1.1.1")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { WithExempt("10.0.0.0/8", "10.0.0.0/") })
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	l := New(1)
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	e := gin.New()
+	e.GET("", l.Middleware(), func(ctx *gin.Context) {
+		close(entered)
+		<-unblock
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "1.2.3.4:1000"
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1001"
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"code":"too_many_requests"}`, w.Body.String())
+
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, 0, l.Active("1.2.3.4"))
+}
+
+func TestListener(t *testing.T) {
+	nl, _ := net.Listen("tcp", "127.0.0.1:0")
+	l := New(1)
+	ln := l.Listener(nl)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	c1, _ := net.Dial("tcp", nl.Addr().String())
+	defer c1.Close()
+	server1 := <-accepted
+
+	// Second connection from the same IP is closed by the server
+	c2, _ := net.Dial("tcp", nl.Addr().String())
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := c2.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Len(t, accepted, 0)
+
+	server1.Close()
+	assert.Equal(t, 0, l.Active("127.0.0.1"))
+
+	c3, _ := net.Dial("tcp", nl.Addr().String())
+	defer c3.Close()
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted after slot released")
+	}
+}
@@ -0,0 +1,255 @@
+// CORS middleware
+//
+// Cross-origin resource sharing with origin allowlists and wildcard patterns, credentials and preflight caching.
+// Rejected origins, methods and headers are logged through the request logger and respond 403 through the errors
+// package, so they show up in logs rather than only in the browser console.
+//
+//	api := e.Group("/api", cors.New(
+//		cors.WithOrigins("https://app.example.com", "https://*.preview.example.com"),
+//		cors.WithCredentials(true),
+//	))
+//	public := e.Group("/public", cors.New(cors.WithOrigins("*")))
+//
+// Each group can have its own configuration by using separate middleware. As gin only runs group middleware for
+// matched routes, preflight requests need an OPTIONS route in the group unless the middleware is used on the
+// engine. Requests without an Origin header, or from the same origin, pass through unchanged. Behind a TLS
+// terminating proxy, use WithTrustedProxies so same-origin requests are recognised from X-Forwarded-Proto.
+package cors
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/internal/ipnets"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+type corsOpts struct {
+	all         bool                     // Any origin allowed
+	origins     map[string]bool          // Exact origins allowed
+	patterns    [][2]string              // Origin prefix and suffix around a wildcard
+	originFunc  func(origin string) bool // Additional origin check
+	methods     []string
+	headers     []string
+	expose      []string
+	credentials bool
+	maxAge      time.Duration
+	trusted     []*net.IPNet // Peers whose X-Forwarded-Proto is honoured
+}
+
+// Modifier function for customising CORS behaviour
+type CORSOpts func(*corsOpts) *corsOpts
+
+// WithOrigins allows the origins, e.g. https://app.example.com. An origin may contain one * wildcard, e.g.
+// https://*.example.com, and * alone allows any origin.
+func WithOrigins(origins ...string) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		for _, o := range origins {
+			o = strings.ToLower(strings.TrimSuffix(o, "/"))
+			switch i := strings.IndexByte(o, '*'); {
+			case o == "*":
+				co.all = true
+			case i >= 0:
+				co.patterns = append(co.patterns, [2]string{o[:i], o[i+1:]})
+			default:
+				co.origins[o] = true
+			}
+		}
+		return co
+	}
+}
+
+// WithOriginFunc allows origins for which fn returns true, in addition to WithOrigins
+func WithOriginFunc(fn func(origin string) bool) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.originFunc = fn
+		return co
+	}
+}
+
+// WithMethods sets the methods allowed in preflight requests (default GET, HEAD, POST, PUT, PATCH, DELETE)
+func WithMethods(methods ...string) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.methods = methods
+		return co
+	}
+}
+
+// WithHeaders sets the request headers allowed in preflight requests (default Accept, Authorization, Content-Type,
+// X-Request-ID). Simple headers are always allowed.
+func WithHeaders(headers ...string) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.headers = headers
+		return co
+	}
+}
+
+// WithExposeHeaders sets the response headers readable by the client, beyond the simple response headers
+func WithExposeHeaders(headers ...string) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.expose = headers
+		return co
+	}
+}
+
+// WithCredentials allows cookies and HTTP authentication on cross-origin requests. It can't be combined with the *
+// origin, as any site could then make authenticated requests; New panics if both are given.
+func WithCredentials(allow bool) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.credentials = allow
+		return co
+	}
+}
+
+// WithTrustedProxies honours X-Forwarded-Proto from peers within the IPs or CIDR ranges when detecting same-origin
+// requests. Panics if an entry is neither an IP nor a CIDR range.
+func WithTrustedProxies(entries ...string) CORSOpts {
+	nets, err := ipnets.Parse(entries...)
+	if err != nil {
+		panic("cors: invalid trusted proxy: " + err.Error())
+	}
+	return func(co *corsOpts) *corsOpts {
+		co.trusted = append(co.trusted, nets...)
+		return co
+	}
+}
+
+// WithMaxAge sets how long browsers may cache preflight results (default 10 minutes)
+func WithMaxAge(d time.Duration) CORSOpts {
+	return func(co *corsOpts) *corsOpts {
+		co.maxAge = d
+		return co
+	}
+}
+
+func (co *corsOpts) allowed(origin string) bool {
+	if co.all {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if co.origins[o] {
+		return true
+	}
+	for _, p := range co.patterns {
+		if len(o) > len(p[0])+len(p[1]) && strings.HasPrefix(o, p[0]) && strings.HasSuffix(o, p[1]) {
+			return true
+		}
+	}
+	return co.originFunc != nil && co.originFunc(origin)
+}
+
+// Simple request headers, which never need to be allowed
+var simpleHeaders = map[string]bool{"Accept": true, "Accept-Language": true, "Content-Language": true, "Content-Type": true}
+
+// New creates CORS middleware from options. With no origins given, no cross-origin requests are allowed.
+// Panics if credentials are allowed for any origin.
+func New(opts ...CORSOpts) gin.HandlerFunc {
+	co := &corsOpts{
+		origins: map[string]bool{},
+		methods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		headers: []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
+		maxAge:  10 * time.Minute,
+	}
+	for _, f := range opts {
+		co = f(co)
+	}
+	if co.all && co.credentials {
+		panic("cors: invalid configuration: credentials can't be allowed for any origin")
+	}
+
+	methods := map[string]bool{}
+	for _, m := range co.methods {
+		methods[strings.ToUpper(m)] = true
+	}
+	headers := map[string]bool{}
+	for _, h := range co.headers {
+		headers[http.CanonicalHeaderKey(h)] = true
+	}
+	allowMethods := strings.Join(co.methods, ", ")
+	allowHeaders := strings.Join(co.headers, ", ")
+	expose := strings.Join(co.expose, ", ")
+	maxAge := strconv.Itoa(int(co.maxAge.Seconds()))
+
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if origin == "" || co.sameOrigin(ctx.Request, origin) {
+			return
+		}
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+
+		h := ctx.Writer.Header()
+		h.Add("Vary", "Origin")
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !co.allowed(origin) {
+			reject(ctx, origin, "origin", "cors_origin_not_allowed")
+			return
+		}
+		if co.all {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if co.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if expose != "" {
+				h.Set("Access-Control-Expose-Headers", expose)
+			}
+			return
+		}
+
+		if method := ctx.GetHeader("Access-Control-Request-Method"); !methods[strings.ToUpper(method)] {
+			reject(ctx, origin, "method "+method, "cors_method_not_allowed")
+			return
+		}
+		for _, name := range strings.Split(ctx.GetHeader("Access-Control-Request-Headers"), ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !headers[name] && !simpleHeaders[name] {
+				reject(ctx, origin, "header "+name, "cors_header_not_allowed")
+				return
+			}
+		}
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if co.maxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func reject(ctx *gin.Context, origin, what, code string) {
+	zlog.GetLogger(ctx).Warn().
+		Str("origin", origin).
+		Str("rejected", what).
+		Msg("CORS request rejected")
+	errors.AbortWith(ctx, http.StatusForbidden, code)
+}
+
+// Report whether the origin is the request's own, which browsers may send on same-origin POSTs
+func (co *corsOpts) sameOrigin(r *http.Request, origin string) bool {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if len(co.trusted) > 0 && ipnets.Contains(co.trusted, r.RemoteAddr) {
+		// The first value is set by the proxy nearest the client
+		if proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ","); proto != "" {
+			scheme = strings.ToLower(strings.TrimSpace(proto))
+		}
+	}
+	return strings.EqualFold(origin, scheme+"://"+r.Host)
+}
@@ -0,0 +1,124 @@
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(e *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	api := e.Group("/api", New(
+		WithOrigins("https://app.example.com", "https://*.preview.example.com"),
+		WithCredentials(true),
+		WithExposeHeaders("X-Request-ID"),
+	))
+	api.GET("/users", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+	api.OPTIONS("/users", func(*gin.Context) {})
+	pub := e.Group("/public", New(WithOrigins("*"), WithMaxAge(0)))
+	pub.GET("/info", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+	pub.OPTIONS("/info", func(*gin.Context) {})
+
+	// No origin or same origin
+	w := request(e, http.MethodGet, "/api/users", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Vary"))
+	w = request(e, http.MethodGet, "/api/users", map[string]string{"Origin": "http://example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request(e, http.MethodGet, "/api/users", map[string]string{"Origin": "https://app.example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	w = request(e, http.MethodGet, "/api/users", map[string]string{"Origin": "https://pr-12.preview.example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, origin := range []string{"https://evil.com", "https://.preview.example.com", "https://app.example.com.evil.com"} {
+		buf.Reset()
+		w = request(e, http.MethodGet, "/api/users", map[string]string{"Origin": origin})
+		assert.Equal(t, http.StatusForbidden, w.Code, origin)
+		assert.Contains(t, w.Body.String(), `"code":"cors_origin_not_allowed"`)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, buf.String(), `"origin":"`+origin+`"`)
+	}
+
+	preflight := map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	}
+	w = request(e, http.MethodOptions, "/api/users", preflight)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	preflight["Access-Control-Request-Method"] = "TRACE"
+	w = request(e, http.MethodOptions, "/api/users", preflight)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "cors_method_not_allowed")
+
+	preflight["Access-Control-Request-Method"] = "GET"
+	preflight["Access-Control-Request-Headers"] = "X-Secret"
+	w = request(e, http.MethodOptions, "/api/users", preflight)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "cors_header_not_allowed")
+
+	// Per-group configuration
+	w = request(e, http.MethodGet, "/public/info", map[string]string{"Origin": "https://evil.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	w = request(e, http.MethodOptions, "/public/info", map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestCORSConfig(t *testing.T) {
+	assert.Panics(t, func() { New(WithOrigins("*"), WithCredentials(true)) })
+	assert.Panics(t, func() { WithTrustedProxies("nope") })
+	assert.NotPanics(t, func() { New(WithOrigins("*"), WithCredentials(false)) })
+}
+
+func TestSameOriginForwarded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/", New(WithTrustedProxies("10.0.0.0/8")), func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+
+	serve := func(peer, proto string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/", nil)
+		req.TLS = nil
+		req.RemoteAddr = peer
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("X-Forwarded-Proto", proto)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// TLS terminated by a trusted proxy
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1:1000", "https").Code)
+	// The header is ignored from other peers
+	assert.Equal(t, http.StatusForbidden, serve("1.2.3.4:1000", "https").Code)
+}
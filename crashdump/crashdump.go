@@ -0,0 +1,257 @@
+// Crash diagnostics
+//
+// Captures a diagnostics bundle on disk when a handler panics, containing the panic value and stack, a dump of all
+// goroutines, a heap profile and the most recent requests, so the state at the time of a crash can be inspected
+// after the process has moved on.
+//
+//	ring := crashdump.NewRing(100)
+//	d := crashdump.New("/var/lib/app/crash", crashdump.WithRequests(ring), crashdump.WithRetention(10, 7*24*time.Hour))
+//	e.Use(zlog.Logger(zerolog.InfoLevel), ring.Middleware(), zlog.Recovery(zlog.WithPanicHook(d.Capture)))
+//
+// Each bundle is a directory named crash-<UTC timestamp>. Bundles are written at most once per minimum interval to
+// avoid filling the disk during a panic storm, and the oldest are removed beyond the retention limits.
+package crashdump
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog/log"
+)
+
+// Prefix of bundle directory names
+const bundlePrefix = "crash-"
+
+// Layout of the bundle timestamp, sortable by name
+const timeLayout = "20060102T150405.000000000Z"
+
+type dumperOpts struct {
+	goroutines  bool
+	heap        bool
+	requests    *Ring
+	maxBundles  int
+	maxAge      time.Duration
+	minInterval time.Duration
+	clock       func() time.Time
+}
+
+// Modifier function for customising dumper behaviour
+type DumperOpts func(*dumperOpts) *dumperOpts
+
+// WithGoroutines sets whether a dump of all goroutine stacks is included (default true)
+func WithGoroutines(enabled bool) DumperOpts {
+	return func(do *dumperOpts) *dumperOpts {
+		do.goroutines = enabled
+		return do
+	}
+}
+
+// WithHeap sets whether a heap profile is included, readable with go tool pprof (default true)
+func WithHeap(enabled bool) DumperOpts {
+	return func(do *dumperOpts) *dumperOpts {
+		do.heap = enabled
+		return do
+	}
+}
+
+// WithRequests includes the recent requests recorded by the ring
+func WithRequests(ring *Ring) DumperOpts {
+	return func(do *dumperOpts) *dumperOpts {
+		do.requests = ring
+		return do
+	}
+}
+
+// WithRetention sets the maximum number of bundles kept and the maximum age of bundles, 0 for no limit
+// (default 10 bundles, no age limit)
+func WithRetention(maxBundles int, maxAge time.Duration) DumperOpts {
+	return func(do *dumperOpts) *dumperOpts {
+		do.maxBundles = maxBundles
+		do.maxAge = maxAge
+		return do
+	}
+}
+
+// WithMinInterval sets the minimum time between bundles, later panics within the interval are only logged
+// (default 1 minute)
+func WithMinInterval(d time.Duration) DumperOpts {
+	return func(do *dumperOpts) *dumperOpts {
+		do.minInterval = d
+		return do
+	}
+}
+
+// Dumper writes diagnostics bundles to a directory
+type Dumper struct {
+	dir  string
+	opts *dumperOpts
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// New creates a dumper writing bundles to the directory, which is created if needed
+func New(dir string, opts ...DumperOpts) *Dumper {
+	do := &dumperOpts{
+		goroutines:  true,
+		heap:        true,
+		maxBundles:  10,
+		minInterval: time.Minute,
+		clock:       time.Now,
+	}
+	for _, f := range opts {
+		do = f(do)
+	}
+	return &Dumper{dir: dir, opts: do}
+}
+
+// Capture writes a bundle for a recovered panic, logging the bundle path. Its signature matches zlog.PanicHook.
+func (d *Dumper) Capture(ctx *gin.Context, v interface{}) {
+	logger := zlog.GetLogger(ctx)
+	path, err := d.Write(ctx, v)
+	switch {
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to write crash diagnostics")
+	case path == "":
+		logger.Debug().Msg("Crash diagnostics skipped, within minimum interval")
+	default:
+		logger.Warn().Str("path", path).Msg("Wrote crash diagnostics")
+	}
+}
+
+// Write writes a bundle for the panic value, returning its path. The request may be nil. Returns an empty path if
+// a bundle was written within the minimum interval.
+func (d *Dumper) Write(ctx *gin.Context, v interface{}) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.opts.clock().UTC()
+	if !d.last.IsZero() && now.Sub(d.last) < d.opts.minInterval {
+		return "", nil
+	}
+	d.last = now
+
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return "", err
+	}
+	// Write to a temporary directory first, so partial bundles aren't mistaken for complete ones
+	tmp, err := os.MkdirTemp(d.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	if err := d.writeBundle(tmp, ctx, v, now); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	path := filepath.Join(d.dir, bundlePrefix+now.Format(timeLayout))
+	if err := os.Rename(tmp, path); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+
+	if err := d.prune(now); err != nil {
+		log.Warn().Err(err).Str("dir", d.dir).Msg("Failed to prune crash diagnostics")
+	}
+	return path, nil
+}
+
+func (d *Dumper) writeBundle(dir string, ctx *gin.Context, v interface{}, now time.Time) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "panic: %v\n", v)
+	if ctx != nil && ctx.Request != nil {
+		fmt.Fprintf(&b, "request: %s %s\n", ctx.Request.Method, ctx.Request.URL.Path)
+		if id := zlog.GetRequestID(ctx); id != "" {
+			fmt.Fprintf(&b, "request_id: %s\n", id)
+		}
+	}
+	fmt.Fprintf(&b, "\n%s", debug.Stack())
+	if err := os.WriteFile(filepath.Join(dir, "panic.txt"), []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+
+	if d.opts.goroutines {
+		if err := writeProfile(filepath.Join(dir, "goroutines.txt"), "goroutine", 2); err != nil {
+			return err
+		}
+	}
+	if d.opts.heap {
+		if err := writeProfile(filepath.Join(dir, "heap.pprof"), "heap", 0); err != nil {
+			return err
+		}
+	}
+	if d.opts.requests != nil {
+		data, err := json.MarshalIndent(d.opts.requests.Snapshot(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "requests.json"), data, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeProfile(path, name string, level int) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(name).WriteTo(f, level); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Bundles returns the paths of the bundles in the directory, oldest first
+func (d *Dumper) Bundles() ([]string, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var res []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), bundlePrefix) {
+			res = append(res, filepath.Join(d.dir, e.Name()))
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Remove bundles beyond the retention limits, oldest first
+func (d *Dumper) prune(now time.Time) error {
+	bundles, err := d.Bundles()
+	if err != nil {
+		return err
+	}
+	for i, path := range bundles {
+		remaining := len(bundles) - i
+		expired := false
+		if d.opts.maxAge > 0 {
+			t, err := time.Parse(timeLayout, strings.TrimPrefix(filepath.Base(path), bundlePrefix))
+			expired = err == nil && now.Sub(t) > d.opts.maxAge
+		}
+		if !expired && (d.opts.maxBundles <= 0 || remaining <= d.opts.maxBundles) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package crashdump
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.Nop()
+	dir := t.TempDir()
+
+	ring := NewRing(2)
+	d := New(dir, WithRequests(ring), WithMinInterval(0))
+
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel), ring.Middleware(), zlog.Recovery(zlog.WithPanicHook(d.Capture)))
+	e.GET("/ok", func(ctx *gin.Context) {})
+	e.GET("/boom", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	for _, p := range []string{"/ok", "/ok", "/ok", "/boom"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	bundles, err := d.Bundles()
+	assert.NoError(t, err)
+	assert.Len(t, bundles, 1)
+
+	data, err := os.ReadFile(filepath.Join(bundles[0], "panic.txt"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "panic: boom")
+	assert.Contains(t, string(data), "request: GET /boom")
+
+	data, err = os.ReadFile(filepath.Join(bundles[0], "goroutines.txt"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "goroutine ")
+	assert.FileExists(t, filepath.Join(bundles[0], "heap.pprof"))
+
+	// The panicking request completes after the bundle is written
+	var reqs []Request
+	data, err = os.ReadFile(filepath.Join(bundles[0], "requests.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &reqs))
+	assert.Len(t, reqs, 2)
+	assert.Equal(t, "/ok", reqs[1].Path)
+	assert.Equal(t, 200, reqs[1].Status)
+
+	snap := ring.Snapshot()
+	assert.Equal(t, "/boom", snap[1].Path)
+	assert.Equal(t, 500, snap[1].Status)
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	d := New(dir, WithGoroutines(false), WithHeap(false), WithRetention(2, time.Hour), WithMinInterval(time.Minute))
+	d.opts.clock = func() time.Time { return now }
+
+	path, err := d.Write(nil, "first")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, path)
+
+	// Within the minimum interval
+	path, err = d.Write(nil, "skipped")
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		now = now.Add(2 * time.Minute)
+		path, err := d.Write(nil, i)
+		assert.NoError(t, err)
+		paths = append(paths, path)
+	}
+	bundles, _ := d.Bundles()
+	assert.Equal(t, paths[1:], bundles)
+
+	now = now.Add(time.Hour - time.Minute)
+	path, _ = d.Write(nil, "late")
+	bundles, _ = d.Bundles()
+	assert.Equal(t, []string{paths[2], path}, bundles)
+}
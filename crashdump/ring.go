@@ -0,0 +1,75 @@
+package crashdump
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Request is a summary of a completed request. Query strings and bodies aren't recorded.
+type Request struct {
+	Time     time.Time     `json:"time"`
+	ID       string        `json:"id,omitempty"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Route    string        `json:"route,omitempty"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Ring keeps the most recent requests, for inclusion in diagnostics bundles
+type Ring struct {
+	mu   sync.Mutex
+	buf  []Request
+	next int
+	full bool
+}
+
+// NewRing creates a ring keeping the last size requests
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{buf: make([]Request, size)}
+}
+
+// Add records a request, replacing the oldest if the ring is full
+func (r *Ring) Add(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = req
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot returns the recorded requests, oldest first
+func (r *Ring) Snapshot() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Request{}, r.buf[:r.next]...)
+	}
+	return append(append([]Request{}, r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+// Middleware records each request once later handlers have completed, including those that panic.
+// Use before zlog.Recovery() so the final status of recovered requests is recorded.
+func (r *Ring) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		r.Add(Request{
+			Time:     start,
+			ID:       zlog.GetRequestID(ctx),
+			Method:   ctx.Request.Method,
+			Path:     ctx.Request.URL.Path,
+			Route:    ctx.FullPath(),
+			Status:   ctx.Writer.Status(),
+			Duration: time.Since(start),
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// Scheduled jobs
+//
+// Runs registered jobs on cron schedules, with overlap protection, optional jitter and a run ID per run on the job
+// logger, plus admin handlers to list jobs and trigger them manually.
+//
+//	s := cron.New(cron.WithJitter(30 * time.Second))
+//	s.MustAdd("cleanup", "0 3 * * *", cleanup)
+//	go s.Run(ctx)
+//	admin.GET("/jobs", s.ListHandler())
+//	admin.POST("/jobs/:name", s.TriggerHandler())
+//
+// Jobs receive a context carrying a logger with job and run_id fields, retrieved with zlog.GetLogger. A run is
+// skipped if the previous run of the job is still in progress.
+package cron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog/log"
+)
+
+// Job is a scheduled function. Returned errors are logged and reported in the job status.
+type Job func(ctx context.Context) error
+
+// Status is the state of a job
+type Status struct {
+	Name      string        `json:"name"`
+	Schedule  string        `json:"schedule"`
+	Next      time.Time     `json:"next"`
+	Running   bool          `json:"running"`
+	Runs      uint64        `json:"runs"`
+	Skipped   uint64        `json:"skipped"` // Runs skipped as the previous run was in progress
+	LastStart time.Time     `json:"last_start,omitempty"`
+	LastRun   time.Duration `json:"last_run,omitempty"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+type schedulerOpts struct {
+	jitter   time.Duration
+	location *time.Location
+}
+
+// Modifier function for customising scheduler behaviour
+type SchedulerOpts func(*schedulerOpts) *schedulerOpts
+
+// WithJitter delays each scheduled run by a random duration up to max, spreading load across instances
+func WithJitter(max time.Duration) SchedulerOpts {
+	return func(so *schedulerOpts) *schedulerOpts {
+		so.jitter = max
+		return so
+	}
+}
+
+// WithLocation sets the time zone schedules are evaluated in (default UTC)
+func WithLocation(loc *time.Location) SchedulerOpts {
+	return func(so *schedulerOpts) *schedulerOpts {
+		so.location = loc
+		return so
+	}
+}
+
+type job struct {
+	name     string
+	expr     string
+	schedule Schedule
+	fn       Job
+	status   Status
+}
+
+// Scheduler runs jobs on their schedules
+type Scheduler struct {
+	opts *schedulerOpts
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	changed chan struct{} // Signals Run to recompute the next wake up
+	wg      sync.WaitGroup
+	ctx     context.Context // Context of Run, for triggered runs
+}
+
+// New creates a scheduler
+func New(opts ...SchedulerOpts) *Scheduler {
+	so := &schedulerOpts{location: time.UTC}
+	for _, f := range opts {
+		so = f(so)
+	}
+	return &Scheduler{opts: so, jobs: map[string]*job{}, changed: make(chan struct{}, 1), ctx: context.Background()}
+}
+
+// Add registers a job to run on the cron expression, see Parse. Names must be unique.
+func (s *Scheduler) Add(name, expr string, fn Job) error {
+	schedule, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("cron: job %s already added", name)
+	}
+	j := &job{name: name, expr: expr, schedule: schedule, fn: fn}
+	j.status = Status{Name: name, Schedule: expr, Next: schedule.Next(time.Now().In(s.opts.location))}
+	s.jobs[name] = j
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// MustAdd is as Add, but panics on error, for static registration
+func (s *Scheduler) MustAdd(name, expr string, fn Job) {
+	if err := s.Add(name, expr, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Run runs jobs as they become due until the context is cancelled, then waits for running jobs to finish. Jobs
+// receive a context derived from ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		now := time.Now().In(s.opts.location)
+		wake := now.Add(time.Hour)
+
+		s.mu.Lock()
+		for _, j := range s.jobs {
+			// A zero next time means the schedule never activates again
+			if !j.status.Next.IsZero() && !j.status.Next.After(now) {
+				s.start(ctx, j, s.delay())
+				j.status.Next = j.schedule.Next(now)
+			}
+			if !j.status.Next.IsZero() && j.status.Next.Before(wake) {
+				wake = j.status.Next
+			}
+		}
+		s.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-timer.C:
+		case <-s.changed:
+		}
+	}
+}
+
+func (s *Scheduler) delay() time.Duration {
+	if s.opts.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(mrand.Int63n(int64(s.opts.jitter)))
+}
+
+// Trigger runs a job immediately, outside its schedule. Returns an error if the job doesn't exist or is running.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if j.status.Running {
+		return ErrRunning
+	}
+	s.start(s.ctx, j, 0)
+	return nil
+}
+
+var (
+	ErrNotFound = fmt.Errorf("cron: job not found")
+	ErrRunning  = fmt.Errorf("cron: job already running")
+)
+
+// Start a run of the job after the delay, skipping it if already running. Called with the lock held.
+func (s *Scheduler) start(ctx context.Context, j *job, delay time.Duration) {
+	if j.status.Running {
+		j.status.Skipped++
+		log.Warn().Str("job", j.name).Msg("Skipping job run, previous run in progress")
+		return
+	}
+	j.status.Running = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				s.mu.Lock()
+				j.status.Running = false
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.run(ctx, j)
+	}()
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	logger := log.Logger.With().Str("job", j.name).Str("run_id", newRunID()).Logger()
+	ctx = zlog.WithLogger(ctx, &logger)
+
+	start := time.Now()
+	logger.Info().Msg("Job started")
+	err := call(ctx, j)
+	elapsed := time.Since(start)
+	if err != nil {
+		logger.Error().Err(err).Dur("elapsed", elapsed).Msg("Job failed")
+	} else {
+		logger.Info().Dur("elapsed", elapsed).Msg("Job completed")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastStart = start
+	j.status.LastRun = elapsed
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+}
+
+// Call the job, converting a panic to an error so a failing job doesn't stop the scheduler
+func call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return j.fn(ctx)
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	io.ReadFull(rand.Reader, b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Jobs returns the status of each job, sorted by name
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	res := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		res = append(res, j.status)
+	}
+	s.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// ListHandler responds with the status of each job
+func (s *Scheduler) ListHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"jobs": s.Jobs()})
+	}
+}
+
+// TriggerHandler runs the job named by the name path parameter, responding 202 if started, 404 if not found or
+// 409 if already running
+func (s *Scheduler) TriggerHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("name")
+		switch err := s.Trigger(name); err {
+		case nil:
+			zlog.GetLogger(ctx).Info().Str("job", name).Msg("Job triggered")
+			ctx.Status(http.StatusAccepted)
+		case ErrNotFound:
+			ginxerrors.AbortWith(ctx, http.StatusNotFound, "job_not_found")
+		default:
+			ginxerrors.AbortWith(ctx, http.StatusConflict, "job_running")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 30, 15, 0, time.UTC) // Wednesday
+	for expr, next := range map[string]string{
+		"* * * * *":         "2024-01-31T10:31:00Z",
+		"0 3 * * *":         "2024-02-01T03:00:00Z",
+		"*/15 * * * *":      "2024-01-31T10:45:00Z",
+		"0 9-17/4 * * *":    "2024-01-31T13:00:00Z",
+		"0 0 29 2 *":        "2024-02-29T00:00:00Z",
+		"0 0 * * sun":       "2024-02-04T00:00:00Z",
+		"0 0 * * 7":         "2024-02-04T00:00:00Z",
+		"0 0 1 * fri":       "2024-02-01T00:00:00Z", // Either day field matches
+		"30 10 * jan,mar *": "2024-03-01T10:30:00Z",
+		"@monthly":          "2024-02-01T00:00:00Z",
+		"@every 90s":        "2024-01-31T10:31:45Z",
+	} {
+		s, err := Parse(expr)
+		if assert.NoError(t, err, expr) {
+			assert.Equal(t, next, s.Next(base).Format(time.RFC3339), expr)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@every x",
+		"0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+	// Either day field matching, so the weekday makes this valid
+	_, err := Parse("0 0 30 2 mon")
+	assert.NoError(t, err)
+}
+
+type never struct{}
+
+func (never) Next(time.Time) time.Time { return time.Time{} }
+
+func TestSchedulerNever(t *testing.T) {
+	s := New()
+	var runs int32
+	assert.NoError(t, s.Add("never", "@hourly", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}))
+	s.mu.Lock()
+	s.jobs["never"].schedule = never{}
+	s.jobs["never"].status.Next = time.Time{}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+	assert.Zero(t, atomic.LoadInt32(&runs))
+}
+
+func TestScheduler(t *testing.T) {
+	s := New()
+	var runs int32
+	release := make(chan struct{})
+	assert.NoError(t, s.Add("tick", "@every 10ms", func(ctx context.Context) error {
+		assert.NotNil(t, zlog.GetLogger(ctx))
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return errors.New("failed")
+	}))
+	assert.Error(t, s.Add("tick", "@hourly", nil))
+	assert.Error(t, s.Add("bad", "* *", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// The first run blocks, so later runs are skipped
+	assert.Eventually(t, func() bool { return s.Jobs()[0].Skipped > 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	assert.Equal(t, ErrRunning, s.Trigger("tick"))
+	close(release)
+
+	assert.Eventually(t, func() bool { return s.Jobs()[0].Runs > 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, "failed", s.Jobs()[0].LastError)
+}
+
+func TestHandlers(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	s := New(WithJitter(time.Hour))
+	ran := make(chan struct{}, 1)
+	s.MustAdd("report", "0 0 1 1 *", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	e := gin.New()
+	e.GET("/jobs", s.ListHandler())
+	e.POST("/jobs/:name", s.TriggerHandler())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs/report", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	<-ran // Triggered runs aren't delayed by jitter
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/jobs/missing", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/jobs", nil)
+	e.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"name":"report","schedule":"0 0 1 1 *"`)
+}
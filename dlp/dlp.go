@@ -0,0 +1,371 @@
+// Data leak prevention
+//
+// Inspects outbound response bodies for sensitive data such as card numbers, national insurance or social security
+// numbers and API keys, which shouldn't appear in API responses, and alerts, masks or blocks the response.
+//
+//	e.Use(dlp.New(
+//		dlp.WithAction(dlp.Mask),
+//		dlp.WithRoutes("/users/:id", "/export/*path"),
+//		dlp.WithSampleRate(10),
+//	).Middleware())
+//
+// Responses are buffered up to a size limit so they can be modified, larger or streamed responses are passed
+// through unscanned. Matched values are never logged, only the rule names and counts.
+package dlp
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Action taken when sensitive data is found
+type Action int
+
+const (
+	Alert Action = iota // Log and call the alert hook, sending the response unchanged
+	Mask                // Replace matched values, keeping the last four characters
+	Block               // Replace the response with a 500 error
+)
+
+// Rule detects a type of sensitive data
+type Rule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool // Optional check to reduce false positives, e.g. Luhn checksum
+}
+
+var (
+	// CardNumber matches payment card numbers passing the Luhn check
+	CardNumber = Rule{
+		Name:     "card_number",
+		Pattern:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhn,
+	}
+
+	// USSSN matches US social security numbers in the dashed format
+	USSSN = Rule{
+		Name:    "us_ssn",
+		Pattern: regexp.MustCompile(`\b(?:00[1-9]|0[1-9]\d|[1-578]\d\d|6[0-57-9]\d|66[0-57-9])-(?:0[1-9]|[1-9]\d)-(?:000[1-9]|00[1-9]\d|0[1-9]\d\d|[1-9]\d{3})\b`),
+	}
+
+	// UKNINO matches UK national insurance numbers
+	UKNINO = Rule{
+		Name:    "uk_nino",
+		Pattern: regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`),
+	}
+
+	// AWSAccessKey matches AWS access key IDs
+	AWSAccessKey = Rule{
+		Name:    "aws_access_key",
+		Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	}
+
+	// GitHubToken matches GitHub personal access and app tokens
+	GitHubToken = Rule{
+		Name:    "github_token",
+		Pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,255}\b`),
+	}
+
+	// PrivateKey matches PEM private key headers
+	PrivateKey = Rule{
+		Name:    "private_key",
+		Pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----`),
+	}
+
+	// DefaultRules are applied if no rules are specified
+	DefaultRules = []Rule{CardNumber, USSSN, UKNINO, AWSAccessKey, GitHubToken, PrivateKey}
+)
+
+// Finding is the number of matches of a rule in a response
+type Finding struct {
+	Rule  string
+	Count int
+}
+
+type scanOpts struct {
+	rules      []Rule
+	action     Action
+	routes     map[string]bool               // Route patterns to scan, all if empty
+	sampleRate uint32                        // Scan one in n responses
+	maxBody    int                           // Largest body buffered for scanning
+	alert      func(*gin.Context, []Finding) // Called when sensitive data is found
+	types      []string                      // Scanned content types
+}
+
+// Modifier function for customising scanner behaviour
+type ScanOpts func(*scanOpts) *scanOpts
+
+// Scanner inspects response bodies
+type Scanner struct {
+	opts    *scanOpts
+	counter uint32
+}
+
+// New creates a response scanner, alerting on DefaultRules for all routes by default
+func New(opts ...ScanOpts) *Scanner {
+	so := &scanOpts{
+		rules:      DefaultRules,
+		action:     Alert,
+		sampleRate: 1,
+		maxBody:    1 << 20,
+		types:      []string{"application/json", "text/", "application/xml", "application/x-www-form-urlencoded"},
+	}
+	for _, f := range opts {
+		so = f(so)
+	}
+	return &Scanner{opts: so}
+}
+
+// WithRules sets the rules to apply, replacing DefaultRules
+func WithRules(rules ...Rule) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.rules = rules
+		return so
+	}
+}
+
+// WithAction sets the action when sensitive data is found, defaults to Alert
+func WithAction(a Action) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.action = a
+		return so
+	}
+}
+
+// WithRoutes restricts scanning to the given route patterns, as returned by ctx.FullPath()
+func WithRoutes(routes ...string) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.routes = map[string]bool{}
+		for _, r := range routes {
+			so.routes[r] = true
+		}
+		return so
+	}
+}
+
+// WithSampleRate scans one in every n responses to bound overhead, defaults to scanning all responses.
+// Sampling is only suitable with Alert, as unsampled responses are never masked or blocked.
+func WithSampleRate(n uint32) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		if n == 0 {
+			n = 1
+		}
+		so.sampleRate = n
+		return so
+	}
+}
+
+// WithMaxBody sets the largest response body scanned, defaults to 1MB
+func WithMaxBody(n int) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.maxBody = n
+		return so
+	}
+}
+
+// WithAlertFunc sets a hook called when sensitive data is found, in addition to the warning log line
+func WithAlertFunc(fn func(ctx *gin.Context, findings []Finding)) ScanOpts {
+	return func(so *scanOpts) *scanOpts {
+		so.alert = fn
+		return so
+	}
+}
+
+// Scan returns the rules matched in the body
+func (s *Scanner) Scan(body []byte) []Finding {
+	var res []Finding
+	for _, r := range s.opts.rules {
+		n := 0
+		for _, m := range r.Pattern.FindAll(body, -1) {
+			if r.Validate == nil || r.Validate(string(m)) {
+				n++
+			}
+		}
+		if n > 0 {
+			res = append(res, Finding{Rule: r.Name, Count: n})
+		}
+	}
+	return res
+}
+
+// Mask replaces matched values in the body, keeping the last four characters of values longer than eight
+func (s *Scanner) Mask(body []byte) []byte {
+	for _, r := range s.opts.rules {
+		r := r
+		body = r.Pattern.ReplaceAllFunc(body, func(m []byte) []byte {
+			if r.Validate != nil && !r.Validate(string(m)) {
+				return m
+			}
+			return mask(m)
+		})
+	}
+	return body
+}
+
+func mask(m []byte) []byte {
+	keep := 0
+	if len(m) > 8 {
+		keep = 4
+	}
+	res := bytes.Repeat([]byte{'*'}, len(m))
+	copy(res[len(m)-keep:], m[len(m)-keep:])
+	return res
+}
+
+// Middleware scans responses of the configured routes
+func (s *Scanner) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if len(s.opts.routes) > 0 && !s.opts.routes[ctx.FullPath()] {
+			return
+		}
+		if s.opts.sampleRate > 1 && atomic.AddUint32(&s.counter, 1)%s.opts.sampleRate != 0 {
+			return
+		}
+
+		// Headers set before the handler, e.g. by request ID or CORS middleware, are kept on blocked responses
+		before := ctx.Writer.Header().Clone()
+		w := &bufferWriter{ResponseWriter: ctx.Writer, limit: s.opts.maxBody}
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+		}()
+		ctx.Next()
+		ctx.Writer = w.ResponseWriter
+
+		if w.passthrough {
+			zlog.GetLogger(ctx).Debug().Msg("Response too large or streamed, not scanned for sensitive data")
+			return
+		}
+		body := w.buf.Bytes()
+		if len(body) == 0 || !s.scannable(w.Header().Get("Content-Type")) {
+			w.ResponseWriter.Write(body)
+			return
+		}
+
+		findings := s.Scan(body)
+		if len(findings) == 0 {
+			w.ResponseWriter.Write(body)
+			return
+		}
+
+		ev := zlog.GetLogger(ctx).Warn()
+		for _, f := range findings {
+			ev.Int(f.Rule, f.Count)
+		}
+		ev.Str("action", s.opts.action.String()).Msg("Sensitive data in response")
+		if s.opts.alert != nil {
+			s.opts.alert(ctx, findings)
+		}
+
+		switch s.opts.action {
+		case Block:
+			// Drop the handler's headers, so the error response sets its own Content-Type
+			h := w.Header()
+			for k := range h {
+				delete(h, k)
+			}
+			for k, v := range before {
+				h[k] = v
+			}
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			errors.AbortWith(ctx, http.StatusInternalServerError, "response_blocked")
+		case Mask:
+			masked := s.Mask(body)
+			w.Header().Set("Content-Length", strconv.Itoa(len(masked)))
+			w.ResponseWriter.Write(masked)
+		default:
+			w.ResponseWriter.Write(body)
+		}
+	}
+}
+
+func (s *Scanner) scannable(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	for _, t := range s.opts.types {
+		if mt == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mt, t)) || strings.HasSuffix(mt, "+json") {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Action) String() string {
+	switch a {
+	case Mask:
+		return "mask"
+	case Block:
+		return "block"
+	}
+	return "alert"
+}
+
+// Buffers the response body up to the limit, switching to passthrough beyond it or on flush
+type bufferWriter struct {
+	gin.ResponseWriter
+	buf         bytes.Buffer
+	limit       int
+	passthrough bool
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	if !w.passthrough && w.buf.Len()+len(p) <= w.limit {
+		return w.buf.Write(p)
+	}
+	if err := w.release(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferWriter) Flush() {
+	_ = w.release()
+	w.ResponseWriter.Flush()
+}
+
+func (w *bufferWriter) release() error {
+	w.passthrough = true
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Luhn checksum, ignoring separators
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,172 @@
+// Drain redirects
+//
+// Sheds traffic to another region while an instance is shutting down or overloaded. Idempotent requests are
+// redirected with 307 to the failover origin, preserving the method, path and query, while other requests are
+// rejected with 503 and Retry-After, since they can't safely be replayed by the client.
+//
+//	d := drainredirect.New("https://eu-west.api.example.com", drainredirect.WithOverload(overloaded))
+//	e.Use(d.Middleware())
+//	...
+//	d.Shutdown(ctx, srv, 10*time.Second)
+//
+// Clients which can't follow redirects can be given the failover URL in a header instead, see WithHeader.
+package drainredirect
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Idempotent methods which are redirected by default
+var idempotent = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+type drainOpts struct {
+	overload   func() bool   // Additional shedding condition, e.g. queue depth
+	header     string        // Failover URL header instead of redirecting, empty to redirect
+	retryAfter time.Duration // Retry-After for rejected requests
+}
+
+// Modifier function for customising drain behaviour
+type DrainOpts func(*drainOpts) *drainOpts
+
+// Drainer redirects or rejects requests while draining
+type Drainer struct {
+	target   *url.URL
+	opts     *drainOpts
+	draining int32
+}
+
+// New creates a drainer redirecting to the failover origin, e.g. https://other-region.example.com.
+// Panics if the target has no scheme, such as other-region.example.com without https://.
+func New(target string, opts ...DrainOpts) *Drainer {
+	u, err := url.Parse(target)
+	if err != nil || !u.IsAbs() {
+		panic("drainredirect: invalid failover target " + target)
+	}
+	do := &drainOpts{
+		retryAfter: 5 * time.Second,
+	}
+	for _, f := range opts {
+		do = f(do)
+	}
+	return &Drainer{target: u, opts: do}
+}
+
+// Drain starts shedding all requests
+func (d *Drainer) Drain() {
+	atomic.StoreInt32(&d.draining, 1)
+}
+
+// Resume stops shedding requests, other than for overload
+func (d *Drainer) Resume() {
+	atomic.StoreInt32(&d.draining, 0)
+}
+
+// Draining returns true if requests are currently being shed
+func (d *Drainer) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1 || (d.opts.overload != nil && d.opts.overload())
+}
+
+// Middleware sheds requests while draining or overloaded
+func (d *Drainer) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !d.Draining() {
+			return
+		}
+
+		// Don't keep the connection to this instance alive
+		ctx.Header("Connection", "close")
+
+		if !idempotent[ctx.Request.Method] {
+			zlog.GetLogger(ctx).Debug().Msg("Rejected request while draining")
+			ctx.Header("Retry-After", strconv.Itoa(int(d.opts.retryAfter.Seconds())))
+			errors.AbortWith(ctx, http.StatusServiceUnavailable, "draining")
+			return
+		}
+
+		location := d.Location(ctx.Request)
+		zlog.GetLogger(ctx).Debug().Str("location", location).Msg("Redirected request while draining")
+		if d.opts.header != "" {
+			ctx.Header(d.opts.header, location)
+			ctx.Header("Retry-After", strconv.Itoa(int(d.opts.retryAfter.Seconds())))
+			errors.AbortWith(ctx, http.StatusServiceUnavailable, "draining")
+			return
+		}
+		ctx.Redirect(http.StatusTemporaryRedirect, location)
+		ctx.Abort()
+	}
+}
+
+// Location returns the failover URL for the request
+func (d *Drainer) Location(r *http.Request) string {
+	u := *d.target
+	u.Path = singleJoin(d.target.Path, r.URL.Path)
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+	return u.String()
+}
+
+func singleJoin(a, b string) string {
+	switch {
+	case a == "" || a == "/":
+		return b
+	case a[len(a)-1] == '/' && len(b) > 0 && b[0] == '/':
+		return a + b[1:]
+	case a[len(a)-1] != '/' && (len(b) == 0 || b[0] != '/'):
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// Shutdown drains for the grace period, giving load balancers and clients time to move to the failover
+// region, then gracefully shuts down the server
+func (d *Drainer) Shutdown(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	d.Drain()
+	zlog.GetLogger(ctx).Info().Dur("grace", grace).Str("target", d.target.String()).Msg("Draining before shutdown")
+
+	select {
+	case <-time.After(grace):
+	case <-ctx.Done():
+	}
+	return srv.Shutdown(ctx)
+}
+
+// WithOverload sheds requests whenever fn returns true, in addition to when draining.
+// fn is called for every request, so must be cheap.
+func WithOverload(fn func() bool) DrainOpts {
+	return func(do *drainOpts) *drainOpts {
+		do.overload = fn
+		return do
+	}
+}
+
+// WithHeader responds with 503 and the failover URL in the header instead of redirecting, for clients which
+// handle failover themselves
+func WithHeader(name string) DrainOpts {
+	return func(do *drainOpts) *drainOpts {
+		do.header = name
+		return do
+	}
+}
+
+// WithRetryAfter sets the Retry-After value for rejected requests, defaults to 5 seconds
+func WithRetryAfter(d time.Duration) DrainOpts {
+	return func(do *drainOpts) *drainOpts {
+		do.retryAfter = d
+		return do
+	}
+}
@@ -0,0 +1,83 @@
+package drainredirect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setup(d *Drainer) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(d.Middleware())
+	e.Any("/*path", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	return e
+}
+
+func do(e *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestDrain(t *testing.T) {
+	d := New("https://other.example.com/api/")
+	e := setup(d)
+
+	assert.Equal(t, http.StatusOK, do(e, "GET", "/users?page=2").Code)
+
+	d.Drain()
+
+	w := do(e, "GET", "/users?page=2")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://other.example.com/api/users?page=2", w.Header().Get("Location"))
+	assert.Equal(t, "close", w.Header().Get("Connection"))
+
+	w = do(e, "POST", "/users")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":"draining"}`, w.Body.String())
+
+	d.Resume()
+	assert.Equal(t, http.StatusOK, do(e, "GET", "/users").Code)
+}
+
+func TestOverloadHeader(t *testing.T) {
+	overloaded := false
+	d := New("https://other.example.com",
+		WithOverload(func() bool { return overloaded }),
+		WithHeader("X-Failover-Location"),
+		WithRetryAfter(time.Minute),
+	)
+	e := setup(d)
+
+	assert.Equal(t, http.StatusOK, do(e, "DELETE", "/items/1").Code)
+
+	overloaded = true
+	w := do(e, "DELETE", "/items/1")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "https://other.example.com/items/1", w.Header().Get("X-Failover-Location"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestInvalidTarget(t *testing.T) {
+	assert.Panics(t, func() { New("/relative") })
+}
+
+func TestShutdown(t *testing.T) {
+	d := New("https://other.example.com")
+	srv := &http.Server{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, d.Shutdown(ctx, srv, time.Hour))
+	assert.True(t, d.Draining())
+}
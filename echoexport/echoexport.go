@@ -0,0 +1,214 @@
+// Router groups as http.Handler
+//
+// Exposes ginx routes as a plain http.Handler, with their start and stop hooks, for embedding inside other servers
+// such as Lambda adapters, test harnesses or desktop apps.
+//
+//	h := echoexport.New(func(r gin.IRouter) {
+//		r.GET("/users/:id", getUser)
+//	}, echoexport.WithMiddleware(zlog.Logger(zerolog.InfoLevel)), echoexport.WithStartHook(db.Connect))
+//	if err := h.Start(ctx); err != nil { ... }
+//	mux.Handle("/api/", http.StripPrefix("/api", h))
+//	...
+//	h.Shutdown(ctx)
+//
+// Start hooks run on Start, which must be called before the handler serves requests, and stop hooks on Shutdown in
+// reverse order. Requests respond 503 until started and after shutdown. The request context is used as a fallback
+// for gin context values, so values set by the embedding server remain available to handlers.
+package echoexport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/modulemount"
+	"github.com/rs/zerolog/log"
+)
+
+// Hook is a start or stop hook
+type Hook func(ctx context.Context) error
+
+type handlerOpts struct {
+	prefix     string
+	middleware []gin.HandlerFunc
+	start      []Hook
+	stop       []Hook
+}
+
+// Modifier function for customising handler behaviour
+type HandlerOpts func(*handlerOpts) *handlerOpts
+
+// WithPrefix registers the routes under a path prefix, e.g. when the embedding server doesn't strip it
+func WithPrefix(prefix string) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.prefix = prefix
+		return ho
+	}
+}
+
+// WithMiddleware adds middleware applied to all requests, including those not matching a route
+func WithMiddleware(mw ...gin.HandlerFunc) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.middleware = append(ho.middleware, mw...)
+		return ho
+	}
+}
+
+// WithStartHook adds a hook run before the handler serves requests
+func WithStartHook(h Hook) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.start = append(ho.start, h)
+		return ho
+	}
+}
+
+// WithStopHook adds a hook run on Shutdown, after in-flight requests complete
+func WithStopHook(h Hook) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.stop = append(ho.stop, h)
+		return ho
+	}
+}
+
+// Handler serves routes as an http.Handler
+type Handler struct {
+	engine *gin.Engine
+	opts   *handlerOpts
+
+	startMu  sync.Mutex // Held while start hooks run, without blocking requests
+	mu       sync.Mutex
+	started  bool
+	stopped  bool
+	inFlight sync.WaitGroup
+}
+
+// New creates a handler serving the routes registered by the function
+func New(routes func(r gin.IRouter), opts ...HandlerOpts) *Handler {
+	ho := &handlerOpts{}
+	for _, f := range opts {
+		ho = f(ho)
+	}
+
+	h := &Handler{engine: gin.New(), opts: ho}
+	h.engine.ContextWithFallback = true
+	h.engine.Use(h.guard)
+	h.engine.Use(ho.middleware...)
+	routes(h.engine.Group(ho.prefix))
+	return h
+}
+
+// FromModule creates a handler serving a module, with its shutdown as a stop hook
+func FromModule(m modulemount.Module, opts ...HandlerOpts) (*Handler, error) {
+	var err error
+	var a *modulemount.Assembler
+	h := New(func(r gin.IRouter) {
+		a = modulemount.New(r)
+		err = a.Mount("/", m)
+	}, append(opts, WithStopHook(func(ctx context.Context) error { return a.Shutdown(ctx) }))...)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Engine returns the underlying engine, e.g. to customise NoRoute handling
+func (h *Handler) Engine() *gin.Engine {
+	return h.engine
+}
+
+// Start runs the start hooks in order with the context if not already started. A failed start can be retried.
+func (h *Handler) Start(ctx context.Context) error {
+	h.startMu.Lock()
+	defer h.startMu.Unlock()
+
+	h.mu.Lock()
+	stopped, started := h.stopped, h.started
+	h.mu.Unlock()
+	if stopped {
+		return fmt.Errorf("echoexport: handler shut down")
+	}
+	if started {
+		return nil
+	}
+	for _, hook := range h.opts.start {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("echoexport: start hook: %w", err)
+		}
+	}
+
+	h.mu.Lock()
+	h.started = true
+	h.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP serves the request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.engine.ServeHTTP(w, r)
+}
+
+// Reject requests before Start and after Shutdown, and track those in flight
+func (h *Handler) guard(ctx *gin.Context) {
+	h.mu.Lock()
+	if h.stopped || !h.started {
+		stopped := h.stopped
+		h.mu.Unlock()
+		if stopped {
+			errors.AbortWith(ctx, http.StatusServiceUnavailable, "shutting_down")
+		} else {
+			log.Error().Msg("Handler not started")
+			errors.AbortWith(ctx, http.StatusServiceUnavailable, "not_started")
+		}
+		return
+	}
+	h.inFlight.Add(1)
+	h.mu.Unlock()
+
+	defer h.inFlight.Done()
+	ctx.Next()
+}
+
+// Shutdown stops accepting requests, waits for in-flight requests until the context is done, then runs the stop
+// hooks in reverse order, returning the first error. Stop hooks don't run if the handler never started.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	// Wait for a Start in progress, so stop hooks run if it succeeds
+	h.startMu.Lock()
+	defer h.startMu.Unlock()
+
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return nil
+	}
+	h.stopped = true
+	started := h.started
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn().Msg("Shutting down with requests in flight")
+	}
+
+	if !started {
+		return nil
+	}
+	var first error
+	for i := len(h.opts.stop) - 1; i >= 0; i-- {
+		if err := h.opts.stop[i](ctx); err != nil {
+			log.Error().Err(err).Msg("Stop hook failed")
+			if first == nil {
+				first = fmt.Errorf("echoexport: stop hook: %w", err)
+			}
+		}
+	}
+	return first
+}
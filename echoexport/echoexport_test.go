@@ -0,0 +1,113 @@
+package echoexport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/modulemount"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func get(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "outer"))
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var events []string
+	hook := func(name string) Hook {
+		return func(context.Context) error {
+			events = append(events, name)
+			return nil
+		}
+	}
+
+	h := New(func(r gin.IRouter) {
+		r.GET("/hello", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, "%s %s", ctx.GetString("mw"), ctx.Value(ctxKey{}))
+		})
+	},
+		WithPrefix("/api"),
+		WithMiddleware(func(ctx *gin.Context) { ctx.Set("mw", "inner") }),
+		WithStartHook(hook("start1")), WithStartHook(hook("start2")),
+		WithStopHook(hook("stop1")), WithStopHook(hook("stop2")),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", h)
+	w := get(mux, "/api/hello")
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"not_started"`)
+	assert.Empty(t, events)
+
+	assert.NoError(t, h.Start(context.Background()))
+	w = get(mux, "/api/hello")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "inner outer", w.Body.String())
+	get(mux, "/api/hello")
+	assert.Equal(t, []string{"start1", "start2"}, events)
+
+	assert.NoError(t, h.Shutdown(context.Background()))
+	assert.Equal(t, []string{"start1", "start2", "stop2", "stop1"}, events)
+	w = get(mux, "/api/hello")
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"shutting_down"`)
+	assert.NoError(t, h.Shutdown(context.Background()))
+	assert.Error(t, h.Start(context.Background()))
+}
+
+func TestStartFailure(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	fail := true
+	h := New(func(r gin.IRouter) {
+		r.GET("/", func(ctx *gin.Context) {})
+	}, WithStartHook(func(context.Context) error {
+		if fail {
+			return errors.New("no database")
+		}
+		return nil
+	}))
+
+	assert.Error(t, h.Start(context.Background()))
+	assert.Equal(t, 503, get(h, "/").Code)
+	fail = false
+	assert.NoError(t, h.Start(context.Background()))
+	assert.Equal(t, 200, get(h, "/").Code)
+}
+
+type module struct {
+	modulemount.Base
+	stopped bool
+}
+
+func (m *module) Name() string { return "test" }
+
+func (m *module) Routes(r gin.IRouter) {
+	r.GET("/ping", func(ctx *gin.Context) { ctx.String(http.StatusOK, "pong") })
+}
+
+func (m *module) Shutdown(context.Context) error {
+	m.stopped = true
+	return nil
+}
+
+func TestFromModule(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	m := &module{}
+	h, err := FromModule(m)
+	assert.NoError(t, err)
+	assert.NoError(t, h.Start(context.Background()))
+	assert.Equal(t, "pong", get(h, "/ping").Body.String())
+	assert.NoError(t, h.Shutdown(context.Background()))
+	assert.True(t, m.stopped)
+}
@@ -0,0 +1,487 @@
+// Typed configuration loader
+//
+// Loads configuration into a struct from defaults, a JSON file, environment variables and command line flags, in
+// increasing order of precedence, then resolves secret references and validates the result.
+//
+//	type Config struct {
+//		Addr     string        `json:"addr" env:"ADDR" flag:"addr" default:":8080"`
+//		LogLevel zerolog.Level `json:"log_level" default:"info"`
+//		Timeout  time.Duration `json:"timeout" default:"30s" validate:"min=1s"`
+//		DB       struct {
+//			URL string `json:"url" secret:"true" validate:"required"`
+//		} `json:"db"`
+//	}
+//
+//	var cfg Config
+//	err := envconfig.Load(&cfg, envconfig.WithPrefix("APP_"), envconfig.WithFile("config.json"),
+//		envconfig.WithFlags(flag.CommandLine, os.Args[1:]))
+//
+// Environment variable names default to the upper snake case field path, e.g. APP_DB_URL, unless set with the env
+// tag. String values of the form file:/path or env:NAME are replaced by the file contents or variable value, so
+// secrets can be mounted rather than placed in the environment. Fields tagged secret:"true" are redacted by Handler.
+package envconfig
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const redacted = "[REDACTED]"
+
+type loadOpts struct {
+	prefix   string
+	file     string
+	flags    *flag.FlagSet
+	args     []string
+	lookup   func(string) (string, bool)
+	readFile func(string) ([]byte, error)
+	validate *validator.Validate
+}
+
+// Modifier function for customising loader behaviour
+type LoadOpts func(*loadOpts) *loadOpts
+
+// WithPrefix sets the prefix of all environment variable names, e.g. APP_
+func WithPrefix(prefix string) LoadOpts {
+	return func(lo *loadOpts) *loadOpts {
+		lo.prefix = prefix
+		return lo
+	}
+}
+
+// WithFile loads a JSON config file, overriding defaults. A missing file is an error.
+func WithFile(path string) LoadOpts {
+	return func(lo *loadOpts) *loadOpts {
+		lo.file = path
+		return lo
+	}
+}
+
+// WithFlags defines a flag for each field with a flag tag on the flag set and parses args. Flags which are set
+// override all other sources.
+func WithFlags(fs *flag.FlagSet, args []string) LoadOpts {
+	return func(lo *loadOpts) *loadOpts {
+		lo.flags = fs
+		lo.args = args
+		return lo
+	}
+}
+
+// WithLookup sets the environment variable lookup, defaults to os.LookupEnv
+func WithLookup(fn func(string) (string, bool)) LoadOpts {
+	return func(lo *loadOpts) *loadOpts {
+		lo.lookup = fn
+		return lo
+	}
+}
+
+// WithValidator sets the validator applied after loading, using validate struct tags
+func WithValidator(v *validator.Validate) LoadOpts {
+	return func(lo *loadOpts) *loadOpts {
+		lo.validate = v
+		return lo
+	}
+}
+
+// A settable leaf field of the config struct
+type field struct {
+	value reflect.Value
+	sf    reflect.StructField
+	path  string // Dotted JSON path, for errors
+	env   string
+	flag  string
+}
+
+// Load populates the struct pointed to by target
+func Load(target interface{}, opts ...LoadOpts) error {
+	lo := &loadOpts{
+		lookup:   os.LookupEnv,
+		readFile: os.ReadFile,
+	}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+	if lo.validate == nil {
+		lo.validate = validator.New()
+		lo.validate.SetTagName("validate")
+	}
+
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("envconfig: target must be a pointer to a struct, received %T", target)
+	}
+
+	var fields []field
+	collect(rv.Elem(), "", lo.prefix, &fields)
+
+	// Defaults
+	for _, f := range fields {
+		if d, ok := f.sf.Tag.Lookup("default"); ok {
+			if err := set(f.value, d); err != nil {
+				return fmt.Errorf("envconfig: default for %s: %w", f.path, err)
+			}
+		}
+	}
+
+	// File
+	if lo.file != "" {
+		data, err := lo.readFile(lo.file)
+		if err != nil {
+			return fmt.Errorf("envconfig: %w", err)
+		}
+		if err := applyFile(data, fields); err != nil {
+			return fmt.Errorf("envconfig: parsing %s: %w", lo.file, err)
+		}
+	}
+
+	// Environment
+	for _, f := range fields {
+		if v, ok := lo.lookup(f.env); ok {
+			if err := set(f.value, v); err != nil {
+				return fmt.Errorf("envconfig: %s: %w", f.env, err)
+			}
+		}
+	}
+
+	// Flags
+	if lo.flags != nil {
+		if err := applyFlags(lo, fields); err != nil {
+			return err
+		}
+	}
+
+	// Secret references
+	for _, f := range fields {
+		if f.value.Kind() != reflect.String {
+			continue
+		}
+		v, err := resolve(lo, f.value.String())
+		if err != nil {
+			return fmt.Errorf("envconfig: %s: %w", f.path, err)
+		}
+		f.value.SetString(v)
+	}
+
+	if err := lo.validate.Struct(target); err != nil {
+		return fmt.Errorf("envconfig: %w", err)
+	}
+	return nil
+}
+
+// Set fields present in the JSON file. Strings are parsed as for environment variables, so durations and levels
+// can be written naturally, e.g. "30s".
+func applyFile(data []byte, fields []field) error {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		raw, ok := lookupJSON(root, strings.Split(f.path, "."))
+		if !ok {
+			continue
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			if err := set(f.value, s); err != nil {
+				return fmt.Errorf("%s: %w", f.path, err)
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, f.value.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+func lookupJSON(obj map[string]json.RawMessage, path []string) (json.RawMessage, bool) {
+	raw, ok := obj[path[0]]
+	if !ok || len(path) == 1 {
+		return raw, ok
+	}
+	var child map[string]json.RawMessage
+	if json.Unmarshal(raw, &child) != nil {
+		return nil, false
+	}
+	return lookupJSON(child, path[1:])
+}
+
+func applyFlags(lo *loadOpts, fields []field) error {
+	values := map[string]*string{}
+	byName := map[string]field{}
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		usage := f.sf.Tag.Get("usage")
+		if usage == "" {
+			usage = "Overrides " + f.env
+		}
+		values[f.flag] = lo.flags.String(f.flag, f.sf.Tag.Get("default"), usage)
+		byName[f.flag] = f
+	}
+	if err := lo.flags.Parse(lo.args); err != nil {
+		return fmt.Errorf("envconfig: %w", err)
+	}
+
+	var err error
+	lo.flags.Visit(func(fl *flag.Flag) {
+		f, ok := byName[fl.Name]
+		if !ok || err != nil {
+			return
+		}
+		if serr := set(f.value, *values[fl.Name]); serr != nil {
+			err = fmt.Errorf("envconfig: flag -%s: %w", fl.Name, serr)
+		}
+	})
+	return err
+}
+
+// Replace file: and env: references with their contents
+func resolve(lo *loadOpts, v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, "file:"):
+		data, err := lo.readFile(strings.TrimPrefix(v, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(v, "env:"):
+		name := strings.TrimPrefix(v, "env:")
+		res, ok := lo.lookup(name)
+		if !ok {
+			return "", fmt.Errorf("referenced variable %s not set", name)
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func collect(v reflect.Value, path, env string, fields *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		envName := sf.Tag.Get("env")
+		if envName == "" {
+			envName = env + upperSnake(sf.Name)
+		}
+		fp := name
+		if path != "" {
+			fp = path + "." + name
+		}
+
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && !reflect.PointerTo(sf.Type).Implements(textUnmarshaler) {
+			collect(fv, fp, envName+"_", fields)
+			continue
+		}
+		*fields = append(*fields, field{
+			value: fv,
+			sf:    sf,
+			path:  fp,
+			env:   envName,
+			flag:  sf.Tag.Get("flag"),
+		})
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Set a field from its string representation
+func set(v reflect.Value, s string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice:
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		res := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := set(res.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(res)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// Convert a Go field name to upper snake case, e.g. MaxIdleConns to MAX_IDLE_CONNS
+func upperSnake(s string) string {
+	var b strings.Builder
+	r := []rune(s)
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) && (unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
+}
+
+// Redact returns the config as a JSON compatible map, with non-empty secret fields replaced, including those in
+// structs reached through pointers, slices, arrays and maps
+func Redact(cfg interface{}) map[string]interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(cfg))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return redactStruct(rv)
+}
+
+func redactStruct(v reflect.Value) map[string]interface{} {
+	res := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := jsonName(sf)
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case sf.Tag.Get("secret") == "true":
+			if fv.IsZero() {
+				res[name] = ""
+			} else {
+				res[name] = redacted
+			}
+		case fv.Kind() == reflect.Struct && !reflect.PointerTo(sf.Type).Implements(textUnmarshaler):
+			res[name] = redactStruct(fv)
+		case fv.Type() == durationType:
+			res[name] = time.Duration(fv.Int()).String()
+		case hasSecret(fv.Type(), map[reflect.Type]bool{}):
+			res[name] = redactValue(fv)
+		default:
+			res[name] = fv.Interface()
+		}
+	}
+	return res
+}
+
+// Redact a pointer, slice, array or map containing structs with secret fields
+func redactValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Struct:
+		return redactStruct(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = redactValue(v.Index(i))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		res := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			res[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value())
+		}
+		return res
+	}
+	return v.Interface()
+}
+
+// Whether values of the type can contain secret fields. Interfaces are assumed not to, as the config is loaded into
+// concrete types.
+func hasSecret(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasSecret(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.IsExported() && (sf.Tag.Get("secret") == "true" || hasSecret(sf.Type, seen)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Handler serves the redacted config as JSON, for an admin endpoint
+func Handler(cfg interface{}) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Redact(cfg))
+	}
+}
@@ -0,0 +1,122 @@
+package envconfig
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Addr     string        `json:"addr" flag:"addr" default:":8080"`
+	LogLevel zerolog.Level `json:"log_level" default:"info"`
+	Timeout  time.Duration `json:"timeout" default:"30s" validate:"min=1s"`
+	Origins  []string      `json:"origins"`
+	MaxConns int           `json:"max_conns" env:"CONNS" default:"10"`
+	DB       struct {
+		URL      string `json:"url" secret:"true" validate:"required"`
+		PoolSize int    `json:"pool_size" default:"4"`
+	} `json:"db"`
+}
+
+func env(vars map[string]string) LoadOpts {
+	return WithLookup(func(k string) (string, bool) {
+		v, ok := vars[k]
+		return v, ok
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	_ = os.WriteFile(file, []byte(`{"addr":":9000","timeout":"5s","log_level":"warn","db":{"url":"postgres://file","pool_size":8}}`), 0o600)
+	secret := filepath.Join(dir, "secret")
+	_ = os.WriteFile(secret, []byte("postgres://secret\n"), 0o600)
+
+	var cfg testConfig
+	err := Load(&cfg,
+		WithPrefix("APP_"),
+		WithFile(file),
+		env(map[string]string{"APP_ADDR": ":9100", "APP_ORIGINS": "a.com, b.com", "CONNS": "20", "APP_DB_URL": "file:" + secret}),
+		WithFlags(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-addr", ":9200"}),
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ":9200", cfg.Addr)
+	assert.Equal(t, zerolog.WarnLevel, cfg.LogLevel)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Equal(t, []string{"a.com", "b.com"}, cfg.Origins)
+	assert.Equal(t, 20, cfg.MaxConns)
+	assert.Equal(t, "postgres://secret", cfg.DB.URL)
+	assert.Equal(t, 8, cfg.DB.PoolSize)
+}
+
+func TestLoadValidation(t *testing.T) {
+	var cfg testConfig
+	assert.Error(t, Load(&cfg, env(nil)))
+
+	assert.Error(t, Load(&cfg, env(map[string]string{"DB_URL": "x", "TIMEOUT": "10ms"})))
+	assert.Error(t, Load(&cfg, env(map[string]string{"DB_URL": "env:MISSING"})))
+	assert.Error(t, Load(&cfg, env(map[string]string{"DB_URL": "x", "CONNS": "many"})))
+
+	assert.NoError(t, Load(&cfg, env(map[string]string{"DB_URL": "env:OTHER", "OTHER": "postgres://other"})))
+	assert.Equal(t, "postgres://other", cfg.DB.URL)
+	assert.Equal(t, ":8080", cfg.Addr)
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	var cfg testConfig
+	_ = Load(&cfg, env(map[string]string{"DB_URL": "postgres://user:pass@db"}))
+
+	e := gin.New()
+	e.GET("/config", Handler(&cfg))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/config", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), "pass")
+	assert.Contains(t, w.Body.String(), `"url":"[REDACTED]"`)
+	assert.Contains(t, w.Body.String(), `"timeout":"30s"`)
+	assert.Contains(t, w.Body.String(), `"log_level":"info"`)
+}
+
+func TestRedactNested(t *testing.T) {
+	type upstream struct {
+		Name string `json:"name"`
+		Pass string `json:"pass" secret:"true"`
+	}
+	cfg := struct {
+		Upstreams []upstream           `json:"upstreams"`
+		Primary   *upstream            `json:"primary"`
+		Backup    *upstream            `json:"backup"`
+		ByName    map[string]*upstream `json:"by_name"`
+		Tags      []string             `json:"tags"`
+	}{
+		Upstreams: []upstream{{"a", "secret-a"}, {"b", ""}},
+		Primary:   &upstream{"p", "secret-p"},
+		ByName:    map[string]*upstream{"m": {"m", "secret-m"}},
+		Tags:      []string{"x"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"upstreams": []interface{}{
+			map[string]interface{}{"name": "a", "pass": redacted},
+			map[string]interface{}{"name": "b", "pass": ""},
+		},
+		"primary": map[string]interface{}{"name": "p", "pass": redacted},
+		"backup":  nil,
+		"by_name": map[string]interface{}{"m": map[string]interface{}{"name": "m", "pass": redacted}},
+		"tags":    []string{"x"},
+	}, Redact(&cfg))
+}
@@ -0,0 +1,41 @@
+package errors
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const configKey = "errors"
+
+// Config is the error response configuration of a router group, overriding the global settings
+type Config struct {
+	Detail bool // Include the error detail, see SetErrorDetailOutput
+}
+
+// WithConfig middleware sets the error response configuration for later handlers, e.g. a router group
+func WithConfig(c Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(configKey, c)
+	}
+}
+
+// WithDetail middleware sets whether error detail is included in responses for later handlers, overriding
+// SetErrorDetailOutput, e.g. only for internal routes:
+//
+//	internal := e.Group("/internal", errors.WithDetail(true))
+func WithDetail(enabled bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c := getConfig(ctx)
+		c.Detail = enabled
+		ctx.Set(configKey, c)
+	}
+}
+
+// Return the configuration set on the context, or the global configuration
+func getConfig(ctx *gin.Context) Config {
+	if v, ok := ctx.Get(configKey); ok {
+		if c, ok := v.(Config); ok {
+			return c
+		}
+	}
+	return Config{Detail: detail}
+}
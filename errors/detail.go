@@ -0,0 +1,47 @@
+package errors
+
+import (
+	"strings"
+)
+
+var detailFunc = DefaultDetail
+
+// Prefixes of database driver messages, which may include queries, values or connection details
+var driverPrefixes = []string{"pq: ", "ERROR: ", "FATAL: ", "sqlite3: ", "mysql: ", "Error 1", "Error 2", "sql: "}
+
+// SetDetailFunc sets the function producing the error detail included in responses when SetErrorDetailOutput is
+// enabled, so deployments control exactly what internal error text reaches clients
+func SetDetailFunc(fn func(error) string) {
+	detailFunc = fn
+}
+
+// DefaultDetail is the default detail function. It returns the first line of the error message, dropping stack
+// traces, and drops the message from the first wrapped database driver error onwards.
+//
+//	"loading user 7: pq: relation \"users\" does not exist" -> "loading user 7"
+func DefaultDetail(err error) string {
+	msg := err.Error()
+	if i := strings.IndexAny(msg, "\r\n"); i >= 0 {
+		msg = msg[:i]
+	}
+	parts := strings.Split(msg, ": ")
+	for i, p := range parts {
+		if i > 0 && isDriverMessage(p+": ") {
+			parts = parts[:i]
+			break
+		}
+	}
+	if isDriverMessage(msg) {
+		return ""
+	}
+	return strings.TrimSpace(strings.Join(parts, ": "))
+}
+
+func isDriverMessage(s string) bool {
+	for _, p := range driverPrefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
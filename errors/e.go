@@ -0,0 +1,114 @@
+package errors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// E is a structured error carrying the response status, machine readable code, public message and metadata, and
+// optionally an internal cause. E values are immutable, each method returns a modified copy, so they can be
+// declared once and reused:
+//
+//	var ErrUserNotFound = errors.New("user_not_found").Status(404).Msg("User not found")
+//	...
+//	if errors.AbortWithError(ctx, ErrUserNotFound.Meta("id", id).Wrap(err), 500, "internal_error") {
+//		return
+//	}
+//
+// errors.Is matches E values by code, so wrapped and modified copies still match the declared error.
+type E struct {
+	code   string
+	status int
+	msg    string
+	meta   map[string]interface{}
+	cause  error
+}
+
+// New creates an error with the code and status 500
+func New(code string) *E {
+	return &E{code: code, status: http.StatusInternalServerError}
+}
+
+func (e *E) clone() *E {
+	c := *e
+	return &c
+}
+
+// Status returns a copy with the response status
+func (e *E) Status(status int) *E {
+	c := e.clone()
+	c.status = status
+	return c
+}
+
+// Msg returns a copy with the public message, included in the response body
+func (e *E) Msg(msg string) *E {
+	c := e.clone()
+	c.msg = msg
+	return c
+}
+
+// Meta returns a copy with an additional metadata field, included in the response body
+func (e *E) Meta(key string, value interface{}) *E {
+	c := e.clone()
+	c.meta = make(map[string]interface{}, len(e.meta)+1)
+	for k, v := range e.meta {
+		c.meta[k] = v
+	}
+	c.meta[key] = value
+	return c
+}
+
+// Wrap returns a copy with the internal cause, which is only included in the response if error detail is enabled
+func (e *E) Wrap(cause error) *E {
+	c := e.clone()
+	c.cause = cause
+	return c
+}
+
+// Code returns the machine readable code
+func (e *E) Code() string {
+	return e.code
+}
+
+// StatusCode returns the response status
+func (e *E) StatusCode() int {
+	return e.status
+}
+
+// Message returns the public message
+func (e *E) Message() string {
+	return e.msg
+}
+
+// Metadata returns a copy of the metadata fields
+func (e *E) Metadata() map[string]interface{} {
+	res := make(map[string]interface{}, len(e.meta))
+	for k, v := range e.meta {
+		res[k] = v
+	}
+	return res
+}
+
+// Error returns the code, message and cause
+func (e *E) Error() string {
+	parts := []string{e.code}
+	if e.msg != "" {
+		parts = append(parts, e.msg)
+	}
+	if e.cause != nil {
+		parts = append(parts, e.cause.Error())
+	}
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns the internal cause
+func (e *E) Unwrap() error {
+	return e.cause
+}
+
+// Is matches any E with the same code
+func (e *E) Is(target error) bool {
+	t, ok := target.(*E)
+	return ok && t.code == e.code
+}
@@ -1,18 +1,30 @@
 // Error wrapper
 //
 // Shorthand for checking error states, and conditionally aborting with specified status and JSON body.
-// Inclusion of actual error message can also be enabled.
+// Inclusion of actual error message can also be enabled. Errors of type E carry their own status, code, message
+// and metadata, which take precedence over those given at the call site. Handler() writes the response for errors
+// recorded on the context instead, and Recovery() records panics for it. Responses can be rendered as RFC 7807
+// application/problem+json, globally with SetProblemOutput or per call with AbortWithProblem.
 package errors
 
 import (
 	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/redmapletech/ginx/zlog"
 )
 
+// Content type of problem responses
+const ProblemContentType = "application/problem+json"
+
 var (
-	detail = false
+	detail      = false
+	requestID   = true
+	problem     = false
+	problemType = ""
 
 	ErrNoDetail = fmt.Errorf("error: no detail")
 )
@@ -24,24 +36,131 @@ var (
 //	if errors.AbortWithError(ctx, err, 400, "error_short_code") {
 //		return
 //	}
+//
+// If err is or wraps an E, its status and code are used instead, and its message and metadata are added to the body.
+// Otherwise errors with a mapping added by Register use the mapped status and code. The failed fields of a
+// bind.ErrValidation are added as errors, as are the individual errors of a multiple error, see AbortWithErrors.
+// Messages added with RegisterMessage are included in the language requested by Accept-Language.
 func AbortWithError(ctx *gin.Context, err error, status int, code string) bool {
+	return abortWithError(ctx, err, status, code, problem)
+}
+
+// AbortWithProblem is as AbortWithError, but always responds with application/problem+json
+func AbortWithProblem(ctx *gin.Context, err error, status int, code string) bool {
+	return abortWithError(ctx, err, status, code, true)
+}
+
+func abortWithError(ctx *gin.Context, err error, status int, code string, asProblem bool) bool {
 	if err != nil {
-		body := gin.H{"code": code}
-		if detail && !errors.Is(err, ErrNoDetail) {
-			body["error"] = err.Error()
-		}
-		ctx.AbortWithStatusJSON(status, body)
+		status, body := response(err, status, code, getConfig(ctx))
+		write(ctx, status, body, asProblem)
 		return true
 	}
 	return false
 }
 
+// Abort with the response body, converted to a problem if required
+func write(ctx *gin.Context, status int, body gin.H, asProblem bool) {
+	if lang := localise(ctx, body); lang != "" {
+		ctx.Header("Content-Language", lang)
+	}
+	if requestID {
+		if id := zlog.GetRequestID(ctx); id != "" {
+			body["request_id"] = id
+		}
+		if tc := zlog.GetTraceContext(ctx); tc.Valid() {
+			body["trace_id"] = tc.TraceID
+		}
+	}
+	if asProblem {
+		ctx.Header("Content-Type", ProblemContentType)
+		body = toProblem(ctx, status, body)
+	}
+	ctx.AbortWithStatusJSON(status, body)
+}
+
+// Convert a response body to an RFC 7807 problem. The message becomes the detail, and other fields are kept as
+// extension members.
+func toProblem(ctx *gin.Context, status int, body gin.H) gin.H {
+	p := gin.H{
+		"type":     "about:blank",
+		"title":    http.StatusText(status),
+		"status":   status,
+		"instance": ctx.Request.URL.Path,
+	}
+	if problemType != "" {
+		p["type"] = problemType + body["code"].(string)
+	}
+	if id := zlog.GetRequestID(ctx); id != "" {
+		p["instance"] = ctx.Request.URL.Path + "#" + id
+	}
+	for k, v := range body {
+		if k == "message" {
+			k = "detail"
+		}
+		p[k] = v
+	}
+	return p
+}
+
+// Build the response status and body for an error
+func response(err error, status int, code string, cfg Config) (int, gin.H) {
+	if errs := unwrapMulti(err); errs != nil {
+		return multiResponse(errs, status, code, cfg)
+	}
+	body := gin.H{"code": code}
+	var e *E
+	if errors.As(err, &e) {
+		status = e.status
+		body["code"] = e.code
+		if e.msg != "" {
+			body["message"] = e.msg
+		}
+		if len(e.meta) > 0 {
+			body["meta"] = e.meta
+		}
+	} else if s, c, ok := lookup(err); ok {
+		status = s
+		body["code"] = c
+	}
+	if vErr := (bind.ErrValidation{}); errors.As(err, &vErr) {
+		body["errors"] = vErr.Fields
+	}
+	if cfg.Detail && !errors.Is(err, ErrNoDetail) {
+		if d := detailFunc(err); d != "" {
+			body["error"] = d
+		}
+	}
+	return status, body
+}
+
 // AbortWith is shorthand for calling AbortWithError using ErrNoDetail to suppress the error detail
 func AbortWith(ctx *gin.Context, status int, code string) bool {
 	return AbortWithError(ctx, ErrNoDetail, status, code)
 }
 
-// SetErrorDetailOutput sets whether the internal error message is included in the JSON response
+// SetRequestIDOutput sets whether the zlog request ID and trace ID are included in error responses, as request_id
+// and trace_id, when available. Enabled by default, so users can quote them in support requests.
+func SetRequestIDOutput(output bool) {
+	requestID = output
+}
+
+// SetProblemOutput sets whether error responses are rendered as RFC 7807 application/problem+json. The problem
+// instance is the request path, with the zlog request ID as the fragment if set.
+func SetProblemOutput(output bool) {
+	problem = output
+}
+
+// SetProblemTypeBase sets a URI prefix for the problem type, which the error code is appended to. By default the
+// type is about:blank.
+//
+//	errors.SetProblemTypeBase("https://errors.example.com/")
+func SetProblemTypeBase(uri string) {
+	problemType = uri
+}
+
+// SetErrorDetailOutput sets whether the internal error message is included in the JSON response, as returned by
+// the function set with SetDetailFunc. Router groups can override it with WithDetail.
 func SetErrorDetailOutput(output bool) {
 	detail = output
 }
@@ -0,0 +1,417 @@
+package errors
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/", handler)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestAbortWithError(t *testing.T) {
+	w := serve(func(ctx *gin.Context) {
+		if AbortWithError(ctx, nil, 400, "unused") {
+			t.Fail()
+		}
+		AbortWithError(ctx, errors.New("bad"), 400, "bad_request")
+	})
+	assert.Equal(t, 400, w.Code)
+	assert.JSONEq(t, `{"code":"bad_request"}`, w.Body.String())
+
+	SetErrorDetailOutput(true)
+	defer SetErrorDetailOutput(false)
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("bad"), 400, "bad_request")
+	})
+	assert.JSONEq(t, `{"code":"bad_request","error":"bad"}`, w.Body.String())
+
+	w = serve(func(ctx *gin.Context) {
+		AbortWith(ctx, 403, "forbidden")
+	})
+	assert.JSONEq(t, `{"code":"forbidden"}`, w.Body.String())
+}
+
+func TestDetail(t *testing.T) {
+	for in, out := range map[string]string{
+		"bad": "bad",
+		"loading user 7: pq: relation \"users\" does not exist":     "loading user 7",
+		"insert: Error 1062: Duplicate entry 'a@b' for key 'email'": "insert",
+		"panic: boom\ngoroutine 1 [running]:":                       "panic: boom",
+		"ERROR: syntax error at or near \"x\"":                      "",
+	} {
+		assert.Equal(t, out, DefaultDetail(errors.New(in)), in)
+	}
+
+	SetErrorDetailOutput(true)
+	SetDetailFunc(func(err error) string { return "redacted" })
+	defer SetErrorDetailOutput(false)
+	defer SetDetailFunc(DefaultDetail)
+	w := serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("secret"), 500, "internal_error")
+	})
+	assert.JSONEq(t, `{"code":"internal_error","error":"redacted"}`, w.Body.String())
+
+	SetDetailFunc(DefaultDetail)
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("sql: connection is already closed"), 500, "internal_error")
+	})
+	assert.JSONEq(t, `{"code":"internal_error"}`, w.Body.String())
+}
+
+var errNotFound = New("user_not_found").Status(404).Msg("User not found")
+
+func TestE(t *testing.T) {
+	cause := errors.New("sql: no rows")
+	err := fmt.Errorf("loading: %w", errNotFound.Meta("id", 7).Wrap(cause))
+
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, New("other"))
+	assert.Equal(t, "loading: user_not_found: User not found: sql: no rows", err.Error())
+
+	// The declared error is unchanged
+	assert.Empty(t, errNotFound.Metadata())
+	assert.Nil(t, errNotFound.Unwrap())
+
+	w := serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, err, 500, "internal_error")
+	})
+	assert.Equal(t, 404, w.Code)
+	assert.JSONEq(t, `{"code":"user_not_found","message":"User not found","meta":{"id":7}}`, w.Body.String())
+}
+
+type createUser struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithIDGenerator(func() string { return "req1" })), Handler())
+	e.POST("/users", bind.To(createUser{}, bind.WithAbort(true)), func(ctx *gin.Context) {})
+	e.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Error(errNotFound.Wrap(errors.New("no rows")))
+	})
+	e.GET("/teapot", func(ctx *gin.Context) {
+		ctx.Status(http.StatusTeapot)
+		ctx.Error(errors.New("short and stout"))
+	})
+	e.GET("/fail", func(ctx *gin.Context) {
+		ctx.Error(errors.New("db down"))
+	})
+	e.GET("/written", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "partial")
+		ctx.Error(errors.New("late"))
+	})
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		res                string
+	}{
+		{"POST", "/users", `{}`, 400, `{"code":"validation_error","errors":[{"field":"Name","path":"name","rule":"required"}],"request_id":"req1"}`},
+		{"POST", "/users", `{`, 400, `{"code":"binding_error","request_id":"req1"}`},
+		{"GET", "/users/1", "", 404, `{"code":"user_not_found","message":"User not found","request_id":"req1"}`},
+		{"GET", "/teapot", "", 418, `{"code":"internal_error","request_id":"req1"}`},
+		{"GET", "/fail", "", 500, `{"code":"internal_error","request_id":"req1"}`},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.path)
+		assert.JSONEq(t, tc.res, w.Body.String(), tc.path)
+	}
+	assert.Contains(t, buf.String(), `"error_code":"user_not_found"`)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/written", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "partial", w.Body.String())
+}
+
+type quotaError struct{ limit int }
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota %d exceeded", e.limit) }
+
+func TestRegister(t *testing.T) {
+	errConflict := errors.New("conflict")
+	Register(errConflict, http.StatusConflict, "conflict")
+	RegisterType[*quotaError](http.StatusTooManyRequests, "quota_exceeded")
+	assert.Contains(t, Mappings(), Mapping{Status: http.StatusConflict, Code: "conflict"})
+
+	w := serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, fmt.Errorf("save: %w", errConflict), 500, "internal_error")
+	})
+	assert.Equal(t, 409, w.Code)
+	assert.JSONEq(t, `{"code":"conflict"}`, w.Body.String())
+
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, fmt.Errorf("query: %w", sql.ErrNoRows), 500, "internal_error")
+	})
+	assert.Equal(t, 404, w.Code)
+	assert.JSONEq(t, `{"code":"not_found"}`, w.Body.String())
+
+	// E takes precedence over the registry
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, errNotFound.Status(410).Wrap(errConflict), 500, "internal_error")
+	})
+	assert.Equal(t, 410, w.Code)
+
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(Handler())
+	e.GET("/", func(ctx *gin.Context) {
+		ctx.Error(fmt.Errorf("upload: %w", &quotaError{limit: 10}))
+	})
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 429, w.Code)
+	assert.JSONEq(t, `{"code":"quota_exceeded"}`, w.Body.String())
+
+	// Plain errors are not matched by type
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("other"), 500, "internal_error")
+	})
+	assert.Equal(t, 500, w.Code)
+}
+
+func TestProblem(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithIDGenerator(func() string { return "req1" })))
+	e.GET("/users/:id", func(ctx *gin.Context) {
+		AbortWithProblem(ctx, errNotFound.Meta("id", 7), 500, "internal_error")
+	})
+	e.GET("/fail", func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("db down"), 500, "internal_error")
+	})
+	handled := e.Group("/handled", Handler(WithProblem(false)))
+	handled.GET("/fail", func(ctx *gin.Context) {
+		ctx.Error(errors.New("db down"))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/7", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"User not found",
+		"instance":"/users/7#req1","code":"user_not_found","meta":{"id":7},"request_id":"req1"}`, w.Body.String())
+
+	SetProblemOutput(true)
+	SetProblemTypeBase("https://errors.example.com/")
+	defer SetProblemOutput(false)
+	defer SetProblemTypeBase("")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fail", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"https://errors.example.com/internal_error","title":"Internal Server Error","status":500,
+		"instance":"/fail#req1","code":"internal_error","request_id":"req1"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/handled/fail", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"internal_error","request_id":"req1"}`, w.Body.String())
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	var recorded error
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithIDGenerator(func() string { return "req1" })), Handler(), func(ctx *gin.Context) {
+		ctx.Next()
+		recorded = ctx.Errors.Last()
+	}, Recovery())
+	e.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+	assert.JSONEq(t, `{"code":"internal_error","request_id":"req1"}`, w.Body.String())
+	assert.Contains(t, buf.String(), `"errors":["internal_error: panic: boom"]`)
+
+	var p *PanicError
+	assert.True(t, errors.As(recorded, &p))
+	assert.Equal(t, "boom", p.Value)
+	assert.Contains(t, string(p.Stack), "TestRecovery")
+}
+
+func TestMustCheck(t *testing.T) {
+	w := serve(func(ctx *gin.Context) {
+		v, ok := Must(ctx, 7, nil, 500, "internal_error")
+		assert.True(t, ok)
+		assert.Equal(t, 7, v)
+		assert.False(t, Check(ctx, nil))
+
+		if _, ok := Must(ctx, "", fmt.Errorf("lookup: %w", sql.ErrNoRows), 500, "internal_error"); !ok {
+			return
+		}
+		t.Fail()
+	})
+	assert.Equal(t, 404, w.Code)
+	assert.JSONEq(t, `{"code":"not_found"}`, w.Body.String())
+
+	w = serve(func(ctx *gin.Context) {
+		assert.True(t, Check(ctx, errors.New("db down")))
+	})
+	assert.Equal(t, 500, w.Code)
+	assert.JSONEq(t, `{"code":"internal_error"}`, w.Body.String())
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithIDGenerator(func() string { return "req1" }), zlog.WithTraceContext()))
+	e.GET("/", func(ctx *gin.Context) {
+		AbortWith(ctx, 403, "forbidden")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"code":"forbidden","request_id":"req1","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+		w.Body.String())
+
+	SetRequestIDOutput(false)
+	defer SetRequestIDOutput(true)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"code":"forbidden"}`, w.Body.String())
+}
+
+type joined []error
+
+func (j joined) Error() string   { return "joined" }
+func (j joined) Unwrap() []error { return j }
+
+func TestAbortWithErrors(t *testing.T) {
+	w := serve(func(ctx *gin.Context) {
+		assert.False(t, AbortWithErrors(ctx, []error{nil, nil}, 422, "unused"))
+		AbortWithErrors(ctx, []error{
+			errNotFound.Meta("id", 1),
+			nil,
+			fmt.Errorf("item 3: %w", sql.ErrNoRows),
+			errors.New("failed"),
+		}, 422, "batch_failed")
+	})
+	assert.Equal(t, 422, w.Code)
+	assert.JSONEq(t, `{"code":"batch_failed","errors":[
+		{"code":"user_not_found","message":"User not found","meta":{"id":1}},
+		{"code":"not_found"},
+		{"code":"batch_failed"}
+	]}`, w.Body.String())
+
+	// As created by errors.Join
+	SetErrorDetailOutput(true)
+	defer SetErrorDetailOutput(false)
+	w = serve(func(ctx *gin.Context) {
+		AbortWithError(ctx, joined{errors.New("a"), errors.New("b")}, 400, "invalid")
+	})
+	assert.Equal(t, 400, w.Code)
+	assert.JSONEq(t, `{"code":"invalid","errors":[{"code":"invalid","error":"a"},{"code":"invalid","error":"b"}]}`,
+		w.Body.String())
+}
+
+func TestWithDetail(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	SetRequestIDOutput(false)
+	defer SetRequestIDOutput(true)
+
+	e := gin.New()
+	fail := func(ctx *gin.Context) {
+		AbortWithError(ctx, errors.New("db down"), 500, "internal_error")
+	}
+	e.GET("/public", fail)
+	e.Group("/internal", WithDetail(true)).GET("/", fail)
+	e.Group("/quiet", WithConfig(Config{Detail: false})).GET("/", fail)
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	assert.JSONEq(t, `{"code":"internal_error"}`, get("/public"))
+	assert.JSONEq(t, `{"code":"internal_error","error":"db down"}`, get("/internal/"))
+
+	SetErrorDetailOutput(true)
+	defer SetErrorDetailOutput(false)
+	assert.JSONEq(t, `{"code":"internal_error","error":"db down"}`, get("/public"))
+	assert.JSONEq(t, `{"code":"internal_error"}`, get("/quiet/"))
+}
+
+func TestMessages(t *testing.T) {
+	RegisterMessage("item_not_found", "en", "Item {id} not found")
+	RegisterMessage("item_not_found", "de", "Artikel {id} nicht gefunden")
+	RegisterMessage("item_not_found", "pt-BR", "Item {id} não encontrado")
+	errItem := New("item_not_found").Status(404).Msg("Not found")
+
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/", func(ctx *gin.Context) {
+		AbortWithError(ctx, errItem.Meta("id", 7), 500, "internal_error")
+	})
+	get := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", accept)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	for accept, msg := range map[string]string{
+		"de-CH, de;q=0.9":     "Artikel 7 nicht gefunden",
+		"pt-BR":               "Item 7 não encontrado",
+		"fr;q=0.9, de;q=0.5":  "Artikel 7 nicht gefunden",
+		"fr, en-GB;q=0.8":     "Item 7 not found",
+		"":                    "Item 7 not found",
+		"de;q=0, pt-PT;q=0.1": "Item 7 not found",
+	} {
+		assert.JSONEq(t, `{"code":"item_not_found","message":"`+msg+`","meta":{"id":7}}`, get(accept).Body.String(), accept)
+	}
+	assert.Equal(t, "de", get("de").Header().Get("Content-Language"))
+
+	SetDefaultLanguage("fr")
+	defer SetDefaultLanguage("en")
+	w := get("es")
+	assert.JSONEq(t, `{"code":"item_not_found","message":"Not found","meta":{"id":7}}`, w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Language"))
+
+	assert.Equal(t, []string{"pt-br", "pt", "en"}, acceptLanguages("pt-BR,en;q=0.5,*;q=0.1"))
+}
@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+type handlerOpts struct {
+	status  int    // Status for unmapped errors
+	code    string // Code for unmapped errors
+	problem *bool  // Problem output, global setting if nil
+}
+
+// Modifier function for customising error handler behaviour
+type HandlerOpts func(*handlerOpts) *handlerOpts
+
+// WithDefault sets the status and code of errors without a mapping (default 500 "internal_error")
+func WithDefault(status int, code string) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.status = status
+		ho.code = code
+		return ho
+	}
+}
+
+// WithProblem sets whether responses are rendered as application/problem+json, overriding SetProblemOutput
+func WithProblem(enabled bool) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.problem = &enabled
+		return ho
+	}
+}
+
+// Handler middleware writes a single consistent error response for errors added to ctx.Errors by later handlers,
+// e.g. by bind.WithAbort or ctx.Error(err), so handlers only need to record the error and return:
+//
+//	e.Use(zlog.Logger(zerolog.InfoLevel), errors.Handler())
+//	...
+//	if err != nil {
+//		ctx.Error(ErrUserNotFound.Wrap(err))
+//		return
+//	}
+//
+// The last error determines the response. E errors use their own status and code, registered errors their mapped
+// status and code, bind errors respond 400 "validation_error" or "binding_error", and a status already set by the
+// handler, e.g. with AbortWithStatus, is kept. Other errors use the default. Nothing is written if the handler already wrote a response.
+//
+// The code is added to the request logger as error_code, so it appears on the zlog RES line alongside the errors.
+func Handler(opts ...HandlerOpts) gin.HandlerFunc {
+	ho := &handlerOpts{status: http.StatusInternalServerError, code: "internal_error"}
+	for _, f := range opts {
+		ho = f(ho)
+	}
+
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		last := ctx.Errors.Last()
+		if last == nil || ctx.Writer.Written() {
+			return
+		}
+
+		status, code := ho.status, ho.code
+		if s := ctx.Writer.Status(); s != http.StatusOK {
+			status = s
+		}
+		if vErr := (bind.ErrValidation{}); errors.As(last.Err, &vErr) {
+			status, code = http.StatusBadRequest, "validation_error"
+		} else if last.IsType(gin.ErrorTypeBind) || errors.As(last.Err, &bind.ErrDecode{}) {
+			status, code = http.StatusBadRequest, "binding_error"
+		}
+
+		status, body := response(last.Err, status, code, getConfig(ctx))
+		zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("error_code", body["code"].(string))
+		})
+		asProblem := problem
+		if ho.problem != nil {
+			asProblem = *ho.problem
+		}
+		write(ctx, status, body, asProblem)
+	}
+}
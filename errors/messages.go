@@ -0,0 +1,131 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	messagesMu      sync.RWMutex
+	messages        = map[string]map[string]string{} // Templates by code and language
+	defaultLanguage = "en"
+)
+
+// RegisterMessage adds a localised public message for an error code, in a language such as en or pt-BR. Templates
+// can reference metadata of E errors as {key}. Responses include the message in the language best matching the
+// Accept-Language header, falling back to the default language, then to the message of the E error.
+//
+//	errors.RegisterMessage("user_not_found", "en", "User {id} not found")
+//	errors.RegisterMessage("user_not_found", "de", "Benutzer {id} nicht gefunden")
+func RegisterMessage(code, lang, template string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	if messages[code] == nil {
+		messages[code] = map[string]string{}
+	}
+	messages[code][strings.ToLower(lang)] = template
+}
+
+// SetDefaultLanguage sets the language of messages used when none match the Accept-Language header (default en)
+func SetDefaultLanguage(lang string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	defaultLanguage = strings.ToLower(lang)
+}
+
+// Set the localised message of a response body and any individual errors, returning the language used
+func localise(ctx *gin.Context, body gin.H) string {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	if len(messages) == 0 {
+		return ""
+	}
+	langs := append(acceptLanguages(ctx.GetHeader("Accept-Language")), defaultLanguage)
+	used := localiseBody(body, langs)
+	if list, ok := body["errors"].([]gin.H); ok {
+		for _, b := range list {
+			if l := localiseBody(b, langs); used == "" {
+				used = l
+			}
+		}
+	}
+	return used
+}
+
+func localiseBody(body gin.H, langs []string) string {
+	code, _ := body["code"].(string)
+	templates := messages[code]
+	if templates == nil {
+		return ""
+	}
+	for _, lang := range langs {
+		if tmpl, ok := templates[lang]; ok {
+			meta, _ := body["meta"].(map[string]interface{})
+			body["message"] = expand(tmpl, meta)
+			return lang
+		}
+	}
+	return ""
+}
+
+// Replace {key} in the template with metadata values
+func expand(tmpl string, meta map[string]interface{}) string {
+	if len(meta) == 0 || !strings.Contains(tmpl, "{") {
+		return tmpl
+	}
+	pairs := make([]string, 0, len(meta)*2)
+	for k, v := range meta {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// Parse an Accept-Language header into lower case language tags by descending quality, with base languages added
+// after their regional variants, e.g. "pt-BR,en;q=0.5" gives pt-br, pt, en
+func acceptLanguages(header string) []string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				if p, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = p
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var res []string
+	seen := map[string]bool{}
+	add := func(l string) {
+		if !seen[l] {
+			seen[l] = true
+			res = append(res, l)
+		}
+	}
+	for _, t := range tags {
+		add(t.lang)
+		if i := strings.Index(t.lang, "-"); i > 0 {
+			add(t.lang[:i])
+		}
+	}
+	return res
+}
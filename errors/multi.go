@@ -0,0 +1,69 @@
+package errors
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Several independent errors, unwrapping as errors.Join does
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+// AbortWithErrors aborts with the status and code, and an errors array with an entry for each non-nil error, as
+// AbortWithError would respond with its code, message, metadata and detail. Returns false if all errors are nil.
+//
+//	var errs []error
+//	for _, item := range batch {
+//		errs = append(errs, process(item))
+//	}
+//	if errors.AbortWithErrors(ctx, errs, 422, "batch_failed") {
+//		return
+//	}
+//
+// Errors combining several errors, as created by errors.Join or fmt.Errorf with multiple %w verbs, passed to
+// AbortWithError or recorded for Handler are reported the same way.
+func AbortWithErrors(ctx *gin.Context, errs []error, status int, code string) bool {
+	var m multiError
+	for _, err := range errs {
+		if err != nil {
+			m = append(m, err)
+		}
+	}
+	if len(m) == 0 {
+		return false
+	}
+	return AbortWithError(ctx, m, status, code)
+}
+
+// Return the errors combined in err, or nil if it is not a multiple error
+func unwrapMulti(err error) []error {
+	if m, ok := err.(interface{ Unwrap() []error }); ok {
+		return m.Unwrap()
+	}
+	return nil
+}
+
+// Build the response for a multiple error, with the status and code given, and a body entry for each error
+func multiResponse(errs []error, status int, code string, cfg Config) (int, gin.H) {
+	list := make([]gin.H, 0, len(errs))
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		_, body := response(err, status, code, cfg)
+		list = append(list, body)
+	}
+	return status, gin.H{"code": code, "errors": list}
+}
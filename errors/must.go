@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Must returns the value and true if err is nil, otherwise aborts as AbortWithError and returns false:
+//
+//	user, err := store.GetUser(ctx, id)
+//	user, ok := errors.Must(ctx, user, err, 500, "internal_error")
+//	if !ok {
+//		return
+//	}
+func Must[T any](ctx *gin.Context, value T, err error, status int, code string) (T, bool) {
+	if AbortWithError(ctx, err, status, code) {
+		return value, false
+	}
+	return value, true
+}
+
+// Check is AbortWithError relying on E errors and the mappings added by Register for the status and code, with
+// 500 "internal_error" for other errors. Returns true if the request was aborted:
+//
+//	if errors.Check(ctx, store.DeleteUser(ctx, id)) {
+//		return
+//	}
+func Check(ctx *gin.Context, err error) bool {
+	return AbortWithError(ctx, err, http.StatusInternalServerError, "internal_error")
+}
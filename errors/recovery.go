@@ -0,0 +1,45 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPanic is the error recorded by Recovery, responding 500 "internal_error"
+var ErrPanic = New("internal_error").Status(http.StatusInternalServerError)
+
+// PanicError is a recovered panic, wrapped by ErrPanic
+type PanicError struct {
+	Value interface{} // Recovered value
+	Stack []byte      // Stack of the panicking goroutine
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap returns the recovered value if it is an error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Recovery middleware recovers from panics in later handlers, recording them on ctx.Errors as ErrPanic wrapping a
+// PanicError and aborting, without writing a response. Use after Handler, so panics get the same response as other
+// errors, and the zlog RES line records the panic alongside other errors:
+//
+//	e.Use(zlog.Logger(zerolog.InfoLevel), errors.Handler(), errors.Recovery())
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			if v := recover(); v != nil {
+				ctx.Error(ErrPanic.Wrap(&PanicError{Value: v, Stack: debug.Stack()}))
+				ctx.Abort()
+			}
+		}()
+		ctx.Next()
+	}
+}
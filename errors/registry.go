@@ -0,0 +1,81 @@
+package errors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+type mapping struct {
+	match  func(error) bool
+	status int
+	code   string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []mapping
+)
+
+func init() {
+	Register(sql.ErrNoRows, http.StatusNotFound, "not_found")
+	Register(context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout")
+	RegisterType[*http.MaxBytesError](http.StatusRequestEntityTooLarge, "request_too_large")
+}
+
+// Register maps errors matching target with errors.Is to a response status and code, used by AbortWithError and
+// Handler in place of those given at the call site. Mappings are checked in registration order, after E errors
+// which always use their own status and code. sql.ErrNoRows, context.DeadlineExceeded and *http.MaxBytesError are
+// registered by default.
+//
+//	errors.Register(store.ErrConflict, 409, "conflict")
+func Register(target error, status int, code string) {
+	register(mapping{match: func(err error) bool { return errors.Is(err, target) }, status: status, code: code})
+}
+
+// RegisterType maps errors matching the type T with errors.As to a response status and code, as for Register
+//
+//	errors.RegisterType[*store.ValidationError](422, "invalid_entity")
+func RegisterType[T error](status int, code string) {
+	register(mapping{match: func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, status: status, code: code})
+}
+
+func register(m mapping) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Return the status and code of the first mapping matching the error
+func lookup(err error) (int, string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, m := range registry {
+		if m.match(err) {
+			return m.status, m.code, true
+		}
+	}
+	return 0, "", false
+}
+
+// Mapping is a registered error mapping
+type Mapping struct {
+	Status int
+	Code   string
+}
+
+// Mappings returns the registered mappings in registration order, e.g. to document the error codes of an API
+func Mappings() []Mapping {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	res := make([]Mapping, len(registry))
+	for i, m := range registry {
+		res[i] = Mapping{Status: m.status, Code: m.code}
+	}
+	return res
+}
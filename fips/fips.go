@@ -0,0 +1,194 @@
+// Restricted crypto mode
+//
+// When enabled, ginx packages only accept FIPS 140 approved algorithms and key sizes: TLS configurations are limited
+// to TLS 1.2 with AES-GCM cipher suites and NIST curves, JWT verification to SHA-2 based algorithms with adequate
+// keys, and password hashing is refused as neither argon2id nor bcrypt is approved. Subsystems configured with a
+// disallowed algorithm panic when they are created, so a misconfiguration fails at startup rather than in production
+// traffic.
+//
+// The mode is enabled by building with the fips tag, or by calling Enable before configuring other packages:
+//
+//	go build -tags fips ./...
+//
+//	if cfg.FIPS {
+//		fips.Enable()
+//	}
+//
+// This restricts which algorithms ginx uses, it doesn't make the Go crypto implementation a validated module.
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrNotApproved is wrapped by errors for algorithms and keys not approved in FIPS mode
+var ErrNotApproved = errors.New("not FIPS approved")
+
+var enabled atomic.Bool
+
+func init() {
+	if buildTag {
+		enabled.Store(true)
+	}
+}
+
+// Enabled reports whether FIPS mode is enabled
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Enable enables FIPS mode. Subsystems check the mode when created, so this must be called first.
+func Enable() {
+	enabled.Store(true)
+}
+
+// Approved algorithm names: JWS algorithms, hashes and password hashing schemes
+var approved = map[string]bool{
+	"HS256": true, "HS384": true, "HS512": true,
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"SHA-224": true, "SHA-256": true, "SHA-384": true, "SHA-512": true, "SHA-512/224": true, "SHA-512/256": true,
+	"SHA3-224": true, "SHA3-256": true, "SHA3-384": true, "SHA3-512": true,
+	"pbkdf2": true,
+}
+
+// Approved reports whether the algorithm is approved, e.g. RS256, SHA-256 or argon2id
+func Approved(algorithm string) bool {
+	return approved[algorithm]
+}
+
+// ApprovedHash reports whether the hash is approved
+func ApprovedHash(h crypto.Hash) bool {
+	return approved[h.String()]
+}
+
+// Check returns an error if FIPS mode is enabled and the algorithm isn't approved
+func Check(subsystem, algorithm string) error {
+	if !Enabled() || Approved(algorithm) {
+		return nil
+	}
+	return fmt.Errorf("fips: %s configured with %s: %w", subsystem, algorithm, ErrNotApproved)
+}
+
+// CheckKey returns an error if FIPS mode is enabled and the key is too weak: RSA keys under 2048 bits, ECDSA keys
+// not on a NIST P curve, or HMAC secrets ([]byte) under 112 bits
+func CheckKey(subsystem string, key interface{}) error {
+	if !Enabled() {
+		return nil
+	}
+	var weak string
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			weak = fmt.Sprintf("%d bit RSA key", k.N.BitLen())
+		}
+	case *ecdsa.PublicKey:
+		if c := k.Curve; c != elliptic.P256() && c != elliptic.P384() && c != elliptic.P521() {
+			weak = "ECDSA key on " + c.Params().Name
+		}
+	case []byte:
+		if len(k) < 14 {
+			weak = fmt.Sprintf("%d bit HMAC secret", len(k)*8)
+		}
+	default:
+		weak = fmt.Sprintf("%T key", key)
+	}
+	if weak == "" {
+		return nil
+	}
+	return fmt.Errorf("fips: %s configured with %s: %w", subsystem, weak, ErrNotApproved)
+}
+
+// Require panics if the algorithm isn't approved in FIPS mode, see Check
+func Require(subsystem, algorithm string) {
+	if err := Check(subsystem, algorithm); err != nil {
+		panic(err)
+	}
+}
+
+// RequireKey panics if the key is too weak in FIPS mode, see CheckKey
+func RequireKey(subsystem string, key interface{}) {
+	if err := CheckKey(subsystem, key); err != nil {
+		panic(err)
+	}
+}
+
+// CipherSuites are the approved TLS 1.2 cipher suites
+var CipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// Curves are the approved key exchange curves
+var Curves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// CheckTLS returns an error if FIPS mode is enabled and the configuration explicitly allows a protocol version, cipher
+// suite or curve which isn't approved
+func CheckTLS(subsystem string, cfg *tls.Config) error {
+	if !Enabled() {
+		return nil
+	}
+	if cfg.MinVersion != 0 && cfg.MinVersion < tls.VersionTLS12 {
+		return fmt.Errorf("fips: %s configured with TLS versions before 1.2: %w", subsystem, ErrNotApproved)
+	}
+	for _, s := range cfg.CipherSuites {
+		if !containsSuite(s) {
+			return fmt.Errorf("fips: %s configured with %s: %w", subsystem, tls.CipherSuiteName(s), ErrNotApproved)
+		}
+	}
+	for _, c := range cfg.CurvePreferences {
+		if !containsCurve(c) {
+			return fmt.Errorf("fips: %s configured with curve %s: %w", subsystem, c, ErrNotApproved)
+		}
+	}
+	return nil
+}
+
+// TLSConfig restricts the configuration in place to approved parameters if FIPS mode is enabled, and returns it.
+// TLS 1.3 is disabled, as its cipher suites can't be configured in crypto/tls and include ChaCha20-Poly1305.
+// Panics if the configuration explicitly allows unapproved parameters, see CheckTLS.
+func TLSConfig(subsystem string, cfg *tls.Config) *tls.Config {
+	if !Enabled() {
+		return cfg
+	}
+	if err := CheckTLS(subsystem, cfg); err != nil {
+		panic(err)
+	}
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	if len(cfg.CipherSuites) == 0 {
+		cfg.CipherSuites = CipherSuites
+	}
+	if len(cfg.CurvePreferences) == 0 {
+		cfg.CurvePreferences = Curves
+	}
+	return cfg
+}
+
+func containsSuite(s uint16) bool {
+	for _, a := range CipherSuites {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCurve(c tls.CurveID) bool {
+	for _, a := range Curves {
+		if a == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withFIPS(t *testing.T) {
+	prev := enabled.Load()
+	Enable()
+	t.Cleanup(func() { enabled.Store(prev) })
+}
+
+func TestCheck(t *testing.T) {
+	if !buildTag {
+		assert.False(t, Enabled())
+		assert.NoError(t, Check("passwordx", "argon2id"))
+	}
+	withFIPS(t)
+
+	assert.NoError(t, Check("auth.JWT", "RS256"))
+	assert.True(t, ApprovedHash(crypto.SHA384))
+	assert.False(t, ApprovedHash(crypto.MD5))
+	err := Check("passwordx", "argon2id")
+	assert.ErrorIs(t, err, ErrNotApproved)
+	assert.EqualError(t, err, "fips: passwordx configured with argon2id: not FIPS approved")
+	assert.PanicsWithError(t, "fips: auth.JWT configured with none: not FIPS approved", func() { Require("auth.JWT", "none") })
+}
+
+func TestCheckKey(t *testing.T) {
+	withFIPS(t)
+
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	assert.EqualError(t, CheckKey("auth.JWT", &weak.PublicKey), "fips: auth.JWT configured with 1024 bit RSA key: not FIPS approved")
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, CheckKey("auth.JWT", &ec.PublicKey))
+	assert.NoError(t, CheckKey("auth.JWT", []byte("0123456789abcdef")))
+	assert.ErrorIs(t, CheckKey("auth.JWT", []byte("short")), ErrNotApproved)
+	assert.Panics(t, func() { RequireKey("auth.JWT", "not a key") })
+}
+
+func TestTLSConfig(t *testing.T) {
+	if !buildTag {
+		cfg := TLSConfig("test", &tls.Config{})
+		assert.Empty(t, cfg.CipherSuites)
+	}
+	withFIPS(t)
+
+	cfg := TLSConfig("test", &tls.Config{})
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	assert.Equal(t, CipherSuites, cfg.CipherSuites)
+	assert.Equal(t, Curves, cfg.CurvePreferences)
+
+	assert.ErrorIs(t, CheckTLS("test", &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}}), ErrNotApproved)
+	assert.ErrorIs(t, CheckTLS("test", &tls.Config{MinVersion: tls.VersionTLS10}), ErrNotApproved)
+	assert.ErrorIs(t, CheckTLS("test", &tls.Config{CurvePreferences: []tls.CurveID{tls.X25519}}), ErrNotApproved)
+	assert.Panics(t, func() { TLSConfig("test", &tls.Config{CurvePreferences: []tls.CurveID{tls.X25519}}) })
+}
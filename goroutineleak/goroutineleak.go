@@ -0,0 +1,231 @@
+// Request-scoped goroutine tracking
+//
+// Launches goroutines tied to the request that started them, and reports those still running a grace period after
+// the request completes. Leaked goroutines usually only show up as slow memory growth, this names them.
+//
+//	t := goroutineleak.New(5 * time.Second)
+//	e.Use(zlog.Logger(zerolog.InfoLevel), t.Middleware())
+//	...
+//	goroutineleak.Go(ctx, "send-email", func(ctx context.Context) {
+//		...
+//	})
+//	admin.GET("/leaks", t.Handler())
+//
+// Leaks are logged through the request logger with the launch site. Goroutines launched with a context that isn't
+// from a tracked request are run untracked.
+package goroutineleak
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Leak is a goroutine which outlived its request
+type Leak struct {
+	Name      string    `json:"name"`
+	Caller    string    `json:"caller"` // Launch site as file:line
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Started   time.Time `json:"started"`
+	Ended     time.Time `json:"request_ended"`
+}
+
+type trackerOpts struct {
+	report func(Leak)
+}
+
+// Modifier function for customising tracker behaviour
+type TrackerOpts func(*trackerOpts) *trackerOpts
+
+// WithReportFunc sets a function called once for each leaked goroutine, in addition to logging
+func WithReportFunc(fn func(Leak)) TrackerOpts {
+	return func(to *trackerOpts) *trackerOpts {
+		to.report = fn
+		return to
+	}
+}
+
+// Tracker tracks goroutines launched by requests
+type Tracker struct {
+	grace time.Duration
+	opts  *trackerOpts
+
+	mu     sync.Mutex
+	leaked map[*task]Leak
+}
+
+type scopeKey struct{}
+
+// Goroutines launched by a single request
+type scope struct {
+	t       *Tracker
+	logger  *zerolog.Logger
+	request Leak // Request fields of reported leaks
+
+	mu    sync.Mutex
+	tasks map[*task]bool
+	ended time.Time
+}
+
+type task struct {
+	name    string
+	caller  string
+	started time.Time
+	leaked  bool
+}
+
+// New creates a tracker reporting goroutines still running the grace period after their request completes
+func New(grace time.Duration, opts ...TrackerOpts) *Tracker {
+	to := &trackerOpts{}
+	for _, f := range opts {
+		to = f(to)
+	}
+	return &Tracker{grace: grace, opts: to, leaked: map[*task]Leak{}}
+}
+
+// Middleware attaches a goroutine scope to the request context, for use by Go. Use after zlog.Logger() so leaks are
+// logged with the request ID.
+func (t *Tracker) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s := &scope{
+			t:      t,
+			logger: zlog.GetLogger(ctx),
+			request: Leak{
+				RequestID: zlog.GetRequestID(ctx),
+				Method:    ctx.Request.Method,
+				Route:     ctx.FullPath(),
+			},
+			tasks: map[*task]bool{},
+		}
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), scopeKey{}, s))
+		defer s.end()
+		ctx.Next()
+	}
+}
+
+// Go runs fn in a new goroutine tracked by the request scope of the context. The name identifies the goroutine in
+// reports. A gin context is replaced by its request context, as gin reuses contexts once the request completes.
+// The request context is cancelled when the request completes, so goroutines which should outlive it briefly need
+// their own context.
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	if gctx, ok := ctx.(*gin.Context); ok && gctx.Request != nil {
+		ctx = gctx.Request.Context()
+	}
+	s, _ := ctx.Value(scopeKey{}).(*scope)
+	if s == nil {
+		go fn(ctx)
+		return
+	}
+
+	tk := &task{name: name, started: time.Now()}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		tk.caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	s.mu.Lock()
+	s.tasks[tk] = true
+	ended := !s.ended.IsZero()
+	s.mu.Unlock()
+	if ended {
+		// Launched by another goroutine after the request completed
+		time.AfterFunc(s.t.grace, s.check)
+	}
+
+	go func() {
+		defer s.done(tk)
+		fn(ctx)
+	}()
+}
+
+// The tracker's leaked map is updated under the scope lock, so check can't record a task after done removes it
+func (s *scope) done(tk *task) {
+	s.mu.Lock()
+	delete(s.tasks, tk)
+	leaked := tk.leaked
+	if leaked {
+		s.t.mu.Lock()
+		delete(s.t.leaked, tk)
+		s.t.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	if leaked {
+		s.logger.Info().Str("goroutine", tk.name).Dur("elapsed", time.Since(tk.started)).Msg("Leaked goroutine completed")
+	}
+}
+
+func (s *scope) end() {
+	s.mu.Lock()
+	s.ended = time.Now()
+	running := len(s.tasks)
+	s.mu.Unlock()
+	if running > 0 {
+		time.AfterFunc(s.t.grace, s.check)
+	}
+}
+
+// Report goroutines running for the grace period since the later of the request ending and their launch
+func (s *scope) check() {
+	now := time.Now()
+	var leaks []Leak
+
+	s.mu.Lock()
+	for tk := range s.tasks {
+		since := s.ended
+		if tk.started.After(since) {
+			since = tk.started
+		}
+		if !tk.leaked && now.Sub(since) >= s.t.grace {
+			tk.leaked = true
+			l := s.request
+			l.Name, l.Caller, l.Started, l.Ended = tk.name, tk.caller, tk.started, s.ended
+			leaks = append(leaks, l)
+
+			s.t.mu.Lock()
+			s.t.leaked[tk] = l
+			s.t.mu.Unlock()
+		}
+	}
+	ended := s.ended
+	s.mu.Unlock()
+
+	for _, l := range leaks {
+		s.logger.Warn().
+			Str("goroutine", l.Name).
+			Str("caller", l.Caller).
+			Str("route", l.Route).
+			Dur("outlived", now.Sub(ended)).
+			Msg("Goroutine outlived request")
+		if s.t.opts.report != nil {
+			s.t.opts.report(l)
+		}
+	}
+}
+
+// Leaked returns the leaked goroutines still running, oldest first
+func (t *Tracker) Leaked() []Leak {
+	t.mu.Lock()
+	res := make([]Leak, 0, len(t.leaked))
+	for _, l := range t.leaked {
+		res = append(res, l)
+	}
+	t.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Started.Before(res[j].Started) })
+	return res
+}
+
+// Handler responds with the leaked goroutines still running
+func (t *Tracker) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"grace": t.grace.String(), "leaked": t.Leaked()})
+	}
+}
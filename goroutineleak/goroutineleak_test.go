@@ -0,0 +1,99 @@
+package goroutineleak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.Nop()
+
+	var mu sync.Mutex
+	var reports []Leak
+	tr := New(20*time.Millisecond, WithReportFunc(func(l Leak) {
+		mu.Lock()
+		reports = append(reports, l)
+		mu.Unlock()
+	}))
+
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel), tr.Middleware())
+	e.GET("/work", func(ctx *gin.Context) {
+		Go(ctx, "quick", func(context.Context) {})
+		Go(ctx, "stuck", func(context.Context) {
+			<-release
+			close(finished)
+		})
+	})
+	e.GET("/leaks", tr.Handler())
+
+	req, _ := http.NewRequest("GET", "/work", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, tr.Leaked())
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "stuck", reports[0].Name)
+	assert.Equal(t, "/work", reports[0].Route)
+	assert.Contains(t, reports[0].Caller, "goroutineleak_test.go:")
+	mu.Unlock()
+
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/leaks", nil)
+	e.ServeHTTP(w, req)
+	var res struct {
+		Leaked []Leak `json:"leaked"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Len(t, res.Leaked, 1)
+
+	close(release)
+	<-finished
+	assert.Eventually(t, func() bool { return len(tr.Leaked()) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestUntracked(t *testing.T) {
+	done := make(chan struct{})
+	Go(context.Background(), "background", func(context.Context) {
+		close(done)
+	})
+	<-done
+}
+
+func TestCheckDoneRace(t *testing.T) {
+	tr := New(0)
+	nop := zerolog.Nop()
+	for i := 0; i < 1000; i++ {
+		s := &scope{t: tr, logger: &nop, tasks: map[*task]bool{}, ended: time.Now()}
+		tk := &task{name: "worker", started: s.ended.Add(-time.Second)}
+		s.tasks[tk] = true
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.check()
+		}()
+		go func() {
+			defer wg.Done()
+			s.done(tk)
+		}()
+		wg.Wait()
+	}
+	assert.Empty(t, tr.Leaked())
+}
@@ -0,0 +1,212 @@
+// Request header hygiene
+//
+// Strips or normalises inbound headers which handlers shouldn't trust or see: hop-by-hop headers meant for a proxy,
+// path override headers such as X-Original-URL, and forwarding headers not added by a trusted proxy. Requests with
+// too many or too large headers, or repeated single-value headers, are rejected through the errors package.
+//
+//	e.Use(zlog.New(), headerpolicy.New(headerpolicy.WithTrustedProxies("10.0.0.0/8")))
+//
+// X-Forwarded-For is trimmed to the addresses appended by trusted proxies and the first untrusted address before
+// them, the client, so anything the client sent itself is dropped. Without trusted proxies all forwarding headers
+// are removed. Violations are logged through the request logger, stripped headers at debug level.
+package headerpolicy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/internal/ipnets"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Hop-by-hop headers, which are meaningful only to a proxy. Connection and Upgrade are kept for websockets.
+var hopByHop = []string{"Keep-Alive", "Proxy-Connection", "Proxy-Authorization", "Proxy-Authenticate", "Te", "Trailer"}
+
+// DefaultStrip are headers always removed, which some frameworks and proxies use to override the request path
+var DefaultStrip = []string{"X-Original-Url", "X-Rewrite-Url", "X-Http-Method-Override", "X-Http-Method", "X-Method-Override"}
+
+// Forwarding headers, only trusted from trusted proxies
+var forwarding = []string{"X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port", "X-Real-Ip", "Forwarded"}
+
+type policyOpts struct {
+	trusted      []*net.IPNet
+	strip        []string
+	single       []string
+	maxHeaders   int
+	maxValueSize int
+	maxTotalSize int
+}
+
+// Modifier function for customising header policy
+type PolicyOpts func(*policyOpts) *policyOpts
+
+// WithTrustedProxies trusts forwarding headers added by peers within the IPs or CIDR ranges. Panics if an entry isn't
+// an IP or CIDR range.
+func WithTrustedProxies(entries ...string) PolicyOpts {
+	nets, err := ipnets.Parse(entries...)
+	if err != nil {
+		panic("headerpolicy: invalid trusted proxy: " + err.Error())
+	}
+	return func(po *policyOpts) *policyOpts {
+		po.trusted = append(po.trusted, nets...)
+		return po
+	}
+}
+
+// WithStrip also removes the headers (default DefaultStrip)
+func WithStrip(names ...string) PolicyOpts {
+	return func(po *policyOpts) *policyOpts {
+		po.strip = append(po.strip, names...)
+		return po
+	}
+}
+
+// WithSingleValue sets headers which are rejected if repeated (default Authorization and Content-Type)
+func WithSingleValue(names ...string) PolicyOpts {
+	return func(po *policyOpts) *policyOpts {
+		po.single = names
+		return po
+	}
+}
+
+// WithLimits sets the maximum number of header lines (default 100), size of a header name and value (default
+// 8 KiB) and total header size (default 32 KiB), or 0 for no limit
+func WithLimits(count, valueSize, totalSize int) PolicyOpts {
+	return func(po *policyOpts) *policyOpts {
+		po.maxHeaders, po.maxValueSize, po.maxTotalSize = count, valueSize, totalSize
+		return po
+	}
+}
+
+// New creates the header policy middleware from options
+func New(opts ...PolicyOpts) gin.HandlerFunc {
+	po := &policyOpts{
+		strip:        append([]string{}, DefaultStrip...),
+		single:       []string{"Authorization", "Content-Type"},
+		maxHeaders:   100,
+		maxValueSize: 8 << 10,
+		maxTotalSize: 32 << 10,
+	}
+	for _, f := range opts {
+		po = f(po)
+	}
+	strip := append(append([]string{}, hopByHop...), po.strip...)
+	for i, name := range strip {
+		strip[i] = http.CanonicalHeaderKey(name)
+	}
+
+	return func(ctx *gin.Context) {
+		h := ctx.Request.Header
+		if code, name := po.checkLimits(h); code != "" {
+			zlog.GetLogger(ctx).Warn().Str("header", name).Str("violation", code).Msg("Request headers rejected")
+			status := http.StatusRequestHeaderFieldsTooLarge
+			if code == "duplicate_header" {
+				status = http.StatusBadRequest
+			}
+			errors.AbortWith(ctx, status, code)
+			return
+		}
+
+		var stripped []string
+		for _, name := range strip {
+			if _, ok := h[name]; ok {
+				h.Del(name)
+				stripped = append(stripped, name)
+			}
+		}
+		// Headers the client marked as hop-by-hop in Connection, other than Upgrade
+		for _, v := range h.Values("Connection") {
+			for _, name := range strings.Split(v, ",") {
+				name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+				if _, ok := h[name]; ok && name != "Upgrade" && name != "Connection" {
+					h.Del(name)
+					stripped = append(stripped, name)
+				}
+			}
+		}
+		if len(stripped) > 0 {
+			zlog.GetLogger(ctx).Debug().Strs("headers", stripped).Msg("Stripped request headers")
+		}
+
+		po.forwarding(ctx)
+	}
+}
+
+// Return the error code and header name if the headers exceed a limit or repeat a single-value header
+func (po *policyOpts) checkLimits(h http.Header) (string, string) {
+	count, total := 0, 0
+	for name, values := range h {
+		for _, v := range values {
+			count++
+			size := len(name) + len(v)
+			total += size
+			if po.maxValueSize > 0 && size > po.maxValueSize {
+				return "header_too_large", name
+			}
+		}
+	}
+	if po.maxHeaders > 0 && count > po.maxHeaders {
+		return "too_many_headers", ""
+	}
+	if po.maxTotalSize > 0 && total > po.maxTotalSize {
+		return "headers_too_large", ""
+	}
+	for _, name := range po.single {
+		if len(h.Values(name)) > 1 {
+			return "duplicate_header", http.CanonicalHeaderKey(name)
+		}
+	}
+	return "", ""
+}
+
+// Remove forwarding headers not added by trusted proxies, and trim X-Forwarded-For to the trusted chain
+func (po *policyOpts) forwarding(ctx *gin.Context) {
+	h := ctx.Request.Header
+	xff := h.Values("X-Forwarded-For")
+	peer := po.trustedIP(ctx.Request.RemoteAddr)
+	if !peer {
+		var spoofed []string
+		for _, name := range append([]string{"X-Forwarded-For"}, forwarding...) {
+			if _, ok := h[name]; ok {
+				spoofed = append(spoofed, name)
+				h.Del(name)
+			}
+		}
+		if len(spoofed) > 0 {
+			zlog.GetLogger(ctx).Warn().Strs("headers", spoofed).Str("peer", ctx.Request.RemoteAddr).
+				Msg("Forwarding headers from untrusted peer removed")
+		}
+		return
+	}
+	if len(xff) == 0 {
+		return
+	}
+
+	// Walk back from the nearest hop while it is a trusted proxy, keeping the first untrusted address
+	var hops []string
+	for _, v := range xff {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	start := 0
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !po.trustedIP(hops[i]) {
+			start = i
+			break
+		}
+	}
+	if start > 0 {
+		zlog.GetLogger(ctx).Warn().Strs("dropped", hops[:start]).Msg("Spoofed X-Forwarded-For entries removed")
+	}
+	h.Set("X-Forwarded-For", strings.Join(hops[start:], ", "))
+}
+
+func (po *policyOpts) trustedIP(addr string) bool {
+	return ipnets.Contains(po.trusted, addr)
+}
@@ -0,0 +1,94 @@
+package headerpolicy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h gin.HandlerFunc, remote string, headers http.Header) (*httptest.ResponseRecorder, http.Header) {
+	gin.SetMode(gin.TestMode)
+	var seen http.Header
+	e := gin.New()
+	e.GET("/", h, func(ctx *gin.Context) {
+		seen = ctx.Request.Header.Clone()
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remote
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w, seen
+}
+
+func TestStrip(t *testing.T) {
+	_, seen := serve(New(WithStrip("X-Debug")), "1.2.3.4:1000", http.Header{
+		"Keep-Alive":     {"timeout=5"},
+		"Connection":     {"Upgrade, X-Secret"},
+		"Upgrade":        {"websocket"},
+		"X-Secret":       {"1"},
+		"X-Original-Url": {"/admin"},
+		"X-Debug":        {"1"},
+		"Accept":         {"*/*"},
+	})
+	assert.Equal(t, http.Header{"Connection": {"Upgrade, X-Secret"}, "Upgrade": {"websocket"}, "Accept": {"*/*"}}, seen)
+}
+
+func TestForwarding(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+	h := New(WithTrustedProxies("10.0.0.0/8"))
+
+	// Untrusted peer
+	_, seen := serve(h, "1.2.3.4:1000", http.Header{"X-Forwarded-For": {"9.9.9.9"}, "X-Forwarded-Proto": {"https"}})
+	assert.Empty(t, seen.Get("X-Forwarded-For"))
+	assert.Empty(t, seen.Get("X-Forwarded-Proto"))
+	assert.Contains(t, buf.String(), "untrusted peer")
+
+	// Trusted proxy chain, with a spoofed entry from the client
+	buf.Reset()
+	_, seen = serve(h, "10.0.0.2:1000", http.Header{
+		"X-Forwarded-For":   {"6.6.6.6, 5.6.7.8", "10.0.0.1"},
+		"X-Forwarded-Proto": {"https"},
+	})
+	assert.Equal(t, "5.6.7.8, 10.0.0.1", seen.Get("X-Forwarded-For"))
+	assert.Equal(t, "https", seen.Get("X-Forwarded-Proto"))
+	assert.Contains(t, buf.String(), `"dropped":["6.6.6.6"]`)
+
+	// An IPv4-mapped proxy address trusts only that proxy
+	_, seen = serve(New(WithTrustedProxies("::ffff:10.0.0.1")), "[::1]:1000", http.Header{
+		"X-Forwarded-Proto": {"https"},
+	})
+	assert.Empty(t, seen.Get("X-Forwarded-Proto"))
+
+	assert.Panics(t, func() { WithTrustedProxies("nope") })
+}
+
+func TestLimits(t *testing.T) {
+	h := New(WithLimits(3, 20, 40))
+	w, _ := serve(h, "1.2.3.4:1000", http.Header{"A": {"1", "2"}, "B": {"3"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, _ = serve(h, "1.2.3.4:1000", http.Header{"A": {"1", "2"}, "B": {"3", "4"}})
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "too_many_headers")
+
+	w, _ = serve(h, "1.2.3.4:1000", http.Header{"A": {strings.Repeat("x", 20)}})
+	assert.Contains(t, w.Body.String(), "header_too_large")
+
+	w, _ = serve(h, "1.2.3.4:1000", http.Header{"A": {strings.Repeat("x", 15)}, "B": {strings.Repeat("x", 15)}, "C": {strings.Repeat("x", 15)}})
+	assert.Contains(t, w.Body.String(), "headers_too_large")
+
+	w, _ = serve(New(), "1.2.3.4:1000", http.Header{"Authorization": {"Bearer a", "Bearer b"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "duplicate_header")
+}
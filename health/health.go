@@ -0,0 +1,259 @@
+// Liveness and readiness endpoints
+//
+// Checkers for dependencies are registered by name, and run concurrently by the readiness endpoint with a timeout
+// each, caching results briefly so frequent probes don't load the dependencies. The liveness endpoint runs no checks,
+// as a failing dependency shouldn't get the process restarted.
+//
+//	health.Register("db", health.CheckerFunc(db.PingContext), health.WithTimeout(2*time.Second))
+//	e.Use(zlog.New(health.SkipLogging()))
+//	health.Routes(e.Group("/health"))
+//
+// Probe requests are left out of the access log by SkipLogging. Changes in check results are logged instead, so
+// failures are still visible without a log line per probe.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog/log"
+)
+
+// Checker checks a dependency
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to a Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Status of a check or of readiness overall
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Result is the result of a check
+type Result struct {
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Latency float64   `json:"latency_ms"`
+	Checked time.Time `json:"checked"`
+	Cached  bool      `json:"cached,omitempty"`
+}
+
+// Report is the result of all checks
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type checkOpts struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	optional bool
+}
+
+// Modifier function for customising a check
+type CheckOpts func(*checkOpts) *checkOpts
+
+// WithTimeout sets the timeout of the check (default 5 seconds)
+func WithTimeout(d time.Duration) CheckOpts {
+	return func(co *checkOpts) *checkOpts {
+		co.timeout = d
+		return co
+	}
+}
+
+// WithCacheTTL sets how long a result is reused (default 5 seconds), or 0 to check on every probe
+func WithCacheTTL(d time.Duration) CheckOpts {
+	return func(co *checkOpts) *checkOpts {
+		co.cacheTTL = d
+		return co
+	}
+}
+
+// WithOptional reports the check without it failing readiness, e.g. for a cache the service can run without
+func WithOptional() CheckOpts {
+	return func(co *checkOpts) *checkOpts {
+		co.optional = true
+		return co
+	}
+}
+
+type check struct {
+	checker Checker
+	opts    *checkOpts
+
+	mu   sync.Mutex // Held while checking, so concurrent probes share a result
+	last *Result
+}
+
+// Registry holds checkers and the probe routes registered for them
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]*check
+	paths  map[string]bool
+	clock  func() time.Time
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]*check{}, paths: map[string]bool{}, clock: time.Now}
+}
+
+// DefaultRegistry is used by the package level functions
+var DefaultRegistry = NewRegistry()
+
+// Register adds or replaces a checker in the default registry
+func Register(name string, c Checker, opts ...CheckOpts) {
+	DefaultRegistry.Register(name, c, opts...)
+}
+
+// Routes registers the probe endpoints of the default registry, see Registry.Routes
+func Routes(rg *gin.RouterGroup) {
+	DefaultRegistry.Routes(rg)
+}
+
+// SkipLogging returns a zlog option leaving probes of the default registry out of the access log
+func SkipLogging() zlog.LoggerOpts {
+	return DefaultRegistry.SkipLogging()
+}
+
+// Register adds or replaces a checker
+func (r *Registry) Register(name string, c Checker, opts ...CheckOpts) {
+	co := &checkOpts{timeout: 5 * time.Second, cacheTTL: 5 * time.Second}
+	for _, f := range opts {
+		co = f(co)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = &check{checker: c, opts: co}
+}
+
+// Check runs all checks concurrently, using cached results where fresh
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]*check, len(r.checks))
+	for name, c := range r.checks {
+		checks[name] = c
+	}
+	r.mu.RUnlock()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range checks {
+		wg.Add(1)
+		go func(name string, c *check) {
+			defer wg.Done()
+			res := r.run(ctx, name, c)
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[name] = res
+			if res.Status != StatusOK && !c.opts.optional {
+				rep.Status = StatusFail
+			}
+		}(name, c)
+	}
+	wg.Wait()
+	return rep
+}
+
+func (r *Registry) run(ctx context.Context, name string, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := r.clock()
+	if c.last != nil && now.Sub(c.last.Checked) < c.opts.cacheTTL {
+		res := *c.last
+		res.Cached = true
+		return res
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.opts.timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.checker.Check(cctx)
+	res := Result{Status: StatusOK, Latency: float64(time.Since(start).Microseconds()) / 1000, Checked: now}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	// Log transitions rather than each result, as probes aren't logged
+	switch {
+	case err != nil && (c.last == nil || c.last.Status == StatusOK):
+		log.Warn().Err(err).Str("check", name).Msg("Health check failed")
+	case err == nil && c.last != nil && c.last.Status != StatusOK:
+		log.Info().Str("check", name).Msg("Health check recovered")
+	}
+	c.last = &res
+	return res
+}
+
+// Names returns the names of the registered checks, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Routes registers GET /livez, which always responds 200, and GET /readyz, which responds 200 if all required
+// checks pass and 503 otherwise, with the report
+func (r *Registry) Routes(rg *gin.RouterGroup) {
+	rg.GET("/livez", r.LiveHandler())
+	rg.GET("/readyz", r.ReadyHandler())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	base := rg.BasePath()
+	if base == "/" {
+		base = ""
+	}
+	r.paths[base+"/livez"] = true
+	r.paths[base+"/readyz"] = true
+}
+
+// LiveHandler responds 200 while the process is serving
+func (r *Registry) LiveHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// ReadyHandler runs the checks, responding 200 if all required checks pass and 503 otherwise
+func (r *Registry) ReadyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rep := r.Check(ctx.Request.Context())
+		code := http.StatusOK
+		if rep.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.JSON(code, rep)
+	}
+}
+
+// SkipLogging returns a zlog option leaving requests to routes registered by Routes out of the access log. Routes
+// may be registered after the logger is created.
+func (r *Registry) SkipLogging() zlog.LoggerOpts {
+	return zlog.WithSkipFunc(func(ctx *gin.Context) bool {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.paths[ctx.FullPath()]
+	})
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	buf := &syncBuffer{}
+	log.Logger = zerolog.New(buf)
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.clock = func() time.Time { return now }
+
+	calls := 0
+	var dbErr error
+	r.Register("db", CheckerFunc(func(ctx context.Context) error {
+		calls++
+		return dbErr
+	}))
+	r.Register("cache", CheckerFunc(func(ctx context.Context) error { return errors.New("down") }), WithOptional())
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), WithTimeout(time.Millisecond), WithOptional())
+	assert.Equal(t, []string{"cache", "db", "slow"}, r.Names())
+
+	rep := r.Check(context.Background())
+	assert.Equal(t, StatusOK, rep.Status)
+	assert.Equal(t, StatusFail, rep.Checks["cache"].Status)
+	assert.Equal(t, "context deadline exceeded", rep.Checks["slow"].Error)
+
+	// Cached until the TTL passes
+	dbErr = errors.New("connection refused")
+	rep = r.Check(context.Background())
+	assert.Equal(t, StatusOK, rep.Status)
+	assert.True(t, rep.Checks["db"].Cached)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(5 * time.Second)
+	buf.Reset()
+	rep = r.Check(context.Background())
+	assert.Equal(t, StatusFail, rep.Status)
+	assert.Equal(t, "connection refused", rep.Checks["db"].Error)
+	assert.Contains(t, buf.String(), `"check":"db"`)
+	assert.NotContains(t, buf.String(), `"check":"cache"`) // Failure already logged
+
+	now = now.Add(5 * time.Second)
+	dbErr = nil
+	buf.Reset()
+	r.Check(context.Background())
+	assert.Contains(t, buf.String(), "Health check recovered")
+}
+
+func TestRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	r := NewRegistry()
+	e := gin.New()
+	e.Use(zlog.New(zlog.WithLevel(zerolog.TraceLevel), r.SkipLogging()))
+	r.Routes(e.Group("/health"))
+	e.GET("/other", func(*gin.Context) {})
+
+	healthy := true
+	r.Register("db", CheckerFunc(func(ctx context.Context) error {
+		if !healthy {
+			return errors.New("down")
+		}
+		return nil
+	}), WithCacheTTL(0))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, get("/health/livez").Code)
+	w := get("/health/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rep Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rep))
+	assert.Equal(t, StatusOK, rep.Checks["db"].Status)
+
+	healthy = false
+	assert.Equal(t, http.StatusServiceUnavailable, get("/health/readyz").Code)
+	assert.Equal(t, http.StatusOK, get("/health/livez").Code)
+	assert.NotContains(t, buf.String(), "/health/")
+
+	get("/other")
+	assert.Contains(t, buf.String(), "/other")
+}
+
+// Buffer safe for logging from the concurrent checks
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,177 @@
+// Heartbeat reporting
+//
+// Reporter pings external heartbeat URLs, as used by healthchecks.io and similar dead man's switch monitors, on a
+// schedule and on start, stop and failure, so that a process dying silently is detected by the monitor noticing the
+// pings stop.
+//
+//	hb := heartbeats.New([]string{"https://hc-ping.com/<uuid>"}, heartbeats.WithInterval(time.Minute))
+//	if err := hb.Start(ctx); err != nil { ... }
+//	go hb.Run(ctx)
+//	...
+//	hb.Stop(context.Background())
+//
+// Each event is sent as a POST to the URL with the event's suffix, /start, /fail or /log for a stop, or no suffix for
+// a scheduled ping, with a short message as the body. Failed pings are logged with the URL host only, as heartbeat
+// URLs usually contain a secret.
+package heartbeats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Event is a lifecycle event reported to the heartbeat URLs
+type Event string
+
+const (
+	EventPing  Event = ""
+	EventStart Event = "start"
+	EventFail  Event = "fail"
+	EventStop  Event = "log" // Logged by the monitor without changing the check state
+)
+
+type reporterOpts struct {
+	interval time.Duration
+	client   *http.Client
+	check    func(ctx context.Context) error
+}
+
+// Modifier function for customising heartbeat reporting
+type ReporterOpts func(*reporterOpts) *reporterOpts
+
+// WithInterval sets the time between scheduled pings (default 1 minute)
+func WithInterval(d time.Duration) ReporterOpts {
+	return func(ro *reporterOpts) *reporterOpts {
+		ro.interval = d
+		return ro
+	}
+}
+
+// WithClient sets the HTTP client used for pings (default a client with a 10 second timeout)
+func WithClient(c *http.Client) ReporterOpts {
+	return func(ro *reporterOpts) *reporterOpts {
+		ro.client = c
+		return ro
+	}
+}
+
+// WithCheck runs the check before each scheduled ping, reporting a failure instead if it returns an error
+func WithCheck(check func(ctx context.Context) error) ReporterOpts {
+	return func(ro *reporterOpts) *reporterOpts {
+		ro.check = check
+		return ro
+	}
+}
+
+// Reporter pings heartbeat URLs
+type Reporter struct {
+	urls []string
+	opts *reporterOpts
+}
+
+// New creates a reporter for the heartbeat URLs
+func New(urls []string, opts ...ReporterOpts) *Reporter {
+	ro := &reporterOpts{interval: time.Minute, client: &http.Client{Timeout: 10 * time.Second}}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+	return &Reporter{urls: urls, opts: ro}
+}
+
+// Start reports the process starting
+func (r *Reporter) Start(ctx context.Context) error {
+	return r.Send(ctx, EventStart, "started")
+}
+
+// Stop reports the process stopping gracefully
+func (r *Reporter) Stop(ctx context.Context) error {
+	return r.Send(ctx, EventStop, "stopping")
+}
+
+// Fail reports a failure with the reason
+func (r *Reporter) Fail(ctx context.Context, reason string) error {
+	return r.Send(ctx, EventFail, reason)
+}
+
+// Run sends scheduled pings on the interval until the context is cancelled
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.tick(ctx)
+		}
+	}
+}
+
+// Send a scheduled ping, or a failure if the check fails
+func (r *Reporter) tick(ctx context.Context) {
+	if r.opts.check != nil {
+		if err := r.opts.check(ctx); err != nil {
+			_ = r.Fail(ctx, err.Error())
+			return
+		}
+	}
+	_ = r.Send(ctx, EventPing, "ok")
+}
+
+// Send reports the event to every URL, logging and returning the first error
+func (r *Reporter) Send(ctx context.Context, event Event, msg string) error {
+	var first error
+	for _, u := range r.urls {
+		if err := r.send(ctx, u, event, msg); err != nil {
+			log.Warn().Err(err).Str("host", host(u)).Str("event", eventName(event)).Msg("Heartbeat failed")
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (r *Reporter) send(ctx context.Context, u string, event Event, msg string) error {
+	if event != EventPing {
+		u = strings.TrimSuffix(u, "/") + "/" + string(event)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(msg))
+	if err != nil {
+		return errors.New("invalid heartbeat url") // Not wrapped, the error includes the URL
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := r.opts.client.Do(req)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func eventName(e Event) string {
+	if e == EventPing {
+		return "ping"
+	}
+	return string(e)
+}
+
+func host(u string) string {
+	if p, err := url.Parse(u); err == nil {
+		return p.Host
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package heartbeats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.calls = append(rec.calls, r.Method+" "+r.URL.Path+" "+string(b))
+	if strings.HasPrefix(r.URL.Path, "/broken") {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (rec *recorder) get() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string{}, rec.calls...)
+}
+
+func TestReporter(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	healthy := true
+	hb := New([]string{srv.URL + "/abc"}, WithInterval(10*time.Millisecond), WithCheck(func(ctx context.Context) error {
+		if !healthy {
+			return errors.New("db down")
+		}
+		return nil
+	}))
+	assert.NoError(t, hb.Start(context.Background()))
+	hb.tick(context.Background())
+	healthy = false
+	hb.tick(context.Background())
+	assert.NoError(t, hb.Stop(context.Background()))
+	assert.Equal(t, []string{"POST /abc/start started", "POST /abc ok", "POST /abc/fail db down", "POST /abc/log stopping"}, rec.get())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hb.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return len(rec.get()) >= 6 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestSendErrors(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	hb := New([]string{srv.URL + "/broken", srv.URL + "/ok"})
+	err := hb.Send(context.Background(), EventPing, "ok")
+	assert.EqualError(t, err, "unexpected status 404")
+	assert.Len(t, rec.get(), 2) // Later URLs still pinged
+
+	hb = New([]string{"http://127.0.0.1:1/secret"})
+	err = hb.Start(context.Background())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
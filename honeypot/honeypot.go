@@ -0,0 +1,261 @@
+// Honeypot traps
+//
+// Decoy routes, hidden form fields and decoy credentials which no legitimate client should ever touch.
+// Any interaction is logged at error level through the request-scoped zlog logger, passed to an optional
+// notifier hook, and can automatically add the client IP to an in-memory denylist enforced by Guard().
+//
+//	trap := honeypot.New(honeypot.WithDenylist(time.Hour))
+//	e.Use(trap.Guard())
+//	trap.Routes(e, honeypot.DefaultPaths...)
+//	e.POST("/signup", trap.Field("website"), handler)
+//
+// The denylist is keyed by ctx.ClientIP(), so behind a proxy the engine's trusted proxies must be configured with
+// gin's SetTrustedProxies. Otherwise clients choose their own IP with X-Forwarded-For, can't be denied, and could deny
+// others. The denylist is bounded by WithDenylistSize.
+package honeypot
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Kinds of trap reported in events
+const (
+	KindRoute      = "route"
+	KindField      = "field"
+	KindCredential = "credential"
+)
+
+// DefaultPaths are commonly probed paths which are safe to use as decoys in most applications
+var DefaultPaths = []string{
+	"/.env",
+	"/.git/config",
+	"/wp-login.php",
+	"/wp-admin",
+	"/xmlrpc.php",
+	"/phpmyadmin",
+	"/admin.php",
+	"/server-status",
+}
+
+// Event describes a single trap interaction
+type Event struct {
+	Kind      string    `json:"kind"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"agent"`
+	Detail    string    `json:"detail,omitempty"` // Field name or decoy username
+	Time      time.Time `json:"time"`
+}
+
+// Notifier is called for every trap interaction, e.g. to forward to an alerting pipeline.
+// It is called synchronously within the request and should not block.
+type Notifier func(ctx *gin.Context, ev Event)
+
+type trapOpts struct {
+	notifier Notifier      // Event hook
+	ttl      time.Duration // Denylist duration, disabled if zero
+	size     int           // Maximum denylisted IPs
+	status   int           // Status code returned from decoy routes
+}
+
+// Modifier function for customising trap behaviour
+type TrapOpts func(*trapOpts) *trapOpts
+
+// Trap holds the configuration and denylist shared by all traps created from it
+type Trap struct {
+	opts *trapOpts
+
+	mu     sync.Mutex
+	denied map[string]time.Time // IP -> expiry
+	users  map[string]bool      // Decoy usernames
+
+	nextSweep time.Time // Earliest time expired denylist entries are next swept
+}
+
+// Minimum interval between sweeps of expired denylist entries
+const sweepInterval = time.Minute
+
+// New creates a trap set
+func New(opts ...TrapOpts) *Trap {
+	to := &trapOpts{
+		status: http.StatusNotFound,
+		size:   10000,
+	}
+	for _, f := range opts {
+		to = f(to)
+	}
+	return &Trap{
+		opts:   to,
+		denied: map[string]time.Time{},
+		users:  map[string]bool{},
+	}
+}
+
+// Routes registers decoy handlers for all methods on the given paths
+func (t *Trap) Routes(r gin.IRoutes, paths ...string) {
+	for _, p := range paths {
+		r.Any(p, t.Handler())
+	}
+}
+
+// Handler returns a decoy handler which records the access and responds as if the route did not exist
+func (t *Trap) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t.trigger(ctx, KindRoute, "")
+		ctx.AbortWithStatus(t.opts.status)
+	}
+}
+
+// Field returns a middleware which triggers if the named hidden form field is submitted with a value.
+// The request is aborted with the decoy status so bots receive no useful feedback.
+func (t *Trap) Field(name string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.PostForm(name) != "" {
+			t.trigger(ctx, KindField, name)
+			ctx.AbortWithStatus(t.opts.status)
+		}
+	}
+}
+
+// AddCredentials registers decoy usernames, e.g. accounts seeded in leaked credential lists
+func (t *Trap) AddCredentials(usernames ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, u := range usernames {
+		t.users[u] = true
+	}
+}
+
+// CheckCredential should be called by login handlers with the submitted username.
+// Returns true, and triggers the trap, if the username is a registered decoy.
+func (t *Trap) CheckCredential(ctx *gin.Context, username string) bool {
+	t.mu.Lock()
+	hit := t.users[username]
+	t.mu.Unlock()
+
+	if hit {
+		t.trigger(ctx, KindCredential, username)
+	}
+	return hit
+}
+
+// Guard returns a middleware rejecting requests from denylisted IPs with 403
+func (t *Trap) Guard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if t.Denied(ctx.ClientIP()) {
+			errors.AbortWith(ctx, http.StatusForbidden, "forbidden")
+		}
+	}
+}
+
+// Denied returns whether the IP is currently denylisted
+func (t *Trap) Denied(ip string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	exp, ok := t.denied[ip]
+	if ok && time.Now().After(exp) {
+		delete(t.denied, ip)
+		return false
+	}
+	return ok
+}
+
+// Release removes an IP from the denylist
+func (t *Trap) Release(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.denied, ip)
+}
+
+func (t *Trap) trigger(ctx *gin.Context, kind, detail string) {
+	ev := Event{
+		Kind:      kind,
+		Method:    ctx.Request.Method,
+		Path:      ctx.Request.URL.Path,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.GetHeader("User-Agent"),
+		Detail:    detail,
+		Time:      time.Now(),
+	}
+
+	if t.opts.ttl > 0 {
+		t.deny(ev.IP, ev.Time)
+	}
+
+	zlog.GetLogger(ctx).Error().
+		Bool("honeypot", true).
+		Str("kind", ev.Kind).
+		Str("method", ev.Method).
+		Str("ip", ev.IP).
+		Str("detail", ev.Detail).
+		Bool("denylisted", t.opts.ttl > 0).
+		Msgf("Honeypot %s triggered by %s", ev.Kind, ev.IP)
+
+	if t.opts.notifier != nil {
+		t.opts.notifier(ctx, ev)
+	}
+}
+
+// Add the IP to the denylist, sweeping expired entries periodically and evicting an arbitrary entry if still full
+func (t *Trap) deny(ip string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.After(t.nextSweep) {
+		for k, exp := range t.denied {
+			if now.After(exp) {
+				delete(t.denied, k)
+			}
+		}
+		t.nextSweep = now.Add(sweepInterval)
+	}
+	if _, ok := t.denied[ip]; !ok && len(t.denied) >= t.opts.size {
+		// Map iteration order is random, so this evicts an arbitrary entry
+		for k := range t.denied {
+			delete(t.denied, k)
+			break
+		}
+	}
+	t.denied[ip] = now.Add(t.opts.ttl)
+}
+
+// WithNotifier sets a hook called for every trap interaction
+func WithNotifier(n Notifier) TrapOpts {
+	return func(to *trapOpts) *trapOpts {
+		to.notifier = n
+		return to
+	}
+}
+
+// WithDenylist enables automatic denylisting of the client IP for the given duration
+func WithDenylist(ttl time.Duration) TrapOpts {
+	return func(to *trapOpts) *trapOpts {
+		to.ttl = ttl
+		return to
+	}
+}
+
+// WithDenylistSize sets the maximum number of denylisted IPs (default 10000). When full, an arbitrary IP is released
+// to make room.
+func WithDenylistSize(n int) TrapOpts {
+	return func(to *trapOpts) *trapOpts {
+		to.size = n
+		return to
+	}
+}
+
+// WithStatus sets the status code returned by decoy routes and fields, default 404
+func WithStatus(status int) TrapOpts {
+	return func(to *trapOpts) *trapOpts {
+		to.status = status
+		return to
+	}
+}
@@ -0,0 +1,99 @@
+package honeypot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteTrap(t *testing.T) {
+	var events []Event
+	trap := New(WithNotifier(func(ctx *gin.Context, ev Event) {
+		events = append(events, ev)
+	}), WithDenylist(time.Minute))
+
+	e := gin.New()
+	e.Use(trap.Guard())
+	trap.Routes(e, "/.env")
+	e.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/ok", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/.env", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Result().StatusCode)
+	assert.Len(t, events, 1)
+	assert.Equal(t, KindRoute, events[0].Kind)
+
+	// Now denylisted
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ok", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Result().StatusCode)
+
+	trap.Release(events[0].IP)
+	assert.False(t, trap.Denied(events[0].IP))
+}
+
+func TestFieldTrap(t *testing.T) {
+	trap := New()
+
+	e := gin.New()
+	e.POST("", trap.Field("website"), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	form := url.Values{"name": {"a"}}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	form.Set("website", "http://spam")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Result().StatusCode)
+}
+
+func TestCredentialTrap(t *testing.T) {
+	trap := New()
+	trap.AddCredentials("admin")
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request, _ = http.NewRequest("POST", "/login", nil)
+
+	assert.True(t, trap.CheckCredential(ctx, "admin"))
+	assert.False(t, trap.CheckCredential(ctx, "alice"))
+}
+
+func TestDenylistSize(t *testing.T) {
+	trap := New(WithDenylist(time.Minute), WithDenylistSize(10))
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		trap.deny("192.0.2."+strconv.Itoa(i), now)
+	}
+	assert.Len(t, trap.denied, 10)
+	assert.True(t, trap.Denied("192.0.2.99"))
+
+	// Expired entries are swept on the next denial after the sweep interval
+	trap.deny("198.51.100.1", now.Add(2*time.Minute))
+	assert.Len(t, trap.denied, 1)
+	assert.True(t, trap.Denied("198.51.100.1"))
+}
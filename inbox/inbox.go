@@ -0,0 +1,182 @@
+// Exactly-once message intake
+//
+// Implements the inbox pattern for webhook and queue endpoints: each message ID is persisted before processing,
+// messages already processed are skipped, and failed messages can be reprocessed by redelivery or through the admin
+// handlers.
+//
+//	in := inbox.New(store, func(ctx context.Context, e *inbox.Entry) error {
+//		return orders.Apply(ctx, e.Payload)
+//	})
+//	e.POST("/webhooks/payments", in.Handler("payments", inbox.HeaderID("X-Webhook-ID")))
+//	admin.GET("/inbox", in.ListHandler())
+//	admin.POST("/inbox/:id/reprocess", in.ReprocessHandler())
+//
+// Complements idempotency keys, which replay responses to clients, by making intake of events safe against
+// duplicate delivery and crashes between receipt and processing. A processor panic marks the entry failed, and an
+// entry left processing by a crashed instance can be claimed again once the lease (WithLease) has passed.
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Processor handles a message. Returning an error marks the entry failed, to be retried.
+type Processor func(ctx context.Context, e *Entry) error
+
+// IDFunc extracts the message ID from a request, returning an empty string if missing
+type IDFunc func(ctx *gin.Context) string
+
+// HeaderID reads the message ID from a request header
+func HeaderID(name string) IDFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+type inboxOpts struct {
+	lease time.Duration // Time after which an entry being processed may be claimed again
+}
+
+// Modifier function for customising inbox behaviour
+type InboxOpts func(*inboxOpts) *inboxOpts
+
+// WithLease sets how long a processing attempt may take before the entry can be claimed again (default 5 minutes).
+// It should be longer than any processing attempt, or a message may be processed concurrently.
+func WithLease(d time.Duration) InboxOpts {
+	return func(io *inboxOpts) *inboxOpts {
+		io.lease = d
+		return io
+	}
+}
+
+// Inbox records and processes messages
+type Inbox struct {
+	store Store
+	proc  Processor
+	opts  *inboxOpts
+}
+
+// New creates an inbox processing messages with proc
+func New(store Store, proc Processor, opts ...InboxOpts) *Inbox {
+	io := &inboxOpts{lease: 5 * time.Minute}
+	for _, f := range opts {
+		io = f(io)
+	}
+	return &Inbox{store: store, proc: proc, opts: io}
+}
+
+// Receive persists the message, then processes it unless already processed or being processed, in which case
+// ErrDone or ErrBusy is returned. A message that previously failed is processed again. Processing errors are
+// returned after the entry is marked failed.
+func (in *Inbox) Receive(ctx context.Context, id, source string, payload []byte) error {
+	_, err := in.store.Insert(ctx, &Entry{
+		ID:         id,
+		Source:     source,
+		Payload:    payload,
+		Status:     StatusReceived,
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return in.Reprocess(ctx, id)
+}
+
+// Reprocess processes a stored message that was not processed successfully. If the processor panics, the entry is
+// marked failed before the panic continues.
+func (in *Inbox) Reprocess(ctx context.Context, id string) error {
+	e, err := in.store.Claim(ctx, id, in.opts.lease)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			in.store.Complete(ctx, id, fmt.Errorf("inbox: processor panicked: %v", r))
+			panic(r)
+		}
+	}()
+	procErr := in.proc(ctx, e)
+	if err := in.store.Complete(ctx, id, procErr); err != nil {
+		return err
+	}
+	return procErr
+}
+
+// Handler receives the request body as a message with the ID from the request, responding 204 once processed or if
+// already processed, 400 if the ID is missing, 409 if being processed concurrently, or an error response from
+// errors.AbortWithError if processing failed so the sender retries
+func (in *Inbox) Handler(source string, id IDFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		msgID := id(ctx)
+		if msgID == "" {
+			ginxerrors.AbortWith(ctx, http.StatusBadRequest, "missing_message_id")
+			return
+		}
+		zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("message_id", msgID)
+		})
+
+		body, err := ctx.GetRawData()
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_body") {
+			return
+		}
+		in.respond(ctx, in.Receive(ctx.Request.Context(), msgID, source, body))
+	}
+}
+
+func (in *Inbox) respond(ctx *gin.Context, err error) {
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusNoContent)
+	case errors.Is(err, ErrDone):
+		zlog.GetLogger(ctx).Debug().Msg("Skipping duplicate message")
+		ctx.Status(http.StatusNoContent)
+	case errors.Is(err, ErrBusy):
+		ginxerrors.AbortWith(ctx, http.StatusConflict, "message_processing")
+	case errors.Is(err, ErrNotFound):
+		ginxerrors.AbortWith(ctx, http.StatusNotFound, "message_not_found")
+	default:
+		zlog.GetLogger(ctx).Error().Err(err).Msg("Message processing failed")
+		ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "processing_failed")
+	}
+}
+
+// ListHandler responds with entries with the status query parameter (default failed), up to the limit query
+// parameter (default 100)
+func (in *Inbox) ListHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := Status(ctx.DefaultQuery("status", string(StatusFailed)))
+		limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_limit") {
+			return
+		}
+		entries, err := in.store.List(ctx.Request.Context(), status, limit)
+		if ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "list_failed") {
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"entries": entries})
+	}
+}
+
+// ReprocessHandler processes the entry with the id path parameter again, responding as Handler, except 404 if not
+// found and 409 if already processed
+func (in *Inbox) ReprocessHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		err := in.Reprocess(ctx.Request.Context(), ctx.Param("id"))
+		if errors.Is(err, ErrDone) {
+			ginxerrors.AbortWith(ctx, http.StatusConflict, "message_processed")
+			return
+		}
+		in.respond(ctx, err)
+	}
+}
@@ -0,0 +1,119 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInbox(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store := NewMemoryStore()
+	var processed []string
+	fail := true
+	in := New(store, func(ctx context.Context, e *Entry) error {
+		if string(e.Payload) == "bad" && fail {
+			return errors.New("rejected")
+		}
+		processed = append(processed, e.ID)
+		return nil
+	})
+
+	e := gin.New()
+	e.POST("/hook", in.Handler("payments", HeaderID("X-Webhook-ID")))
+	e.GET("/inbox", in.ListHandler())
+	e.POST("/inbox/:id/reprocess", in.ReprocessHandler())
+
+	do := func(method, path, id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if id != "" {
+			req.Header.Set("X-Webhook-ID", id)
+		}
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, 204, do("POST", "/hook", "a", "ok").Code)
+	assert.Equal(t, 204, do("POST", "/hook", "a", "ok").Code)
+	assert.Equal(t, 400, do("POST", "/hook", "", "ok").Code)
+	assert.Equal(t, []string{"a"}, processed)
+
+	w := do("POST", "/hook", "b", "bad")
+	assert.Equal(t, 500, w.Code)
+	assert.JSONEq(t, `{"code":"processing_failed"}`, w.Body.String())
+
+	w = do("GET", "/inbox", "", "")
+	var list struct{ Entries []*Entry }
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Entries, 1) {
+		assert.Equal(t, "b", list.Entries[0].ID)
+		assert.Equal(t, "payments", list.Entries[0].Source)
+		assert.Equal(t, "rejected", list.Entries[0].Error)
+	}
+
+	fail = false
+	assert.Equal(t, 204, do("POST", "/inbox/b/reprocess", "", "").Code)
+	assert.Equal(t, 409, do("POST", "/inbox/b/reprocess", "", "").Code)
+	assert.Equal(t, 404, do("POST", "/inbox/c/reprocess", "", "").Code)
+	assert.Equal(t, []string{"a", "b"}, processed)
+
+	entry, err := store.Get(context.Background(), "b")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusDone, entry.Status)
+	assert.Equal(t, 2, entry.Attempts)
+}
+
+func TestBusy(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	in := New(store, func(ctx context.Context, e *Entry) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- in.Receive(context.Background(), "a", "", nil) }()
+	<-started
+	assert.ErrorIs(t, in.Receive(context.Background(), "a", "", nil), ErrBusy)
+	close(release)
+	assert.NoError(t, <-done)
+	assert.ErrorIs(t, in.Receive(context.Background(), "a", "", nil), ErrDone)
+}
+
+func TestProcessorPanic(t *testing.T) {
+	store := NewMemoryStore()
+	in := New(store, func(ctx context.Context, e *Entry) error {
+		panic("boom")
+	})
+
+	assert.PanicsWithValue(t, "boom", func() { in.Receive(context.Background(), "a", "", nil) })
+	entry, err := store.Get(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, entry.Status)
+	assert.Equal(t, "inbox: processor panicked: boom", entry.Error)
+}
+
+func TestLease(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Insert(ctx, &Entry{ID: "a", Status: StatusReceived})
+
+	// Claimed by an instance which then crashed
+	_, err := store.Claim(ctx, "a", time.Minute)
+	assert.NoError(t, err)
+
+	in := New(store, func(ctx context.Context, e *Entry) error { return nil }, WithLease(50*time.Millisecond))
+	assert.ErrorIs(t, in.Reprocess(ctx, "a"), ErrBusy)
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, in.Reprocess(ctx, "a"))
+}
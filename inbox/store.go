@@ -0,0 +1,150 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of an inbox entry
+type Status string
+
+const (
+	StatusReceived   Status = "received"
+	StatusProcessing Status = "processing"
+	StatusDone       Status = "done"
+	StatusFailed     Status = "failed"
+)
+
+// Entry is a received message
+type Entry struct {
+	ID          string    `json:"id"`
+	Source      string    `json:"source,omitempty"`
+	Payload     []byte    `json:"payload"`
+	Status      Status    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error,omitempty"` // Error of the last failed attempt
+	ReceivedAt  time.Time `json:"received_at"`
+	ClaimedAt   time.Time `json:"claimed_at,omitempty"` // Start of the latest attempt
+	ProcessedAt time.Time `json:"processed_at,omitempty"`
+}
+
+var (
+	ErrNotFound = fmt.Errorf("inbox: entry not found")
+	ErrBusy     = fmt.Errorf("inbox: entry being processed")
+	ErrDone     = fmt.Errorf("inbox: entry already processed")
+)
+
+// Store persists entries. Implementations must make Insert and Claim atomic, so concurrent deliveries of a message
+// are processed once.
+type Store interface {
+	// Insert adds the entry if no entry has its ID, returning false if one already exists
+	Insert(ctx context.Context, e *Entry) (bool, error)
+	// Get returns ErrNotFound if no entry has the ID
+	Get(ctx context.Context, id string) (*Entry, error)
+	// Claim marks a received or failed entry as processing and returns it, or returns ErrNotFound, ErrBusy or
+	// ErrDone. An entry claimed longer than the lease ago is claimed again, as its processor is assumed to have died.
+	Claim(ctx context.Context, id string, lease time.Duration) (*Entry, error)
+	// Complete records an attempt of a claimed entry, as done if procErr is nil, otherwise as failed
+	Complete(ctx context.Context, id string, procErr error) error
+	// List returns entries with the status, oldest first, up to limit if positive
+	List(ctx context.Context, status Status, limit int) ([]*Entry, error)
+}
+
+// MemoryStore is an in-memory Store for tests and single instance deployments
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*Entry{}}
+}
+
+// Insert implements Store
+func (s *MemoryStore) Insert(_ context.Context, e *Entry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[e.ID]; ok {
+		return false, nil
+	}
+	c := *e
+	s.entries[e.ID] = &c
+	return true, nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[id]; ok {
+		c := *e
+		return &c, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Claim implements Store
+func (s *MemoryStore) Claim(_ context.Context, id string, lease time.Duration) (*Entry, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	switch e.Status {
+	case StatusProcessing:
+		if now.Sub(e.ClaimedAt) < lease {
+			return nil, ErrBusy
+		}
+	case StatusDone:
+		return nil, ErrDone
+	}
+	e.Status = StatusProcessing
+	e.ClaimedAt = now
+	c := *e
+	return &c, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(_ context.Context, id string, procErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	e.Attempts++
+	if procErr != nil {
+		e.Status = StatusFailed
+		e.Error = procErr.Error()
+	} else {
+		e.Status = StatusDone
+		e.Error = ""
+		e.ProcessedAt = time.Now()
+	}
+	return nil
+}
+
+// List implements Store
+func (s *MemoryStore) List(_ context.Context, status Status, limit int) ([]*Entry, error) {
+	s.mu.Lock()
+	var res []*Entry
+	for _, e := range s.entries {
+		if e.Status == status {
+			c := *e
+			res = append(res, &c)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].ReceivedAt.Before(res[j].ReceivedAt) })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+// IP ranges
+//
+// Parses and matches lists of IPs and CIDR ranges, such as trusted proxies or exempt clients, shared by the
+// middleware packages so they treat entries the same way.
+//
+//	nets, err := ipnets.Parse("10.0.0.0/8", "192.0.2.1")
+//	...
+//	if ipnets.Contains(nets, ctx.Request.RemoteAddr) {
+package ipnets
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Parse parses IPs and CIDR ranges. A single IP matches only that address; IPv4-mapped IPv6 addresses are treated
+// as the IPv4 address they map.
+func Parse(entries ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, e := range entries {
+		if strings.Contains(e, "/") {
+			_, n, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP or CIDR range %q", e)
+			}
+			nets = append(nets, n)
+			continue
+		}
+
+		ip := net.ParseIP(e)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR range %q", e)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		bits := len(ip) * 8
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// Contains reports whether addr, an IP with or without a port, is within any of the ranges
+func Contains(nets []*net.IPNet, addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package ipnets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	nets, err := Parse("10.0.0.0/8", "192.0.2.1", "2001:db8::1", "::ffff:172.16.0.1")
+	assert.NoError(t, err)
+
+	assert.True(t, Contains(nets, "10.1.2.3:443"))
+	assert.True(t, Contains(nets, "192.0.2.1"))
+	assert.False(t, Contains(nets, "192.0.2.2"))
+	assert.True(t, Contains(nets, "[2001:db8::1]:80"))
+	assert.False(t, Contains(nets, "2001:db8::2"))
+	assert.False(t, Contains(nets, "invalid"))
+
+	// An IPv4-mapped address matches only itself, not the /32 of the IPv6 space it would otherwise cover
+	assert.True(t, Contains(nets, "172.16.0.1"))
+	assert.True(t, Contains(nets, "::ffff:172.16.0.1"))
+	assert.False(t, Contains(nets, "::1"))
+	assert.False(t, Contains(nets, "172.16.0.2"))
+
+	_, err = Parse("10.0.0.0/8", "nope")
+	assert.EqualError(t, err, `invalid IP or CIDR range "nope"`)
+	_, err = Parse("10.0.0.0/33")
+	assert.Error(t, err)
+}
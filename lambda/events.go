@@ -0,0 +1,136 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProxyRequest is an API Gateway REST API (payload version 1.0) or HTTP API (payload version 2.0) proxy event
+type ProxyRequest struct {
+	Version         string `json:"version"` // 2.0 for HTTP API payloads
+	IsBase64Encoded bool   `json:"isBase64Encoded"`
+	Body            string `json:"body"`
+
+	Headers map[string]string `json:"headers"`
+
+	// Version 1.0
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+
+	// Version 2.0
+	RawPath        string   `json:"rawPath"`
+	RawQueryString string   `json:"rawQueryString"`
+	Cookies        []string `json:"cookies"`
+
+	RequestContext struct {
+		RequestID string `json:"requestId"`
+		Identity  struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"` // Version 1.0
+		HTTP struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"` // Version 2.0
+	} `json:"requestContext"`
+}
+
+// ProxyResponse is an API Gateway proxy response, in the payload version of the request
+type ProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"` // Version 1.0
+	Cookies           []string            `json:"cookies,omitempty"`           // Version 2.0
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+func (e *ProxyRequest) v2() bool {
+	return e.Version == "2.0"
+}
+
+// Convert the event to an http.Request
+func (e *ProxyRequest) request(ctx context.Context) (*http.Request, error) {
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	method, path, query, ip := e.HTTPMethod, e.Path, "", e.RequestContext.Identity.SourceIP
+	if e.v2() {
+		method, path, query, ip = e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString, e.RequestContext.HTTP.SourceIP
+	} else if len(e.MultiValueQueryStringParameters) > 0 {
+		query = url.Values(e.MultiValueQueryStringParameters).Encode()
+	} else if len(e.QueryStringParameters) > 0 {
+		q := url.Values{}
+		for k, v := range e.QueryStringParameters {
+			q.Set(k, v)
+		}
+		query = q.Encode()
+	}
+
+	u := &url.URL{Path: path, RawQuery: query}
+	req, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, vs := range e.MultiValueHeaders {
+		req.Header.Del(k)
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if len(e.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+	req.Host = req.Header.Get("Host")
+	if ip != "" {
+		req.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+	req.RequestURI = u.RequestURI()
+	return req, nil
+}
+
+// Convert a recorded response to the response for the event
+func (e *ProxyRequest) response(w *httptest.ResponseRecorder) *ProxyResponse {
+	res := &ProxyResponse{StatusCode: w.Code, Headers: map[string]string{}}
+	for k, vs := range w.Header() {
+		switch {
+		case e.v2() && k == "Set-Cookie":
+			res.Cookies = vs
+		case e.v2():
+			res.Headers[k] = strings.Join(vs, ",")
+		case len(vs) == 1:
+			res.Headers[k] = vs[0]
+		default:
+			if res.MultiValueHeaders == nil {
+				res.MultiValueHeaders = map[string][]string{}
+			}
+			res.MultiValueHeaders[k] = vs
+		}
+	}
+
+	body := w.Body.Bytes()
+	if utf8.Valid(body) {
+		res.Body = string(body)
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(body)
+		res.IsBase64Encoded = true
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+// Serverless adapter
+//
+// Runs an engine behind AWS Lambda with API Gateway REST or HTTP API proxy events, or as a Google Cloud Function,
+// translating events to http.Requests and passing on the platform request ID.
+//
+//	// AWS Lambda, with github.com/aws/aws-lambda-go/lambda
+//	lambda.Start(ginxlambda.Handler(e, ginxlambda.WithFlush(logBuffer.Flush)))
+//
+//	// Google Cloud Functions
+//	functions.HTTP("api", ginxlambda.CloudFunction(e))
+//
+// The platform request ID is set in the X-Request-ID header, so zlog reuses it with
+// zlog.WithRequestIDHeader(lambda.RequestIDHeader). The function is frozen between invocations, so buffered log
+// output is flushed with the WithFlush function before each invocation returns.
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Header set to the platform request ID
+	RequestIDHeader = "X-Request-ID"
+
+	// Header containing the execution ID of a Google Cloud Function
+	cloudFunctionIDHeader = "Function-Execution-Id"
+)
+
+type handlerOpts struct {
+	flush func() error
+}
+
+// Modifier function for customising adapter behaviour
+type HandlerOpts func(*handlerOpts) *handlerOpts
+
+// WithFlush sets a function called after each invocation, before the function can be frozen, to flush buffered
+// log output, e.g. of a diode or buffered writer used by zlog
+func WithFlush(flush func() error) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.flush = flush
+		return ho
+	}
+}
+
+func (ho *handlerOpts) done() {
+	if ho.flush == nil {
+		return
+	}
+	if err := ho.flush(); err != nil {
+		log.Error().Err(err).Msg("Failed to flush logs")
+	}
+}
+
+// Handler returns a Lambda handler serving API Gateway proxy events with h. Payload versions 1.0 and 2.0 are
+// detected from the event, and the response uses the same version. Response bodies that aren't valid UTF-8 are
+// base64 encoded.
+func Handler(h http.Handler, opts ...HandlerOpts) func(ctx context.Context, event json.RawMessage) (*ProxyResponse, error) {
+	ho := &handlerOpts{}
+	for _, f := range opts {
+		ho = f(ho)
+	}
+
+	return func(ctx context.Context, event json.RawMessage) (*ProxyResponse, error) {
+		defer ho.done()
+
+		var e ProxyRequest
+		if err := json.Unmarshal(event, &e); err != nil {
+			return nil, err
+		}
+		req, err := e.request(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if id := e.RequestContext.RequestID; id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return e.response(w), nil
+	}
+}
+
+// CloudFunction returns an HTTP function serving requests with h, using the function execution ID as the request ID
+func CloudFunction(h http.Handler, opts ...HandlerOpts) http.HandlerFunc {
+	ho := &handlerOpts{}
+	for _, f := range opts {
+		ho = f(ho)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer ho.done()
+
+		if id := r.Header.Get(cloudFunctionIDHeader); id != "" {
+			r.Header.Set(RequestIDHeader, id)
+		}
+		h.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,98 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func engine() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithRequestIDHeader(RequestIDHeader)))
+	e.POST("/items/:id", func(ctx *gin.Context) {
+		body, _ := ctx.GetRawData()
+		cookie, _ := ctx.Cookie("session")
+		ctx.SetCookie("a", "1", 0, "/", "", false, false)
+		ctx.SetCookie("b", "2", 0, "/", "", false, false)
+		ctx.JSON(http.StatusCreated, gin.H{
+			"id":      ctx.Param("id"),
+			"q":       ctx.QueryArray("q"),
+			"body":    string(body),
+			"cookie":  cookie,
+			"ip":      ctx.ClientIP(),
+			"request": zlog.GetRequestID(ctx),
+		})
+	})
+	e.GET("/bin", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/octet-stream", []byte{0xff, 0xfe})
+	})
+	return e
+}
+
+func TestHandlerV1(t *testing.T) {
+	flushed := 0
+	h := Handler(engine(), WithFlush(func() error { flushed++; return nil }))
+
+	res, err := h(context.Background(), json.RawMessage(`{
+		"httpMethod": "POST",
+		"path": "/items/7",
+		"multiValueQueryStringParameters": {"q": ["a", "b"]},
+		"headers": {"Content-Type": "text/plain", "Cookie": "session=s1"},
+		"body": "aGVsbG8=",
+		"isBase64Encoded": true,
+		"requestContext": {"requestId": "apigw-1", "identity": {"sourceIp": "203.0.113.9"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, res.StatusCode)
+	assert.JSONEq(t, `{"id":"7","q":["a","b"],"body":"hello","cookie":"s1","ip":"203.0.113.9","request":"apigw-1"}`,
+		res.Body)
+	assert.Len(t, res.MultiValueHeaders["Set-Cookie"], 2)
+	assert.Equal(t, "apigw-1", res.Headers["X-Request-Id"])
+	assert.Equal(t, 1, flushed)
+
+	res, err = h(context.Background(), json.RawMessage(`{"httpMethod":"GET","path":"/bin"}`))
+	assert.NoError(t, err)
+	assert.True(t, res.IsBase64Encoded)
+	assert.Equal(t, "//4=", res.Body)
+	assert.Equal(t, 2, flushed)
+}
+
+func TestHandlerV2(t *testing.T) {
+	h := Handler(engine())
+	res, err := h(context.Background(), json.RawMessage(`{
+		"version": "2.0",
+		"rawPath": "/items/7",
+		"rawQueryString": "q=a&q=b",
+		"cookies": ["session=s1"],
+		"headers": {"content-type": "text/plain"},
+		"body": "hello",
+		"requestContext": {"requestId": "apigw-2", "http": {"method": "POST", "sourceIp": "203.0.113.9"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, res.StatusCode)
+	assert.JSONEq(t, `{"id":"7","q":["a","b"],"body":"hello","cookie":"s1","ip":"203.0.113.9","request":"apigw-2"}`,
+		res.Body)
+	assert.Len(t, res.Cookies, 2)
+	assert.Empty(t, res.MultiValueHeaders)
+
+	_, err = h(context.Background(), json.RawMessage(`[]`))
+	assert.Error(t, err)
+}
+
+func TestCloudFunction(t *testing.T) {
+	h := CloudFunction(engine())
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/items/1", nil)
+	req.Header.Set(cloudFunctionIDHeader, "exec-1")
+	h(w, req)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), `"request":"exec-1"`)
+}
@@ -0,0 +1,201 @@
+// In-process load testing
+//
+// Replays recorded requests against a handler in-process at a configurable concurrency, and reports latency
+// percentiles per route, for performance regression checks before release without a deployed environment.
+//
+//	reqs, err := loadtest.Load(file)
+//	report := loadtest.Run(ctx, engine, reqs, loadtest.WithConcurrency(8), loadtest.WithIterations(100))
+//	report.WriteTo(os.Stdout)
+//	if regressions := report.Regressions(baseline, 0.2); len(regressions) > 0 {
+//		...
+//	}
+//
+// Recordings are JSON lines of Request. Requests are grouped by their recorded Route if set, otherwise by the route
+// pattern they match when the handler is a *gin.Engine, or by method and path.
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request is a recorded request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"` // Path and query
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`  // Base64 in JSON
+	Route  string      `json:"route,omitempty"` // Route pattern for grouping, optional
+}
+
+// Load reads recorded requests as JSON lines
+func Load(r io.Reader) ([]Request, error) {
+	var res []Request
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var req Request
+		if err := dec.Decode(&req); err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+		res = append(res, req)
+	}
+}
+
+type runOpts struct {
+	concurrency int
+	iterations  int
+	duration    time.Duration
+}
+
+// Modifier function for customising load test behaviour
+type RunOpts func(*runOpts) *runOpts
+
+// WithConcurrency sets the number of concurrent workers (default 1)
+func WithConcurrency(n int) RunOpts {
+	return func(ro *runOpts) *runOpts {
+		ro.concurrency = n
+		return ro
+	}
+}
+
+// WithIterations sets the number of times the recordings are replayed (default 1)
+func WithIterations(n int) RunOpts {
+	return func(ro *runOpts) *runOpts {
+		ro.iterations = n
+		return ro
+	}
+}
+
+// WithDuration replays the recordings repeatedly for the duration, instead of a fixed number of iterations
+func WithDuration(d time.Duration) RunOpts {
+	return func(ro *runOpts) *runOpts {
+		ro.duration = d
+		return ro
+	}
+}
+
+// Run replays the requests against the handler, stopping early if the context is cancelled. An empty report is
+// returned if there are no requests or the concurrency is less than one.
+func Run(ctx context.Context, h http.Handler, reqs []Request, opts ...RunOpts) *Report {
+	ro := &runOpts{concurrency: 1, iterations: 1}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+	if len(reqs) == 0 || ro.concurrency < 1 {
+		return &Report{}
+	}
+	if ro.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ro.duration)
+		defer cancel()
+	}
+
+	var routes []gin.RouteInfo
+	if e, ok := h.(*gin.Engine); ok {
+		routes = e.Routes()
+	}
+
+	// Feed request indices to the workers
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := 0; ro.duration > 0 || i < ro.iterations; i++ {
+			for j := range reqs {
+				select {
+				case next <- j:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	c := newCollector()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < ro.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := reqs[i]
+				status, elapsed := do(ctx, h, r)
+				c.add(r.Method, routeOf(routes, r), status, elapsed)
+			}
+		}()
+	}
+	wg.Wait()
+	return c.report(time.Since(start))
+}
+
+func do(ctx context.Context, h http.Handler, r Request) (int, time.Duration) {
+	req := httptest.NewRequest(r.Method, r.URL, bytes.NewReader(r.Body)).WithContext(ctx)
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(w, req)
+	return w.Code, time.Since(start)
+}
+
+// Group a request by the engine route it matches, preferring static segments as gin does
+func routeOf(routes []gin.RouteInfo, r Request) string {
+	if r.Route != "" {
+		return r.Route
+	}
+	p := r.URL
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	best, bestScore := "", -1
+	for _, ri := range routes {
+		if ri.Method != r.Method {
+			continue
+		}
+		if score, ok := match(ri.Path, p); ok && score > bestScore {
+			best, bestScore = ri.Path, score
+		}
+	}
+	if best == "" {
+		return p
+	}
+	return best
+}
+
+// Match a gin route pattern, returning the number of static segments matched
+func match(pattern, path string) (int, bool) {
+	ps, segs := strings.Split(pattern, "/"), strings.Split(path, "/")
+	score := 0
+	for i, p := range ps {
+		if strings.HasPrefix(p, "*") {
+			return score, true
+		}
+		if i >= len(segs) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":"):
+			if segs[i] == "" {
+				return 0, false
+			}
+		case p == segs[i]:
+			score++
+		default:
+			return 0, false
+		}
+	}
+	return score, len(ps) == len(segs)
+}
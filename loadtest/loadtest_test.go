@@ -0,0 +1,92 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const recordings = `{"method":"GET","url":"/users/1?fields=name"}
+{"method":"GET","url":"/users/me"}
+{"method":"POST","url":"/users","header":{"Content-Type":["application/json"]},"body":"eyJuYW1lIjoiYSJ9"}
+{"method":"GET","url":"/fail"}
+`
+
+func TestRun(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var bodies int32
+
+	e := gin.New()
+	e.GET("/users/:id", func(ctx *gin.Context) {})
+	e.GET("/users/me", func(ctx *gin.Context) {})
+	e.POST("/users", func(ctx *gin.Context) {
+		var body struct{ Name string }
+		if ctx.ShouldBindJSON(&body) == nil && body.Name == "a" {
+			atomic.AddInt32(&bodies, 1)
+		}
+	})
+	e.GET("/fail", func(ctx *gin.Context) {
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	reqs, err := Load(strings.NewReader(recordings))
+	assert.NoError(t, err)
+	assert.Len(t, reqs, 4)
+
+	report := Run(context.Background(), e, reqs, WithConcurrency(4), WithIterations(10))
+	assert.Equal(t, 40, report.Requests)
+	assert.Equal(t, int32(10), bodies)
+
+	routes := map[string]RouteReport{}
+	for _, rr := range report.Routes {
+		routes[rr.Method+" "+rr.Route] = rr
+	}
+	assert.Equal(t, 10, routes["GET /users/:id"].Requests)
+	assert.Equal(t, 10, routes["GET /users/me"].Requests)
+	assert.Equal(t, 10, routes["GET /fail"].Errors)
+	assert.LessOrEqual(t, routes["GET /users/:id"].P50, routes["GET /users/:id"].Max)
+
+	buf := &bytes.Buffer{}
+	_, err = report.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "/users/:id")
+	assert.Contains(t, buf.String(), "40 requests in")
+}
+
+func TestDuration(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	report := Run(context.Background(), h, []Request{{Method: "GET", URL: "/a?x=1"}}, WithDuration(10*time.Millisecond))
+	assert.Greater(t, report.Requests, 1)
+	assert.Equal(t, "/a", report.Routes[0].Route)
+}
+
+func TestEmpty(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	report := Run(context.Background(), h, nil, WithDuration(time.Hour))
+	assert.Equal(t, 0, report.Requests)
+
+	report = Run(context.Background(), h, []Request{{Method: "GET", URL: "/a"}}, WithConcurrency(0))
+	assert.Equal(t, 0, report.Requests)
+}
+
+func TestRegressions(t *testing.T) {
+	baseline := &Report{Routes: []RouteReport{
+		{Method: "GET", Route: "/a", P90: 10 * time.Millisecond},
+		{Method: "GET", Route: "/b", P90: 10 * time.Millisecond},
+	}}
+	current := &Report{Routes: []RouteReport{
+		{Method: "GET", Route: "/a", P90: 11 * time.Millisecond},
+		{Method: "GET", Route: "/b", P90: 13 * time.Millisecond},
+		{Method: "GET", Route: "/c", P90: time.Second},
+	}}
+	r := current.Regressions(baseline, 0.2)
+	assert.Len(t, r, 1)
+	assert.Equal(t, "GET /b p90 10ms -> 13ms", r[0].String())
+}
@@ -0,0 +1,149 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// RouteReport is the latency distribution of a route
+type RouteReport struct {
+	Method   string        `json:"method"`
+	Route    string        `json:"route"`
+	Requests int           `json:"requests"`
+	Errors   int           `json:"errors"` // 5xx responses
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+}
+
+// Report is the result of a run, which can be saved as JSON as a baseline for later runs
+type Report struct {
+	Elapsed    time.Duration `json:"elapsed"`
+	Requests   int           `json:"requests"`
+	Throughput float64       `json:"throughput"` // Requests per second
+	Routes     []RouteReport `json:"routes"`     // Sorted by route, then method
+}
+
+// Regression is a route slower than in the baseline
+type Regression struct {
+	Method   string
+	Route    string
+	Baseline time.Duration // Baseline P90
+	Current  time.Duration // Current P90
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s p90 %s -> %s", r.Method, r.Route, r.Baseline, r.Current)
+}
+
+// Regressions returns routes whose P90 latency exceeds the baseline by more than the tolerance, e.g. 0.2 for 20%.
+// Routes missing from either report are ignored.
+func (r *Report) Regressions(baseline *Report, tolerance float64) []Regression {
+	base := map[string]RouteReport{}
+	for _, rr := range baseline.Routes {
+		base[rr.Method+" "+rr.Route] = rr
+	}
+	var res []Regression
+	for _, rr := range r.Routes {
+		b, ok := base[rr.Method+" "+rr.Route]
+		if !ok {
+			continue
+		}
+		if float64(rr.P90) > float64(b.P90)*(1+tolerance) {
+			res = append(res, Regression{Method: rr.Method, Route: rr.Route, Baseline: b.P90, Current: rr.P90})
+		}
+	}
+	return res
+}
+
+// WriteTo writes the report as a table
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tROUTE\tREQUESTS\tERRORS\tP50\tP90\tP99\tMAX")
+	for _, rr := range r.Routes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			rr.Method, rr.Route, rr.Requests, rr.Errors, rr.P50, rr.P90, rr.P99, rr.Max)
+	}
+	tw.Flush()
+	fmt.Fprintf(&b, "%d requests in %s, %.1f req/s\n", r.Requests, r.Elapsed, r.Throughput)
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+type collector struct {
+	mu     sync.Mutex
+	routes map[[2]string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func newCollector() *collector {
+	return &collector{routes: map[[2]string]*samples{}}
+}
+
+func (c *collector) add(method, route string, status int, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := [2]string{method, route}
+	s, ok := c.routes[k]
+	if !ok {
+		s = &samples{}
+		c.routes[k] = s
+	}
+	s.latencies = append(s.latencies, elapsed)
+	if status >= 500 {
+		s.errors++
+	}
+}
+
+func (c *collector) report(elapsed time.Duration) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &Report{Elapsed: elapsed}
+	for k, s := range c.routes {
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		r.Routes = append(r.Routes, RouteReport{
+			Method:   k[0],
+			Route:    k[1],
+			Requests: len(s.latencies),
+			Errors:   s.errors,
+			P50:      percentile(s.latencies, 0.5),
+			P90:      percentile(s.latencies, 0.9),
+			P99:      percentile(s.latencies, 0.99),
+			Max:      s.latencies[len(s.latencies)-1],
+		})
+		r.Requests += len(s.latencies)
+	}
+	sort.Slice(r.Routes, func(i, j int) bool {
+		if r.Routes[i].Route != r.Routes[j].Route {
+			return r.Routes[i].Route < r.Routes[j].Route
+		}
+		return r.Routes[i].Method < r.Routes[j].Method
+	})
+	if elapsed > 0 {
+		r.Throughput = float64(r.Requests) / elapsed.Seconds()
+	}
+	return r
+}
+
+// Nearest rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
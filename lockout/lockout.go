@@ -0,0 +1,436 @@
+// Credential attempt lockout
+//
+// Tracks failed authentication attempts per identity and client IP, locking the pair out for an
+// exponentially increasing duration once a threshold is reached. Lockouts can be lifted early with
+// signed unlock tokens (e.g. emailed to the account owner).
+//
+// Login handlers, or the failure/success hooks of an authentication middleware, report outcomes with
+// Fail and Succeed; Guard rejects locked out attempts before credentials are checked:
+//
+//	l := lockout.New(lockout.WithSecret(secret))
+//	e.POST("/login", l.Guard(lockout.FormIdentity("username")), func(ctx *gin.Context) {
+//		if !valid {
+//			l.Fail(ctx, username)
+//			...
+//		}
+//		l.Succeed(ctx, username)
+//	})
+package lockout
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Audit event types
+const (
+	EventFailure  = "failure"
+	EventLocked   = "locked"
+	EventRejected = "rejected"
+	EventSuccess  = "success"
+	EventUnlocked = "unlocked"
+)
+
+var (
+	ErrInvalidToken = fmt.Errorf("lockout: invalid unlock token")
+	ErrExpiredToken = fmt.Errorf("lockout: unlock token expired")
+)
+
+// Record is the attempt state of an identity and IP pair
+type Record struct {
+	Failures    int       // Consecutive failures
+	Last        time.Time // Time of the last failure
+	LockedUntil time.Time // Zero if not locked
+	Expires     time.Time // Time after which the record is no longer needed and can be dropped
+}
+
+// Store persists attempt records. Implementations must be safe for concurrent use, and treat records past their
+// expiry as absent.
+type Store interface {
+	Get(identity, ip string) (Record, bool)
+	// Update atomically replaces the record of the pair with the result of fn, which is passed the current record
+	// or the zero Record, and returns the new record
+	Update(identity, ip string, fn func(Record) Record) Record
+	Delete(identity, ip string)
+	// DeleteIdentity removes the records of an identity for all IPs
+	DeleteIdentity(identity string)
+}
+
+// Event describes a lockout state change for auditing
+type Event struct {
+	Type        string    `json:"type"`
+	Identity    string    `json:"identity"`
+	IP          string    `json:"ip,omitempty"`
+	Failures    int       `json:"failures,omitempty"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
+
+// Auditor receives lockout events, e.g. to forward to an audit log
+type Auditor func(ctx *gin.Context, ev Event)
+
+type lockoutOpts struct {
+	threshold int           // Failures before the first lockout
+	base      time.Duration // Duration of the first lockout
+	max       time.Duration // Upper bound on lockout duration
+	window    time.Duration // Failures are forgotten after this period without a failure
+	tokenTTL  time.Duration // Unlock token validity
+	secret    []byte        // Unlock token signing key
+	store     Store
+	auditor   Auditor
+}
+
+// Modifier function for customising lockout behaviour
+type LockoutOpts func(*lockoutOpts) *lockoutOpts
+
+// Lockout tracks attempts and enforces lockouts
+type Lockout struct {
+	opts *lockoutOpts
+}
+
+// New creates a lockout tracker, using an in-memory store unless WithStore is given
+func New(opts ...LockoutOpts) *Lockout {
+	lo := &lockoutOpts{
+		threshold: 5,
+		base:      time.Minute,
+		max:       24 * time.Hour,
+		window:    time.Hour,
+		tokenTTL:  time.Hour,
+	}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+	if lo.store == nil {
+		lo.store = NewMemoryStore()
+	}
+	if lo.secret == nil {
+		lo.secret = make([]byte, 32)
+		rand.Read(lo.secret)
+	}
+	return &Lockout{opts: lo}
+}
+
+// Guard returns a middleware rejecting attempts for locked out identity and IP pairs with 429.
+// The identity func extracts the identity being authenticated, e.g. a username, from the request.
+func (l *Lockout) Guard(identity func(*gin.Context) string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := identity(ctx)
+		if id == "" {
+			return
+		}
+		if remaining := l.Locked(id, ctx.ClientIP()); remaining > 0 {
+			l.audit(ctx, Event{Type: EventRejected, Identity: id, IP: ctx.ClientIP()})
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+			errors.AbortWith(ctx, http.StatusTooManyRequests, "account_locked")
+		}
+	}
+}
+
+// Locked returns the remaining lockout duration for the pair, or zero if not locked
+func (l *Lockout) Locked(identity, ip string) time.Duration {
+	r, ok := l.opts.store.Get(identity, ip)
+	if !ok {
+		return 0
+	}
+	if d := time.Until(r.LockedUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Fail records a failed attempt, locking the pair out if the threshold is reached.
+// Returns the lockout duration applied, or zero.
+func (l *Lockout) Fail(ctx *gin.Context, identity string) time.Duration {
+	ip := ctx.ClientIP()
+	now := time.Now()
+
+	var d time.Duration
+	r := l.opts.store.Update(identity, ip, func(r Record) Record {
+		if !r.Last.IsZero() && now.Sub(r.Last) > l.opts.window {
+			r = Record{}
+		}
+		r.Failures++
+		r.Last = now
+
+		d = 0
+		if r.Failures >= l.opts.threshold {
+			d = l.duration(r.Failures)
+			r.LockedUntil = now.Add(d)
+		}
+		// Keep the record while failures are remembered or the pair is locked out
+		r.Expires = now.Add(l.opts.window)
+		if r.LockedUntil.After(r.Expires) {
+			r.Expires = r.LockedUntil
+		}
+		return r
+	})
+
+	if d > 0 {
+		l.audit(ctx, Event{Type: EventLocked, Identity: identity, IP: ip, Failures: r.Failures, LockedUntil: r.LockedUntil})
+	} else {
+		l.audit(ctx, Event{Type: EventFailure, Identity: identity, IP: ip, Failures: r.Failures})
+	}
+	return d
+}
+
+// Succeed clears the attempt record of the pair after a successful authentication
+func (l *Lockout) Succeed(ctx *gin.Context, identity string) {
+	ip := ctx.ClientIP()
+	if _, ok := l.opts.store.Get(identity, ip); ok {
+		l.opts.store.Delete(identity, ip)
+		l.audit(ctx, Event{Type: EventSuccess, Identity: identity, IP: ip})
+	}
+}
+
+// UnlockToken creates a signed token which lifts all lockouts on the identity when redeemed
+func (l *Lockout) UnlockToken(identity string) string {
+	payload := make([]byte, 8, 8+len(identity))
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Add(l.opts.tokenTTL).Unix()))
+	payload = append(payload, identity...)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(l.sign(payload))
+}
+
+// Unlock redeems an unlock token, clearing all records of its identity.
+// Returns the identity which was unlocked.
+func (l *Lockout) Unlock(ctx *gin.Context, token string) (string, error) {
+	enc := base64.RawURLEncoding
+
+	p, s, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, err := enc.DecodeString(p)
+	if err != nil || len(payload) < 8 {
+		return "", ErrInvalidToken
+	}
+	sig, err := enc.DecodeString(s)
+	if err != nil || !hmac.Equal(sig, l.sign(payload)) {
+		return "", ErrInvalidToken
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint64(payload)) {
+		return "", ErrExpiredToken
+	}
+
+	identity := string(payload[8:])
+	l.opts.store.DeleteIdentity(identity)
+	l.audit(ctx, Event{Type: EventUnlocked, Identity: identity, IP: ctx.ClientIP()})
+	return identity, nil
+}
+
+// UnlockHandler returns a handler redeeming the token in the "token" query parameter
+func (l *Lockout) UnlockHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, err := l.Unlock(ctx, ctx.Query("token")); errors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_token") {
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (l *Lockout) duration(failures int) time.Duration {
+	// Double for each failure beyond the threshold
+	exp := failures - l.opts.threshold
+	if exp > 32 {
+		return l.opts.max
+	}
+	d := l.opts.base << uint(exp)
+	if d > l.opts.max || d <= 0 {
+		return l.opts.max
+	}
+	return d
+}
+
+func (l *Lockout) sign(payload []byte) []byte {
+	m := hmac.New(sha256.New, l.opts.secret)
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+func (l *Lockout) audit(ctx *gin.Context, ev Event) {
+	e := zlog.GetLogger(ctx).Warn()
+	if ev.Type == EventSuccess || ev.Type == EventUnlocked {
+		e = zlog.GetLogger(ctx).Info()
+	}
+	e.Str("event", ev.Type).
+		Str("identity", ev.Identity).
+		Str("ip", ev.IP).
+		Int("failures", ev.Failures).
+		Msg("lockout " + ev.Type)
+
+	if l.opts.auditor != nil {
+		l.opts.auditor(ctx, ev)
+	}
+}
+
+// FormIdentity returns an identity func reading a form field
+func FormIdentity(field string) func(*gin.Context) string {
+	return func(ctx *gin.Context) string {
+		return ctx.PostForm(field)
+	}
+}
+
+// HeaderIdentity returns an identity func reading a request header
+func HeaderIdentity(name string) func(*gin.Context) string {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// WithThreshold sets the number of consecutive failures before the first lockout
+func WithThreshold(n int) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.threshold = n
+		return lo
+	}
+}
+
+// WithDurations sets the first lockout duration and the upper bound it doubles towards
+func WithDurations(base, max time.Duration) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.base = base
+		lo.max = max
+		return lo
+	}
+}
+
+// WithWindow sets the quiet period after which failures are forgotten
+func WithWindow(d time.Duration) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.window = d
+		return lo
+	}
+}
+
+// WithSecret sets the unlock token signing key, required when running multiple instances
+func WithSecret(secret []byte) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.secret = secret
+		return lo
+	}
+}
+
+// WithTokenTTL sets the validity of unlock tokens
+func WithTokenTTL(d time.Duration) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.tokenTTL = d
+		return lo
+	}
+}
+
+// WithStore sets the record store
+func WithStore(s Store) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.store = s
+		return lo
+	}
+}
+
+// WithAuditor sets a hook receiving all lockout events
+func WithAuditor(a Auditor) LockoutOpts {
+	return func(lo *lockoutOpts) *lockoutOpts {
+		lo.auditor = a
+		return lo
+	}
+}
+
+// Minimum interval between sweeps of expired records from a MemoryStore
+const pruneInterval = time.Minute
+
+// MemoryStore is an in-memory Store suitable for single instance deployments.
+// Expired records are swept at most once per minute as records are updated.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]map[string]Record // identity -> ip -> record
+	nextPrune time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: map[string]map[string]Record{},
+	}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(identity, ip string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[identity][ip]
+	if !ok || expired(r, time.Now()) {
+		return Record{}, false
+	}
+	return r, true
+}
+
+// Update implements Store
+func (s *MemoryStore) Update(identity, ip string, fn func(Record) Record) Record {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextPrune) {
+		s.prune(now)
+		s.nextPrune = now.Add(pruneInterval)
+	}
+
+	r, ok := s.records[identity][ip]
+	if !ok || expired(r, now) {
+		r = Record{}
+	}
+	r = fn(r)
+	if s.records[identity] == nil {
+		s.records[identity] = map[string]Record{}
+	}
+	s.records[identity][ip] = r
+	return r
+}
+
+// Remove expired records, with the lock held
+func (s *MemoryStore) prune(now time.Time) {
+	for identity, ips := range s.records {
+		for ip, r := range ips {
+			if expired(r, now) {
+				delete(ips, ip)
+			}
+		}
+		if len(ips) == 0 {
+			delete(s.records, identity)
+		}
+	}
+}
+
+func expired(r Record, now time.Time) bool {
+	return !r.Expires.IsZero() && now.After(r.Expires)
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(identity, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records[identity], ip)
+	if len(s.records[identity]) == 0 {
+		delete(s.records, identity)
+	}
+}
+
+// DeleteIdentity implements Store
+func (s *MemoryStore) DeleteIdentity(identity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, identity)
+}
@@ -0,0 +1,127 @@
+package lockout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testContext() *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request, _ = http.NewRequest("POST", "/login", nil)
+	ctx.Request.RemoteAddr = "192.0.2.1:1234"
+	return ctx
+}
+
+func TestExponentialLockout(t *testing.T) {
+	l := New(WithThreshold(3), WithDurations(time.Minute, 10*time.Minute))
+	ctx := testContext()
+
+	assert.Zero(t, l.Fail(ctx, "alice"))
+	assert.Zero(t, l.Fail(ctx, "alice"))
+	assert.Equal(t, time.Minute, l.Fail(ctx, "alice"))
+	assert.Equal(t, 2*time.Minute, l.Fail(ctx, "alice"))
+	assert.Equal(t, 4*time.Minute, l.Fail(ctx, "alice"))
+	assert.Equal(t, 8*time.Minute, l.Fail(ctx, "alice"))
+	assert.Equal(t, 10*time.Minute, l.Fail(ctx, "alice"))
+
+	assert.Greater(t, l.Locked("alice", "192.0.2.1"), time.Duration(0))
+	assert.Zero(t, l.Locked("alice", "192.0.2.2"))
+	assert.Zero(t, l.Locked("bob", "192.0.2.1"))
+
+	l.Succeed(ctx, "alice")
+	assert.Zero(t, l.Locked("alice", "192.0.2.1"))
+}
+
+func TestConcurrentFailures(t *testing.T) {
+	l := New(WithThreshold(1000))
+	ctx := testContext()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Fail(ctx.Copy(), "alice")
+		}()
+	}
+	wg.Wait()
+
+	r, ok := l.opts.store.Get("alice", "192.0.2.1")
+	assert.True(t, ok)
+	assert.Equal(t, 50, r.Failures)
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	s := NewMemoryStore()
+	past := time.Now().Add(-time.Second)
+
+	s.Update("alice", "192.0.2.1", func(r Record) Record {
+		r.Failures = 1
+		r.Expires = past
+		return r
+	})
+	_, ok := s.Get("alice", "192.0.2.1")
+	assert.False(t, ok)
+
+	s.nextPrune = time.Time{}
+	r := s.Update("bob", "192.0.2.1", func(r Record) Record {
+		r.Failures++
+		return r
+	})
+	assert.Equal(t, 1, r.Failures)
+	assert.NotContains(t, s.records, "alice")
+}
+
+func TestUnlockToken(t *testing.T) {
+	var events []string
+	l := New(WithThreshold(1), WithAuditor(func(ctx *gin.Context, ev Event) {
+		events = append(events, ev.Type)
+	}))
+	ctx := testContext()
+
+	l.Fail(ctx, "alice")
+	assert.Greater(t, l.Locked("alice", "192.0.2.1"), time.Duration(0))
+
+	id, err := l.Unlock(ctx, l.UnlockToken("alice"))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", id)
+	assert.Zero(t, l.Locked("alice", "192.0.2.1"))
+	assert.Equal(t, []string{EventLocked, EventUnlocked}, events)
+
+	_, err = New().Unlock(ctx, l.UnlockToken("alice"))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestGuard(t *testing.T) {
+	l := New(WithThreshold(2))
+
+	e := gin.New()
+	e.POST("/login", l.Guard(FormIdentity("username")), func(ctx *gin.Context) {
+		l.Fail(ctx, ctx.PostForm("username"))
+		ctx.Status(http.StatusUnauthorized)
+	})
+
+	form := url.Values{"username": {"alice"}}.Encode()
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		e.ServeHTTP(w, req)
+		codes = append(codes, w.Result().StatusCode)
+
+		if i == 2 {
+			assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		}
+	}
+
+	assert.Equal(t, []int{401, 401, 429}, codes)
+}
@@ -0,0 +1,282 @@
+// Media upload processing
+//
+// Validates uploaded images before they are accepted, and runs post-processing hooks such as thumbnail generation
+// or transcoding afterwards. Validation sniffs the actual format from the content rather than trusting the
+// filename or declared content type, and reads only the image header to check dimensions, so decompression bombs
+// (small files declaring huge dimensions) are rejected before any pixels are decoded.
+//
+//	p := mediaproc.New(
+//		mediaproc.WithLimits(mediaproc.Limits{MaxWidth: 8000, MaxHeight: 8000, MaxPixels: 40e6}),
+//		mediaproc.WithProcessor("thumb", mediaproc.Thumbnail(256, 256, saveThumb)),
+//	)
+//	e.POST("/avatars", p.Validate("file"), func(ctx *gin.Context) {
+//		info := mediaproc.GetInfo(ctx)
+//		...
+//		p.Process(ctx, key, data)
+//	})
+//
+// Processors run asynchronously through a Runner, which can be backed by a job queue, see WithRunner.
+package mediaproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF decoder
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+const infoKey = "mediaproc"
+
+var (
+	// ErrFormat is returned for content which isn't an allowed image format
+	ErrFormat = errors.New("mediaproc: unsupported image format")
+
+	// ErrMismatch is returned when the sniffed content type doesn't match the decoded image format
+	ErrMismatch = errors.New("mediaproc: content type mismatch")
+
+	// ErrDimensions is returned for images exceeding the dimension or pixel limits
+	ErrDimensions = errors.New("mediaproc: image dimensions exceed limits")
+)
+
+// Limits restricts accepted images. Zero values are unlimited.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int      // Width * height, the main decompression bomb guard
+	MaxBytes  int64    // Encoded size
+	Formats   []string // Allowed formats as named by the image package, e.g. "png", "jpeg"
+}
+
+// DefaultLimits allows PNG, JPEG and GIF images up to 50 megapixels and 20MB
+var DefaultLimits = Limits{
+	MaxWidth:  16384,
+	MaxHeight: 16384,
+	MaxPixels: 50_000_000,
+	MaxBytes:  20 << 20,
+	Formats:   []string{"png", "jpeg", "gif"},
+}
+
+// Info describes a validated image
+type Info struct {
+	Format      string // Image package format name, e.g. "png"
+	ContentType string // Sniffed MIME type, e.g. "image/png"
+	Width       int
+	Height      int
+	Size        int64 // Encoded size in bytes
+}
+
+// Inspect validates the encoded image against the limits without decoding pixel data
+func (l Limits) Inspect(data []byte) (Info, error) {
+	info := Info{
+		ContentType: http.DetectContentType(data),
+		Size:        int64(len(data)),
+	}
+	if l.MaxBytes > 0 && info.Size > l.MaxBytes {
+		return info, fmt.Errorf("%w: %d bytes", ErrDimensions, info.Size)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return info, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	info.Format, info.Width, info.Height = format, cfg.Width, cfg.Height
+
+	if len(l.Formats) > 0 && !contains(l.Formats, format) {
+		return info, fmt.Errorf("%w: %s", ErrFormat, format)
+	}
+	if info.ContentType != "image/"+format {
+		return info, fmt.Errorf("%w: %s decoded as %s", ErrMismatch, info.ContentType, format)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 ||
+		(l.MaxWidth > 0 && cfg.Width > l.MaxWidth) ||
+		(l.MaxHeight > 0 && cfg.Height > l.MaxHeight) ||
+		(l.MaxPixels > 0 && cfg.Width*cfg.Height > l.MaxPixels) {
+		return info, fmt.Errorf("%w: %dx%d", ErrDimensions, cfg.Width, cfg.Height)
+	}
+	return info, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Input is passed to processors for a validated upload
+type Input struct {
+	Key  string // Storage key of the original upload
+	Info Info
+	Data []byte
+}
+
+// Processor post-processes a validated upload, e.g. generating a thumbnail
+type Processor interface {
+	Process(ctx context.Context, in Input) error
+}
+
+// ProcessorFunc adapts a function to a Processor
+type ProcessorFunc func(ctx context.Context, in Input) error
+
+// Process calls f(ctx, in)
+func (f ProcessorFunc) Process(ctx context.Context, in Input) error {
+	return f(ctx, in)
+}
+
+// Runner executes processing jobs, e.g. by enqueueing them on a job queue. Name identifies the job for logging
+// and deduplication, in the form "<processor>:<key>".
+type Runner interface {
+	Run(ctx context.Context, name string, job func(context.Context) error) error
+}
+
+// RunnerFunc adapts a function to a Runner
+type RunnerFunc func(ctx context.Context, name string, job func(context.Context) error) error
+
+// Run calls f(ctx, name, job)
+func (f RunnerFunc) Run(ctx context.Context, name string, job func(context.Context) error) error {
+	return f(ctx, name, job)
+}
+
+// Goroutine runs each job in a new goroutine, logging failures. Jobs are lost if the process exits.
+var Goroutine Runner = RunnerFunc(func(ctx context.Context, name string, job func(context.Context) error) error {
+	logger := zlog.GetLogger(ctx)
+	go func() {
+		jctx := zlog.WithLogger(context.Background(), logger)
+		if err := job(jctx); err != nil {
+			logger.Error().Err(err).Str("job", name).Msg("Media processing failed")
+		}
+	}()
+	return nil
+})
+
+type namedProcessor struct {
+	name string
+	p    Processor
+}
+
+type pipelineOpts struct {
+	limits     Limits
+	processors []namedProcessor
+	runner     Runner
+}
+
+// Modifier function for customising pipeline behaviour
+type PipelineOpts func(*pipelineOpts) *pipelineOpts
+
+// Pipeline validates uploads and runs processors on them
+type Pipeline struct {
+	opts *pipelineOpts
+}
+
+// New creates a processing pipeline
+func New(opts ...PipelineOpts) *Pipeline {
+	po := &pipelineOpts{
+		limits: DefaultLimits,
+		runner: Goroutine,
+	}
+	for _, f := range opts {
+		po = f(po)
+	}
+	return &Pipeline{opts: po}
+}
+
+// WithLimits sets the validation limits, defaults to DefaultLimits
+func WithLimits(l Limits) PipelineOpts {
+	return func(po *pipelineOpts) *pipelineOpts {
+		po.limits = l
+		return po
+	}
+}
+
+// WithProcessor adds a named processor, run in the order added
+func WithProcessor(name string, p Processor) PipelineOpts {
+	return func(po *pipelineOpts) *pipelineOpts {
+		po.processors = append(po.processors, namedProcessor{name, p})
+		return po
+	}
+}
+
+// WithRunner sets the job runner for processors, defaults to Goroutine
+func WithRunner(r Runner) PipelineOpts {
+	return func(po *pipelineOpts) *pipelineOpts {
+		po.runner = r
+		return po
+	}
+}
+
+// Inspect validates an upload against the pipeline limits
+func (p *Pipeline) Inspect(data []byte) (Info, error) {
+	return p.opts.limits.Inspect(data)
+}
+
+// Process validates an upload stored under key and submits a job for each processor
+func (p *Pipeline) Process(ctx context.Context, key string, data []byte) (Info, error) {
+	info, err := p.Inspect(data)
+	if err != nil {
+		return info, err
+	}
+	in := Input{Key: key, Info: info, Data: data}
+	for _, np := range p.opts.processors {
+		np := np
+		if err := p.opts.runner.Run(ctx, np.name+":"+key, func(ctx context.Context) error {
+			return np.p.Process(ctx, in)
+		}); err != nil {
+			return info, fmt.Errorf("mediaproc: submitting %s: %w", np.name, err)
+		}
+	}
+	return info, nil
+}
+
+// Validate checks the multipart file field is a valid image, aborting with 413 if it is too large, or 415 if it
+// isn't an allowed format or its dimensions exceed the limits. The image info is attached to the context, see GetInfo.
+func (p *Pipeline) Validate(field string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		fh, err := ctx.FormFile(field)
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "missing_file") {
+			return
+		}
+		if p.opts.limits.MaxBytes > 0 && fh.Size > p.opts.limits.MaxBytes {
+			ginxerrors.AbortWith(ctx, http.StatusRequestEntityTooLarge, "file_too_large")
+			return
+		}
+
+		f, err := fh.Open()
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_file") {
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if ginxerrors.AbortWithError(ctx, err, http.StatusBadRequest, "invalid_file") {
+			return
+		}
+
+		info, err := p.Inspect(data)
+		if err != nil {
+			zlog.GetLogger(ctx).Warn().Err(err).Str("field", field).Msg("Rejected media upload")
+			ginxerrors.AbortWithError(ctx, err, http.StatusUnsupportedMediaType, "invalid_image")
+			return
+		}
+		ctx.Set(infoKey, &info)
+	}
+}
+
+// GetInfo returns the image info attached by Validate, or nil
+func GetInfo(ctx *gin.Context) *Info {
+	if v, ok := ctx.Get(infoKey); ok {
+		return v.(*Info)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package mediaproc
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func pngData(w, h int) []byte {
+	buf := &bytes.Buffer{}
+	_ = png.Encode(buf, image.NewGray(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+// Runs jobs synchronously for testing
+var inline = RunnerFunc(func(ctx context.Context, name string, job func(context.Context) error) error {
+	return job(ctx)
+})
+
+func TestInspect(t *testing.T) {
+	info, err := DefaultLimits.Inspect(pngData(30, 20))
+	assert.NoError(t, err)
+	assert.Equal(t, "png", info.Format)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, 30, info.Width)
+	assert.Equal(t, 20, info.Height)
+
+	_, err = DefaultLimits.Inspect([]byte("<svg></svg>"))
+	assert.ErrorIs(t, err, ErrFormat)
+
+	_, err = Limits{MaxPixels: 100}.Inspect(pngData(11, 10))
+	assert.ErrorIs(t, err, ErrDimensions)
+
+	_, err = Limits{Formats: []string{"jpeg"}}.Inspect(pngData(1, 1))
+	assert.ErrorIs(t, err, ErrFormat)
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	var stored []byte
+	var storedKey string
+
+	p := New(
+		WithRunner(inline),
+		WithProcessor("thumb", Thumbnail(10, 10, func(ctx context.Context, key string, data []byte) error {
+			storedKey, stored = key, data
+			return nil
+		})),
+	)
+
+	_, err := p.Process(context.Background(), "a.png", pngData(40, 20))
+	assert.NoError(t, err)
+	assert.Equal(t, "a.png", storedKey)
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(stored))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, cfg.Width)
+	assert.Equal(t, 5, cfg.Height)
+}
+
+func TestValidate(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	p := New(WithLimits(Limits{MaxPixels: 10000, Formats: []string{"png"}}))
+	e := gin.New()
+	e.POST("/", p.Validate("file"), func(ctx *gin.Context) {
+		info := GetInfo(ctx)
+		ctx.JSON(http.StatusOK, info)
+	})
+
+	upload := func(data []byte) *httptest.ResponseRecorder {
+		buf := &bytes.Buffer{}
+		mw := multipart.NewWriter(buf)
+		fw, _ := mw.CreateFormFile("file", "upload.png")
+		_, _ = fw.Write(data)
+		_ = mw.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload(pngData(50, 50))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"Width":50`)
+
+	w = upload(pngData(200, 200))
+	assert.Equal(t, 415, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_image")
+
+	w = upload([]byte("GIF89a not really"))
+	assert.Equal(t, 415, w.Code)
+}
@@ -0,0 +1,55 @@
+package mediaproc
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+)
+
+// Thumbnail returns a processor which scales images to fit within maxWidth x maxHeight, preserving aspect ratio,
+// and passes the PNG encoded result to store. Images already within the bounds are re-encoded without scaling.
+// Scaling is nearest-neighbour, use a custom processor where quality matters.
+func Thumbnail(maxWidth, maxHeight int, store func(ctx context.Context, key string, png []byte) error) Processor {
+	return ProcessorFunc(func(ctx context.Context, in Input) error {
+		src, _, err := image.Decode(bytes.NewReader(in.Data))
+		if err != nil {
+			return err
+		}
+
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, scale(src, maxWidth, maxHeight)); err != nil {
+			return err
+		}
+		return store(ctx, in.Key, buf.Bytes())
+	})
+}
+
+func scale(src image.Image, maxWidth, maxHeight int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return src
+	}
+
+	// Fit the larger relative dimension to the bound
+	dw, dh := maxWidth, h*maxWidth/w
+	if dh > maxHeight {
+		dw, dh = w*maxHeight/h, maxHeight
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy := b.Min.Y + y*h/dh
+		for x := 0; x < dw; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*w/dw, sy))
+		}
+	}
+	return dst
+}
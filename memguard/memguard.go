@@ -0,0 +1,173 @@
+// Per-request allocation budget
+//
+// Debug middleware measuring the heap allocated while each request is handled, from runtime metrics deltas, and
+// logging requests over a budget. Helps find allocation-heavy handlers in load tests or local runs.
+//
+//	e.Use(zlog.Logger(zerolog.InfoLevel), memguard.Middleware(1<<20, memguard.WithObjectBudget(10000)))
+//	admin.GET("/allocs", memguard.Handler())
+//
+// The runtime counters are process wide, so measurements are only attributed to a request when no other request
+// was in flight at any point while it was handled; other requests are skipped. Small allocations are counted by the
+// runtime in batches, so figures are approximate. Not intended for production use.
+package memguard
+
+import (
+	"net/http"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+const (
+	metricBytes   = "/gc/heap/allocs:bytes"
+	metricObjects = "/gc/heap/allocs:objects"
+)
+
+// RouteStats are the allocation measurements of a route
+type RouteStats struct {
+	Method       string `json:"method"`
+	Route        string `json:"route"`
+	Measured     uint64 `json:"measured"`      // Requests measured, excluding those skipped for concurrency
+	OverBudget   uint64 `json:"over_budget"`   // Measured requests over budget
+	MaxBytes     uint64 `json:"max_bytes"`     // Most bytes allocated by a single request
+	MaxObjects   uint64 `json:"max_objects"`   // Most objects allocated by a single request
+	TotalBytes   uint64 `json:"total_bytes"`   // Total bytes allocated by measured requests
+	TotalObjects uint64 `json:"total_objects"` // Total objects allocated by measured requests
+}
+
+type guardOpts struct {
+	objects    uint64 // Object budget, 0 for none
+	sampleRate uint32 // Measure one in every n requests
+	counter    uint32
+}
+
+// Modifier function for customising guard behaviour
+type GuardOpts func(*guardOpts) *guardOpts
+
+// WithObjectBudget also flags requests allocating more than n heap objects
+func WithObjectBudget(n uint64) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.objects = n
+		return g
+	}
+}
+
+// WithSampleRate measures one in every n requests, reducing the overhead of reading runtime metrics
+func WithSampleRate(n uint32) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.sampleRate = n
+		return g
+	}
+}
+
+var (
+	// Requests in flight through any guard, and a generation incremented whenever a request starts, so a request
+	// can tell if another overlapped it
+	inFlight   int64
+	generation uint64
+
+	mu     sync.Mutex
+	routes = map[string]*RouteStats{}
+)
+
+// Middleware measures heap allocations of each request handled alone, logging those allocating more than budget
+// bytes at warn level through the request logger. A budget of 0 records stats without logging.
+func Middleware(budget uint64, opts ...GuardOpts) gin.HandlerFunc {
+	g := &guardOpts{sampleRate: 1}
+	for _, f := range opts {
+		g = f(g)
+	}
+
+	return func(ctx *gin.Context) {
+		gen := atomic.AddUint64(&generation, 1)
+		concurrent := atomic.AddInt64(&inFlight, 1) > 1
+		defer atomic.AddInt64(&inFlight, -1)
+
+		if concurrent || (g.sampleRate > 1 && atomic.AddUint32(&g.counter, 1)%g.sampleRate != 0) {
+			ctx.Next()
+			return
+		}
+
+		before := read()
+		ctx.Next()
+		after := read()
+
+		// Another request started while this one was in flight
+		if atomic.LoadUint64(&generation) != gen {
+			return
+		}
+
+		bytes := after[0].Value.Uint64() - before[0].Value.Uint64()
+		objects := after[1].Value.Uint64() - before[1].Value.Uint64()
+		over := (budget > 0 && bytes > budget) || (g.objects > 0 && objects > g.objects)
+		record(ctx.Request.Method, ctx.FullPath(), bytes, objects, over)
+
+		if over {
+			zlog.GetLogger(ctx).Warn().
+				Uint64("alloc_bytes", bytes).
+				Uint64("alloc_objects", objects).
+				Uint64("budget_bytes", budget).
+				Str("route", ctx.FullPath()).
+				Msg("Request over allocation budget")
+		}
+	}
+}
+
+func read() []metrics.Sample {
+	s := []metrics.Sample{{Name: metricBytes}, {Name: metricObjects}}
+	metrics.Read(s)
+	return s
+}
+
+func record(method, route string, bytes, objects uint64, over bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	k := method + " " + route
+	rs, ok := routes[k]
+	if !ok {
+		rs = &RouteStats{Method: method, Route: route}
+		routes[k] = rs
+	}
+	rs.Measured++
+	if over {
+		rs.OverBudget++
+	}
+	if bytes > rs.MaxBytes {
+		rs.MaxBytes = bytes
+	}
+	if objects > rs.MaxObjects {
+		rs.MaxObjects = objects
+	}
+	rs.TotalBytes += bytes
+	rs.TotalObjects += objects
+}
+
+// Routes returns the measurements by route, highest maximum bytes first
+func Routes() []RouteStats {
+	mu.Lock()
+	res := make([]RouteStats, 0, len(routes))
+	for _, rs := range routes {
+		res = append(res, *rs)
+	}
+	mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].MaxBytes > res[j].MaxBytes })
+	return res
+}
+
+// Reset clears the measurements
+func Reset() {
+	mu.Lock()
+	routes = map[string]*RouteStats{}
+	mu.Unlock()
+}
+
+// Handler responds with the measurements by route
+func Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"routes": Routes()})
+	}
+}
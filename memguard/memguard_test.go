@@ -0,0 +1,70 @@
+package memguard
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var sink []byte
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	Reset()
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel), Middleware(512<<10))
+	e.GET("/heavy", func(ctx *gin.Context) {
+		sink = make([]byte, 1<<20)
+	})
+	e.GET("/light", func(ctx *gin.Context) {})
+
+	for _, p := range []string{"/heavy", "/light"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Contains(t, buf.String(), `"message":"Request over allocation budget"`)
+	assert.Contains(t, buf.String(), `"route":"/heavy"`)
+	assert.NotContains(t, buf.String(), `"route":"/light","message":"Request over`)
+
+	stats := Routes()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "/heavy", stats[0].Route)
+	assert.Equal(t, uint64(1), stats[0].OverBudget)
+	assert.GreaterOrEqual(t, stats[0].MaxBytes, uint64(1<<20))
+	assert.Equal(t, uint64(0), stats[1].OverBudget)
+}
+
+func TestConcurrentSkipped(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.Nop()
+	Reset()
+
+	inner := gin.New()
+	inner.Use(Middleware(1))
+	inner.GET("/inner", func(ctx *gin.Context) {})
+
+	// A request starting while another is in flight means neither is measured
+	e := gin.New()
+	e.Use(Middleware(1))
+	e.GET("/outer", func(ctx *gin.Context) {
+		req, _ := http.NewRequest("GET", "/inner", nil)
+		inner.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	req, _ := http.NewRequest("GET", "/outer", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, Routes())
+}
@@ -0,0 +1,173 @@
+// Startup schema migrations
+//
+// Runs pending migrations at startup before the server reports ready, holding an advisory lock when the runner
+// supports one so only one instance migrates, and logging each step.
+//
+//	m := migrate.New(migrate.Steps(store,
+//		migrate.Step{ID: "0001_create_users", Up: createUsers},
+//		migrate.Step{ID: "0002_add_email_index", Up: addEmailIndex},
+//	))
+//	h := echoexport.New(routes, echoexport.WithStartHook(m.Run))
+//	admin.GET("/readyz", m.ReadyHandler())
+//	if err := h.Start(ctx); err != nil { ... }
+//
+// Run can also be called directly before serving, or in a goroutine with the ready handler gating traffic. Runners
+// for external migration tools implement Runner, and Locker for advisory locks.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Runner applies migrations
+type Runner interface {
+	// Pending returns the IDs of migrations not yet applied, in order
+	Pending(ctx context.Context) ([]string, error)
+	// Apply applies and records a pending migration
+	Apply(ctx context.Context, id string) error
+}
+
+// State of a migrator
+type State string
+
+const (
+	StatePending State = "pending"
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateFailed  State = "failed"
+)
+
+// Status is the progress of a migrator
+type Status struct {
+	State   State    `json:"state"`
+	Applied []string `json:"applied,omitempty"` // Applied by this run
+	Error   string   `json:"error,omitempty"`
+}
+
+// Migrator runs migrations and tracks readiness
+type Migrator struct {
+	runner Runner
+
+	mu      sync.Mutex
+	status  Status
+	err     error         // Error of the last run
+	running chan struct{} // Closed when the run in progress completes, nil if none
+}
+
+// New creates a migrator applying the runner's migrations
+func New(runner Runner) *Migrator {
+	return &Migrator{runner: runner, status: Status{State: StatePending}}
+}
+
+// Run applies pending migrations in order, stopping at the first failure. Its signature matches
+// echoexport.Hook. Calling Run again after success does nothing, and after failure retries. Calls during a run wait
+// for it, until their context is done, and return its error.
+func (m *Migrator) Run(ctx context.Context) error {
+	m.mu.Lock()
+	if m.status.State == StateDone {
+		m.mu.Unlock()
+		return nil
+	}
+	if wait := m.running; wait != nil {
+		m.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.err
+	}
+	done := make(chan struct{})
+	m.running = done
+	m.status = Status{State: StateRunning}
+	m.mu.Unlock()
+
+	err := m.run(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err, m.running = err, nil
+	close(done)
+	if err != nil {
+		m.status.State = StateFailed
+		m.status.Error = err.Error()
+		log.Error().Err(err).Msg("Migration failed")
+		return err
+	}
+	m.status.State = StateDone
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context) error {
+	if l, ok := m.runner.(Locker); ok {
+		start := time.Now()
+		unlock, err := l.Lock(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: acquiring lock: %w", err)
+		}
+		log.Debug().Dur("elapsed", time.Since(start)).Msg("Acquired migration lock")
+		defer func() {
+			if err := unlock(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Failed to release migration lock")
+			}
+		}()
+	}
+
+	// Pending is read under the lock, as another instance may have applied migrations while waiting
+	pending, err := m.runner.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: listing pending migrations: %w", err)
+	}
+	if len(pending) == 0 {
+		log.Info().Msg("No pending migrations")
+		return nil
+	}
+	log.Info().Strs("pending", pending).Msg("Applying migrations")
+
+	for _, id := range pending {
+		start := time.Now()
+		if err := m.runner.Apply(ctx, id); err != nil {
+			return fmt.Errorf("migrate: applying %s: %w", id, err)
+		}
+		log.Info().Str("migration", id).Dur("elapsed", time.Since(start)).Msg("Applied migration")
+		m.mu.Lock()
+		m.status.Applied = append(m.status.Applied, id)
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Status returns the progress of the migrator
+func (m *Migrator) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.status
+	s.Applied = append([]string(nil), s.Applied...)
+	return s
+}
+
+// Ready returns true once migrations have been applied
+func (m *Migrator) Ready() bool {
+	return m.Status().State == StateDone
+}
+
+// ReadyHandler responds 200 once migrations have been applied, otherwise 503, with the status
+func (m *Migrator) ReadyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s := m.Status()
+		code := http.StatusOK
+		if s.State != StateDone {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.JSON(code, s)
+	}
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	store := NewMemoryStore()
+	var ran []string
+	fail := true
+	step := func(id string) Step {
+		return Step{ID: id, Up: func(context.Context) error {
+			if id == "0002" && fail {
+				return errors.New("syntax error")
+			}
+			ran = append(ran, id)
+			return nil
+		}}
+	}
+	m := New(Steps(store, step("0002"), step("0001"), step("0003")))
+
+	e := gin.New()
+	e.GET("/readyz", m.ReadyHandler())
+	ready := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, ready())
+
+	err := m.Run(context.Background())
+	assert.EqualError(t, err, "migrate: applying 0002: syntax error")
+	assert.Equal(t, StateFailed, m.Status().State)
+	assert.Equal(t, http.StatusServiceUnavailable, ready())
+
+	fail = false
+	assert.NoError(t, m.Run(context.Background()))
+	assert.Equal(t, []string{"0001", "0002", "0003"}, ran)
+	assert.Equal(t, []string{"0002", "0003"}, m.Status().Applied)
+	assert.True(t, m.Ready())
+	assert.Equal(t, http.StatusOK, ready())
+
+	// Another instance sharing the store has nothing to do
+	other := New(Steps(store, step("0001"), step("0002"), step("0003")))
+	assert.NoError(t, other.Run(context.Background()))
+	assert.Len(t, ran, 3)
+
+	assert.Panics(t, func() { Steps(store, step("0001"), step("0001")) })
+}
+
+func TestLock(t *testing.T) {
+	store := NewMemoryStore()
+	unlock, err := store.Lock(context.Background())
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	applied := 0
+	m := New(Steps(store, Step{ID: "0001", Up: func(context.Context) error {
+		mu.Lock()
+		applied++
+		mu.Unlock()
+		return nil
+	}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Run(ctx), context.DeadlineExceeded)
+
+	unlock(context.Background())
+	assert.NoError(t, m.Run(context.Background()))
+	assert.Equal(t, 1, applied)
+}
+
+func TestConcurrentRun(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	m := New(Steps(store, Step{ID: "0001", Up: func(context.Context) error {
+		<-release
+		return errors.New("syntax error")
+	}}))
+
+	first := make(chan error)
+	go func() { first <- m.Run(context.Background()) }()
+	assert.Eventually(t, func() bool { return m.Status().State == StateRunning }, time.Second, time.Millisecond)
+
+	// A concurrent call waits for the run in progress and returns its error
+	second := make(chan error)
+	go func() { second <- m.Run(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	assert.Error(t, <-first)
+	assert.ErrorContains(t, <-second, "syntax error")
+}
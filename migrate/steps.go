@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Step is a migration step, applied once in ID order
+type Step struct {
+	ID string // Sortable identifier, e.g. 0001_create_users
+	Up func(ctx context.Context) error
+}
+
+// Store records applied steps, e.g. in a schema_migrations table. Stores implementing Locker are locked while
+// steps are applied.
+type Store interface {
+	Applied(ctx context.Context) ([]string, error)
+	Record(ctx context.Context, id string) error
+}
+
+// Locker is implemented by runners or stores supporting an advisory lock, so only one instance migrates at a time,
+// e.g. with pg_advisory_lock. Lock blocks until the lock is held or the context is done.
+type Locker interface {
+	Lock(ctx context.Context) (unlock func(ctx context.Context) error, err error)
+}
+
+type steps struct {
+	store Store
+	steps []Step
+}
+
+// Steps returns a runner applying the steps not yet recorded in the store. Panics if two steps share an ID.
+func Steps(store Store, s ...Step) Runner {
+	s = append([]Step{}, s...)
+	sort.Slice(s, func(i, j int) bool { return s[i].ID < s[j].ID })
+	for i := 1; i < len(s); i++ {
+		if s[i].ID == s[i-1].ID {
+			panic("migrate: duplicate step " + s[i].ID)
+		}
+	}
+	return &steps{store: store, steps: s}
+}
+
+// Pending implements Runner
+func (s *steps) Pending(ctx context.Context) ([]string, error) {
+	applied, err := s.store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for _, id := range applied {
+		done[id] = true
+	}
+	var res []string
+	for _, st := range s.steps {
+		if !done[st.ID] {
+			res = append(res, st.ID)
+		}
+	}
+	return res, nil
+}
+
+// Apply implements Runner
+func (s *steps) Apply(ctx context.Context, id string) error {
+	for _, st := range s.steps {
+		if st.ID == id {
+			if err := st.Up(ctx); err != nil {
+				return err
+			}
+			return s.store.Record(ctx, id)
+		}
+	}
+	return fmt.Errorf("migrate: unknown step %s", id)
+}
+
+// Lock implements Locker if the store does
+func (s *steps) Lock(ctx context.Context) (func(context.Context) error, error) {
+	if l, ok := s.store.(Locker); ok {
+		return l.Lock(ctx)
+	}
+	return func(context.Context) error { return nil }, nil
+}
+
+// MemoryStore is an in-memory Store and Locker for tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	applied []string
+	lock    chan struct{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lock: make(chan struct{}, 1)}
+}
+
+// Applied implements Store
+func (s *MemoryStore) Applied(context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.applied...), nil
+}
+
+// Record implements Store
+func (s *MemoryStore) Record(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied = append(s.applied, id)
+	return nil
+}
+
+// Lock implements Locker
+func (s *MemoryStore) Lock(ctx context.Context) (func(context.Context) error, error) {
+	select {
+	case s.lock <- struct{}{}:
+		return func(context.Context) error {
+			<-s.lock
+			return nil
+		}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,203 @@
+// Feature modules
+//
+// Structures an application as a set of modules, each owning its routes, middleware, health check and shutdown,
+// mounted by an assembler under isolated path prefixes.
+//
+//	a := modulemount.New(e, modulemount.WithConfig(cfg.Modules))
+//	if err := a.Mount("/billing", billing.Module{}); err != nil {
+//		...
+//	}
+//	e.GET("/healthz", a.HealthHandler())
+//	...
+//	a.Shutdown(ctx)
+//
+// Requests handled by a module have a module field added to the request logger. Embed Base in a module to
+// default the optional methods.
+package modulemount
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Module is a self-contained feature of the application
+type Module interface {
+	Name() string
+	Routes(r gin.IRouter)               // Registers routes relative to the module prefix
+	Middleware() []gin.HandlerFunc      // Applied to all module routes
+	Health(ctx context.Context) error   // Returns an error if the module is unhealthy
+	Shutdown(ctx context.Context) error // Releases resources, called in reverse mount order
+}
+
+// Configurable modules receive their config section before mounting
+type Configurable interface {
+	Configure(raw json.RawMessage) error
+}
+
+// Base provides default no-op implementations of the optional Module methods
+type Base struct{}
+
+// Middleware returns no middleware
+func (Base) Middleware() []gin.HandlerFunc { return nil }
+
+// Health always reports healthy
+func (Base) Health(context.Context) error { return nil }
+
+// Shutdown does nothing
+func (Base) Shutdown(context.Context) error { return nil }
+
+type assemblerOpts struct {
+	config map[string]json.RawMessage // Config sections by module name
+}
+
+// Modifier function for customising assembler behaviour
+type AssemblerOpts func(*assemblerOpts) *assemblerOpts
+
+// WithConfig sets the config sections passed to Configurable modules, by module name
+func WithConfig(config map[string]json.RawMessage) AssemblerOpts {
+	return func(ao *assemblerOpts) *assemblerOpts {
+		ao.config = config
+		return ao
+	}
+}
+
+// Assembler mounts modules onto a router
+type Assembler struct {
+	r    gin.IRouter
+	opts *assemblerOpts
+
+	mu      sync.Mutex
+	mounted []mounted
+}
+
+type mounted struct {
+	prefix string
+	module Module
+}
+
+// New creates an assembler mounting modules onto the router
+func New(r gin.IRouter, opts ...AssemblerOpts) *Assembler {
+	ao := &assemblerOpts{}
+	for _, f := range opts {
+		ao = f(ao)
+	}
+	return &Assembler{r: r, opts: ao}
+}
+
+// Mount configures the module and registers its routes under the prefix. Prefixes must not overlap with those of
+// other modules, and module names must be unique.
+func (a *Assembler) Mount(prefix string, m Module) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	for _, mm := range a.mounted {
+		if mm.module.Name() == m.Name() {
+			return fmt.Errorf("modulemount: module %s already mounted", m.Name())
+		}
+		if overlaps(mm.prefix, prefix) {
+			return fmt.Errorf("modulemount: prefix %s of %s overlaps %s of %s", prefix, m.Name(), mm.prefix, mm.module.Name())
+		}
+	}
+
+	if c, ok := m.(Configurable); ok {
+		if err := c.Configure(a.opts.config[m.Name()]); err != nil {
+			return fmt.Errorf("modulemount: configuring %s: %w", m.Name(), err)
+		}
+	}
+
+	name := m.Name()
+	g := a.r.Group(prefix, func(ctx *gin.Context) {
+		zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", name)
+		})
+	})
+	g.Use(m.Middleware()...)
+	m.Routes(g)
+
+	a.mounted = append(a.mounted, mounted{prefix, m})
+	log.Debug().Str("module", name).Str("prefix", prefix).Msg("Mounted module")
+	return nil
+}
+
+// Modules returns the names of the mounted modules in mount order
+func (a *Assembler) Modules() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	res := make([]string, 0, len(a.mounted))
+	for _, mm := range a.mounted {
+		res = append(res, mm.module.Name())
+	}
+	return res
+}
+
+// Health checks all modules, returning the errors of unhealthy modules by name
+func (a *Assembler) Health(ctx context.Context) map[string]error {
+	a.mu.Lock()
+	mods := append([]mounted{}, a.mounted...)
+	a.mu.Unlock()
+
+	res := map[string]error{}
+	for _, mm := range mods {
+		if err := mm.module.Health(ctx); err != nil {
+			res[mm.module.Name()] = err
+		}
+	}
+	return res
+}
+
+// HealthHandler responds 200 if all modules are healthy, otherwise 503 with the status of each module
+func (a *Assembler) HealthHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		failed := a.Health(ctx)
+		status := map[string]string{}
+		for _, name := range a.Modules() {
+			status[name] = "ok"
+			if err, ok := failed[name]; ok {
+				status[name] = "unhealthy"
+				zlog.GetLogger(ctx).Warn().Err(err).Str("module", name).Msg("Module unhealthy")
+			}
+		}
+		code := http.StatusOK
+		if len(failed) > 0 {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.JSON(code, gin.H{"modules": status})
+	}
+}
+
+// Shutdown shuts down all modules in reverse mount order, returning the first error
+func (a *Assembler) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	mods := append([]mounted{}, a.mounted...)
+	a.mu.Unlock()
+
+	var first error
+	for i := len(mods) - 1; i >= 0; i-- {
+		name := mods[i].module.Name()
+		if err := mods[i].module.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Str("module", name).Msg("Module shutdown failed")
+			if first == nil {
+				first = fmt.Errorf("modulemount: shutting down %s: %w", name, err)
+			}
+		}
+	}
+	return first
+}
+
+// Prefixes overlap if one is a path prefix of the other
+func overlaps(a, b string) bool {
+	if a == "/" || b == "/" || a == b {
+		return true
+	}
+	return strings.HasPrefix(a+"/", b+"/") || strings.HasPrefix(b+"/", a+"/")
+}
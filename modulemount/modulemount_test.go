@@ -0,0 +1,89 @@
+package modulemount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type testModule struct {
+	Base
+	name     string
+	greeting string
+	healthy  error
+	shutdown *[]string
+}
+
+func (m *testModule) Name() string { return m.name }
+
+func (m *testModule) Routes(r gin.IRouter) {
+	r.GET("/hello", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, m.greeting)
+	})
+}
+
+func (m *testModule) Configure(raw json.RawMessage) error {
+	if raw == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, &m.greeting)
+}
+
+func (m *testModule) Health(context.Context) error { return m.healthy }
+
+func (m *testModule) Shutdown(context.Context) error {
+	*m.shutdown = append(*m.shutdown, m.name)
+	return nil
+}
+
+func get(e *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestMount(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	var order []string
+
+	a := New(e, WithConfig(map[string]json.RawMessage{"billing": json.RawMessage(`"hi billing"`)}))
+	assert.NoError(t, a.Mount("/billing", &testModule{name: "billing", shutdown: &order}))
+	assert.NoError(t, a.Mount("users/", &testModule{name: "users", greeting: "hi users", shutdown: &order}))
+
+	assert.Error(t, a.Mount("/billing/v2", &testModule{name: "other", shutdown: &order}))
+	assert.Error(t, a.Mount("/other", &testModule{name: "users", shutdown: &order}))
+
+	assert.Equal(t, "hi billing", get(e, "/billing/hello").Body.String())
+	assert.Equal(t, "hi users", get(e, "/users/hello").Body.String())
+	assert.Equal(t, []string{"billing", "users"}, a.Modules())
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.Equal(t, []string{"users", "billing"}, order)
+}
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	var order []string
+
+	a := New(e)
+	_ = a.Mount("/a", &testModule{name: "a", shutdown: &order})
+	b := &testModule{name: "b", shutdown: &order}
+	_ = a.Mount("/b", b)
+	e.GET("/healthz", a.HealthHandler())
+
+	assert.Equal(t, 200, get(e, "/healthz").Code)
+
+	b.healthy = errors.New("db down")
+	w := get(e, "/healthz")
+	assert.Equal(t, 503, w.Code)
+	assert.JSONEq(t, `{"modules":{"a":"ok","b":"unhealthy"}}`, w.Body.String())
+}
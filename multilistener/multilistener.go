@@ -0,0 +1,186 @@
+// Multiple listeners
+//
+// Serve several listeners from one process, each with its own handler and TLS settings, under a single
+// lifecycle. Typically used to separate the public API from admin and metrics endpoints:
+//
+//	g := multilistener.New(multilistener.WithShutdownTimeout(10 * time.Second))
+//	g.Add(multilistener.Listener{Name: "api", Addr: ":8443", Handler: api, TLSConfig: tlsConfig})
+//	g.Add(multilistener.Listener{Name: "admin", Addr: "127.0.0.1:9000", Handler: admin})
+//	err := g.Run(ctx)
+//
+// Run returns when the context is cancelled or any listener fails, after gracefully shutting down all of them.
+package multilistener
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Listener describes a single server of the group
+type Listener struct {
+	Name      string       // Name used in logs and errors
+	Addr      string       // TCP address to listen on, ignored if Listener is set
+	Handler   http.Handler // Handler, usually a gin engine
+	TLSConfig *tls.Config  // Serve TLS if set
+	Listener  net.Listener // Pre-created listener, e.g. from unixsock
+}
+
+type groupOpts struct {
+	shutdownTimeout   time.Duration
+	readHeaderTimeout time.Duration
+}
+
+// Modifier function for customising listener group behaviour
+type GroupOpts func(*groupOpts) *groupOpts
+
+// Group runs a set of listeners with a shared lifecycle
+type Group struct {
+	opts      *groupOpts
+	listeners []Listener
+	ready     chan struct{}
+
+	mu    sync.Mutex
+	addrs map[string]net.Addr
+}
+
+// New creates an empty listener group
+func New(opts ...GroupOpts) *Group {
+	gro := &groupOpts{
+		shutdownTimeout:   30 * time.Second,
+		readHeaderTimeout: 10 * time.Second,
+	}
+	for _, f := range opts {
+		gro = f(gro)
+	}
+	return &Group{
+		opts:  gro,
+		ready: make(chan struct{}),
+		addrs: map[string]net.Addr{},
+	}
+}
+
+// Add adds a listener to the group. Listeners must be added before Run.
+func (g *Group) Add(l Listener) *Group {
+	g.listeners = append(g.listeners, l)
+	return g
+}
+
+// Ready returns a channel closed once all listeners are accepting connections
+func (g *Group) Ready() <-chan struct{} {
+	return g.ready
+}
+
+// Addr returns the bound address of the named listener once ready, e.g. to discover an ephemeral port
+func (g *Group) Addr(name string) net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.addrs[name]
+}
+
+// Run binds all listeners and serves until the context is cancelled or a listener fails, then shuts down all
+// servers gracefully. If any listener fails to bind, those already bound are closed and the error returned.
+// Run must only be called once.
+func (g *Group) Run(ctx context.Context) error {
+	logger := zlog.GetLogger(ctx)
+
+	// Bind everything first so a failure doesn't leave a partially started group
+	listeners := make([]net.Listener, len(g.listeners))
+	for i, l := range g.listeners {
+		nl := l.Listener
+		if nl == nil {
+			var err error
+			nl, err = net.Listen("tcp", l.Addr)
+			if err != nil {
+				for _, bound := range listeners[:i] {
+					bound.Close()
+				}
+				return fmt.Errorf("multilistener: %s: %w", l.Name, err)
+			}
+		}
+		if l.TLSConfig != nil {
+			nl = tls.NewListener(nl, l.TLSConfig)
+		}
+		listeners[i] = nl
+
+		g.mu.Lock()
+		g.addrs[l.Name] = nl.Addr()
+		g.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	servers := make([]*http.Server, len(g.listeners))
+	errs := make(chan error, len(g.listeners))
+	var wg sync.WaitGroup
+	for i, l := range g.listeners {
+		srv := &http.Server{
+			Handler:           l.Handler,
+			TLSConfig:         l.TLSConfig,
+			ReadHeaderTimeout: g.opts.readHeaderTimeout,
+		}
+		servers[i] = srv
+
+		wg.Add(1)
+		go func(l Listener, nl net.Listener) {
+			defer wg.Done()
+			logger.Info().Str("listener", l.Name).Str("addr", nl.Addr().String()).Bool("tls", l.TLSConfig != nil).Msg("Listening")
+			if err := srv.Serve(nl); !errors.Is(err, http.ErrServerClosed) {
+				logger.Error().Err(err).Str("listener", l.Name).Msg("Listener failed")
+				errs <- fmt.Errorf("multilistener: %s: %w", l.Name, err)
+				cancel()
+			}
+		}(l, listeners[i])
+	}
+	close(g.ready)
+
+	<-ctx.Done()
+
+	// Shut down all servers in parallel, sharing the timeout
+	shutdown, done := context.WithTimeout(context.Background(), g.opts.shutdownTimeout)
+	defer done()
+	var sg sync.WaitGroup
+	for i, srv := range servers {
+		sg.Add(1)
+		go func(name string, srv *http.Server) {
+			defer sg.Done()
+			if err := srv.Shutdown(shutdown); err != nil {
+				logger.Warn().Err(err).Str("listener", name).Msg("Forced close after shutdown timeout")
+				srv.Close()
+			}
+		}(g.listeners[i].Name, srv)
+	}
+	sg.Wait()
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// WithShutdownTimeout sets how long in-flight requests are given to complete on shutdown, defaults to 30s
+func WithShutdownTimeout(d time.Duration) GroupOpts {
+	return func(gro *groupOpts) *groupOpts {
+		gro.shutdownTimeout = d
+		return gro
+	}
+}
+
+// WithReadHeaderTimeout sets the request header read timeout of all servers, defaults to 10s
+func WithReadHeaderTimeout(d time.Duration) GroupOpts {
+	return func(gro *groupOpts) *groupOpts {
+		gro.readHeaderTimeout = d
+		return gro
+	}
+}
@@ -0,0 +1,86 @@
+package multilistener
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func engine(body string) *gin.Engine {
+	e := gin.New()
+	e.GET("", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, body)
+	})
+	return e
+}
+
+func get(t *testing.T, addr net.Addr) string {
+	res, err := http.Get("http://" + addr.String())
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	return string(b)
+}
+
+func TestRun(t *testing.T) {
+	g := New(WithShutdownTimeout(time.Second)).
+		Add(Listener{Name: "api", Addr: "127.0.0.1:0", Handler: engine("api")}).
+		Add(Listener{Name: "admin", Addr: "127.0.0.1:0", Handler: engine("admin")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- g.Run(ctx) }()
+	<-g.Ready()
+
+	assert.Equal(t, "api", get(t, g.Addr("api")))
+	assert.Equal(t, "admin", get(t, g.Addr("admin")))
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, err := net.Dial("tcp", g.Addr("api").String())
+	assert.Error(t, err)
+}
+
+func TestRunBindFailure(t *testing.T) {
+	taken, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer taken.Close()
+
+	pre, _ := net.Listen("tcp", "127.0.0.1:0")
+	g := New().
+		Add(Listener{Name: "first", Listener: pre, Handler: engine("first")}).
+		Add(Listener{Name: "second", Addr: taken.Addr().String(), Handler: engine("second")})
+
+	err := g.Run(context.Background())
+
+	assert.ErrorContains(t, err, "multilistener: second")
+	_, err = net.Dial("tcp", pre.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestRunListenerFailure(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	g := New().
+		Add(Listener{Name: "api", Addr: "127.0.0.1:0", Handler: engine("api")}).
+		Add(Listener{Name: "closed", Listener: l, Handler: engine("closed")})
+
+	done := make(chan error)
+	go func() { done <- g.Run(context.Background()) }()
+	<-g.Ready()
+	l.Close()
+
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "multilistener: closed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("group did not stop after listener failure")
+	}
+}
@@ -0,0 +1,316 @@
+// Password hashing and policy
+//
+// Argon2id and bcrypt password hashing with parameters encoded in the hash, so they can be strengthened
+// over time without invalidating stored hashes. Verify reports when a hash was produced with outdated
+// parameters and returns a replacement to store, allowing transparent upgrades on login.
+//
+// A configurable password policy is also provided, which can be registered as a custom validation rule
+// for use in binding tags:
+//
+//	passwordx.RegisterValidation("password", passwordx.DefaultPolicy)
+//
+//	type Signup struct {
+//		Password string `json:"password" binding:"required,password"`
+//	}
+package passwordx
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin/binding"
+	v "github.com/go-playground/validator/v10"
+	"github.com/redmapletech/ginx/fips"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	ErrMismatch      = fmt.Errorf("passwordx: password does not match")
+	ErrUnknownFormat = fmt.Errorf("passwordx: unknown hash format")
+)
+
+// Params are the hashing parameters, recorded in each hash produced
+type Params struct {
+	Algorithm string
+
+	// Argon2id
+	Memory  uint32 // KiB
+	Time    uint32 // Iterations
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+
+	// Bcrypt
+	Cost int
+}
+
+// DefaultParams follow the OWASP recommendations for argon2id
+var DefaultParams = Params{
+	Algorithm: Argon2id,
+	Memory:    64 * 1024,
+	Time:      3,
+	Threads:   2,
+	SaltLen:   16,
+	KeyLen:    32,
+}
+
+// Hasher hashes and verifies passwords with a fixed set of current parameters
+type Hasher struct {
+	params Params
+}
+
+// NewHasher creates a hasher producing hashes with the given parameters. Panics in FIPS mode, as neither algorithm
+// is approved.
+func NewHasher(p Params) *Hasher {
+	fips.Require("passwordx", p.Algorithm)
+	return &Hasher{params: p}
+}
+
+// Not created with NewHasher, so importing the package doesn't panic in FIPS mode
+var defaultHasher = &Hasher{params: DefaultParams}
+
+// Hash hashes a password using the default parameters
+func Hash(password string) (string, error) {
+	return defaultHasher.Hash(password)
+}
+
+// Verify verifies a password against a hash using the default parameters, see Hasher.Verify
+func Verify(password, encoded string) (string, error) {
+	return defaultHasher.Verify(password, encoded)
+}
+
+// Hash hashes a password with the current parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	if err := fips.Check("passwordx", h.params.Algorithm); err != nil {
+		return "", err
+	}
+	switch h.params.Algorithm {
+	case Argon2id:
+		salt := make([]byte, h.params.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+		enc := base64.RawStdEncoding
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.params.Memory, h.params.Time, h.params.Threads,
+			enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+	case Bcrypt:
+		res, err := bcrypt.GenerateFromPassword([]byte(password), h.params.Cost)
+		return string(res), err
+	}
+	return "", fmt.Errorf("passwordx: unsupported algorithm %q", h.params.Algorithm)
+}
+
+// Verify checks a password against an encoded hash of either algorithm.
+// Returns ErrMismatch if the password is incorrect. On success, if the hash was produced with
+// parameters other than the current ones, a replacement hash is returned which should be stored.
+func (h *Hasher) Verify(password, encoded string) (string, error) {
+	p, err := Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	if err := fips.Check("passwordx", p.Algorithm); err != nil {
+		return "", err
+	}
+
+	switch p.Algorithm {
+	case Argon2id:
+		salt, key, err := argon2Parts(encoded)
+		if err != nil {
+			return "", err
+		}
+		cmp := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, cmp) != 1 {
+			return "", ErrMismatch
+		}
+	case Bcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return "", ErrMismatch
+			}
+			return "", err
+		}
+	}
+
+	if h.NeedsRehash(encoded) {
+		return h.Hash(password)
+	}
+	return "", nil
+}
+
+// NeedsRehash returns whether the hash was produced with parameters other than the current ones
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	p, err := Decode(encoded)
+	if err != nil {
+		return true
+	}
+	if p.Algorithm != h.params.Algorithm {
+		return true
+	}
+	switch p.Algorithm {
+	case Argon2id:
+		return p.Memory != h.params.Memory || p.Time != h.params.Time || p.Threads != h.params.Threads ||
+			p.SaltLen != h.params.SaltLen || p.KeyLen != h.params.KeyLen
+	case Bcrypt:
+		return p.Cost != h.params.Cost
+	}
+	return true
+}
+
+// Decode extracts the parameters recorded in an encoded hash
+func Decode(encoded string) (Params, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 6 {
+			return Params{}, ErrUnknownFormat
+		}
+		var version int
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return Params{}, ErrUnknownFormat
+		}
+		p := Params{Algorithm: Argon2id}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+			return Params{}, ErrUnknownFormat
+		}
+		salt, key, err := argon2Parts(encoded)
+		if err != nil {
+			return Params{}, err
+		}
+		p.SaltLen = uint32(len(salt))
+		p.KeyLen = uint32(len(key))
+		return p, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return Params{}, ErrUnknownFormat
+		}
+		return Params{Algorithm: Bcrypt, Cost: cost}, nil
+	}
+	return Params{}, ErrUnknownFormat
+}
+
+func argon2Parts(encoded string) ([]byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, ErrUnknownFormat
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, ErrUnknownFormat
+	}
+	key, err := enc.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, ErrUnknownFormat
+	}
+	return salt, key, nil
+}
+
+// Policy describes password requirements
+type Policy struct {
+	MinLength  int             // Minimum length in characters
+	MaxLength  int             // Maximum length in characters, zero for no limit
+	MinClasses int             // Minimum number of character classes (upper, lower, digit, symbol)
+	Upper      bool            // Require an upper case letter
+	Lower      bool            // Require a lower case letter
+	Digit      bool            // Require a digit
+	Symbol     bool            // Require a symbol or punctuation character
+	Denylist   map[string]bool // Rejected passwords, compared case insensitively
+}
+
+// DefaultPolicy follows NIST 800-63B guidance of favouring length over composition rules
+var DefaultPolicy = Policy{
+	MinLength: 12,
+	MaxLength: 128,
+}
+
+// PolicyError lists the policy rules a password failed
+type PolicyError struct {
+	Rules []string
+}
+
+func (e PolicyError) Error() string {
+	return "passwordx: password does not meet policy: " + strings.Join(e.Rules, ", ")
+}
+
+// Validate checks the password against the policy, returning a PolicyError if any rules fail
+func (p Policy) Validate(password string) error {
+	var failed []string
+
+	n := utf8.RuneCountInString(password)
+	if n < p.MinLength {
+		failed = append(failed, "min_length")
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		failed = append(failed, "max_length")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, c := range []struct {
+		rule     string
+		required bool
+		present  bool
+	}{
+		{"upper", p.Upper, upper},
+		{"lower", p.Lower, lower},
+		{"digit", p.Digit, digit},
+		{"symbol", p.Symbol, symbol},
+	} {
+		if c.present {
+			classes++
+		} else if c.required {
+			failed = append(failed, c.rule)
+		}
+	}
+	if classes < p.MinClasses {
+		failed = append(failed, "min_classes")
+	}
+
+	if p.Denylist[strings.ToLower(password)] {
+		failed = append(failed, "denylisted")
+	}
+
+	if len(failed) > 0 {
+		return PolicyError{Rules: failed}
+	}
+	return nil
+}
+
+// RegisterValidation registers the policy as a custom rule with gin's default validator
+func RegisterValidation(tag string, p Policy) error {
+	engine, ok := binding.Validator.Engine().(*v.Validate)
+	if !ok {
+		return fmt.Errorf("passwordx: unsupported validator engine %T", binding.Validator.Engine())
+	}
+	return engine.RegisterValidation(tag, func(fl v.FieldLevel) bool {
+		return p.Validate(fl.Field().String()) == nil
+	})
+}
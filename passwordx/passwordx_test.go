@@ -0,0 +1,94 @@
+package passwordx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/stretchr/testify/assert"
+)
+
+var testParams = Params{
+	Algorithm: Argon2id,
+	Memory:    1024,
+	Time:      1,
+	Threads:   1,
+	SaltLen:   16,
+	KeyLen:    32,
+}
+
+func TestHashVerify(t *testing.T) {
+	h := NewHasher(testParams)
+
+	encoded, err := h.Hash("correct horse")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	rehash, err := h.Verify("correct horse", encoded)
+	assert.NoError(t, err)
+	assert.Empty(t, rehash)
+
+	_, err = h.Verify("wrong", encoded)
+	assert.ErrorIs(t, err, ErrMismatch)
+}
+
+func TestRehashOnVerify(t *testing.T) {
+	old := NewHasher(Params{Algorithm: Bcrypt, Cost: 4})
+	encoded, _ := old.Hash("correct horse")
+
+	h := NewHasher(testParams)
+	assert.True(t, h.NeedsRehash(encoded))
+
+	rehash, err := h.Verify("correct horse", encoded)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rehash, "$argon2id$"))
+	assert.False(t, h.NeedsRehash(rehash))
+
+	// Stronger argon2 parameters also trigger a rehash
+	stronger := testParams
+	stronger.Time = 2
+	rehash, err = NewHasher(stronger).Verify("correct horse", rehash)
+	assert.NoError(t, err)
+	assert.Contains(t, rehash, "t=2")
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	_, err := Decode("plaintext")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{
+		MinLength: 8,
+		Upper:     true,
+		Digit:     true,
+		Denylist:  map[string]bool{"password1a": true},
+	}
+
+	assert.NoError(t, p.Validate("Abcdefg1"))
+	assert.Equal(t, PolicyError{Rules: []string{"min_length", "upper", "digit"}}, p.Validate("abc"))
+	assert.Equal(t, PolicyError{Rules: []string{"upper", "denylisted"}}, p.Validate("password1a"))
+}
+
+func TestRegisterValidation(t *testing.T) {
+	type signup struct {
+		Password string `json:"password" binding:"required,testpassword"`
+	}
+	assert.NoError(t, RegisterValidation("testpassword", Policy{MinLength: 10}))
+
+	e := gin.New()
+	e.POST("", bind.To(signup{}, bind.WithDetail(true)), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"password":"short"}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Result().StatusCode)
+	assert.Equal(t, `{"code":"validation_error","errors":[{"field":"Password","path":"password","rule":"testpassword"}]}`, w.Body.String())
+}
@@ -0,0 +1,210 @@
+// Middleware policy audit
+//
+// Inspects the assembled engine at startup and reports routes missing required middleware, such as authentication
+// or rate limiting, against a declared policy. Middleware is identified by the package or function name of the
+// handlers in each route chain, so group and engine level middleware is included.
+//
+//	err := policyaudit.Enforce(e, true,
+//		policyaudit.Rule{Name: "auth", Middleware: []string{"github.com/redmapletech/ginx/auth."}, Exempt: []string{"/healthz"}},
+//		policyaudit.Rule{Name: "ratelimit", Middleware: []string{"github.com/redmapletech/ginx/ratelimit."}},
+//	)
+//	if err != nil {
+//		log.Fatal().Err(err).Msg("Route policy violated")
+//	}
+//
+// Gin doesn't expose the handler chains of registered routes, so they are read from the engine by reflection.
+// Route tables built elsewhere, such as by routerdsl, can be checked directly with CheckRoutes, naming their handlers
+// with HandlerName.
+package policyaudit
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Rule requires middleware on a set of routes
+type Rule struct {
+	Name       string   // Rule name used in reports, e.g. "auth"
+	Middleware []string // Handler function name prefixes, any of which satisfies the rule
+	Paths      []string // Route patterns or path.Match globs the rule applies to, all routes if empty
+	Methods    []string // Methods the rule applies to, all if empty
+	Exempt     []string // Route patterns or globs excluded from the rule
+}
+
+// Route is a registered route and the names of its handler chain, in order
+type Route struct {
+	Method   string
+	Path     string
+	Handlers []string
+}
+
+// Violation is a route missing the middleware required by a rule
+type Violation struct {
+	Method string
+	Path   string
+	Rule   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s: missing %s", v.Method, v.Path, v.Rule)
+}
+
+// Routes returns all routes registered on the engine with their handler chains, sorted by path then method
+func Routes(e *gin.Engine) ([]Route, error) {
+	trees := reflect.ValueOf(e).Elem().FieldByName("trees")
+	if !trees.IsValid() || trees.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("policyaudit: unsupported gin engine layout")
+	}
+
+	var res []Route
+	for i := 0; i < trees.Len(); i++ {
+		t := trees.Index(i)
+		method := t.FieldByName("method")
+		root := t.FieldByName("root")
+		if !method.IsValid() || !root.IsValid() {
+			return nil, fmt.Errorf("policyaudit: unsupported gin engine layout")
+		}
+		if err := walk(root, method.String(), &res); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res, nil
+}
+
+func walk(n reflect.Value, method string, res *[]Route) error {
+	if n.Kind() != reflect.Pointer || n.IsNil() {
+		return nil
+	}
+	n = n.Elem()
+	handlers, fullPath, children := n.FieldByName("handlers"), n.FieldByName("fullPath"), n.FieldByName("children")
+	if !handlers.IsValid() || !fullPath.IsValid() || !children.IsValid() {
+		return fmt.Errorf("policyaudit: unsupported gin engine layout")
+	}
+
+	if handlers.Len() > 0 {
+		r := Route{Method: method, Path: fullPath.String()}
+		for i := 0; i < handlers.Len(); i++ {
+			r.Handlers = append(r.Handlers, funcName(handlers.Index(i)))
+		}
+		*res = append(*res, r)
+	}
+	for i := 0; i < children.Len(); i++ {
+		if err := walk(children.Index(i), method, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandlerName returns the function name of a handler, as matched against Rule.Middleware
+func HandlerName(h gin.HandlerFunc) string {
+	return funcName(reflect.ValueOf(h))
+}
+
+func funcName(v reflect.Value) string {
+	if v.IsNil() {
+		return ""
+	}
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+// Check returns the violations of the rules by the routes registered on the engine
+func Check(e *gin.Engine, rules ...Rule) ([]Violation, error) {
+	routes, err := Routes(e)
+	if err != nil {
+		return nil, err
+	}
+	return CheckRoutes(routes, rules...), nil
+}
+
+// CheckRoutes returns the violations of the rules by the routes, e.g. from a declarative route table
+func CheckRoutes(routes []Route, rules ...Rule) []Violation {
+	var res []Violation
+	for _, r := range routes {
+		for _, rule := range rules {
+			if rule.applies(r) && !rule.satisfied(r) {
+				res = append(res, Violation{Method: r.Method, Path: r.Path, Rule: rule.Name})
+			}
+		}
+	}
+	return res
+}
+
+// Enforce checks the engine against the rules, logging each violation. In strict mode an error is returned if there
+// are any violations, so startup can fail fast.
+func Enforce(e *gin.Engine, strict bool, rules ...Rule) error {
+	violations, err := Check(e, rules...)
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		log.Warn().Str("method", v.Method).Str("path", v.Path).Str("rule", v.Rule).Msg("Route missing required middleware")
+	}
+	if strict && len(violations) > 0 {
+		return fmt.Errorf("policyaudit: %d route policy violations, first: %s", len(violations), violations[0])
+	}
+	return nil
+}
+
+func (rule Rule) applies(r Route) bool {
+	if len(rule.Methods) > 0 && !contains(rule.Methods, r.Method) {
+		return false
+	}
+	if len(rule.Paths) > 0 && !matchAny(rule.Paths, r.Path) {
+		return false
+	}
+	return !matchAny(rule.Exempt, r.Path)
+}
+
+// Satisfied by any handler other than the final route handler
+func (rule Rule) satisfied(r Route) bool {
+	if len(r.Handlers) == 0 {
+		return false
+	}
+	for _, h := range r.Handlers[:len(r.Handlers)-1] {
+		for _, m := range rule.Middleware {
+			if strings.HasPrefix(h, m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pat := range patterns {
+		if pat == p {
+			return true
+		}
+		if ok, _ := path.Match(pat, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
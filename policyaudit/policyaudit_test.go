@@ -0,0 +1,76 @@
+package policyaudit
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func requireAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {}
+}
+
+func rateLimit(ctx *gin.Context) {}
+
+func handler(ctx *gin.Context) {}
+
+const pkg = "github.com/redmapletech/ginx/policyaudit."
+
+func engine() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(rateLimit)
+	e.GET("/healthz", handler)
+	e.POST("/login", handler)
+
+	api := e.Group("/api", requireAuth())
+	api.GET("/users/:id", handler)
+	api.DELETE("/users/:id", handler)
+	return e
+}
+
+func TestRoutes(t *testing.T) {
+	routes, err := Routes(engine())
+	assert.NoError(t, err)
+
+	assert.Len(t, routes, 4)
+	assert.Equal(t, "DELETE", routes[0].Method)
+	assert.Equal(t, "/api/users/:id", routes[0].Path)
+	assert.Equal(t, []string{pkg + "rateLimit", pkg + "requireAuth.func1", pkg + "handler"}, routes[0].Handlers)
+}
+
+func TestCheck(t *testing.T) {
+	e := engine()
+
+	v, err := Check(e,
+		Rule{Name: "auth", Middleware: []string{pkg + "requireAuth"}, Exempt: []string{"/healthz"}},
+		Rule{Name: "ratelimit", Middleware: []string{pkg + "rateLimit"}},
+		Rule{Name: "audit", Middleware: []string{"example.com/audit."}, Paths: []string{"/api/*/*"}, Methods: []string{"DELETE"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []Violation{
+		{Method: "DELETE", Path: "/api/users/:id", Rule: "audit"},
+		{Method: "POST", Path: "/login", Rule: "auth"},
+	}, v)
+	assert.Equal(t, "POST /login: missing auth", v[1].String())
+
+	assert.NoError(t, Enforce(e, false, Rule{Name: "auth", Middleware: []string{pkg + "requireAuth"}}))
+	assert.Error(t, Enforce(e, true, Rule{Name: "auth", Middleware: []string{pkg + "requireAuth"}}))
+}
+
+func TestCheckRoutes(t *testing.T) {
+	assert.Equal(t, pkg+"rateLimit", HandlerName(rateLimit))
+	assert.Equal(t, pkg+"requireAuth.func1", HandlerName(requireAuth()))
+	assert.Empty(t, HandlerName(nil))
+
+	routes := []Route{
+		{Method: "GET", Path: "/users", Handlers: []string{HandlerName(requireAuth()), HandlerName(handler)}},
+		{Method: "GET", Path: "/public", Handlers: []string{HandlerName(handler)}},
+		{Method: "GET", Path: "/empty"},
+	}
+	assert.Equal(t, []Violation{
+		{Method: "GET", Path: "/public", Rule: "auth"},
+		{Method: "GET", Path: "/empty", Rule: "auth"},
+	}, CheckRoutes(routes, Rule{Name: "auth", Middleware: []string{pkg + "requireAuth"}}))
+}
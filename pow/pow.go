@@ -0,0 +1,314 @@
+// Proof-of-work challenges
+//
+// Middleware requiring clients to solve a hashcash style challenge before abuse-prone unauthenticated
+// endpoints (signup, password reset, etc.) are processed.
+//
+// Challenges are HMAC signed and carry their own difficulty and expiry, so they can be issued and checked by any
+// instance sharing the secret. Solved challenges are recorded in a Store until they expire, so each is accepted
+// once; share the store between instances to prevent replays across them. A solution is any string s such that
+// SHA-256(challenge + ":" + s) has at least difficulty leading zero bits.
+//
+// The difficulty of newly issued challenges is raised automatically as the rate of protected requests
+// exceeds the configured load threshold.
+//
+//	p := pow.New(secret)
+//	e.GET("/pow", p.Handler())
+//	e.POST("/signup", p.Require(), handler)
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Headers used to submit and issue challenges
+const (
+	HeaderChallenge  = "X-PoW-Challenge"
+	HeaderDifficulty = "X-PoW-Difficulty"
+	HeaderSolution   = "X-PoW-Solution"
+)
+
+// Length of the random nonce in a challenge
+const nonceLen = 16
+
+var (
+	ErrMalformed = fmt.Errorf("pow: malformed challenge")
+	ErrSignature = fmt.Errorf("pow: invalid challenge signature")
+	ErrExpired   = fmt.Errorf("pow: challenge expired")
+	ErrSolution  = fmt.Errorf("pow: insufficient proof of work")
+	ErrReplayed  = fmt.Errorf("pow: challenge already used")
+)
+
+type challengeOpts struct {
+	difficulty    int           // Base difficulty in leading zero bits
+	maxDifficulty int           // Upper bound when auto-tuning
+	ttl           time.Duration // Challenge validity
+	threshold     int           // Protected requests per second before difficulty is raised, disabled if zero
+	code          int           // HTTP status code when no valid solution is supplied
+	store         Store         // Spent challenges
+}
+
+// Modifier function for customising challenge behaviour
+type ChallengeOpts func(*challengeOpts) *challengeOpts
+
+// Challenger issues and verifies challenges
+type Challenger struct {
+	secret []byte
+	opts   *challengeOpts
+
+	mu     sync.Mutex
+	window int64 // Current one second window
+	count  int   // Requests in the current window
+	last   int   // Requests in the previous window
+}
+
+// New creates a challenger signing challenges with secret
+func New(secret []byte, opts ...ChallengeOpts) *Challenger {
+	co := &challengeOpts{
+		difficulty:    16,
+		maxDifficulty: 24,
+		ttl:           2 * time.Minute,
+		code:          http.StatusPreconditionRequired,
+	}
+	for _, f := range opts {
+		co = f(co)
+	}
+	if co.store == nil {
+		co.store = NewMemoryStore()
+	}
+	return &Challenger{
+		secret: secret,
+		opts:   co,
+	}
+}
+
+// Handler returns a handler issuing a new challenge as JSON
+func (c *Challenger) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		challenge, difficulty := c.Issue()
+		ctx.JSON(http.StatusOK, gin.H{
+			"challenge":  challenge,
+			"difficulty": difficulty,
+		})
+	}
+}
+
+// Require returns a middleware rejecting requests without a valid solution.
+// Rejected responses include a fresh challenge in the X-PoW-Challenge and X-PoW-Difficulty headers.
+func (c *Challenger) Require() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c.observe()
+
+		err := c.Verify(ctx, ctx.GetHeader(HeaderChallenge), ctx.GetHeader(HeaderSolution))
+		if err == nil {
+			return
+		}
+		if !isRejection(err) {
+			errors.AbortWithError(ctx, err, http.StatusInternalServerError, "pow_failed")
+			return
+		}
+
+		zlog.GetLogger(ctx).Debug().Err(err).Msg("proof of work rejected")
+
+		challenge, difficulty := c.Issue()
+		ctx.Header(HeaderChallenge, challenge)
+		ctx.Header(HeaderDifficulty, strconv.Itoa(difficulty))
+		errors.AbortWith(ctx, c.opts.code, "pow_required")
+	}
+}
+
+// Issue creates a new signed challenge at the current difficulty
+func (c *Challenger) Issue() (string, int) {
+	difficulty := c.Difficulty()
+
+	payload := make([]byte, nonceLen+1+8)
+	rand.Read(payload[:nonceLen])
+	payload[nonceLen] = byte(difficulty)
+	binary.BigEndian.PutUint64(payload[nonceLen+1:], uint64(time.Now().Add(c.opts.ttl).Unix()))
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), difficulty
+}
+
+// Verify checks the challenge signature and expiry, and that the solution meets its difficulty, then marks the
+// challenge as spent. A challenge already spent returns ErrReplayed.
+func (c *Challenger) Verify(ctx context.Context, challenge, solution string) error {
+	enc := base64.RawURLEncoding
+
+	p, s, ok := strings.Cut(challenge, ".")
+	if !ok {
+		return ErrMalformed
+	}
+	payload, err := enc.DecodeString(p)
+	if err != nil || len(payload) != nonceLen+1+8 {
+		return ErrMalformed
+	}
+	sig, err := enc.DecodeString(s)
+	if err != nil {
+		return ErrMalformed
+	}
+	if !hmac.Equal(sig, c.sign(payload)) {
+		return ErrSignature
+	}
+
+	expires := int64(binary.BigEndian.Uint64(payload[nonceLen+1:]))
+	if time.Now().Unix() > expires {
+		return ErrExpired
+	}
+
+	if leadingZeros(challenge, solution) < int(payload[nonceLen]) {
+		return ErrSolution
+	}
+
+	ok, err = c.opts.store.Spend(ctx, p, time.Unix(expires, 0))
+	if err != nil {
+		return fmt.Errorf("pow: spending challenge: %w", err)
+	}
+	if !ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+func isRejection(err error) bool {
+	switch err {
+	case ErrMalformed, ErrSignature, ErrExpired, ErrSolution, ErrReplayed:
+		return true
+	}
+	return false
+}
+
+// Difficulty returns the difficulty for newly issued challenges, adjusted for recent load
+func (c *Challenger) Difficulty() int {
+	d := c.opts.difficulty
+	if c.opts.threshold <= 0 {
+		return d
+	}
+
+	c.mu.Lock()
+	rate := c.last
+	if now := time.Now().Unix(); now == c.window && c.count > rate {
+		rate = c.count
+	} else if now > c.window+1 {
+		rate = 0
+	}
+	c.mu.Unlock()
+
+	// One extra bit (doubling the work) for each doubling of load over the threshold
+	if rate > c.opts.threshold {
+		d += bits.Len(uint(rate / c.opts.threshold))
+	}
+	if d > c.opts.maxDifficulty {
+		d = c.opts.maxDifficulty
+	}
+	return d
+}
+
+func (c *Challenger) observe() {
+	now := time.Now().Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now != c.window {
+		if now == c.window+1 {
+			c.last = c.count
+		} else {
+			c.last = 0
+		}
+		c.window = now
+		c.count = 0
+	}
+	c.count++
+}
+
+func (c *Challenger) sign(payload []byte) []byte {
+	m := hmac.New(sha256.New, c.secret)
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// Solve finds a solution to the challenge by brute force.
+// Intended for tests and Go clients; browsers should implement the same search in JavaScript.
+func Solve(challenge string, difficulty int) string {
+	for i := uint64(0); ; i++ {
+		s := strconv.FormatUint(i, 36)
+		if leadingZeros(challenge, s) >= difficulty {
+			return s
+		}
+	}
+}
+
+func leadingZeros(challenge, solution string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + solution))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// WithDifficulty sets the base difficulty in leading zero bits
+func WithDifficulty(bits int) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.difficulty = bits
+		return co
+	}
+}
+
+// WithMaxDifficulty sets the upper bound for auto-tuned difficulty
+func WithMaxDifficulty(bits int) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.maxDifficulty = bits
+		return co
+	}
+}
+
+// WithTTL sets how long issued challenges remain valid
+func WithTTL(ttl time.Duration) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.ttl = ttl
+		return co
+	}
+}
+
+// WithLoadThreshold enables difficulty auto-tuning above the given protected requests per second
+func WithLoadThreshold(rps int) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.threshold = rps
+		return co
+	}
+}
+
+// WithStore sets the store of spent challenges, default an in-memory store
+func WithStore(store Store) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.store = store
+		return co
+	}
+}
+
+// WithCode sets the status code returned when no valid solution is supplied, default 428
+func WithCode(code int) ChallengeOpts {
+	return func(co *challengeOpts) *challengeOpts {
+		co.code = code
+		return co
+	}
+}
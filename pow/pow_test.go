@@ -0,0 +1,129 @@
+package pow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	c := New([]byte("secret"), WithDifficulty(8))
+
+	challenge, difficulty := c.Issue()
+	assert.Equal(t, 8, difficulty)
+
+	solution := Solve(challenge, difficulty)
+	assert.NoError(t, c.Verify(context.Background(), challenge, solution))
+
+	other := New([]byte("other"), WithDifficulty(8))
+	assert.ErrorIs(t, other.Verify(context.Background(), challenge, solution), ErrSignature)
+	assert.ErrorIs(t, c.Verify(context.Background(), "garbage", solution), ErrMalformed)
+}
+
+func TestVerifyExpired(t *testing.T) {
+	c := New([]byte("secret"), WithDifficulty(1), WithTTL(-time.Minute))
+
+	challenge, difficulty := c.Issue()
+
+	assert.ErrorIs(t, c.Verify(context.Background(), challenge, Solve(challenge, difficulty)), ErrExpired)
+}
+
+func TestVerifyReplay(t *testing.T) {
+	store := NewMemoryStore()
+	c := New([]byte("secret"), WithDifficulty(4), WithStore(store))
+
+	challenge, difficulty := c.Issue()
+	solution := Solve(challenge, difficulty)
+	assert.NoError(t, c.Verify(context.Background(), challenge, solution))
+	assert.ErrorIs(t, c.Verify(context.Background(), challenge, solution), ErrReplayed)
+
+	// Another instance sharing the store
+	other := New([]byte("secret"), WithDifficulty(4), WithStore(store))
+	assert.ErrorIs(t, other.Verify(context.Background(), challenge, solution), ErrReplayed)
+
+	// A failed attempt doesn't spend the challenge
+	challenge, difficulty = c.Issue()
+	wrong := "x"
+	for leadingZeros(challenge, wrong) >= difficulty {
+		wrong += "x"
+	}
+	assert.ErrorIs(t, c.Verify(context.Background(), challenge, wrong), ErrSolution)
+	assert.NoError(t, c.Verify(context.Background(), challenge, Solve(challenge, difficulty)))
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	s := NewMemoryStore()
+
+	ok, _ := s.Spend(context.Background(), "a", time.Now().Add(-time.Second))
+	assert.True(t, ok)
+	s.nextPrune = time.Time{}
+	ok, _ = s.Spend(context.Background(), "b", time.Now().Add(time.Minute))
+	assert.True(t, ok)
+	assert.NotContains(t, s.spent, "a")
+
+	ok, _ = s.Spend(context.Background(), "b", time.Now().Add(time.Minute))
+	assert.False(t, ok)
+}
+
+func TestRequire(t *testing.T) {
+	c := New([]byte("secret"), WithDifficulty(8))
+
+	e := gin.New()
+	e.GET("/pow", c.Handler())
+	e.POST("/signup", c.Require(), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	// No solution
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/signup", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 428, w.Result().StatusCode)
+	assert.NotEmpty(t, w.Header().Get(HeaderChallenge))
+
+	// Fetch and solve a challenge
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/pow", nil)
+	e.ServeHTTP(w, req)
+
+	var res struct {
+		Challenge  string `json:"challenge"`
+		Difficulty int    `json:"difficulty"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/signup", nil)
+	req.Header.Set(HeaderChallenge, res.Challenge)
+	req.Header.Set(HeaderSolution, Solve(res.Challenge, res.Difficulty))
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	// Replaying the solution is rejected
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 428, w.Result().StatusCode)
+}
+
+func TestDifficultyAutoTune(t *testing.T) {
+	c := New([]byte("secret"), WithDifficulty(8), WithMaxDifficulty(12), WithLoadThreshold(10))
+
+	assert.Equal(t, 8, c.Difficulty())
+
+	for i := 0; i < 1000; i++ {
+		c.observe()
+	}
+
+	d := c.Difficulty()
+	assert.Greater(t, d, 8)
+	assert.LessOrEqual(t, d, 12)
+
+	_, issued := c.Issue()
+	assert.Equal(t, d, issued)
+}
@@ -0,0 +1,51 @@
+package pow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store records spent challenge nonces until their challenge expires, so each challenge is accepted once.
+// Implementations shared between instances must make Spend atomic.
+type Store interface {
+	// Spend records the nonce as spent until expires, returning false if it was already spent
+	Spend(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+// MemoryStore is an in-memory Store for tests and single instance deployments.
+// Expired nonces are pruned at most once per prune interval as nonces are spent.
+type MemoryStore struct {
+	mu        sync.Mutex
+	spent     map[string]time.Time
+	nextPrune time.Time
+}
+
+// Minimum interval between prunes of a MemoryStore
+const pruneInterval = 30 * time.Second
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{spent: map[string]time.Time{}}
+}
+
+// Spend implements Store
+func (s *MemoryStore) Spend(_ context.Context, nonce string, expires time.Time) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextPrune) {
+		for k, exp := range s.spent {
+			if now.After(exp) {
+				delete(s.spent, k)
+			}
+		}
+		s.nextPrune = now.Add(pruneInterval)
+	}
+	if exp, ok := s.spent[nonce]; ok && !now.After(exp) {
+		return false, nil
+	}
+	s.spent[nonce] = expires
+	return true, nil
+}
@@ -0,0 +1,167 @@
+// HTTP protocol support
+//
+// Serve cleartext HTTP/2 (h2c) and HTTP/3 alongside HTTP/1.1, for gRPC-web and low latency clients.
+//
+// h2c is provided by golang.org/x/net/http2/h2c, supporting both prior knowledge and Upgrade requests:
+//
+//	srv := protocols.Server(":8080", e)
+//
+// HTTP/3 requires a QUIC implementation, which isn't a dependency of this module. Any server with
+// ListenAndServe and Close methods can be run alongside the TCP server, e.g. quic-go's http3.Server, and
+// AltSvc advertises it to clients connecting over TCP:
+//
+//	e.Use(protocols.AltSvc(443, 24*time.Hour))
+//	h3 := &http3.Server{Addr: ":443", Handler: e, TLSConfig: tlsConfig}
+//	protocols.Run(ctx, srv, h3)
+//
+// Requests served per protocol are counted by Counter.Metrics.
+package protocols
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Protocol names reported by Protocol
+const (
+	ProtoHTTP1 = "http/1.1"
+	ProtoH2    = "h2"
+	ProtoH2C   = "h2c"
+	ProtoH3    = "h3"
+)
+
+// H2C wraps a handler to additionally serve cleartext HTTP/2. It must be the outermost handler of the server.
+func H2C(h http.Handler) http.Handler {
+	return h2c.NewHandler(h, &http2.Server{})
+}
+
+// Server returns an HTTP server serving HTTP/1.1 and h2c. If TLS is configured on the returned server,
+// HTTP/2 over TLS is also negotiated as usual.
+func Server(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           H2C(h),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// HTTP3Server is a QUIC based HTTP/3 server, e.g. github.com/quic-go/quic-go/http3.Server
+type HTTP3Server interface {
+	ListenAndServe() error
+	Close() error
+}
+
+// Run serves the TCP server and any HTTP/3 servers until the context is cancelled or one of them fails,
+// then shuts all of them down. Servers with TLS configured are started with ListenAndServeTLS.
+func Run(ctx context.Context, srv *http.Server, h3 ...HTTP3Server) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(h3)+1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+		cancel()
+	}()
+	for _, s := range h3 {
+		wg.Add(1)
+		go func(s HTTP3Server) {
+			defer wg.Done()
+			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("protocols: http/3: %w", err)
+			}
+			cancel()
+		}(s)
+	}
+
+	<-ctx.Done()
+	shutdown, done := context.WithTimeout(context.Background(), 30*time.Second)
+	defer done()
+	srv.Shutdown(shutdown)
+	for _, s := range h3 {
+		s.Close()
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Protocol returns the protocol a request was received over
+func Protocol(r *http.Request) string {
+	switch r.ProtoMajor {
+	case 3:
+		return ProtoH3
+	case 2:
+		if r.TLS == nil {
+			return ProtoH2C
+		}
+		return ProtoH2
+	}
+	return ProtoHTTP1
+}
+
+// AltSvc advertises HTTP/3 on the given UDP port to clients connected over TCP
+func AltSvc(port int, maxAge time.Duration) gin.HandlerFunc {
+	value := `h3=":` + strconv.Itoa(port) + `"; ma=` + strconv.Itoa(int(maxAge.Seconds()))
+	return func(ctx *gin.Context) {
+		if ctx.Request.ProtoMajor < 3 {
+			ctx.Header("Alt-Svc", value)
+		}
+	}
+}
+
+// Counter counts requests served per protocol
+type Counter struct {
+	counts [4]uint64
+}
+
+var protocolIndex = map[string]int{ProtoHTTP1: 0, ProtoH2: 1, ProtoH2C: 2, ProtoH3: 3}
+
+// Metrics returns a middleware counting requests per protocol
+func (c *Counter) Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		atomic.AddUint64(&c.counts[protocolIndex[Protocol(ctx.Request)]], 1)
+	}
+}
+
+// Counts returns the number of requests served per protocol
+func (c *Counter) Counts() map[string]uint64 {
+	res := map[string]uint64{}
+	for name, i := range protocolIndex {
+		res[name] = atomic.LoadUint64(&c.counts[i])
+	}
+	return res
+}
+
+// Handler returns a handler serving the counts as JSON
+func (c *Counter) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, c.Counts())
+	}
+}
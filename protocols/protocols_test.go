@@ -0,0 +1,111 @@
+package protocols
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+func TestH2C(t *testing.T) {
+	counter := &Counter{}
+	e := gin.New()
+	e.Use(counter.Metrics(), AltSvc(443, time.Hour))
+	e.GET("", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, Protocol(ctx.Request))
+	})
+
+	srv := httptest.NewServer(Server("", e).Handler)
+	defer srv.Close()
+
+	// Prior knowledge h2c client
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	res, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "h2c", string(body))
+	assert.Equal(t, `h3=":443"; ma=3600`, res.Header.Get("Alt-Svc"))
+
+	res, err = http.Get(srv.URL)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "http/1.1", string(body))
+
+	assert.Equal(t, map[string]uint64{"http/1.1": 1, "h2": 0, "h2c": 1, "h3": 0}, counter.Counts())
+}
+
+func TestAltSvcNotOnH3(t *testing.T) {
+	e := gin.New()
+	e.GET("", AltSvc(443, time.Hour))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.ProtoMajor = 3
+	e.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Alt-Svc"))
+}
+
+type fakeH3 struct {
+	err    error
+	closed chan struct{}
+}
+
+func (f *fakeH3) ListenAndServe() error {
+	if f.err != nil {
+		return f.err
+	}
+	<-f.closed
+	return http.ErrServerClosed
+}
+
+func (f *fakeH3) Close() error {
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h3 := &fakeH3{closed: make(chan struct{})}
+
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, Server("127.0.0.1:0", http.NotFoundHandler()), h3)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	assert.NoError(t, <-done)
+	_, open := <-h3.closed
+	assert.False(t, open)
+}
+
+func TestRunHTTP3Failure(t *testing.T) {
+	failure := errors.New("udp bind failed")
+	h3 := &fakeH3{err: failure, closed: make(chan struct{})}
+
+	err := Run(context.Background(), Server("127.0.0.1:0", http.NotFoundHandler()), h3)
+
+	assert.ErrorIs(t, err, failure)
+}
@@ -0,0 +1,99 @@
+package queueconsume
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a decoded push delivery
+type Message struct {
+	ID          string            `json:"id"`
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Attempt     int               `json:"attempt"`                // Delivery attempt from 1, or 0 if not known
+	PublishTime time.Time         `json:"publish_time,omitempty"` // Zero if not known
+	Source      string            `json:"source,omitempty"`       // Subscription or queue name
+}
+
+// Format decodes a push request into a message
+type Format func(r *http.Request, body []byte) (*Message, error)
+
+// PubSub decodes Google Cloud Pub/Sub push deliveries. The attempt is only known if the subscription has a dead
+// letter policy.
+func PubSub(r *http.Request, body []byte) (*Message, error) {
+	var push struct {
+		Message struct {
+			Data        []byte            `json:"data"`
+			Attributes  map[string]string `json:"attributes"`
+			MessageID   string            `json:"messageId"`
+			PublishTime time.Time         `json:"publishTime"`
+		} `json:"message"`
+		Subscription    string `json:"subscription"`
+		DeliveryAttempt int    `json:"deliveryAttempt"`
+	}
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("queueconsume: decoding pub/sub push: %w", err)
+	}
+	if push.Message.MessageID == "" {
+		return nil, fmt.Errorf("queueconsume: pub/sub push without message ID")
+	}
+	return &Message{
+		ID:          push.Message.MessageID,
+		Data:        push.Message.Data,
+		Attributes:  push.Message.Attributes,
+		Attempt:     push.DeliveryAttempt,
+		PublishTime: push.Message.PublishTime,
+		Source:      push.Subscription,
+	}, nil
+}
+
+// CloudTasks decodes Google Cloud Tasks HTTP target requests, from the X-CloudTasks headers and raw body
+func CloudTasks(r *http.Request, body []byte) (*Message, error) {
+	m := &Message{
+		ID:     r.Header.Get("X-CloudTasks-TaskName"),
+		Data:   body,
+		Source: r.Header.Get("X-CloudTasks-QueueName"),
+	}
+	if m.ID == "" {
+		return nil, fmt.Errorf("queueconsume: cloud tasks request without task name")
+	}
+	if n, err := strconv.Atoi(r.Header.Get("X-CloudTasks-TaskRetryCount")); err == nil {
+		m.Attempt = n + 1
+	}
+	if eta, err := strconv.ParseFloat(r.Header.Get("X-CloudTasks-TaskETA"), 64); err == nil {
+		m.PublishTime = time.Unix(0, int64(eta*float64(time.Second))).UTC()
+	}
+	return m, nil
+}
+
+// Generic decodes deliveries from bridges, e.g. for SQS, with the raw body as data and metadata in headers:
+// X-Message-ID, X-Attempt or X-Receive-Count, X-Queue, and X-Attribute-<name> for each attribute
+func Generic(r *http.Request, body []byte) (*Message, error) {
+	m := &Message{
+		ID:     r.Header.Get("X-Message-ID"),
+		Data:   body,
+		Source: r.Header.Get("X-Queue"),
+	}
+	if m.ID == "" {
+		return nil, fmt.Errorf("queueconsume: request without X-Message-ID")
+	}
+	for _, h := range []string{"X-Attempt", "X-Receive-Count"} {
+		if n, err := strconv.Atoi(r.Header.Get(h)); err == nil {
+			m.Attempt = n
+			break
+		}
+	}
+	for k, v := range r.Header {
+		if strings.HasPrefix(k, "X-Attribute-") && len(v) > 0 {
+			if m.Attributes == nil {
+				m.Attributes = map[string]string{}
+			}
+			m.Attributes[strings.ToLower(strings.TrimPrefix(k, "X-Attribute-"))] = v[0]
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,157 @@
+// Push queue consumers
+//
+// Handlers for consuming push based queues, such as Cloud Tasks, Pub/Sub push subscriptions or SQS through an
+// HTTP bridge. Deliveries are verified and decoded into a Message, and the consumer result is mapped to an ack or a
+// nack for the queue to retry, with messages that can never succeed routed to a poison handler.
+//
+//	e.POST("/push/orders", queueconsume.Handler(queueconsume.PubSub, processOrder,
+//		queueconsume.WithVerifier(queueconsume.TokenVerifier("token", cfg.PushToken)),
+//		queueconsume.WithMaxAttempts(5),
+//		queueconsume.WithPoison(deadLetter),
+//	))
+//
+// A nil error acks with 204, and other errors nack with 503 so the queue retries. Errors are permanent, and the
+// message poisoned rather than retried, if wrapped with Permanent or if they have a 4xx StatusCode() other than 408
+// or 429, e.g. an errors.E. Messages that fail to decode, or reach the maximum attempts, are also poisoned.
+package queueconsume
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Consumer processes a message, returning an error if it should be retried or poisoned
+type Consumer func(ctx *gin.Context, m *Message) error
+
+// PoisonHandler receives messages that can never be processed successfully, e.g. to store them in a dead letter
+// queue. Returning an error nacks the message so it isn't lost. The message data is nil if decoding failed.
+type PoisonHandler func(ctx *gin.Context, m *Message, err error) error
+
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent marks an error as permanent, so the message is poisoned instead of retried
+func Permanent(err error) error {
+	return permanent{err}
+}
+
+// ErrMaxAttempts wraps the consumer error of a message poisoned after reaching the maximum attempts
+var ErrMaxAttempts = fmt.Errorf("queueconsume: max attempts reached")
+
+type handlerOpts struct {
+	verifier    Verifier
+	maxAttempts int
+	poison      PoisonHandler
+}
+
+// Modifier function for customising consumer behaviour
+type HandlerOpts func(*handlerOpts) *handlerOpts
+
+// WithVerifier rejects requests failing verification with 401
+func WithVerifier(v Verifier) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.verifier = v
+		return ho
+	}
+}
+
+// WithMaxAttempts poisons messages failing on or after the nth attempt, if the format provides attempts
+func WithMaxAttempts(n int) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.maxAttempts = n
+		return ho
+	}
+}
+
+// WithPoison sets the handler for poisoned messages. Without one, poisoned messages are logged and acked.
+func WithPoison(p PoisonHandler) HandlerOpts {
+	return func(ho *handlerOpts) *handlerOpts {
+		ho.poison = p
+		return ho
+	}
+}
+
+// Handler returns a handler decoding deliveries with the format and passing them to the consumer
+func Handler(format Format, fn Consumer, opts ...HandlerOpts) gin.HandlerFunc {
+	ho := &handlerOpts{}
+	for _, f := range opts {
+		ho = f(ho)
+	}
+
+	return func(ctx *gin.Context) {
+		body, err := ctx.GetRawData()
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if ho.verifier != nil {
+			if err := ho.verifier(ctx.Request, body); err != nil {
+				zlog.GetLogger(ctx).Warn().Err(err).Msg("Rejected push delivery")
+				ctx.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		m, err := format(ctx.Request, body)
+		if err != nil {
+			ho.poisoned(ctx, &Message{Data: body}, err)
+			return
+		}
+		zlog.Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("message_id", m.ID).Int("attempt", m.Attempt)
+		})
+
+		err = fn(ctx, m)
+		switch {
+		case err == nil:
+			ctx.Status(http.StatusNoContent)
+		case isPermanent(err):
+			ho.poisoned(ctx, m, err)
+		case ho.maxAttempts > 0 && m.Attempt >= ho.maxAttempts:
+			ho.poisoned(ctx, m, fmt.Errorf("%w: %v", ErrMaxAttempts, err))
+		default:
+			zlog.GetLogger(ctx).Warn().Err(err).Msg("Message processing failed, retrying")
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// Route a message to the poison handler, acking unless it fails
+func (ho *handlerOpts) poisoned(ctx *gin.Context, m *Message, err error) {
+	logger := zlog.GetLogger(ctx)
+	if ho.poison == nil {
+		// The data may be sensitive, so only its size and hash are logged for correlation with the publisher
+		sum := sha256.Sum256(m.Data)
+		logger.Error().Err(err).Str("message_id", m.ID).Int("size", len(m.Data)).Hex("sha256", sum[:]).
+			Msg("Dropping poison message")
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	logger.Warn().Err(err).Str("message_id", m.ID).Msg("Poison message")
+	if perr := ho.poison(ctx, m, err); perr != nil {
+		logger.Error().Err(perr).Msg("Poison handler failed, retrying")
+		ctx.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func isPermanent(err error) bool {
+	if errors.As(err, &permanent{}) {
+		return true
+	}
+	var sc interface{ StatusCode() int }
+	if errors.As(err, &sc) {
+		s := sc.StatusCode()
+		return s >= 400 && s < 500 && s != http.StatusRequestTimeout && s != http.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package queueconsume
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const pubsubPush = `{"message":{"data":"%s","attributes":{"kind":"order"},"messageId":"m1",
+	"publishTime":"2024-01-02T03:04:05Z"},"subscription":"projects/p/subscriptions/orders","deliveryAttempt":%s}`
+
+func push(h gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("/push", h)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func pubsub(data, attempt string) *http.Request {
+	body := strings.Replace(strings.Replace(pubsubPush, "%s", data, 1), "%s", attempt, 1)
+	return httptest.NewRequest("POST", "/push?token=secret", strings.NewReader(body))
+}
+
+func TestPubSub(t *testing.T) {
+	var poisoned []string
+	h := Handler(PubSub, func(ctx *gin.Context, m *Message) error {
+		assert.Equal(t, "m1", m.ID)
+		assert.Equal(t, "order", m.Attributes["kind"])
+		assert.Equal(t, "projects/p/subscriptions/orders", m.Source)
+		assert.Equal(t, 2024, m.PublishTime.Year())
+		switch string(m.Data) {
+		case "ok":
+			return nil
+		case "invalid":
+			return Permanent(errors.New("invalid order"))
+		case "missing":
+			return ginxerrors.New("not_found").Status(404)
+		case "throttled":
+			return ginxerrors.New("throttled").Status(429)
+		}
+		return errors.New("db down")
+	},
+		WithVerifier(TokenVerifier("token", "secret")),
+		WithMaxAttempts(5),
+		WithPoison(func(ctx *gin.Context, m *Message, err error) error {
+			poisoned = append(poisoned, string(m.Data)+": "+err.Error())
+			return nil
+		}),
+	)
+
+	for _, tc := range []struct {
+		data, attempt string
+		status        int
+	}{
+		{"b2s=", "1", 204},         // ok
+		{"aW52YWxpZA==", "1", 204}, // invalid
+		{"bWlzc2luZw==", "1", 204}, // missing
+		{"dGhyb3R0bGVk", "1", 503}, // throttled
+		{"ZmFpbA==", "1", 503},     // fail
+		{"ZmFpbA==", "5", 204},     // fail on last attempt
+	} {
+		assert.Equal(t, tc.status, push(h, pubsub(tc.data, tc.attempt)).Code, tc.data)
+	}
+	assert.Equal(t, []string{
+		"invalid: invalid order",
+		"missing: not_found",
+		"fail: queueconsume: max attempts reached: db down",
+	}, poisoned)
+
+	req := httptest.NewRequest("POST", "/push?token=wrong", strings.NewReader(`{}`))
+	assert.Equal(t, 401, push(h, req).Code)
+
+	req = httptest.NewRequest("POST", "/push?token=secret", strings.NewReader(`{`))
+	assert.Equal(t, 204, push(h, req).Code)
+	assert.Len(t, poisoned, 4)
+}
+
+func TestCloudTasks(t *testing.T) {
+	h := Handler(CloudTasks, func(ctx *gin.Context, m *Message) error {
+		assert.Equal(t, "projects/p/locations/l/queues/q/tasks/t1", m.ID)
+		assert.Equal(t, 3, m.Attempt)
+		assert.Equal(t, "q", m.Source)
+		assert.Equal(t, int64(1700000000), m.PublishTime.Unix())
+		assert.Equal(t, "payload", string(m.Data))
+		return nil
+	})
+	req := httptest.NewRequest("POST", "/push", strings.NewReader("payload"))
+	req.Header.Set("X-CloudTasks-TaskName", "projects/p/locations/l/queues/q/tasks/t1")
+	req.Header.Set("X-CloudTasks-QueueName", "q")
+	req.Header.Set("X-CloudTasks-TaskRetryCount", "2")
+	req.Header.Set("X-CloudTasks-TaskETA", "1700000000.5")
+	assert.Equal(t, 204, push(h, req).Code)
+}
+
+func TestGeneric(t *testing.T) {
+	secret := []byte("key")
+	h := Handler(Generic, func(ctx *gin.Context, m *Message) error {
+		assert.Equal(t, "sqs-1", m.ID)
+		assert.Equal(t, 2, m.Attempt)
+		assert.Equal(t, map[string]string{"tenant": "acme"}, m.Attributes)
+		return nil
+	}, WithVerifier(HMACVerifier("X-Signature", secret)))
+
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	req := httptest.NewRequest("POST", "/push", strings.NewReader("body"))
+	req.Header.Set("X-Message-ID", "sqs-1")
+	req.Header.Set("X-Receive-Count", "2")
+	req.Header.Set("X-Attribute-Tenant", "acme")
+	req.Header.Set("X-Signature", sign("body"))
+	assert.Equal(t, 204, push(h, req).Code)
+
+	req = httptest.NewRequest("POST", "/push", strings.NewReader("tampered"))
+	req.Header.Set("X-Message-ID", "sqs-1")
+	req.Header.Set("X-Signature", sign("body"))
+	assert.Equal(t, 401, push(h, req).Code)
+}
+
+func TestEmptySecret(t *testing.T) {
+	assert.Panics(t, func() { TokenVerifier("token", "") })
+	assert.Panics(t, func() { HMACVerifier("X-Signature", nil) })
+}
+
+func TestDroppedDataNotLogged(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	h := Handler(Generic, func(ctx *gin.Context, m *Message) error {
+		return Permanent(errors.New("invalid"))
+	})
+	req := httptest.NewRequest("POST", "/push", strings.NewReader("card 4111111111111111"))
+	req.Header.Set("X-Message-ID", "m1")
+	assert.Equal(t, 204, push(h, req).Code)
+	assert.Contains(t, buf.String(), `"size":21`)
+	assert.NotContains(t, buf.String(), "4111")
+}
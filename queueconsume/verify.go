@@ -0,0 +1,51 @@
+package queueconsume
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redmapletech/ginx/fips"
+)
+
+// Verifier checks the authenticity of a push request, returning an error if it should be rejected
+type Verifier func(r *http.Request, body []byte) error
+
+// HMACVerifier verifies a hex encoded HMAC-SHA256 of the body in the header, optionally prefixed with "sha256=",
+// e.g. as sent by a queue bridge. Panics if the secret is empty, or in FIPS mode if it is too short.
+func HMACVerifier(header string, secret []byte) Verifier {
+	if len(secret) == 0 {
+		panic("queueconsume: HMAC secret is empty")
+	}
+	fips.RequireKey("queueconsume.HMACVerifier", secret)
+	return func(r *http.Request, body []byte) error {
+		sig, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(header), "sha256="))
+		if err != nil || len(sig) == 0 {
+			return fmt.Errorf("queueconsume: missing or malformed signature")
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("queueconsume: invalid signature")
+		}
+		return nil
+	}
+}
+
+// TokenVerifier verifies a shared secret in a query parameter of the push endpoint URL, e.g. for Pub/Sub push
+// subscriptions without authentication. Panics if the token is empty, as requests without the parameter would pass.
+func TokenVerifier(param, token string) Verifier {
+	if token == "" {
+		panic("queueconsume: token is empty")
+	}
+	return func(r *http.Request, body []byte) error {
+		if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get(param)), []byte(token)) != 1 {
+			return fmt.Errorf("queueconsume: invalid token")
+		}
+		return nil
+	}
+}
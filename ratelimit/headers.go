@@ -0,0 +1,134 @@
+// Rate limiting
+//
+// Response headers describing rate limit state, following the IETF RateLimit header fields draft
+// (draft-ietf-httpapi-ratelimit-headers), so all limiters in an application report quota to clients the
+// same way:
+//
+//	RateLimit-Limit: 100
+//	RateLimit-Remaining: 42
+//	RateLimit-Reset: 30
+//	RateLimit-Policy: 100;w=60, 1000;w=3600
+//
+// The legacy X-RateLimit-* headers used by many existing clients can be emitted instead, or as well, by
+// changing the default emitter with SetDefaultEmitter.
+//
+// New creates token bucket rate limiting middleware using these headers, keyed by client IP, a header or the
+// authenticated principal, with buckets held in a sharded MemoryStore or a shared Store such as Redis:
+//
+//	api.Use(auth.APIKey(keys), ratelimit.New(ratelimit.Every(100*time.Millisecond), 20,
+//		ratelimit.WithKey(ratelimit.KeyByPrincipal)))
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes a single quota policy, e.g. 100 requests per minute
+type Policy struct {
+	Limit   int
+	Window  time.Duration
+	Comment string // Optional description, e.g. "sliding window"
+}
+
+// String formats the policy as a RateLimit-Policy list item, e.g. 100;w=60
+func (p Policy) String() string {
+	s := strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(ceilSeconds(p.Window))
+	if p.Comment != "" {
+		s += `;comment="` + strings.ReplaceAll(strings.ReplaceAll(p.Comment, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	return s
+}
+
+// Status is the rate limit state for a request, for the policy closest to being exhausted
+type Status struct {
+	Limit     int           // Requests allowed in the current window
+	Remaining int           // Requests remaining in the current window
+	Reset     time.Duration // Time until the quota resets
+	Policies  []Policy      // All policies applied to the request, optional
+}
+
+// Emitter writes rate limit headers in a specific format
+type Emitter interface {
+	Write(h http.Header, st Status)
+}
+
+// EmitterFunc adapts a function to an Emitter
+type EmitterFunc func(h http.Header, st Status)
+
+// Write calls f
+func (f EmitterFunc) Write(h http.Header, st Status) {
+	f(h, st)
+}
+
+var (
+	// Draft writes the IETF draft RateLimit-* headers, with RateLimit-Reset as delta seconds
+	Draft Emitter = EmitterFunc(writeDraft)
+
+	// Legacy writes X-RateLimit-* headers, with X-RateLimit-Reset as a Unix timestamp
+	Legacy Emitter = legacyEmitter{now: time.Now}
+
+	// Both writes the draft and legacy headers, for migrating clients
+	Both Emitter = EmitterFunc(func(h http.Header, st Status) {
+		Draft.Write(h, st)
+		Legacy.Write(h, st)
+	})
+
+	defaultEmitter = Draft
+)
+
+// SetDefaultEmitter sets the emitter used by WriteHeaders for all limiters
+func SetDefaultEmitter(e Emitter) {
+	defaultEmitter = e
+}
+
+// WriteHeaders writes the rate limit headers for the status using the default emitter
+func WriteHeaders(h http.Header, st Status) {
+	defaultEmitter.Write(h, st)
+}
+
+// WriteRetryAfter sets Retry-After to the time until the quota resets, for rejected requests
+func WriteRetryAfter(h http.Header, st Status) {
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(st.Reset)))
+}
+
+func writeDraft(h http.Header, st Status) {
+	h.Set("RateLimit-Limit", strconv.Itoa(st.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(remaining(st)))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(st.Reset)))
+	if len(st.Policies) > 0 {
+		items := make([]string, len(st.Policies))
+		for i, p := range st.Policies {
+			items[i] = p.String()
+		}
+		h.Set("RateLimit-Policy", strings.Join(items, ", "))
+	}
+}
+
+type legacyEmitter struct {
+	now func() time.Time
+}
+
+func (e legacyEmitter) Write(h http.Header, st Status) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(st.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining(st)))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(e.now().Add(st.Reset).Unix(), 10))
+}
+
+func remaining(st Status) int {
+	if st.Remaining < 0 {
+		return 0
+	}
+	return st.Remaining
+}
+
+// Round up, so clients retrying after the reset don't arrive early
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
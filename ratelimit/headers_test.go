@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraftHeaders(t *testing.T) {
+	h := http.Header{}
+
+	WriteHeaders(h, Status{
+		Limit:     100,
+		Remaining: -3,
+		Reset:     29100 * time.Millisecond,
+		Policies: []Policy{
+			{Limit: 100, Window: time.Minute},
+			{Limit: 1000, Window: time.Hour, Comment: `sliding "window"`},
+		},
+	})
+
+	assert.Equal(t, "100", h.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", h.Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", h.Get("RateLimit-Reset"))
+	assert.Equal(t, `100;w=60, 1000;w=3600;comment="sliding \"window\""`, h.Get("RateLimit-Policy"))
+	assert.Empty(t, h.Get("X-RateLimit-Limit"))
+}
+
+func TestLegacyHeaders(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e := legacyEmitter{now: func() time.Time { return now }}
+	h := http.Header{}
+
+	e.Write(h, Status{Limit: 10, Remaining: 4, Reset: time.Minute})
+
+	assert.Equal(t, "10", h.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "4", h.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1060", h.Get("X-RateLimit-Reset"))
+	assert.Empty(t, h.Get("RateLimit-Limit"))
+}
+
+func TestDefaultEmitter(t *testing.T) {
+	SetDefaultEmitter(Both)
+	defer SetDefaultEmitter(Draft)
+	h := http.Header{}
+
+	WriteHeaders(h, Status{Limit: 10, Remaining: 4, Reset: time.Minute})
+	WriteRetryAfter(h, Status{Reset: 1500 * time.Millisecond})
+
+	assert.Equal(t, "10", h.Get("RateLimit-Limit"))
+	assert.Equal(t, "10", h.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "2", h.Get("Retry-After"))
+}
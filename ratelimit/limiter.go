@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/auth"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// KeyFunc returns the key requests are limited by, or an empty string to not limit the request
+type KeyFunc func(ctx *gin.Context) string
+
+// KeyByIP limits by client IP, respecting gin's trusted proxy configuration
+func KeyByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyByHeader limits by the value of the header, e.g. a tenant ID, falling back to the client IP if missing
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		if v := ctx.GetHeader(name); v != "" {
+			return "header:" + v
+		}
+		return KeyByIP(ctx)
+	}
+}
+
+// KeyByPrincipal limits by the principal set by auth.APIKey, or the subject of the claims set by auth.JWT, falling
+// back to the client IP if unauthenticated
+func KeyByPrincipal(ctx *gin.Context) string {
+	if p := auth.GetPrincipal(ctx); p != nil {
+		return "principal:" + p.ID
+	}
+	if sub := auth.GetClaims(ctx).Subject(); sub != "" {
+		return "sub:" + sub
+	}
+	return KeyByIP(ctx)
+}
+
+type limiterOpts struct {
+	key    KeyFunc
+	store  Store
+	code   string
+	prefix string
+}
+
+// Modifier function for customising rate limiter behaviour
+type LimiterOpts func(*limiterOpts) *limiterOpts
+
+// WithKey sets how requests are keyed (default KeyByIP)
+func WithKey(fn KeyFunc) LimiterOpts {
+	return func(lo *limiterOpts) *limiterOpts {
+		lo.key = fn
+		return lo
+	}
+}
+
+// WithStore sets the bucket store (default a MemoryStore with 32 shards)
+func WithStore(s Store) LimiterOpts {
+	return func(lo *limiterOpts) *limiterOpts {
+		lo.store = s
+		return lo
+	}
+}
+
+// WithCode sets the error code of rejected requests (default rate_limited)
+func WithCode(code string) LimiterOpts {
+	return func(lo *limiterOpts) *limiterOpts {
+		lo.code = code
+		return lo
+	}
+}
+
+// WithPrefix prefixes keys, so limiters sharing a store have separate buckets
+func WithPrefix(prefix string) LimiterOpts {
+	return func(lo *limiterOpts) *limiterOpts {
+		lo.prefix = prefix
+		return lo
+	}
+}
+
+// New creates token bucket rate limiting middleware, allowing bursts of up to burst requests per key, refilled at
+// the limit. Every request gets the rate limit headers, and rejected requests are aborted with 429 through the
+// errors package with Retry-After. If the store fails the request is allowed, and the error logged.
+func New(limit Limit, burst int, opts ...LimiterOpts) gin.HandlerFunc {
+	lo := &limiterOpts{key: KeyByIP, code: "rate_limited"}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+	if lo.store == nil {
+		lo.store = NewMemoryStore(32)
+	}
+
+	policy := []Policy{{Limit: burst, Window: limit.durationFor(float64(burst))}}
+	return func(ctx *gin.Context) {
+		key := lo.key(ctx)
+		if key == "" {
+			return
+		}
+		d, err := lo.store.Take(ctx.Request.Context(), lo.prefix+key, limit, burst)
+		if err != nil {
+			zlog.GetLogger(ctx).Error().Err(err).Msg("Rate limit store failed")
+			return
+		}
+
+		st := Status{Limit: burst, Remaining: d.Remaining, Reset: d.Reset}
+		if limit != Inf {
+			st.Policies = policy
+		}
+		WriteHeaders(ctx.Writer.Header(), st)
+		if !d.Allowed {
+			WriteRetryAfter(ctx.Writer.Header(), Status{Reset: d.RetryAfter})
+			errors.AbortWith(ctx, http.StatusTooManyRequests, lo.code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryStore(4)
+	s.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		d, _ := s.Take(ctx, "a", Every(time.Second), 3)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+	}
+	d, _ := s.Take(ctx, "a", Every(time.Second), 3)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.Reset)
+
+	// Other keys have their own bucket
+	d, _ = s.Take(ctx, "b", Every(time.Second), 3)
+	assert.True(t, d.Allowed)
+
+	now = now.Add(1500 * time.Millisecond)
+	d, _ = s.Take(ctx, "a", Every(time.Second), 3)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	d, _ = s.Take(ctx, "a", Every(time.Second), 3)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	d, _ = s.Take(ctx, "a", Inf, 3)
+	assert.True(t, d.Allowed)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryStore(1)
+	s.clock = func() time.Time { return now }
+	s.Take(context.Background(), "old", Every(time.Second), 1)
+	now = now.Add(time.Minute)
+	for i := 0; i < sweepEvery; i++ {
+		s.Take(context.Background(), "new", Every(time.Millisecond), 1000)
+	}
+	assert.NotContains(t, s.shards[0].buckets, "old")
+	assert.Contains(t, s.shards[0].buckets, "new")
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit, burst int) (Decision, error) {
+	return Decision{}, errors.New("redis down")
+}
+
+func TestNew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/ip", New(Every(time.Minute), 2), func(ctx *gin.Context) {})
+	e.GET("/principal", func(ctx *gin.Context) {
+		ctx.Set(auth.DefaultPrincipalKey, &auth.Principal{ID: ctx.Query("p")})
+	}, New(Every(time.Minute), 1, WithKey(KeyByPrincipal)), func(ctx *gin.Context) {})
+	e.GET("/failing", New(Every(time.Minute), 1, WithStore(failingStore{})), func(ctx *gin.Context) {})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/ip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=120", w.Header().Get("RateLimit-Policy"))
+	get("/ip")
+	w = get("/ip")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "rate_limited")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, get("/principal?p=a").Code)
+	assert.Equal(t, http.StatusOK, get("/principal?p=b").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get("/principal?p=a").Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get("/failing").Code)
+	}
+}
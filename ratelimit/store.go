@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a rate of events per second, as in golang.org/x/time/rate
+type Limit float64
+
+// Inf is an unlimited rate
+const Inf = Limit(math.MaxFloat64)
+
+// Every converts the minimum interval between events to a Limit
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+// Duration for the limit to refill the tokens
+func (l Limit) durationFor(tokens float64) time.Duration {
+	if l == Inf || l <= 0 || tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / float64(l) * float64(time.Second))
+}
+
+// Decision is the result of taking a token from a bucket
+type Decision struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left in the bucket
+	Reset      time.Duration // Time until the bucket is full
+	RetryAfter time.Duration // Time until a token is available, if not allowed
+}
+
+// Store holds token buckets by key. Stores shared between instances, such as Redis, must take tokens atomically,
+// e.g. in a script.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, burst int) (Decision, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	ops     int
+}
+
+// MemoryStore is a Store holding buckets in memory, sharded by key to reduce lock contention. Buckets which have
+// refilled are dropped periodically, as they are equivalent to new buckets.
+type MemoryStore struct {
+	shards []*shard
+	clock  func() time.Time
+}
+
+// Operations on a shard between sweeps for refilled buckets
+const sweepEvery = 1024
+
+// NewMemoryStore creates a MemoryStore with the number of shards, at least 1
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &MemoryStore{shards: make([]*shard, shards), clock: time.Now}
+	for i := range s.shards {
+		s.shards[i] = &shard{buckets: map[string]*bucket{}}
+	}
+	return s
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, burst int) (Decision, error) {
+	if limit == Inf {
+		return Decision{Allowed: true, Remaining: burst}, nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	sh := s.shards[h.Sum32()%uint32(len(s.shards))]
+	now := s.clock()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.ops++; sh.ops >= sweepEvery {
+		sh.ops = 0
+		sh.sweep(now, limit, burst)
+	}
+
+	b, ok := sh.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		sh.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed.Seconds()*float64(limit))
+		b.last = now
+	}
+
+	d := Decision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = limit.durationFor(1 - b.tokens)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = limit.durationFor(float64(burst) - b.tokens)
+	return d, nil
+}
+
+// Drop buckets which would have refilled by now. Buckets are assumed to share the limit and burst, as a store is
+// normally used by one limiter.
+func (sh *shard) sweep(now time.Time, limit Limit, burst int) {
+	for k, b := range sh.buckets {
+		if !now.Before(b.last.Add(limit.durationFor(float64(burst) - b.tokens))) {
+			delete(sh.buckets, k)
+		}
+	}
+}
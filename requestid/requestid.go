@@ -0,0 +1,204 @@
+// Request ID middleware
+//
+// Assigns each request an ID, reusing a trusted incoming ID or generating one, stores it on the request context and
+// returns it in a response header, so requests can be correlated across services without the zlog middleware.
+//
+//	e.Use(requestid.New(requestid.WithIncomingHeaders("X-Request-ID", "X-Amzn-Trace-Id")))
+//	...
+//	id := requestid.Get(ctx)
+//
+// The zlog middleware assigns IDs the same way, and reuses the ID if this middleware runs first.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/internal/ipnets"
+)
+
+const (
+	// Header is the default response header for the request ID
+	Header = "X-Request-ID"
+
+	// MaxLength is the longest incoming request ID accepted
+	MaxLength = 128
+
+	traceparentHeader = "Traceparent"
+)
+
+type idKey struct{}
+
+// Get returns the request ID of the request, or an empty string if not assigned
+func Get(ctx context.Context) string {
+	if gctx, ok := ctx.(*gin.Context); ok {
+		if gctx.Request == nil {
+			return ""
+		}
+		ctx = gctx.Request.Context()
+	}
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Generate a random string to use as the request ID
+func generate() string {
+	b := make([]byte, 8)
+	io.ReadFull(rand.Reader, b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type idOpts struct {
+	generator      func() string
+	incoming       []string     // Incoming request ID headers, in order of preference
+	response       string       // Response header for the request ID, empty to disable
+	responseSet    bool         // Response header set explicitly
+	trustedProxies []*net.IPNet // Peers allowed to set the request ID, any if empty
+}
+
+// Modifier function for customising request ID behaviour
+type IDOpts func(*idOpts) *idOpts
+
+// WithGenerator sets the function generating request IDs when no trusted incoming ID is present, e.g. to use UUIDs
+// or ULIDs. Defaults to 8 random bytes, base64 encoded.
+func WithGenerator(fn func() string) IDOpts {
+	return func(o *idOpts) *idOpts {
+		o.generator = fn
+		return o
+	}
+}
+
+// WithIncomingHeaders reuses a request ID sent by the client or load balancer in any of the headers, checked in
+// order, instead of generating one. For a traceparent header the trace ID is used. Unless WithResponseHeader is
+// given, the ID is returned in the first non-traceparent header.
+//
+// Incoming IDs are only accepted from the addresses given to WithTrustedProxies if set, and must be at most
+// MaxLength printable ASCII characters.
+func WithIncomingHeaders(names ...string) IDOpts {
+	return func(o *idOpts) *idOpts {
+		o.incoming = nil
+		for _, n := range names {
+			n = http.CanonicalHeaderKey(n)
+			o.incoming = append(o.incoming, n)
+			if n != traceparentHeader && !o.responseSet {
+				o.response = n
+				o.responseSet = true
+			}
+		}
+		return o
+	}
+}
+
+// WithResponseHeader sets the response header the request ID is returned in (default X-Request-ID), or disables it
+// if empty
+func WithResponseHeader(name string) IDOpts {
+	return func(o *idOpts) *idOpts {
+		o.response = name
+		o.responseSet = true
+		return o
+	}
+}
+
+// WithTrustedProxies restricts incoming request IDs to requests whose immediate peer is within the IPs or CIDR
+// ranges, e.g. the load balancer. Entries must be IPs or CIDR ranges such as 10.0.0.0/8, or it panics.
+func WithTrustedProxies(entries ...string) IDOpts {
+	nets, err := ipnets.Parse(entries...)
+	if err != nil {
+		panic("requestid: invalid trusted proxy: " + err.Error())
+	}
+	return func(o *idOpts) *idOpts {
+		o.trustedProxies = append(o.trustedProxies, nets...)
+		return o
+	}
+}
+
+// Assigner assigns request IDs, for middleware handling request IDs itself such as zlog
+type Assigner struct {
+	opts *idOpts
+}
+
+// NewAssigner creates an Assigner from options
+func NewAssigner(opts ...IDOpts) *Assigner {
+	o := &idOpts{generator: generate, response: Header}
+	for _, f := range opts {
+		o = f(o)
+	}
+	return &Assigner{opts: o}
+}
+
+// Assign returns the request ID of the request, assigning one if not already done by earlier middleware
+func (a *Assigner) Assign(c *gin.Context) string {
+	if id := Get(c); id != "" {
+		return id
+	}
+
+	id := a.incoming(c)
+	if id == "" {
+		id = a.opts.generator()
+	}
+	if a.opts.response != "" {
+		c.Header(a.opts.response, id)
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), idKey{}, id))
+	return id
+}
+
+// New creates the request ID middleware from options
+func New(opts ...IDOpts) gin.HandlerFunc {
+	a := NewAssigner(opts...)
+	return func(c *gin.Context) {
+		a.Assign(c)
+	}
+}
+
+// Return the first valid incoming request ID from a trusted peer, or an empty string
+func (a *Assigner) incoming(c *gin.Context) string {
+	if len(a.opts.incoming) == 0 || !a.trusted(c.Request.RemoteAddr) {
+		return ""
+	}
+	for _, name := range a.opts.incoming {
+		v := c.GetHeader(name)
+		if name == traceparentHeader {
+			v = traceID(v)
+		}
+		if Valid(v) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (a *Assigner) trusted(remoteAddr string) bool {
+	return len(a.opts.trustedProxies) == 0 || ipnets.Contains(a.opts.trustedProxies, remoteAddr)
+}
+
+// Valid reports whether an incoming request ID is acceptable, at most MaxLength printable ASCII characters
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the trace ID of a W3C traceparent header, or an empty string if invalid
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ""
+	}
+	if len(parts[1]) != 32 || strings.Trim(parts[1], "0123456789abcdef") != "" || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,74 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h gin.HandlerFunc, req *http.Request) (*httptest.ResponseRecorder, string) {
+	gin.SetMode(gin.TestMode)
+	var id string
+	e := gin.New()
+	e.GET("/", h, func(ctx *gin.Context) {
+		id = Get(ctx)
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w, id
+}
+
+func TestNew(t *testing.T) {
+	w, id := serve(New(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, id, 11)
+	assert.Equal(t, id, w.Header().Get(Header))
+
+	// Incoming IDs ignored unless enabled
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(Header, "upstream")
+	_, id = serve(New(WithGenerator(func() string { return "gen" })), req)
+	assert.Equal(t, "gen", id)
+
+	w, id = serve(New(WithIncomingHeaders("Traceparent", "X-Correlation-ID")), req)
+	assert.NotEqual(t, "upstream", id)
+	assert.Equal(t, id, w.Header().Get("X-Correlation-ID"))
+
+	req.Header.Set("X-Correlation-ID", "corr")
+	_, id = serve(New(WithIncomingHeaders("Traceparent", "X-Correlation-ID")), req)
+	assert.Equal(t, "corr", id)
+
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, id = serve(New(WithIncomingHeaders("Traceparent", "X-Correlation-ID")), req)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", id)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(Header, strings.Repeat("x", MaxLength+1))
+	w, id = serve(New(WithIncomingHeaders(Header), WithResponseHeader("")), req)
+	assert.Len(t, id, 11)
+	assert.Empty(t, w.Header().Get(Header))
+}
+
+func TestTrustedProxies(t *testing.T) {
+	for addr, trusted := range map[string]bool{"10.1.2.3:1234": true, "192.168.0.1:1234": false, "[::1]:80": true} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		req.Header.Set(Header, "upstream")
+		_, id := serve(New(WithIncomingHeaders(Header), WithTrustedProxies("10.0.0.0/8", "::1")), req)
+		assert.Equal(t, trusted, id == "upstream", addr)
+	}
+	assert.Panics(t, func() { WithTrustedProxies("invalid") })
+}
+
+func TestAssignReuses(t *testing.T) {
+	a := NewAssigner(WithGenerator(func() string { return "second" }))
+	w, id := serve(func(ctx *gin.Context) {
+		New(WithGenerator(func() string { return "first" }))(ctx)
+		assert.Equal(t, "first", a.Assign(ctx))
+	}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "first", id)
+	assert.Equal(t, "first", w.Header().Get(Header))
+}
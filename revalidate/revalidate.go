@@ -0,0 +1,336 @@
+// Conditional GET revalidation cache
+//
+// Caches upstream responses along with their validators (ETag and Last-Modified), and refreshes them through a
+// revalidation callback using stale-while-revalidate and stale-if-error semantics (RFC 5861). Stale entries within
+// the stale-while-revalidate window are served immediately while a single background refresh runs, and stale
+// entries within the stale-if-error window are served if the refresh fails.
+//
+//	c := revalidate.New(func(ctx context.Context, key string, prev *revalidate.Entry) (*revalidate.Entry, error) {
+//		// Send If-None-Match: prev.ETag upstream, return revalidate.ErrNotModified on 304
+//	})
+//	e.GET("/feed", c.Handler(revalidate.PathKey))
+//
+// Freshness lifetimes are taken from the Cache-Control header of each entry by default, see WithPolicy.
+package revalidate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// ErrNotModified is returned by a Revalidator when the previous entry is still valid, e.g. on an upstream 304.
+// The previous entry is kept and its stored time reset.
+var ErrNotModified = errors.New("revalidate: not modified")
+
+// Entry is a cached response
+type Entry struct {
+	Status       int
+	Header       http.Header
+	Body         []byte
+	ETag         string    // Strong or weak entity tag, including quotes
+	LastModified time.Time // Zero if unknown
+	Stored       time.Time // Time the entry was fetched or last revalidated
+}
+
+// Revalidator fetches a fresh entry for the key. prev is the currently cached entry, or nil if there is none, and
+// carries the validators to send upstream.
+type Revalidator func(ctx context.Context, key string, prev *Entry) (*Entry, error)
+
+// Store holds cached entries
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, e *Entry)
+}
+
+// Freshness describes how long an entry may be served
+type Freshness struct {
+	MaxAge               time.Duration // Served without revalidation
+	StaleWhileRevalidate time.Duration // Served after MaxAge while refreshing in the background
+	StaleIfError         time.Duration // Served after MaxAge if revalidation fails
+}
+
+// Policy decides the freshness lifetimes of an entry
+type Policy interface {
+	Freshness(e *Entry) Freshness
+}
+
+// PolicyFunc adapts a function to a Policy
+type PolicyFunc func(e *Entry) Freshness
+
+// Freshness calls f(e)
+func (f PolicyFunc) Freshness(e *Entry) Freshness {
+	return f(e)
+}
+
+// Fixed returns a policy applying the same lifetimes to all entries
+func Fixed(fr Freshness) Policy {
+	return PolicyFunc(func(*Entry) Freshness { return fr })
+}
+
+// CacheControl is the default policy, reading max-age (or s-maxage), stale-while-revalidate and stale-if-error from
+// the Cache-Control header of the entry. no-cache and no-store give a zero max-age.
+var CacheControl Policy = PolicyFunc(func(e *Entry) Freshness {
+	var fr Freshness
+	sMaxAge := false
+	for _, d := range strings.Split(e.Header.Get("Cache-Control"), ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+		secs, _ := strconv.Atoi(strings.Trim(val, `"`))
+		dur := time.Duration(secs) * time.Second
+		switch strings.ToLower(name) {
+		case "max-age":
+			if !sMaxAge {
+				fr.MaxAge = dur
+			}
+		case "s-maxage":
+			fr.MaxAge = dur
+			sMaxAge = true
+		case "stale-while-revalidate":
+			fr.StaleWhileRevalidate = dur
+		case "stale-if-error":
+			fr.StaleIfError = dur
+		case "no-cache", "no-store":
+			return Freshness{}
+		}
+	}
+	return fr
+})
+
+type cacheOpts struct {
+	store   Store
+	policy  Policy
+	timeout time.Duration    // Timeout for background refreshes
+	clock   func() time.Time // Current time, for testing
+}
+
+// Modifier function for customising cache behaviour
+type CacheOpts func(*cacheOpts) *cacheOpts
+
+// Cache serves entries, revalidating them as they become stale
+type Cache struct {
+	revalidate Revalidator
+	opts       *cacheOpts
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// In-flight revalidation shared by concurrent callers
+type call struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// New creates a cache using the revalidator to fetch and refresh entries
+func New(revalidate Revalidator, opts ...CacheOpts) *Cache {
+	co := &cacheOpts{
+		store:   NewMemoryStore(DefaultStoreSize),
+		policy:  CacheControl,
+		timeout: 30 * time.Second,
+		clock:   time.Now,
+	}
+	for _, f := range opts {
+		co = f(co)
+	}
+	return &Cache{
+		revalidate: revalidate,
+		opts:       co,
+		inflight:   map[string]*call{},
+	}
+}
+
+// WithStore sets the entry store, defaults to a MemoryStore of DefaultStoreSize entries
+func WithStore(s Store) CacheOpts {
+	return func(co *cacheOpts) *cacheOpts {
+		co.store = s
+		return co
+	}
+}
+
+// WithPolicy sets the freshness policy, defaults to CacheControl
+func WithPolicy(p Policy) CacheOpts {
+	return func(co *cacheOpts) *cacheOpts {
+		co.policy = p
+		return co
+	}
+}
+
+// WithRefreshTimeout sets the timeout for refreshes, which aren't bound to the requests waiting for them, defaults
+// to 30s
+func WithRefreshTimeout(d time.Duration) CacheOpts {
+	return func(co *cacheOpts) *cacheOpts {
+		co.timeout = d
+		return co
+	}
+}
+
+// Get returns the entry for the key, revalidating it if stale.
+//
+// Fresh entries are returned directly. Entries within the stale-while-revalidate window are returned directly
+// while a background refresh runs. Otherwise the entry is revalidated before returning, falling back to the stale
+// entry within the stale-if-error window if revalidation fails.
+func (c *Cache) Get(ctx context.Context, key string) (*Entry, error) {
+	prev, ok := c.opts.store.Get(key)
+	if !ok {
+		return c.refresh(ctx, key, nil)
+	}
+
+	fr := c.opts.policy.Freshness(prev)
+	age := c.opts.clock().Sub(prev.Stored)
+	switch {
+	case age < fr.MaxAge:
+		return prev, nil
+	case age < fr.MaxAge+fr.StaleWhileRevalidate:
+		go func() {
+			bctx := context.Background()
+			if _, err := c.refresh(bctx, key, prev); err != nil {
+				zlog.GetLogger(bctx).Warn().Err(err).Str("key", key).Msg("Background revalidation failed")
+			}
+		}()
+		return prev, nil
+	}
+
+	e, err := c.refresh(ctx, key, prev)
+	if err != nil && age < fr.MaxAge+fr.StaleIfError {
+		zlog.GetLogger(ctx).Warn().Err(err).Str("key", key).Msg("Revalidation failed, serving stale entry")
+		return prev, nil
+	}
+	return e, err
+}
+
+// Purge forces the entry for the key to be revalidated on next use
+func (c *Cache) Purge(key string) {
+	if e, ok := c.opts.store.Get(key); ok {
+		stale := *e
+		stale.Stored = time.Time{}
+		c.opts.store.Set(key, &stale)
+	}
+}
+
+// Revalidate the key, sharing the result with any concurrent callers. The fetch runs on a detached context with the
+// refresh timeout, so a caller giving up doesn't cancel it for the others; ctx only bounds the wait.
+func (c *Cache) refresh(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+	c.mu.Lock()
+	cl, ok := c.inflight[key]
+	if !ok {
+		cl = &call{done: make(chan struct{})}
+		c.inflight[key] = cl
+		go c.run(cl, key, prev)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.entry, cl.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Cache) run(cl *call, key string, prev *Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+	defer cancel()
+	cl.entry, cl.err = c.fetch(ctx, key, prev)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(cl.done)
+}
+
+func (c *Cache) fetch(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+	e, err := c.revalidate(ctx, key, prev)
+	if errors.Is(err, ErrNotModified) && prev != nil {
+		// Copy rather than modify, as the previous entry may be in use by other requests
+		fresh := *prev
+		e, err = &fresh, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Header == nil {
+		e.Header = http.Header{}
+	}
+	if e.Status == 0 {
+		e.Status = http.StatusOK
+	}
+	e.Stored = c.opts.clock()
+	c.opts.store.Set(key, e)
+	return e, nil
+}
+
+// PathKey keys entries by request path and query
+func PathKey(ctx *gin.Context) string {
+	return ctx.Request.URL.RequestURI()
+}
+
+// Handler serves cached entries for GET and HEAD requests, answering conditional requests from the entry
+// validators with 304 Not Modified. Revalidation failures without a usable stale entry respond 502.
+func (c *Cache) Handler(key func(*gin.Context) string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		e, err := c.Get(ctx, key(ctx))
+		if err != nil {
+			zlog.GetLogger(ctx).Error().Err(err).Msg("Revalidation failed")
+			ginxerrors.AbortWith(ctx, http.StatusBadGateway, "upstream_unavailable")
+			return
+		}
+
+		h := ctx.Writer.Header()
+		for k, v := range e.Header {
+			h[k] = v
+		}
+		if e.ETag != "" {
+			h.Set("ETag", e.ETag)
+		}
+		if !e.LastModified.IsZero() {
+			h.Set("Last-Modified", e.LastModified.UTC().Format(http.TimeFormat))
+		}
+		h.Set("Age", strconv.Itoa(int(c.opts.clock().Sub(e.Stored).Seconds())))
+
+		if e.Status == http.StatusOK && notModified(ctx.Request, e) {
+			ctx.Status(http.StatusNotModified)
+			ctx.Writer.WriteHeaderNow()
+			return
+		}
+		ctx.Status(e.Status)
+		if ctx.Request.Method == http.MethodHead {
+			ctx.Writer.WriteHeaderNow()
+			return
+		}
+		ctx.Writer.Write(e.Body)
+	}
+}
+
+// Evaluate If-None-Match, or If-Modified-Since if absent, per RFC 9110 13.2.2
+func notModified(r *http.Request, e *Entry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if e.ETag == "" {
+			return false
+		}
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || weakMatch(t, e.ETag) {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !e.LastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !e.LastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
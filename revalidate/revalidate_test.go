@@ -0,0 +1,208 @@
+package revalidate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func withClock(f *fakeClock) CacheOpts {
+	return func(co *cacheOpts) *cacheOpts {
+		co.clock = f.Now
+		return co
+	}
+}
+
+func entry(body string) *Entry {
+	return &Entry{
+		Header: http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=10, stale-if-error=60"}},
+		Body:   []byte(body),
+		ETag:   `"v1"`,
+	}
+}
+
+func TestCacheControlPolicy(t *testing.T) {
+	fr := CacheControl.Freshness(&Entry{Header: http.Header{"Cache-Control": {"public, max-age=5, s-maxage=20, stale-if-error=30"}}})
+	assert.Equal(t, Freshness{MaxAge: 20 * time.Second, StaleIfError: 30 * time.Second}, fr)
+
+	fr = CacheControl.Freshness(&Entry{Header: http.Header{"Cache-Control": {"max-age=5, no-cache"}}})
+	assert.Equal(t, Freshness{}, fr)
+}
+
+func TestGetFreshAndNotModified(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	var calls int32
+	var prevETag string
+
+	c := New(func(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+		atomic.AddInt32(&calls, 1)
+		if prev != nil {
+			prevETag = prev.ETag
+			return nil, ErrNotModified
+		}
+		return entry("a"), nil
+	}, withClock(clock))
+
+	e, err := c.Get(context.Background(), "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(e.Body))
+
+	clock.now = clock.now.Add(5 * time.Second)
+	_, _ = c.Get(context.Background(), "k")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// Beyond stale-while-revalidate, revalidated synchronously with validators
+	clock.now = clock.now.Add(30 * time.Second)
+	e, err = c.Get(context.Background(), "k")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	assert.Equal(t, `"v1"`, prevETag)
+	assert.Equal(t, clock.now, e.Stored)
+}
+
+func TestGetStaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	refreshed := make(chan struct{})
+	var calls int32
+
+	c := New(func(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			defer close(refreshed)
+		}
+		return entry("v" + string(rune('0'+atomic.LoadInt32(&calls)))), nil
+	}, withClock(clock))
+
+	_, _ = c.Get(context.Background(), "k")
+
+	clock.now = clock.now.Add(15 * time.Second)
+	e, err := c.Get(context.Background(), "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(e.Body))
+
+	<-refreshed
+	assert.Eventually(t, func() bool {
+		e, _ := c.Get(context.Background(), "k")
+		return string(e.Body) == "v2"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGetStaleIfError(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	fail := false
+
+	c := New(func(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+		if fail {
+			return nil, errors.New("upstream down")
+		}
+		return entry("a"), nil
+	}, withClock(clock))
+
+	_, _ = c.Get(context.Background(), "k")
+	fail = true
+
+	clock.now = clock.now.Add(30 * time.Second)
+	e, err := c.Get(context.Background(), "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(e.Body))
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err = c.Get(context.Background(), "k")
+	assert.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	lm := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	c := New(func(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+		e := entry("hello " + key)
+		e.LastModified = lm
+		return e, nil
+	})
+
+	e := gin.New()
+	e.GET("/feed", c.Handler(PathKey))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/feed", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "hello /feed", w.Body.String())
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "0", w.Header().Get("Age"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/feed", nil)
+	req.Header.Set("If-None-Match", `W/"v1"`)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/feed", nil)
+	req.Header.Set("If-Modified-Since", lm.Add(-time.Second).Format(http.TimeFormat))
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestRefreshDetached(t *testing.T) {
+	release := make(chan struct{})
+	c := New(func(ctx context.Context, key string, prev *Entry) (*Entry, error) {
+		select {
+		case <-release:
+			return entry("a"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+
+	// The first caller giving up doesn't fail the fetch shared with the second
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		first <- err
+	}()
+	second := make(chan *Entry)
+	go func() {
+		e, _ := c.Get(context.Background(), "k")
+		second <- e
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	e := <-second
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "a", string(e.Body))
+	}
+}
+
+func TestMemoryStoreSize(t *testing.T) {
+	s := NewMemoryStore(2)
+	s.Set("a", entry("a"))
+	s.Set("b", entry("b"))
+	s.Set("b", entry("b2"))
+	assert.Len(t, s.entries, 2)
+
+	s.Set("c", entry("c"))
+	assert.Len(t, s.entries, 2)
+	e, ok := s.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "c", string(e.Body))
+}
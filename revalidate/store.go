@@ -0,0 +1,41 @@
+package revalidate
+
+import "sync"
+
+// DefaultStoreSize is the maximum number of entries in the default MemoryStore
+const DefaultStoreSize = 10000
+
+// MemoryStore is an in-memory Store holding a bounded number of entries. When full, an arbitrary entry is evicted,
+// so keys taken from client input such as the query can't grow it without bound.
+type MemoryStore struct {
+	size int
+
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore creates an empty in-memory store holding up to size entries
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{size: size, entries: map[string]*Entry{}}
+}
+
+// Get returns the entry for the key
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+// Set stores the entry for the key
+func (s *MemoryStore) Set(key string, e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.size {
+		for k := range s.entries {
+			delete(s.entries, k)
+			break
+		}
+	}
+	s.entries[key] = e
+}
@@ -0,0 +1,236 @@
+// Declarative routes
+//
+// Describes routes as data, with the bind target, authentication, rate limiting and handler of each route in one
+// struct, and mounts them onto a gin router. The resulting table is the single source of route metadata for
+// documentation and policy checks, rather than reflecting over the engine afterwards.
+//
+//	table, err := routerdsl.Mount(e, routerdsl.Group{
+//		Path:      "/api",
+//		Auth:      auth.Middleware(),
+//		RateLimit: limiter.Middleware(),
+//		Routes: []routerdsl.Route{
+//			{Method: "GET", Path: "/users/:id", Name: "getUser", Bind: UserParams{}, Handler: getUser},
+//			{Method: "POST", Path: "/login", Public: true, Bind: Login{}, Handler: login},
+//		},
+//	})
+//	e.GET("/openapi.json", table.Handler("API", "1.0"))
+//
+// Route chains are built in the order: group middleware, auth, rate limit, route middleware, bind, handler.
+package routerdsl
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/bind"
+	"github.com/redmapletech/ginx/policyaudit"
+)
+
+// Route describes a single route
+type Route struct {
+	Method  string
+	Path    string // Relative to the enclosing groups
+	Name    string // Operation ID for documentation
+	Summary string
+	Tags    []string
+
+	Bind     interface{}     // Bind target struct, bound with bind.To before the handler, nil for none
+	BindOpts []bind.BindOpts // Options for the bind middleware
+
+	Auth       gin.HandlerFunc   // Authentication middleware, overriding the group
+	Public     bool              // Skip authentication inherited from the group
+	RateLimit  gin.HandlerFunc   // Rate limit middleware, overriding the group
+	Middleware []gin.HandlerFunc // Additional middleware, after auth and rate limiting
+	Handler    gin.HandlerFunc
+}
+
+// Group describes a set of routes sharing a path prefix and middleware. Auth and RateLimit are inherited by nested
+// groups and routes unless overridden.
+type Group struct {
+	Path       string
+	Auth       gin.HandlerFunc
+	RateLimit  gin.HandlerFunc
+	Middleware []gin.HandlerFunc // Applied to all routes in the group, before auth
+	Routes     []Route
+	Groups     []Group
+}
+
+// Spec is a mounted route, with inherited settings resolved
+type Spec struct {
+	Route
+	FullPath string            // Path including all group prefixes and the base path of the router
+	Chain    []gin.HandlerFunc // Complete handler chain registered, excluding middleware of the parent router
+
+	relative string // Path relative to the router
+}
+
+// Table is the set of routes mounted by Mount
+type Table []Spec
+
+// Mount registers the group and its routes on the router, returning the resolved route table.
+// Routes are validated before any are registered, so an invalid definition leaves the router unchanged.
+func Mount(r gin.IRouter, g Group) (Table, error) {
+	var specs Table
+	if err := resolve(g, "/", nil, nil, nil, &specs); err != nil {
+		return nil, err
+	}
+	base := basePath(r)
+	for i := range specs {
+		s := &specs[i]
+		s.FullPath = joinPath(base, s.relative)
+		r.Handle(s.Method, s.relative, s.Chain...)
+		if s.Bind != nil {
+			bind.Registry().Add(s.Method, s.FullPath, s.Bind)
+		}
+	}
+	return specs, nil
+}
+
+func resolve(g Group, prefix string, auth, rateLimit gin.HandlerFunc, mw []gin.HandlerFunc, specs *Table) error {
+	prefix = joinPath(prefix, g.Path)
+	if g.Auth != nil {
+		auth = g.Auth
+	}
+	if g.RateLimit != nil {
+		rateLimit = g.RateLimit
+	}
+	mw = append(append([]gin.HandlerFunc{}, mw...), g.Middleware...)
+
+	for _, rt := range g.Routes {
+		if rt.Method == "" || rt.Handler == nil {
+			return fmt.Errorf("routerdsl: route %s %s requires a method and handler", rt.Method, joinPath(prefix, rt.Path))
+		}
+		if rt.Bind != nil && reflect.TypeOf(rt.Bind).Kind() != reflect.Struct {
+			return fmt.Errorf("routerdsl: route %s %s bind target must be a struct value, not %T", rt.Method, joinPath(prefix, rt.Path), rt.Bind)
+		}
+
+		s := Spec{Route: rt, relative: joinPath(prefix, rt.Path)}
+		s.Method = strings.ToUpper(rt.Method)
+		switch {
+		case rt.Public:
+			s.Auth = nil
+		case rt.Auth == nil:
+			s.Auth = auth
+		}
+		if s.RateLimit == nil {
+			s.RateLimit = rateLimit
+		}
+
+		s.Chain = append(s.Chain, mw...)
+		for _, h := range []gin.HandlerFunc{s.Auth, s.RateLimit} {
+			if h != nil {
+				s.Chain = append(s.Chain, h)
+			}
+		}
+		s.Chain = append(s.Chain, rt.Middleware...)
+		if rt.Bind != nil {
+			s.Chain = append(s.Chain, bind.To(rt.Bind, rt.BindOpts...))
+		}
+		s.Chain = append(s.Chain, rt.Handler)
+		*specs = append(*specs, s)
+	}
+
+	for _, sub := range g.Groups {
+		if err := resolve(sub, prefix, auth, rateLimit, mw, specs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Audit returns the table as routes for policyaudit.CheckRoutes. Middleware of the parent router isn't included.
+func (t Table) Audit() []policyaudit.Route {
+	res := make([]policyaudit.Route, 0, len(t))
+	for _, s := range t {
+		r := policyaudit.Route{Method: s.Method, Path: s.FullPath}
+		for _, h := range s.Chain {
+			r.Handlers = append(r.Handlers, policyaudit.HandlerName(h))
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// Unauthenticated returns the routes without authentication, for review
+func (t Table) Unauthenticated() Table {
+	var res Table
+	for _, s := range t {
+		if s.Auth == nil {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// OpenAPI returns an OpenAPI 3.1 document for the table, with request schemas from the bind targets.
+// Authenticated routes reference a "default" security scheme, which should be defined by the caller.
+func (t Table) OpenAPI(title, version string) map[string]interface{} {
+	schemas := bind.NewSchemas()
+	paths := map[string]interface{}{}
+	for _, s := range t {
+		op := map[string]interface{}{}
+		if s.Bind != nil {
+			e := &bind.RouteEntry{Method: s.Method, Path: s.FullPath, Type: reflect.TypeOf(s.Bind)}
+			op = e.Operation(schemas)
+		}
+		if s.Name != "" {
+			op["operationId"] = s.Name
+		}
+		if s.Summary != "" {
+			op["summary"] = s.Summary
+		}
+		if len(s.Tags) > 0 {
+			op["tags"] = s.Tags
+		}
+		if s.Auth != nil {
+			op["security"] = []interface{}{map[string]interface{}{"default": []string{}}}
+		}
+		op["responses"] = map[string]interface{}{"default": map[string]interface{}{"description": "Response"}}
+
+		p := bind.OpenAPIPath(s.FullPath)
+		item, ok := paths[p].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[p] = item
+		}
+		item[strings.ToLower(s.Method)] = op
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.Components()},
+	}
+}
+
+// Handler returns a handler serving the OpenAPI document as JSON
+func (t Table) Handler(title, version string) gin.HandlerFunc {
+	doc := t.OpenAPI(title, version)
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, doc)
+	}
+}
+
+func basePath(r gin.IRouter) string {
+	if g, ok := r.(interface{ BasePath() string }); ok {
+		return g.BasePath()
+	}
+	return "/"
+}
+
+func joinPath(a, b string) string {
+	if b == "" {
+		return a
+	}
+	res := strings.TrimSuffix(a, "/") + "/" + strings.TrimPrefix(b, "/")
+	if strings.HasSuffix(b, "/") && !strings.HasSuffix(res, "/") {
+		res += "/"
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package routerdsl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/policyaudit"
+	"github.com/stretchr/testify/assert"
+)
+
+type userParams struct {
+	ID     int    `uri:"id"`
+	Fields string `form:"fields" binding:"required"`
+}
+
+func requireAuth(ctx *gin.Context) {
+	if ctx.GetHeader("Authorization") == "" {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+func limit(ctx *gin.Context) {}
+
+func setup(t *testing.T) (*gin.Engine, Table) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+
+	table, err := Mount(e.Group("/v1"), Group{
+		Path:      "/api",
+		Auth:      requireAuth,
+		RateLimit: limit,
+		Routes: []Route{
+			{Method: "get", Path: "/users/:id", Name: "getUser", Tags: []string{"users"}, Bind: userParams{}, Handler: func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, ctx.MustGet("body").(*userParams).Fields)
+			}},
+			{Method: "POST", Path: "/login", Public: true, Handler: func(ctx *gin.Context) {
+				ctx.Status(http.StatusNoContent)
+			}},
+		},
+		Groups: []Group{{
+			Path:   "/admin",
+			Routes: []Route{{Method: "DELETE", Path: "/cache", Handler: func(ctx *gin.Context) {}}},
+		}},
+	})
+	assert.NoError(t, err)
+	return e, table
+}
+
+func TestMount(t *testing.T) {
+	e, table := setup(t)
+
+	assert.Len(t, table, 3)
+	assert.Equal(t, "/v1/api/users/:id", table[0].FullPath)
+	assert.Equal(t, "GET", table[0].Method)
+	assert.Equal(t, "/v1/api/admin/cache", table[2].FullPath)
+	assert.NotNil(t, table[2].Auth)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/api/users/42?fields=name", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "x")
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "name", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/api/login", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+
+	unauth := table.Unauthenticated()
+	assert.Len(t, unauth, 1)
+	assert.Equal(t, "/v1/api/login", unauth[0].FullPath)
+}
+
+func TestMountInvalid(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+
+	_, err := Mount(e, Group{Routes: []Route{{Method: "GET", Path: "/"}}})
+	assert.Error(t, err)
+
+	_, err = Mount(e, Group{Routes: []Route{{Method: "GET", Path: "/", Bind: &userParams{}, Handler: limit}}})
+	assert.Error(t, err)
+
+	// Non-struct bind targets are rejected rather than panicking in bind.To
+	for _, target := range []interface{}{"body", 1, []userParams{}, map[string]string{}} {
+		_, err = Mount(e, Group{Routes: []Route{
+			{Method: "GET", Path: "/ok", Handler: limit},
+			{Method: "POST", Path: "/", Bind: target, Handler: limit},
+		}})
+		assert.Error(t, err)
+	}
+	assert.Empty(t, e.Routes())
+}
+
+func TestAudit(t *testing.T) {
+	_, table := setup(t)
+
+	v := policyaudit.CheckRoutes(table.Audit(), policyaudit.Rule{
+		Name:       "auth",
+		Middleware: []string{"github.com/redmapletech/ginx/routerdsl.requireAuth"},
+	})
+	assert.Equal(t, []policyaudit.Violation{{Method: "POST", Path: "/v1/api/login", Rule: "auth"}}, v)
+}
+
+func TestOpenAPI(t *testing.T) {
+	_, table := setup(t)
+
+	doc := table.OpenAPI("Test", "1.0")
+	paths := doc["paths"].(map[string]interface{})
+	op := paths["/v1/api/users/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+
+	assert.Equal(t, "getUser", op["operationId"])
+	assert.Equal(t, []string{"users"}, op["tags"])
+	assert.NotNil(t, op["security"])
+	assert.Len(t, op["parameters"], 2)
+
+	login := paths["/v1/api/login"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Nil(t, login["security"])
+	assert.Equal(t, "/v1/api/login", table[1].FullPath)
+}
@@ -0,0 +1,383 @@
+// HTML sanitisation
+//
+// Allowlist based sanitisation of rich text input, driven by struct tags on bound request types.
+// Intended to be used directly after a bind middleware, so that any string fields tagged with
+// `sanitize:"<policy>"` are cleaned before reaching the handler:
+//
+//	type Comment struct {
+//		Body string `json:"body" sanitize:"basic"`
+//	}
+//
+//	e.POST("/comments", bind.To(Comment{}), sanitizehtml.Bound(), handler)
+//
+// Three policies are registered by default:
+//   - strict: all markup is removed, text content is kept and escaped
+//   - basic: simple inline and block formatting (b, i, em, strong, p, lists, etc.)
+//   - full: basic plus links, headings, images and tables
+//
+// Additional policies can be registered with RegisterPolicy.
+package sanitizehtml
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/html"
+)
+
+const tagName = "sanitize"
+
+var (
+	defaultKey = "body"
+
+	policiesMu sync.RWMutex
+	policies   = map[string]*Policy{
+		"strict": Strict,
+		"basic":  Basic,
+		"full":   Full,
+	}
+)
+
+// Elements whose content is dropped entirely rather than unwrapped
+var dropContent = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"template": true,
+	"textarea": true,
+	"select":   true,
+	"svg":      true,
+	"math":     true,
+}
+
+// Attributes containing URLs, which are checked against the policy schemes
+var urlAttrs = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+// Policy describes the elements and attributes allowed through sanitisation
+type Policy struct {
+	elements map[string]map[string]bool // Allowed element -> allowed attributes
+	schemes  map[string]bool            // Allowed URL schemes for URL attributes
+}
+
+// NewPolicy creates an empty policy which strips all markup
+func NewPolicy() *Policy {
+	return &Policy{
+		elements: map[string]map[string]bool{},
+		schemes: map[string]bool{
+			"http":   true,
+			"https":  true,
+			"mailto": true,
+		},
+	}
+}
+
+// AllowElements allows the given elements, without any attributes
+func (p *Policy) AllowElements(names ...string) *Policy {
+	for _, n := range names {
+		n = strings.ToLower(n)
+		if _, ok := p.elements[n]; !ok {
+			p.elements[n] = map[string]bool{}
+		}
+	}
+	return p
+}
+
+// AllowAttrs allows the given attributes on an element, implicitly allowing the element
+func (p *Policy) AllowAttrs(element string, attrs ...string) *Policy {
+	p.AllowElements(element)
+	element = strings.ToLower(element)
+	for _, a := range attrs {
+		p.elements[element][strings.ToLower(a)] = true
+	}
+	return p
+}
+
+// AllowSchemes replaces the set of URL schemes permitted in href/src attributes
+func (p *Policy) AllowSchemes(schemes ...string) *Policy {
+	p.schemes = map[string]bool{}
+	for _, s := range schemes {
+		p.schemes[strings.ToLower(s)] = true
+	}
+	return p
+}
+
+// Strict removes all markup
+var Strict = NewPolicy()
+
+// Basic allows simple text formatting
+var Basic = NewPolicy().AllowElements(
+	"b", "i", "u", "s", "em", "strong", "small", "sub", "sup", "mark",
+	"p", "br", "hr", "blockquote", "code", "pre", "ul", "ol", "li",
+)
+
+// Full allows rich content including links, headings, images and tables
+var Full = NewPolicy().AllowElements(
+	"b", "i", "u", "s", "em", "strong", "small", "sub", "sup", "mark",
+	"p", "br", "hr", "blockquote", "code", "pre", "ul", "ol", "li",
+	"h1", "h2", "h3", "h4", "h5", "h6", "span", "div",
+	"table", "thead", "tbody", "tfoot", "tr", "th", "td", "caption",
+	"dl", "dt", "dd", "figure", "figcaption",
+).
+	AllowAttrs("a", "href", "title").
+	AllowAttrs("img", "src", "alt", "title", "width", "height").
+	AllowAttrs("th", "colspan", "rowspan").
+	AllowAttrs("td", "colspan", "rowspan")
+
+// Sanitize returns the input with all disallowed markup removed and text content escaped
+func (p *Policy) Sanitize(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	// Depth inside an element whose content is being dropped
+	skip := 0
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF or malformed input, either way stop here
+			return b.String()
+		}
+
+		tok := z.Token()
+		name := strings.ToLower(tok.Data)
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if dropContent[name] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if attrs, ok := p.elements[name]; ok {
+				p.writeTag(&b, name, tok.Attr, attrs, tt == html.SelfClosingTagToken)
+			}
+		case html.EndTagToken:
+			if dropContent[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if _, ok := p.elements[name]; ok {
+				b.WriteString("</" + name + ">")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		}
+		// Comments and doctypes are always dropped
+	}
+}
+
+func (p *Policy) writeTag(b *strings.Builder, name string, attrs []html.Attribute, allowed map[string]bool, selfClosing bool) {
+	b.WriteString("<" + name)
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !allowed[key] {
+			continue
+		}
+		if urlAttrs[key] && !p.safeURL(a.Val) {
+			continue
+		}
+		b.WriteString(" " + key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if selfClosing {
+		b.WriteString(" /")
+	}
+	b.WriteString(">")
+}
+
+func (p *Policy) safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	// Relative URLs are allowed, protocol-relative URLs only if https is
+	if u.Scheme == "" {
+		return !strings.HasPrefix(strings.TrimSpace(raw), "//") || p.schemes["https"]
+	}
+	return p.schemes[strings.ToLower(u.Scheme)]
+}
+
+// RegisterPolicy registers a named policy for use in sanitize struct tags
+func RegisterPolicy(name string, p *Policy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	policies[name] = p
+}
+
+// GetPolicy returns a registered policy by name
+func GetPolicy(name string) (*Policy, bool) {
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	p, ok := policies[name]
+	return p, ok
+}
+
+// Struct sanitises all tagged string fields of the struct pointed to by v in place.
+// Nested structs, pointers, slices and maps of strings are followed. Fields with an unknown policy are reported in
+// the error, after all other fields have been sanitised.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("sanitizehtml: expected a non-nil pointer, received %T", v)
+	}
+	return walk(rv.Elem(), nil)
+}
+
+func walk(v reflect.Value, p *Policy) error {
+	var first error
+	keep := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			return walk(v.Elem(), p)
+		}
+	case reflect.String:
+		if p != nil && v.CanSet() {
+			v.SetString(p.Sanitize(v.String()))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			keep(walk(v.Index(i), p))
+		}
+	case reflect.Map:
+		if p == nil || v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(p.Sanitize(iter.Value().String())).Convert(v.Type().Elem()))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fp := p
+			if tag, ok := f.Tag.Lookup(tagName); ok {
+				if tag == "-" {
+					continue
+				}
+				policy, ok := GetPolicy(tag)
+				if !ok {
+					keep(fmt.Errorf("sanitizehtml: unknown policy %q on field %s", tag, f.Name))
+					// Sanitise as strictly as possible rather than leaving the field raw
+					policy = Strict
+				}
+				fp = policy
+			}
+			keep(walk(v.Field(i), fp))
+		}
+	}
+	return first
+}
+
+// Types whose policy tags have been checked by Bound
+var checkedTypes sync.Map
+
+// Check returns an error if any sanitize tag of the type, or of types nested within it, names an unregistered policy
+func Check(t reflect.Type) error {
+	return check(t, map[reflect.Type]bool{})
+}
+
+func check(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup(tagName); ok && tag != "-" {
+			if _, ok := GetPolicy(tag); !ok {
+				return fmt.Errorf("sanitizehtml: unknown policy %q on field %s.%s", tag, t.Name(), f.Name)
+			}
+		}
+		if err := check(f.Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sanitizeOpts struct {
+	key string // Context key of the bound struct
+}
+
+// Modifier function for customising sanitise handler behaviour
+type SanitizeOpts func(*sanitizeOpts) *sanitizeOpts
+
+// Bound sanitises the struct previously attached to the context by a bind middleware.
+// The policy tags of each bound type are checked when it is first seen, panicking if a policy isn't registered, as
+// this is a programming error. Any other sanitisation error aborts the request with 500.
+func Bound(opts ...SanitizeOpts) gin.HandlerFunc {
+	so := &sanitizeOpts{
+		key: defaultKey,
+	}
+	for _, f := range opts {
+		so = f(so)
+	}
+
+	return func(ctx *gin.Context) {
+		target, ok := ctx.Get(so.key)
+		if !ok {
+			return
+		}
+		t := reflect.TypeOf(target)
+		if _, ok := checkedTypes.Load(t); !ok {
+			if err := Check(t); err != nil {
+				panic(err)
+			}
+			checkedTypes.Store(t, true)
+		}
+		if err := Struct(target); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// WithKey sets the context key of the bound struct to sanitise
+func WithKey(key string) SanitizeOpts {
+	return func(so *sanitizeOpts) *sanitizeOpts {
+		so.key = key
+		return so
+	}
+}
+
+// SetDefaultKey sets the default context key of the bound struct for all handlers
+func SetDefaultKey(key string) {
+	defaultKey = key
+}
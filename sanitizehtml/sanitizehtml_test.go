@@ -0,0 +1,132 @@
+package sanitizehtml
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeStrict(t *testing.T) {
+	res := Strict.Sanitize(`<p>Hello <b>world</b></p><script>alert(1)</script>`)
+
+	assert.Equal(t, "Hello world", res)
+}
+
+func TestSanitizeBasic(t *testing.T) {
+	res := Basic.Sanitize(`<p onclick="x()">Hello <b>world</b> <a href="http://x">link</a></p>`)
+
+	assert.Equal(t, "<p>Hello <b>world</b> link</p>", res)
+}
+
+func TestSanitizeFullLinks(t *testing.T) {
+	res := Full.Sanitize(`<a href="https://example.com" onmouseover="x()">ok</a><a href="javascript:alert(1)">bad</a>`)
+
+	assert.Equal(t, `<a href="https://example.com">ok</a><a>bad</a>`, res)
+}
+
+func TestSanitizeEscapesText(t *testing.T) {
+	res := Strict.Sanitize(`1 &lt; 2 & <img src=x onerror=alert(1)>`)
+
+	assert.Equal(t, "1 &lt; 2 &amp; ", res)
+}
+
+func TestStruct(t *testing.T) {
+	type nested struct {
+		Note string `sanitize:"strict"`
+	}
+	type body struct {
+		Title   string   `sanitize:"strict"`
+		Content string   `sanitize:"basic"`
+		Tags    []string `sanitize:"strict"`
+		Raw     string
+		Nested  *nested
+	}
+
+	b := &body{
+		Title:   "<h1>Title</h1>",
+		Content: "<p><i>text</i><iframe src=x></iframe></p>",
+		Tags:    []string{"<b>a</b>", "b"},
+		Raw:     "<b>raw</b>",
+		Nested:  &nested{Note: "<u>note</u>"},
+	}
+
+	assert.NoError(t, Struct(b))
+	assert.Equal(t, "Title", b.Title)
+	assert.Equal(t, "<p><i>text</i></p>", b.Content)
+	assert.Equal(t, []string{"a", "b"}, b.Tags)
+	assert.Equal(t, "<b>raw</b>", b.Raw)
+	assert.Equal(t, "note", b.Nested.Note)
+}
+
+func TestStructUnknownPolicy(t *testing.T) {
+	type body struct {
+		Title string `sanitize:"unknown"`
+	}
+
+	assert.Error(t, Struct(&body{}))
+	assert.Error(t, Struct(body{}))
+}
+
+func TestStructUnknownPolicySanitisesOtherFields(t *testing.T) {
+	type body struct {
+		Title   string `sanitize:"unknown"`
+		Content string `sanitize:"basic"`
+	}
+
+	b := &body{Title: "<script>x</script>t", Content: "<em>hi</em><script>x</script>"}
+	assert.Error(t, Struct(b))
+	assert.Equal(t, "t", b.Title)
+	assert.Equal(t, "<em>hi</em>", b.Content)
+}
+
+func TestBoundUnknownPolicy(t *testing.T) {
+	type inner struct {
+		Note string `sanitize:"missing"`
+	}
+	type body struct {
+		Content string `sanitize:"basic"`
+		Inner   []inner
+	}
+
+	assert.Error(t, Check(reflect.TypeOf(&body{})))
+
+	reached := false
+	e := gin.New()
+	e.GET("", func(ctx *gin.Context) {
+		ctx.Set("body", &body{Content: "<script>x</script>"})
+	}, Bound(), func(ctx *gin.Context) {
+		reached = true
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.PanicsWithError(t, `sanitizehtml: unknown policy "missing" on field inner.Note`, func() {
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	assert.False(t, reached)
+}
+
+func TestBound(t *testing.T) {
+	type body struct {
+		Content string `sanitize:"basic"`
+	}
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.GET("", func(ctx *gin.Context) {
+		ctx.Set("body", &body{Content: `<em>hi</em><script>x</script>`})
+	}, Bound(), func(ctx *gin.Context) {
+		b := ctx.MustGet("body").(*body)
+		assert.Equal(t, "<em>hi</em>", b.Content)
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
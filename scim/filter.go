@@ -0,0 +1,400 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	// Match evaluates the filter against a resource decoded into a generic JSON map
+	Match(resource map[string]interface{}) bool
+}
+
+// AttrExpr compares an attribute against a value, e.g. userName eq "bjensen".
+// Value is nil for the "pr" (present) operator.
+type AttrExpr struct {
+	Path  string
+	Op    string // Lower case operator: eq, ne, co, sw, ew, pr, gt, ge, lt, le
+	Value interface{}
+}
+
+// LogicalExpr combines two filters with "and" or "or"
+type LogicalExpr struct {
+	Op          string
+	Left, Right Filter
+}
+
+// NotExpr negates a filter
+type NotExpr struct {
+	Filter Filter
+}
+
+// ValuePathExpr filters the elements of a multi-valued attribute, e.g. emails[type eq "work"]
+type ValuePathExpr struct {
+	Path   string
+	Filter Filter
+}
+
+// ParseFilter parses a SCIM filter expression
+func ParseFilter(s string) (Filter, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("scim: unexpected %q in filter", p.toks[p.pos].text)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("()[]", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c)})
+			i++
+		case c == '"':
+			// JSON string, find the closing quote honouring escapes
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("scim: unterminated string in filter")
+			}
+			toks = append(toks, token{tokString, s[i : j+1]})
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t()[]\"", s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peekKeyword(kw string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokIdent && strings.EqualFold(p.toks[p.pos].text, kw)
+}
+
+func (p *parser) expect(punct string) error {
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokPunct || p.toks[p.pos].text != punct {
+		return fmt.Errorf("scim: expected %q in filter", punct)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Filter, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("scim: unexpected end of filter")
+	}
+
+	if p.peekKeyword("not") {
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Filter: f}, p.expect(")")
+	}
+
+	tok := p.toks[p.pos]
+	if tok.kind == tokPunct && tok.text == "(" {
+		p.pos++
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+
+	if tok.kind != tokIdent {
+		return nil, fmt.Errorf("scim: expected attribute path, found %q", tok.text)
+	}
+	p.pos++
+	path := tok.text
+
+	// Value path, e.g. emails[type eq "work"]
+	if p.pos < len(p.toks) && p.toks[p.pos].text == "[" {
+		p.pos++
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return &ValuePathExpr{Path: path, Filter: f}, p.expect("]")
+	}
+
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokIdent {
+		return nil, fmt.Errorf("scim: expected operator after %q", path)
+	}
+	op := strings.ToLower(p.toks[p.pos].text)
+	p.pos++
+
+	switch op {
+	case "pr":
+		return &AttrExpr{Path: path, Op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("scim: unknown operator %q", op)
+	}
+
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("scim: expected value after %q", op)
+	}
+	v, err := parseValue(p.toks[p.pos])
+	if err != nil {
+		return nil, err
+	}
+	p.pos++
+	return &AttrExpr{Path: path, Op: op, Value: v}, nil
+}
+
+func parseValue(t token) (interface{}, error) {
+	switch t.kind {
+	case tokString:
+		var s string
+		if err := json.Unmarshal([]byte(t.text), &s); err != nil {
+			return nil, fmt.Errorf("scim: invalid string %s in filter", t.text)
+		}
+		return s, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("scim: invalid number %s in filter", t.text)
+		}
+		return n, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("scim: invalid value %q in filter", t.text)
+}
+
+// Match implements Filter. Multi-valued attributes match if any value matches.
+func (e *AttrExpr) Match(resource map[string]interface{}) bool {
+	values := resolve(resource, e.Path)
+	if e.Op == "pr" {
+		for _, v := range values {
+			if v != nil && v != "" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range values {
+		if compare(v, e.Op, e.Value) {
+			return true
+		}
+	}
+	// ne matches absent attributes
+	return len(values) == 0 && e.Op == "ne"
+}
+
+// Match implements Filter
+func (e *LogicalExpr) Match(resource map[string]interface{}) bool {
+	if e.Op == "and" {
+		return e.Left.Match(resource) && e.Right.Match(resource)
+	}
+	return e.Left.Match(resource) || e.Right.Match(resource)
+}
+
+// Match implements Filter
+func (e *NotExpr) Match(resource map[string]interface{}) bool {
+	return !e.Filter.Match(resource)
+}
+
+// Match implements Filter
+func (e *ValuePathExpr) Match(resource map[string]interface{}) bool {
+	for _, v := range resolve(resource, e.Path) {
+		if m, ok := v.(map[string]interface{}); ok && e.Filter.Match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve a dotted attribute path case insensitively, flattening multi-valued attributes
+func resolve(resource map[string]interface{}, path string) []interface{} {
+	// Strip any schema URN prefix, e.g. urn:ietf:params:scim:schemas:core:2.0:User:userName
+	if i := strings.LastIndexByte(path, ':'); i >= 0 {
+		path = path[i+1:]
+	}
+
+	current := []interface{}{resource}
+	for _, name := range strings.Split(path, ".") {
+		var next []interface{}
+		for _, c := range current {
+			m, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			v, ok := m[lookupKey(m, name)]
+			if !ok {
+				continue
+			}
+			if arr, ok := v.([]interface{}); ok {
+				next = append(next, arr...)
+			} else {
+				next = append(next, v)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func lookupKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+func compare(actual interface{}, op string, expected interface{}) bool {
+	switch a := actual.(type) {
+	case string:
+		e, ok := expected.(string)
+		if !ok {
+			return false
+		}
+		// Timestamps compare chronologically, other strings case insensitively
+		if at, err := time.Parse(time.RFC3339, a); err == nil {
+			if et, err := time.Parse(time.RFC3339, e); err == nil {
+				return compareOrdered(at.UnixNano(), op, et.UnixNano())
+			}
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		}
+		return compareOrdered(a, op, e)
+	case float64:
+		e, ok := expected.(float64)
+		return ok && compareOrdered(a, op, e)
+	case bool:
+		e, ok := expected.(bool)
+		switch op {
+		case "eq":
+			return ok && a == e
+		case "ne":
+			return !ok || a != e
+		}
+	case nil:
+		return (op == "eq" && expected == nil) || (op == "ne" && expected != nil)
+	}
+	return false
+}
+
+func compareOrdered[T string | float64 | int64](a T, op string, e T) bool {
+	switch op {
+	case "eq":
+		return a == e
+	case "ne":
+		return a != e
+	case "gt":
+		return a > e
+	case "ge":
+		return a >= e
+	case "lt":
+		return a < e
+	case "le":
+		return a <= e
+	}
+	return false
+}
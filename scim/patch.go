@@ -0,0 +1,180 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PatchOp is a single operation of a PATCH request (RFC 7644 section 3.5.2)
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchRequest is the body of a PATCH request
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// Validate checks the request schema and that each operation is well formed
+func (r *PatchRequest) Validate() error {
+	if !contains(r.Schemas, SchemaPatchOp) {
+		return fmt.Errorf("scim: missing schema %s", SchemaPatchOp)
+	}
+	if len(r.Operations) == 0 {
+		return fmt.Errorf("scim: no operations")
+	}
+	for i, op := range r.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if len(op.Value) == 0 {
+				return fmt.Errorf("scim: operation %d requires a value", i)
+			}
+		case "remove":
+			if op.Path == "" {
+				return fmt.Errorf("scim: operation %d requires a path", i)
+			}
+		default:
+			return fmt.Errorf("scim: operation %d has invalid op %q", i, op.Op)
+		}
+	}
+	return nil
+}
+
+// ApplyPatch applies operations to a resource decoded into a generic JSON map.
+// Providers can use it by marshalling the stored resource to a map, patching, and unmarshalling back.
+//
+// Supported paths are attributes ("active"), sub-attributes ("name.givenName") and value filters on
+// multi-valued attributes, optionally followed by a sub-attribute ("emails[type eq \"work\"].value").
+func ApplyPatch(resource map[string]interface{}, ops []PatchOp) error {
+	for _, op := range ops {
+		var value interface{}
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return fmt.Errorf("scim: invalid value for %s: %w", op.Path, err)
+			}
+		}
+		if err := applyOp(resource, strings.ToLower(op.Op), op.Path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOp(resource map[string]interface{}, op, path string, value interface{}) error {
+	if path == "" {
+		// No path, value is a partial resource merged into the target
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("scim: %s without path requires an object value", op)
+		}
+		for k, v := range m {
+			if err := applyOp(resource, op, k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Strip any schema URN prefix
+	if i := strings.LastIndexByte(path, ':'); i >= 0 && !strings.Contains(path[i:], "[") {
+		path = path[i+1:]
+	}
+
+	// Value filter on a multi-valued attribute
+	if open := strings.IndexByte(path, '['); open >= 0 {
+		end := strings.LastIndexByte(path, ']')
+		if end < open {
+			return fmt.Errorf("scim: invalid path %q", path)
+		}
+		filter, err := ParseFilter(path[open+1 : end])
+		if err != nil {
+			return err
+		}
+		sub := strings.TrimPrefix(path[end+1:], ".")
+		return applyFiltered(resource, op, path[:open], filter, sub, value)
+	}
+
+	parent, name := resource, path
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		key := lookupKey(resource, path[:i])
+		child, ok := resource[key].(map[string]interface{})
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			child = map[string]interface{}{}
+			resource[key] = child
+		}
+		parent, name = child, path[i+1:]
+	}
+	key := lookupKey(parent, name)
+
+	switch op {
+	case "remove":
+		delete(parent, key)
+	case "add":
+		// Adding to a multi-valued attribute appends
+		if existing, ok := parent[key].([]interface{}); ok {
+			if add, ok := value.([]interface{}); ok {
+				parent[key] = append(existing, add...)
+				return nil
+			}
+		}
+		parent[key] = value
+	case "replace":
+		parent[key] = value
+	}
+	return nil
+}
+
+func applyFiltered(resource map[string]interface{}, op, attr string, filter Filter, sub string, value interface{}) error {
+	key := lookupKey(resource, attr)
+	values, _ := resource[key].([]interface{})
+
+	kept := values[:0:0]
+	matched := false
+	for _, v := range values {
+		m, ok := v.(map[string]interface{})
+		if !ok || !filter.Match(m) {
+			kept = append(kept, v)
+			continue
+		}
+		matched = true
+
+		switch {
+		case op == "remove" && sub == "":
+			// Drop the element
+			continue
+		case op == "remove":
+			delete(m, lookupKey(m, sub))
+		case sub == "":
+			if nm, ok := value.(map[string]interface{}); ok {
+				for k, nv := range nm {
+					m[lookupKey(m, k)] = nv
+				}
+			}
+		default:
+			m[lookupKey(m, sub)] = value
+		}
+		kept = append(kept, m)
+	}
+
+	if !matched && op != "remove" {
+		return fmt.Errorf("scim: no values match filter on %s", attr)
+	}
+	resource[key] = kept
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
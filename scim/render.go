@@ -0,0 +1,20 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// scimJSON renders JSON with the SCIM media type
+type scimJSON struct {
+	data interface{}
+}
+
+func (r scimJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+func (r scimJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+}
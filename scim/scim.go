@@ -0,0 +1,376 @@
+// SCIM 2.0 provisioning
+//
+// Mountable handlers implementing the SCIM 2.0 protocol (RFC 7643/7644) for users and groups, so identity
+// providers such as Okta and Azure AD can provision identities into a service.
+//
+// Storage is delegated to a Provider per resource type. Requests are parsed and validated by the handlers,
+// including filter expressions and PATCH operations; ApplyPatch and Filter.Match are available to providers
+// which don't translate these natively into their storage queries.
+//
+//	scim.Mount(e.Group("/scim/v2", authMiddleware), userProvider, groupProvider)
+//
+// Errors are returned in the SCIM error format rather than the errors package format, as required by clients.
+package scim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Schema URNs
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the SCIM media type
+const ContentType = "application/scim+json"
+
+var (
+	defaultCount = 100
+	maxCount     = 1000
+)
+
+// Errors returned by providers, mapped to the corresponding SCIM error responses
+var (
+	ErrNotFound   = fmt.Errorf("scim: resource not found")
+	ErrConflict   = fmt.Errorf("scim: resource already exists")
+	ErrInvalid    = fmt.Errorf("scim: invalid value")
+	ErrMutability = fmt.Errorf("scim: attribute is immutable")
+)
+
+// Meta is the common resource metadata
+type Meta struct {
+	ResourceType string     `json:"resourceType,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+// Name is the components of a user's name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue is an element of a multi-valued attribute such as emails
+type MultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Ref is a reference to another resource, e.g. a group member
+type Ref struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// User is the core SCIM user resource
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName" binding:"required"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Groups      []Ref        `json:"groups,omitempty"` // Read only, maintained via group membership
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// Group is the core SCIM group resource
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName" binding:"required"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Query holds the parameters of a list request
+type Query struct {
+	Filter     Filter // Nil if no filter was given
+	StartIndex int    // 1-based index of the first result
+	Count      int    // Maximum results to return
+	SortBy     string
+	Descending bool
+}
+
+// Provider stores resources of a single type
+type Provider[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	// List returns the page of resources matching the query and the total number of matches
+	List(ctx context.Context, q Query) ([]*T, int, error)
+	Create(ctx context.Context, r *T) (*T, error)
+	Replace(ctx context.Context, id string, r *T) (*T, error)
+	Patch(ctx context.Context, id string, ops []PatchOp) (*T, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// ListResponse is the body of a list request
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []*T     `json:"Resources"`
+}
+
+// Mount registers the SCIM endpoints on the router. Either provider may be nil to omit that resource type.
+func Mount(r gin.IRouter, users Provider[User], groups Provider[Group]) {
+	if users != nil {
+		mountResource(r.Group("/Users"), "User", users)
+	}
+	if groups != nil {
+		mountResource(r.Group("/Groups"), "Group", groups)
+	}
+	r.GET("/ServiceProviderConfig", serviceProviderConfig)
+}
+
+func mountResource[T any](r gin.IRouter, resourceType string, p Provider[T]) {
+	h := &handlers[T]{resourceType: resourceType, p: p}
+	r.GET("", h.list)
+	r.POST("", h.create)
+	r.GET("/:id", h.get)
+	r.PUT("/:id", h.replace)
+	r.PATCH("/:id", h.patch)
+	r.DELETE("/:id", h.delete)
+}
+
+type handlers[T any] struct {
+	resourceType string
+	p            Provider[T]
+}
+
+func (h *handlers[T]) list(ctx *gin.Context) {
+	q := Query{
+		StartIndex: 1,
+		Count:      defaultCount,
+		SortBy:     ctx.Query("sortBy"),
+		Descending: ctx.Query("sortOrder") == "descending",
+	}
+
+	if f := ctx.Query("filter"); f != "" {
+		filter, err := ParseFilter(f)
+		if err != nil {
+			abort(ctx, http.StatusBadRequest, "invalidFilter", err)
+			return
+		}
+		q.Filter = filter
+	}
+	if s := ctx.Query("startIndex"); s != "" {
+		// Values less than 1 are interpreted as 1
+		if n, err := strconv.Atoi(s); err == nil && n > 1 {
+			q.StartIndex = n
+		}
+	}
+	if s := ctx.Query("count"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			q.Count = n
+		}
+	}
+	if q.Count > maxCount {
+		q.Count = maxCount
+	}
+
+	res, total, err := h.p.List(ctx, q)
+	if err != nil {
+		abortProvider(ctx, err)
+		return
+	}
+	for _, r := range res {
+		h.decorate(ctx, r)
+	}
+	if res == nil {
+		res = []*T{}
+	}
+	write(ctx, http.StatusOK, &ListResponse[T]{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   q.StartIndex,
+		ItemsPerPage: len(res),
+		Resources:    res,
+	})
+}
+
+func (h *handlers[T]) get(ctx *gin.Context) {
+	res, err := h.p.Get(ctx, ctx.Param("id"))
+	h.respond(ctx, http.StatusOK, res, err)
+}
+
+func (h *handlers[T]) create(ctx *gin.Context) {
+	r := new(T)
+	if err := ctx.ShouldBindJSON(r); err != nil {
+		abort(ctx, http.StatusBadRequest, "invalidSyntax", err)
+		return
+	}
+	res, err := h.p.Create(ctx, r)
+	if err == nil {
+		h.decorate(ctx, res)
+		ctx.Header("Location", location(res))
+	}
+	h.respond(ctx, http.StatusCreated, res, err)
+}
+
+func (h *handlers[T]) replace(ctx *gin.Context) {
+	r := new(T)
+	if err := ctx.ShouldBindJSON(r); err != nil {
+		abort(ctx, http.StatusBadRequest, "invalidSyntax", err)
+		return
+	}
+	res, err := h.p.Replace(ctx, ctx.Param("id"), r)
+	h.respond(ctx, http.StatusOK, res, err)
+}
+
+func (h *handlers[T]) patch(ctx *gin.Context) {
+	req := &PatchRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		abort(ctx, http.StatusBadRequest, "invalidSyntax", err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		abort(ctx, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+	res, err := h.p.Patch(ctx, ctx.Param("id"), req.Operations)
+	h.respond(ctx, http.StatusOK, res, err)
+}
+
+func (h *handlers[T]) delete(ctx *gin.Context) {
+	if err := h.p.Delete(ctx, ctx.Param("id")); err != nil {
+		abortProvider(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *handlers[T]) respond(ctx *gin.Context, status int, res *T, err error) {
+	if err != nil {
+		abortProvider(ctx, err)
+		return
+	}
+	h.decorate(ctx, res)
+	write(ctx, status, res)
+}
+
+// Fill in schemas and meta attributes providers may have left empty
+func (h *handlers[T]) decorate(ctx *gin.Context, res *T) {
+	base := baseURL(ctx)
+	switch r := any(res).(type) {
+	case *User:
+		if len(r.Schemas) == 0 {
+			r.Schemas = []string{SchemaUser}
+		}
+		if r.Meta == nil {
+			r.Meta = &Meta{}
+		}
+		r.Meta.ResourceType = h.resourceType
+		if r.Meta.Location == "" {
+			r.Meta.Location = base + "/" + r.ID
+		}
+	case *Group:
+		if len(r.Schemas) == 0 {
+			r.Schemas = []string{SchemaGroup}
+		}
+		if r.Meta == nil {
+			r.Meta = &Meta{}
+		}
+		r.Meta.ResourceType = h.resourceType
+		if r.Meta.Location == "" {
+			r.Meta.Location = base + "/" + r.ID
+		}
+	}
+}
+
+func location(res interface{}) string {
+	switch r := res.(type) {
+	case *User:
+		return r.Meta.Location
+	case *Group:
+		return r.Meta.Location
+	}
+	return ""
+}
+
+// Collection URL of the current request, without any resource ID
+func baseURL(ctx *gin.Context) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	path := ctx.Request.URL.Path
+	if id := ctx.Param("id"); id != "" {
+		path = strings.TrimSuffix(path, "/"+id)
+	}
+	return scheme + "://" + ctx.Request.Host + strings.TrimSuffix(path, "/")
+}
+
+func serviceProviderConfig(ctx *gin.Context) {
+	write(ctx, http.StatusOK, gin.H{
+		"schemas":        []string{SchemaSPConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": maxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": true},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication using a bearer token",
+		}},
+	})
+}
+
+func write(ctx *gin.Context, status int, body interface{}) {
+	ctx.Render(status, scimJSON{body})
+}
+
+func abortProvider(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		abort(ctx, http.StatusNotFound, "", err)
+	case errors.Is(err, ErrConflict):
+		abort(ctx, http.StatusConflict, "uniqueness", err)
+	case errors.Is(err, ErrInvalid):
+		abort(ctx, http.StatusBadRequest, "invalidValue", err)
+	case errors.Is(err, ErrMutability):
+		abort(ctx, http.StatusBadRequest, "mutability", err)
+	default:
+		zlog.GetLogger(ctx).Error().Err(err).Msg("scim provider error")
+		abort(ctx, http.StatusInternalServerError, "", fmt.Errorf("internal error"))
+	}
+}
+
+func abort(ctx *gin.Context, status int, scimType string, err error) {
+	body := gin.H{
+		"schemas": []string{SchemaError},
+		"status":  strconv.Itoa(status),
+		"detail":  err.Error(),
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	ctx.Abort()
+	write(ctx, status, body)
+}
@@ -0,0 +1,257 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type memUsers struct {
+	mu    sync.Mutex
+	next  int
+	users map[string]*User
+}
+
+func (m *memUsers) Get(_ context.Context, id string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *u
+	return &c, nil
+}
+
+func (m *memUsers) List(_ context.Context, q Query) ([]*User, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []*User
+	for i := 1; i <= m.next; i++ {
+		u, ok := m.users[strconv.Itoa(i)]
+		if !ok {
+			continue
+		}
+		if q.Filter != nil && !q.Filter.Match(toMap(u)) {
+			continue
+		}
+		c := *u
+		matched = append(matched, &c)
+	}
+	total := len(matched)
+	start := q.StartIndex - 1
+	if start > len(matched) {
+		start = len(matched)
+	}
+	matched = matched[start:]
+	if len(matched) > q.Count {
+		matched = matched[:q.Count]
+	}
+	return matched, total, nil
+}
+
+func (m *memUsers) Create(_ context.Context, u *User) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.users {
+		if e.UserName == u.UserName {
+			return nil, ErrConflict
+		}
+	}
+	m.next++
+	u.ID = strconv.Itoa(m.next)
+	m.users[u.ID] = u
+	c := *u
+	return &c, nil
+}
+
+func (m *memUsers) Replace(_ context.Context, id string, u *User) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[id]; !ok {
+		return nil, ErrNotFound
+	}
+	u.ID = id
+	m.users[id] = u
+	c := *u
+	return &c, nil
+}
+
+func (m *memUsers) Patch(_ context.Context, id string, ops []PatchOp) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	res := toMap(u)
+	if err := ApplyPatch(res, ops); err != nil {
+		return nil, ErrInvalid
+	}
+	b, _ := json.Marshal(res)
+	patched := &User{}
+	if err := json.Unmarshal(b, patched); err != nil {
+		return nil, ErrInvalid
+	}
+	m.users[id] = patched
+	c := *patched
+	return &c, nil
+}
+
+func (m *memUsers) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	b, _ := json.Marshal(v)
+	m := map[string]interface{}{}
+	_ = json.Unmarshal(b, &m)
+	return m
+}
+
+func setup() *gin.Engine {
+	e := gin.New()
+	Mount(e.Group("/scim/v2"), &memUsers{users: map[string]*User{}}, nil)
+	return e
+}
+
+func do(e *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", ContentType)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateGetDelete(t *testing.T) {
+	e := setup()
+
+	w := do(e, "POST", "/scim/v2/Users", `{"schemas":["`+SchemaUser+`"],"userName":"alice"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "http:///scim/v2/Users/1", w.Header().Get("Location"))
+
+	w = do(e, "GET", "/scim/v2/Users/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	u := &User{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), u))
+	assert.Equal(t, "alice", u.UserName)
+	assert.Equal(t, "User", u.Meta.ResourceType)
+
+	w = do(e, "DELETE", "/scim/v2/Users/1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = do(e, "GET", "/scim/v2/Users/1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"schemas":["`+SchemaError+`"],"status":"404","detail":"scim: resource not found"}`, w.Body.String())
+}
+
+func TestCreateConflict(t *testing.T) {
+	e := setup()
+
+	do(e, "POST", "/scim/v2/Users", `{"userName":"alice"}`)
+	w := do(e, "POST", "/scim/v2/Users", `{"userName":"alice"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"scimType":"uniqueness"`)
+}
+
+func TestListFilterPagination(t *testing.T) {
+	e := setup()
+	for _, n := range []string{"alice", "bob", "alex", "carol"} {
+		do(e, "POST", "/scim/v2/Users", `{"userName":"`+n+`"}`)
+	}
+
+	w := do(e, "GET", `/scim/v2/Users?filter=userName+sw+"al"&count=1&startIndex=2`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	res := &ListResponse[User]{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	assert.Equal(t, 2, res.TotalResults)
+	assert.Equal(t, 2, res.StartIndex)
+	assert.Equal(t, 1, res.ItemsPerPage)
+	assert.Equal(t, "alex", res.Resources[0].UserName)
+
+	w = do(e, "GET", `/scim/v2/Users?filter=userName+zz+"al"`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"scimType":"invalidFilter"`)
+}
+
+func TestPatch(t *testing.T) {
+	e := setup()
+	do(e, "POST", "/scim/v2/Users", `{"userName":"alice","active":true,"emails":[{"value":"a@x.com","type":"work"}]}`)
+
+	w := do(e, "PATCH", "/scim/v2/Users/1", `{
+		"schemas":["`+SchemaPatchOp+`"],
+		"Operations":[
+			{"op":"replace","path":"active","value":false},
+			{"op":"replace","path":"emails[type eq \"work\"].value","value":"alice@x.com"},
+			{"op":"add","path":"name.givenName","value":"Alice"}
+		]
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	u := &User{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), u))
+	assert.False(t, *u.Active)
+	assert.Equal(t, "alice@x.com", u.Emails[0].Value)
+	assert.Equal(t, "Alice", u.Name.GivenName)
+
+	w = do(e, "PATCH", "/scim/v2/Users/1", `{"Operations":[{"op":"replace","path":"active","value":true}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseFilter(t *testing.T) {
+	res := map[string]interface{}{
+		"userName": "Alice",
+		"active":   true,
+		"meta":     map[string]interface{}{"created": "2022-01-01T00:00:00Z"},
+		"emails": []interface{}{
+			map[string]interface{}{"value": "a@example.com", "type": "work"},
+			map[string]interface{}{"value": "a@home.org", "type": "home"},
+		},
+	}
+
+	for filter, want := range map[string]bool{
+		`userName eq "alice"`:                               true,
+		`username ne "alice"`:                               false,
+		`active eq true and userName co "lic"`:              true,
+		`not (active eq true) or userName pr`:               true,
+		`meta.created gt "2021-12-31T00:00:00Z"`:            true,
+		`emails[type eq "work" and value ew "example.com"]`: true,
+		`emails[type eq "other"]`:                           false,
+		`emails.type eq "home"`:                             true,
+		`title pr`:                                          false,
+	} {
+		f, err := ParseFilter(filter)
+		if assert.NoError(t, err, filter) {
+			assert.Equal(t, want, f.Match(res), filter)
+		}
+	}
+
+	for _, filter := range []string{`userName eq`, `(active eq true`, `userName xx "a"`, `userName eq "a" and`} {
+		_, err := ParseFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
+
+func TestServiceProviderConfig(t *testing.T) {
+	w := do(setup(), "GET", "/scim/v2/ServiceProviderConfig", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"patch":{"supported":true}`)
+}
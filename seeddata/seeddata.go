@@ -0,0 +1,218 @@
+// Seed data endpoints
+//
+// Admin handlers loading and resetting seed fixtures through registered seeders, for fast resets of QA and
+// development environments. Seeding is only enabled in allowed environments, DefaultAllowedEnvironments unless
+// WithAllowedEnvironments is given, so an empty or unrecognised environment is treated as production. The handlers
+// respond 404 when seeding is disabled, and every load and reset is audit logged.
+//
+//	s := seeddata.New(cfg.Environment, seeddata.WithActor(func(ctx *gin.Context) string { return auth.UserID(ctx) }))
+//	s.Register(users.Seeder{DB: db})
+//	seed := admin.Group("/seed", s.Guard())
+//	seed.GET("", s.ListHandler())
+//	seed.POST("/:name/load", s.LoadHandler())
+//	seed.POST("/:name/reset", s.ResetHandler())
+//
+// The name "all" applies every seeder, in registration order for loads and reverse order for resets.
+package seeddata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Seeder loads and removes a set of fixtures
+type Seeder interface {
+	Name() string
+	Load(ctx context.Context) error  // Inserts the fixtures
+	Reset(ctx context.Context) error // Removes the fixtures and any data derived from them
+}
+
+// All names every seeder in handler paths
+const All = "all"
+
+// Environments where seeding is allowed unless WithAllowedEnvironments is given
+var DefaultAllowedEnvironments = []string{"dev", "development", "local", "test", "qa"}
+
+type seedOpts struct {
+	allowed []string // Environments where seeding is allowed
+	actor   func(ctx *gin.Context) string
+}
+
+// Modifier function for customising seeding behaviour
+type SeedOpts func(*seedOpts) *seedOpts
+
+// WithAllowedEnvironments only allows seeding in the listed environments, instead of the DefaultAllowedEnvironments
+func WithAllowedEnvironments(envs ...string) SeedOpts {
+	return func(so *seedOpts) *seedOpts {
+		so.allowed = envs
+		return so
+	}
+}
+
+// WithActor sets the function identifying the user for audit events
+func WithActor(fn func(ctx *gin.Context) string) SeedOpts {
+	return func(so *seedOpts) *seedOpts {
+		so.actor = fn
+		return so
+	}
+}
+
+// Seeds manages the registered seeders
+type Seeds struct {
+	env  string
+	opts *seedOpts
+
+	mu      sync.Mutex // Held while seeding, so loads and resets don't interleave
+	seeders []Seeder
+}
+
+// New creates a seeder registry for the environment
+func New(env string, opts ...SeedOpts) *Seeds {
+	so := &seedOpts{allowed: DefaultAllowedEnvironments, actor: func(*gin.Context) string { return "" }}
+	for _, f := range opts {
+		so = f(so)
+	}
+	return &Seeds{env: env, opts: so}
+}
+
+// Enabled returns true if seeding is allowed in the environment. It is never allowed in an empty environment.
+func (s *Seeds) Enabled() bool {
+	return s.env != "" && contains(s.opts.allowed, s.env)
+}
+
+func contains(list []string, env string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, env) {
+			return true
+		}
+	}
+	return false
+}
+
+// Register adds a seeder. Panics if a seeder with the same name is already registered, or the name is All.
+func (s *Seeds) Register(seeder Seeder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seeder.Name() == All {
+		panic("seeddata: seeder name " + All + " is reserved")
+	}
+	for _, r := range s.seeders {
+		if r.Name() == seeder.Name() {
+			panic("seeddata: seeder " + seeder.Name() + " already registered")
+		}
+	}
+	s.seeders = append(s.seeders, seeder)
+}
+
+// Names returns the names of the registered seeders in registration order
+func (s *Seeds) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]string, len(s.seeders))
+	for i, r := range s.seeders {
+		res[i] = r.Name()
+	}
+	return res
+}
+
+var errUnknown = fmt.Errorf("seeddata: unknown seeder")
+
+// Load loads the named seeder, or all seeders in order
+func (s *Seeds) Load(ctx context.Context, name string) error {
+	return s.apply(ctx, name, false)
+}
+
+// Reset resets then reloads the named seeder, or resets all seeders in reverse order then loads them in order
+func (s *Seeds) Reset(ctx context.Context, name string) error {
+	return s.apply(ctx, name, true)
+}
+
+func (s *Seeds) apply(ctx context.Context, name string, reset bool) error {
+	if !s.Enabled() {
+		return fmt.Errorf("seeddata: disabled in environment %s", s.env)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var selected []Seeder
+	for _, r := range s.seeders {
+		if name == All || r.Name() == name {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 {
+		return errUnknown
+	}
+	if reset {
+		for i := len(selected) - 1; i >= 0; i-- {
+			if err := selected[i].Reset(ctx); err != nil {
+				return fmt.Errorf("seeddata: resetting %s: %w", selected[i].Name(), err)
+			}
+		}
+	}
+	for _, r := range selected {
+		if err := r.Load(ctx); err != nil {
+			return fmt.Errorf("seeddata: loading %s: %w", r.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Guard middleware responds 404 to all requests when seeding is disabled, hiding the endpoints
+func (s *Seeds) Guard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !s.Enabled() {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		}
+	}
+}
+
+// ListHandler responds with the environment and seeder names
+func (s *Seeds) ListHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"environment": s.env, "enabled": s.Enabled(), "seeders": s.Names()})
+	}
+}
+
+// LoadHandler loads the seeder named by the name path parameter, responding 204, or 404 if unknown
+func (s *Seeds) LoadHandler() gin.HandlerFunc {
+	return s.handler("seed.load", s.Load)
+}
+
+// ResetHandler resets and reloads the seeder named by the name path parameter, responding as LoadHandler
+func (s *Seeds) ResetHandler() gin.HandlerFunc {
+	return s.handler("seed.reset", s.Reset)
+}
+
+func (s *Seeds) handler(action string, fn func(context.Context, string) error) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !s.Enabled() {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		name := ctx.Param("name")
+		start := time.Now()
+		err := fn(ctx.Request.Context(), name)
+
+		event := zlog.Audit(ctx).Action(action).Actor(s.opts.actor(ctx)).Target(name).Str("environment", s.env)
+		switch {
+		case err == errUnknown:
+			event.Err(err).Msg("Seeder not found")
+			ginxerrors.AbortWith(ctx, http.StatusNotFound, "seeder_not_found")
+		case err != nil:
+			event.Err(err).Msg("Seeding failed")
+			ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "seeding_failed")
+		default:
+			event.Str("elapsed", time.Since(start).String()).Msg("Seeded")
+			ctx.Status(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package seeddata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+type seeder struct {
+	name string
+	log  *[]string
+	fail bool
+}
+
+func (s seeder) Name() string { return s.name }
+
+func (s seeder) Load(context.Context) error {
+	if s.fail {
+		return errors.New("constraint violation")
+	}
+	*s.log = append(*s.log, "load "+s.name)
+	return nil
+}
+
+func (s seeder) Reset(context.Context) error {
+	*s.log = append(*s.log, "reset "+s.name)
+	return nil
+}
+
+func engine(s *Seeds) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	g := e.Group("/seed", s.Guard())
+	g.GET("", s.ListHandler())
+	g.POST("/:name/load", s.LoadHandler())
+	g.POST("/:name/reset", s.ResetHandler())
+	return e
+}
+
+func do(e *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestSeeds(t *testing.T) {
+	audit := &bytes.Buffer{}
+	zlog.SetAuditWriter(audit)
+	defer zlog.SetAuditWriter(nil)
+
+	var log []string
+	s := New("qa", WithActor(func(*gin.Context) string { return "tester" }))
+	s.Register(seeder{name: "users", log: &log})
+	s.Register(seeder{name: "orders", log: &log})
+	assert.Panics(t, func() { s.Register(seeder{name: "users"}) })
+	assert.Panics(t, func() { s.Register(seeder{name: All}) })
+	e := engine(s)
+
+	assert.JSONEq(t, `{"environment":"qa","enabled":true,"seeders":["users","orders"]}`, do(e, "GET", "/seed").Body.String())
+	assert.Equal(t, 204, do(e, "POST", "/seed/users/load").Code)
+	assert.Equal(t, 204, do(e, "POST", "/seed/all/reset").Code)
+	w := do(e, "POST", "/seed/missing/load")
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"seeder_not_found"`)
+	assert.Equal(t, []string{"load users", "reset orders", "reset users", "load users", "load orders"}, log)
+
+	assert.Contains(t, audit.String(), `"action":"seed.load","actor":"tester","target":"users","environment":"qa"`)
+	assert.Contains(t, audit.String(), `"action":"seed.reset"`)
+	assert.Contains(t, audit.String(), `"target":"missing"`)
+
+	s.Register(seeder{name: "broken", fail: true})
+	assert.Equal(t, 500, do(e, "POST", "/seed/broken/load").Code)
+	assert.Contains(t, audit.String(), `"error":"seeddata: loading broken: constraint violation"`)
+}
+
+func TestEnvironments(t *testing.T) {
+	var log []string
+	s := New("Production")
+	s.Register(seeder{name: "users", log: &log})
+	assert.False(t, s.Enabled())
+	assert.Equal(t, 404, do(engine(s), "GET", "/seed").Code)
+	assert.Equal(t, 404, do(engine(s), "POST", "/seed/users/load").Code)
+	assert.Error(t, s.Load(context.Background(), "users"))
+	assert.Empty(t, log)
+
+	assert.False(t, New("dev", WithAllowedEnvironments("qa")).Enabled())
+	assert.True(t, New("qa", WithAllowedEnvironments("qa")).Enabled())
+
+	// Unset or unrecognised environments fail closed
+	for _, env := range []string{"", "prd", "production-eu"} {
+		assert.False(t, New(env).Enabled(), env)
+	}
+	assert.False(t, New("", WithAllowedEnvironments("")).Enabled())
+	assert.True(t, New("Dev").Enabled())
+}
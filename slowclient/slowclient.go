@@ -0,0 +1,168 @@
+// Slow client deadlines
+//
+// Per-route read and write deadlines, independent of the server-wide timeouts, so that routes accepting large
+// uploads can allow longer than the rest of the API while slow clients are still cut off everywhere.
+//
+// Deadlines are set on the underlying connection, which must be made available to the middleware by setting
+// the server ConnContext hook:
+//
+//	srv := &http.Server{Handler: e, ConnContext: slowclient.ConnContext}
+//	slowclient.SetHeaderTimeout(srv, 5*time.Second)
+//
+//	e.POST("/upload", slowclient.Deadlines(slowclient.WithRead(5*time.Minute)), handler)
+//	e.Use(slowclient.Deadlines(slowclient.WithRead(10*time.Second), slowclient.WithWrite(30*time.Second)))
+//
+// A request whose body isn't received before the read deadline is aborted with 408 if the handler hasn't
+// responded, and logged. Write deadline expiry is logged, but the client can't be told. Deadlines are only
+// applied to HTTP/1.x requests, as HTTP/2 connections are shared between requests.
+package slowclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+type connKey struct{}
+
+// ConnContext stores the connection in the request context, for use as http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// SetHeaderTimeout sets the header read timeout of the server. This can only be set server wide, as the route
+// isn't known until the headers are read. The ConnContext hook is also installed if not set.
+func SetHeaderTimeout(srv *http.Server, d time.Duration) {
+	srv.ReadHeaderTimeout = d
+	if srv.ConnContext == nil {
+		srv.ConnContext = ConnContext
+	}
+}
+
+type deadlineOpts struct {
+	read  time.Duration // Time allowed to read the request body, from the start of the handler chain
+	write time.Duration // Time allowed to write the response, from the start of the handler chain
+}
+
+// Modifier function for customising deadline behaviour
+type DeadlineOpts func(*deadlineOpts) *deadlineOpts
+
+// Deadlines sets read and write deadlines on the connection for the remainder of the handler chain.
+// Deadlines are cleared once the chain completes, so they don't apply to later requests on the connection.
+func Deadlines(opts ...DeadlineOpts) gin.HandlerFunc {
+	do := &deadlineOpts{}
+	for _, f := range opts {
+		do = f(do)
+	}
+
+	return func(ctx *gin.Context) {
+		conn, ok := ctx.Request.Context().Value(connKey{}).(net.Conn)
+		if !ok || ctx.Request.ProtoMajor != 1 {
+			return
+		}
+
+		now := time.Now()
+		var body *timeoutBody
+		if do.read > 0 {
+			conn.SetReadDeadline(now.Add(do.read))
+			body = &timeoutBody{ReadCloser: ctx.Request.Body}
+			ctx.Request.Body = body
+		}
+		var writer *timeoutWriter
+		if do.write > 0 {
+			conn.SetWriteDeadline(now.Add(do.write))
+			writer = &timeoutWriter{ResponseWriter: ctx.Writer}
+			ctx.Writer = writer
+		}
+
+		ctx.Next()
+
+		if body != nil && body.timedOut {
+			zlog.GetLogger(ctx).Warn().Dur("deadline", do.read).Msg("Request body read deadline exceeded")
+			// The rest of the body can't be read, so the connection can't be reused. The expired read
+			// deadline is left in place so the server doesn't block trying to discard the remaining body.
+			ctx.Header("Connection", "close")
+			if !ctx.Writer.Written() {
+				ginxerrors.AbortWith(ctx, http.StatusRequestTimeout, "request_timeout")
+			}
+		} else if do.read > 0 {
+			// The next request on the connection isn't subject to this route's deadlines
+			conn.SetReadDeadline(time.Time{})
+		}
+		if do.write > 0 {
+			conn.SetWriteDeadline(time.Time{})
+		}
+		if writer != nil && writer.timedOut {
+			zlog.GetLogger(ctx).Warn().Dur("deadline", do.write).Msg("Response write deadline exceeded")
+		}
+	}
+}
+
+// WithRead sets the time allowed to read the request body
+func WithRead(d time.Duration) DeadlineOpts {
+	return func(do *deadlineOpts) *deadlineOpts {
+		do.read = d
+		return do
+	}
+}
+
+// WithWrite sets the time allowed to write the response
+func WithWrite(d time.Duration) DeadlineOpts {
+	return func(do *deadlineOpts) *deadlineOpts {
+		do.write = d
+		return do
+	}
+}
+
+type timeoutBody struct {
+	io.ReadCloser
+	timedOut bool
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if isTimeout(err) {
+		b.timedOut = true
+	}
+	return n, err
+}
+
+type timeoutWriter struct {
+	gin.ResponseWriter
+	timedOut bool
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if isTimeout(err) {
+		w.timedOut = true
+	}
+	return n, err
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	if isTimeout(err) {
+		w.timedOut = true
+	}
+	return n, err
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
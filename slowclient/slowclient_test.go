@@ -0,0 +1,86 @@
+package slowclient
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func server(e *gin.Engine) *httptest.Server {
+	srv := httptest.NewUnstartedServer(e)
+	SetHeaderTimeout(srv.Config, time.Second)
+	srv.Start()
+	return srv
+}
+
+func TestReadDeadline(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("/upload", Deadlines(WithRead(100*time.Millisecond)), func(ctx *gin.Context) {
+		if _, err := io.ReadAll(ctx.Request.Body); err != nil {
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	srv := server(e)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Send part of the body then stall
+	conn.Write([]byte("POST /upload HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nab"))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestTimeout, res.StatusCode)
+}
+
+func TestDeadlinesCleared(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/fast", Deadlines(WithRead(50*time.Millisecond), WithWrite(50*time.Millisecond)), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	srv := server(e)
+	defer srv.Close()
+
+	conn, _ := net.Dial("tcp", srv.Listener.Addr().String())
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte("GET /fast HTTP/1.1\r\nHost: x\r\n\r\n"))
+	res, err := http.ReadResponse(r, nil)
+	assert.NoError(t, err)
+	io.ReadAll(res.Body)
+
+	// Idle longer than the route deadline, the connection must still be usable
+	time.Sleep(150 * time.Millisecond)
+	conn.Write([]byte("GET /fast HTTP/1.1\r\nHost: x\r\n\r\n"))
+	res, err = http.ReadResponse(r, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestNoConnContext(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("", Deadlines(WithRead(time.Nanosecond)), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,237 @@
+// Injection heuristics middleware
+//
+// Inspects query parameters and bound request fields for common SQL injection and XSS probe patterns.
+// Detections are logged through the request-scoped zlog logger (so carry the request ID) and either
+// tagged on the context for later handling, or the request is blocked outright.
+//
+// These are heuristics intended to surface scanning activity to a SOC pipeline, not a replacement for
+// parameterised queries and output encoding.
+package sqlguard
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Mode controls the action taken on detection
+type Mode int
+
+const (
+	// Tag records detections on the context and continues
+	Tag Mode = iota
+	// Block aborts the request with an error response
+	Block
+)
+
+const detectionsKey = "sqlguard"
+
+// Maximum length of an offending value included in log output
+const maxLogValue = 64
+
+var (
+	defaultKey  = "body"
+	defaultMode = Tag
+	defaultCode = http.StatusBadRequest
+)
+
+// Rule is a named pattern matched against input values
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules are the patterns applied unless overridden with WithRules
+var DefaultRules = []Rule{
+	{"sqli_union", regexp.MustCompile(`(?i)\bunion\b[\s\S]*?\bselect\b`)},
+	{"sqli_tautology", regexp.MustCompile(`(?i)['"]\s*(or|and)\s+['"]?\w+['"]?\s*(=|like)\s*['"]?\w+`)},
+	{"sqli_comment", regexp.MustCompile(`(?i)('|")\s*(--|#|/\*)`)},
+	{"sqli_stacked", regexp.MustCompile(`(?i);\s*(drop|delete|insert|update|alter|create|exec|shutdown)\b`)},
+	{"sqli_time", regexp.MustCompile(`(?i)\b(sleep|pg_sleep|benchmark)\s*\(|\bwaitfor\s+delay\b`)},
+	{"xss_script", regexp.MustCompile(`(?i)<\s*script\b`)},
+	{"xss_handler", regexp.MustCompile(`(?i)<[^>]+\bon[a-z]+\s*=`)},
+	{"xss_uri", regexp.MustCompile(`(?i)(javascript|vbscript)\s*:`)},
+}
+
+// Detection describes a single matched rule
+type Detection struct {
+	Source string `json:"source"` // "query" or "body"
+	Field  string `json:"field"`  // Query parameter name or struct field path
+	Rule   string `json:"rule"`   // Name of the matched rule
+	Value  string `json:"-"`      // Offending input value
+}
+
+type guardOpts struct {
+	key   string // Context key of the bound struct
+	mode  Mode   // Action on detection
+	code  int    // HTTP status code if blocking
+	rules []Rule // Patterns to match
+}
+
+// Modifier function for customising guard behaviour
+type GuardOpts func(*guardOpts) *guardOpts
+
+// Guard inspects the query string and any bound struct for suspicious input.
+// To inspect bound fields it must be placed after the bind middleware.
+func Guard(opts ...GuardOpts) gin.HandlerFunc {
+	g := &guardOpts{
+		key:   defaultKey,
+		mode:  defaultMode,
+		code:  defaultCode,
+		rules: DefaultRules,
+	}
+	for _, f := range opts {
+		g = f(g)
+	}
+
+	return func(ctx *gin.Context) {
+		var found []Detection
+
+		// Query parameters
+		for name, values := range ctx.Request.URL.Query() {
+			for _, v := range values {
+				found = append(found, g.match("query", name, v)...)
+			}
+		}
+
+		// Bound struct fields
+		if target, ok := ctx.Get(g.key); ok {
+			g.walk(reflect.ValueOf(target), "", &found)
+		}
+
+		if len(found) == 0 {
+			return
+		}
+
+		logger := zlog.GetLogger(ctx)
+		for _, d := range found {
+			value := d.Value
+			if len(value) > maxLogValue {
+				value = value[:maxLogValue]
+			}
+			logger.Warn().
+				Str("source", d.Source).
+				Str("field", d.Field).
+				Str("rule", d.Rule).
+				Str("value", value).
+				Str("ip", ctx.ClientIP()).
+				Msg("sqlguard detection")
+		}
+
+		ctx.Set(detectionsKey, append(Get(ctx), found...))
+
+		if g.mode == Block {
+			errors.AbortWith(ctx, g.code, "suspicious_input")
+		}
+	}
+}
+
+// Get returns the detections recorded for the current request
+func Get(ctx *gin.Context) []Detection {
+	if v, ok := ctx.Get(detectionsKey); ok {
+		return v.([]Detection)
+	}
+	return nil
+}
+
+// Check returns the detections for a single value using the default rules
+func Check(value string) []Detection {
+	g := &guardOpts{rules: DefaultRules}
+	return g.match("", "", value)
+}
+
+func (g *guardOpts) match(source, field, value string) []Detection {
+	var found []Detection
+	for _, r := range g.rules {
+		if r.Pattern.MatchString(value) {
+			found = append(found, Detection{
+				Source: source,
+				Field:  field,
+				Rule:   r.Name,
+				Value:  value,
+			})
+		}
+	}
+	return found
+}
+
+func (g *guardOpts) walk(v reflect.Value, path string, found *[]Detection) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			g.walk(v.Elem(), path, found)
+		}
+	case reflect.String:
+		*found = append(*found, g.match("body", path, v.String())...)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			g.walk(v.Index(i), path, found)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			g.walk(iter.Value(), join(path, iter.Key().String()), found)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && f.Tag.Get("sqlguard") != "-" {
+				g.walk(v.Field(i), join(path, f.Name), found)
+			}
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// WithKey sets the context key of the bound struct to inspect
+func WithKey(key string) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.key = key
+		return g
+	}
+}
+
+// WithMode sets the action taken on detection for the current handler
+func WithMode(mode Mode) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.mode = mode
+		return g
+	}
+}
+
+// WithCode sets the response status code when blocking, and implies Block mode
+func WithCode(code int) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.mode = Block
+		g.code = code
+		return g
+	}
+}
+
+// WithRules replaces the rules matched for the current handler
+func WithRules(rules ...Rule) GuardOpts {
+	return func(g *guardOpts) *guardOpts {
+		g.rules = rules
+		return g
+	}
+}
+
+// SetDefaultMode sets the default action taken on detection for all handlers
+func SetDefaultMode(mode Mode) {
+	defaultMode = mode
+}
+
+// SetDefaultKey sets the default context key of the bound struct for all handlers
+func SetDefaultKey(key string) {
+	defaultKey = key
+}
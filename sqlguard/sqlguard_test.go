@@ -0,0 +1,79 @@
+package sqlguard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	assert.NotEmpty(t, Check("1 UNION ALL SELECT password FROM users"))
+	assert.NotEmpty(t, Check("' OR '1'='1"))
+	assert.NotEmpty(t, Check("admin'--"))
+	assert.NotEmpty(t, Check("<script>alert(1)</script>"))
+	assert.NotEmpty(t, Check(`<img src=x onerror="alert(1)">`))
+
+	assert.Empty(t, Check("O'Brien"))
+	assert.Empty(t, Check("select a union representative"))
+	assert.Empty(t, Check("hello world"))
+}
+
+func TestGuardTag(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.GET("", Guard(), func(ctx *gin.Context) {
+		found := Get(ctx)
+		assert.Len(t, found, 1)
+		assert.Equal(t, "query", found[0].Source)
+		assert.Equal(t, "q", found[0].Field)
+		assert.Equal(t, "xss_script", found[0].Rule)
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/?q=%3Cscript%3E", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestGuardBlockBody(t *testing.T) {
+	type body struct {
+		Name  string
+		Items []struct{ Note string }
+	}
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.POST("", func(ctx *gin.Context) {
+		b := &body{Name: "ok", Items: []struct{ Note string }{{Note: "x'; DROP TABLE users"}}}
+		ctx.Set("body", b)
+	}, Guard(WithMode(Block)), func(ctx *gin.Context) {
+		t.Fail()
+	})
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Result().StatusCode)
+	assert.Equal(t, `{"code":"suspicious_input"}`, w.Body.String())
+}
+
+func TestGuardClean(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.GET("", Guard(WithMode(Block)), func(ctx *gin.Context) {
+		assert.Empty(t, Get(ctx))
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/?q=hello", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
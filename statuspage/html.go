@@ -0,0 +1,50 @@
+package statuspage
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+type htmlData struct {
+	Title  string
+	Report Report
+}
+
+var page = template.Must(template.New("status").Funcs(template.FuncMap{
+	"label": func(st Status) string {
+		s := string(st)
+		return strings.ToUpper(s[:1]) + strings.ReplaceAll(s[1:], "_", " ")
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.2f%%", f*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.status { padding: 1rem; border-radius: 4px; color: #fff; }
+.operational { background: #2e7d32; } .degraded { background: #f9a825; } .unknown { background: #757575; }
+.partial_outage, .outage { background: #ef6c00; } .major_outage { background: #c62828; }
+.dep { display: flex; justify-content: space-between; padding: 0.75rem 0; border-bottom: 1px solid #ddd; }
+.bars { display: flex; gap: 1px; height: 1.5rem; margin-top: 0.25rem; }
+.bars span { flex: 1; } .ok { background: #2e7d32; } .fail { background: #c62828; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="status {{.Report.Status}}">{{label .Report.Status}}</p>
+{{range .Report.Dependencies}}
+<div>
+<div class="dep"><strong>{{.Name}}</strong><span>{{label .Status}} &middot; {{percent .Availability}}</span></div>
+<div class="bars">{{range .History}}<span class="{{if .OK}}ok{{else}}fail{{end}}" title="{{.Time.Format "2006-01-02 15:04:05"}}"></span>{{end}}</div>
+</div>
+{{end}}
+<p><small>Updated {{.Report.Updated.Format "2006-01-02 15:04:05 MST"}}</small></p>
+</body>
+</html>
+`))
@@ -0,0 +1,281 @@
+// Dependency status page
+//
+// Public status handler aggregating periodic dependency checks, keeping a rolling window of results per dependency
+// in memory, and classifying each dependency and the service overall, rendered as JSON or HTML.
+//
+//	p := statuspage.New(statuspage.WithInterval(30 * time.Second))
+//	p.Add("database", db.PingContext, true)
+//	p.Add("search", search.Ping, false)
+//	go p.Run(ctx)
+//	e.GET("/status", p.Handler())
+//
+// A dependency is in outage if its last check failed, and degraded if its availability over the window is below
+// the threshold or its last check was slow. The service is in major outage if a critical dependency is in outage,
+// in partial outage if another dependency is, and degraded if any dependency is degraded. Check errors are logged
+// but not shown, as the page is intended to be public.
+package statuspage
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Checker checks a dependency, returning an error if unavailable
+type Checker func(ctx context.Context) error
+
+// Status of a dependency or the service
+type Status string
+
+const (
+	StatusOperational   Status = "operational"
+	StatusDegraded      Status = "degraded"
+	StatusPartialOutage Status = "partial_outage"
+	StatusMajorOutage   Status = "major_outage"
+	StatusOutage        Status = "outage"  // Dependency only
+	StatusUnknown       Status = "unknown" // Not yet checked
+)
+
+// Sample is the result of a check
+type Sample struct {
+	Time    time.Time     `json:"time"`
+	OK      bool          `json:"ok"`
+	Latency time.Duration `json:"latency"`
+}
+
+// Dependency is the state of a dependency
+type Dependency struct {
+	Name         string   `json:"name"`
+	Critical     bool     `json:"critical"`
+	Status       Status   `json:"status"`
+	Availability float64  `json:"availability"` // Fraction of successful checks in the window
+	History      []Sample `json:"history"`      // Oldest first
+}
+
+// Report is the state of the service
+type Report struct {
+	Status       Status       `json:"status"`
+	Updated      time.Time    `json:"updated"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+type pageOpts struct {
+	title     string
+	interval  time.Duration
+	timeout   time.Duration
+	window    int
+	threshold float64
+	slow      time.Duration
+}
+
+// Modifier function for customising status page behaviour
+type PageOpts func(*pageOpts) *pageOpts
+
+// WithTitle sets the title of the HTML page (default Service Status)
+func WithTitle(title string) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.title = title
+		return po
+	}
+}
+
+// WithInterval sets the time between checks (default 30 seconds)
+func WithInterval(d time.Duration) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.interval = d
+		return po
+	}
+}
+
+// WithTimeout sets the timeout of each check (default 5 seconds)
+func WithTimeout(d time.Duration) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.timeout = d
+		return po
+	}
+}
+
+// WithWindow sets the number of results kept per dependency (default 60)
+func WithWindow(n int) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.window = n
+		return po
+	}
+}
+
+// WithDegradedThreshold sets the availability over the window below which a dependency is degraded (default 0.9)
+func WithDegradedThreshold(availability float64) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.threshold = availability
+		return po
+	}
+}
+
+// WithSlowThreshold marks a dependency degraded if its last check took longer than d
+func WithSlowThreshold(d time.Duration) PageOpts {
+	return func(po *pageOpts) *pageOpts {
+		po.slow = d
+		return po
+	}
+}
+
+type dependency struct {
+	name     string
+	check    Checker
+	critical bool
+	history  []Sample // Ring buffer
+	next     int
+}
+
+// Page checks dependencies and reports their status
+type Page struct {
+	opts *pageOpts
+
+	mu      sync.Mutex
+	deps    []*dependency
+	updated time.Time
+}
+
+// New creates a status page
+func New(opts ...PageOpts) *Page {
+	po := &pageOpts{title: "Service Status", interval: 30 * time.Second, timeout: 5 * time.Second, window: 60, threshold: 0.9}
+	for _, f := range opts {
+		po = f(po)
+	}
+	return &Page{opts: po}
+}
+
+// Add adds a dependency. An outage of a critical dependency is a major outage of the service.
+func (p *Page) Add(name string, check Checker, critical bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deps = append(p.deps, &dependency{name: name, check: check, critical: critical})
+}
+
+// Run checks all dependencies on the interval until the context is cancelled, starting immediately
+func (p *Page) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+	for {
+		p.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks all dependencies concurrently, recording the results
+func (p *Page) Check(ctx context.Context) {
+	p.mu.Lock()
+	deps := append([]*dependency{}, p.deps...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, d := range deps {
+		wg.Add(1)
+		go func(d *dependency) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, p.opts.timeout)
+			defer cancel()
+			start := time.Now()
+			err := d.check(cctx)
+			s := Sample{Time: start, OK: err == nil, Latency: time.Since(start)}
+			if err != nil {
+				log.Warn().Err(err).Str("dependency", d.name).Msg("Dependency check failed")
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if len(d.history) < p.opts.window {
+				d.history = append(d.history, s)
+			} else {
+				d.history[d.next] = s
+				d.next = (d.next + 1) % p.opts.window
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.updated = time.Now()
+	p.mu.Unlock()
+}
+
+// Report returns the current state of the service
+func (p *Page) Report() Report {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := Report{Status: StatusOperational, Updated: p.updated}
+	if len(p.deps) > 0 && p.updated.IsZero() {
+		r.Status = StatusUnknown
+	}
+	for _, d := range p.deps {
+		dep := Dependency{Name: d.name, Critical: d.critical, Status: StatusUnknown}
+		dep.History = append(append(dep.History, d.history[d.next:]...), d.history[:d.next]...)
+		if len(dep.History) > 0 {
+			ok := 0
+			for _, s := range dep.History {
+				if s.OK {
+					ok++
+				}
+			}
+			dep.Availability = float64(ok) / float64(len(dep.History))
+			last := dep.History[len(dep.History)-1]
+			switch {
+			case !last.OK:
+				dep.Status = StatusOutage
+			case dep.Availability < p.opts.threshold || (p.opts.slow > 0 && last.Latency > p.opts.slow):
+				dep.Status = StatusDegraded
+			default:
+				dep.Status = StatusOperational
+			}
+		}
+		r.Status = worse(r.Status, dep)
+		r.Dependencies = append(r.Dependencies, dep)
+	}
+	return r
+}
+
+// Combine the service status with a dependency status
+func worse(s Status, d Dependency) Status {
+	rank := map[Status]int{StatusOperational: 0, StatusUnknown: 1, StatusDegraded: 2, StatusPartialOutage: 3, StatusMajorOutage: 4}
+	ds := d.Status
+	switch {
+	case ds == StatusOutage && d.Critical:
+		ds = StatusMajorOutage
+	case ds == StatusOutage:
+		ds = StatusPartialOutage
+	}
+	if rank[ds] > rank[s] {
+		return ds
+	}
+	return s
+}
+
+// Handler responds with the report as HTML if preferred by the Accept header or with ?format=html, otherwise JSON.
+// The status is 200 unless the service is in major outage, for use by external monitors.
+func (p *Page) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		r := p.Report()
+		code := http.StatusOK
+		if r.Status == StatusMajorOutage {
+			code = http.StatusServiceUnavailable
+		}
+		if ctx.Query("format") == "html" || ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+			ctx.Header("Content-Type", "text/html; charset=utf-8")
+			ctx.Status(code)
+			if err := page.Execute(ctx.Writer, htmlData{Title: p.opts.title, Report: r}); err != nil {
+				log.Error().Err(err).Msg("Failed to render status page")
+			}
+			return
+		}
+		ctx.JSON(code, r)
+	}
+}
@@ -0,0 +1,94 @@
+package statuspage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	fail := map[string]bool{}
+	check := func(name string) Checker {
+		return func(ctx context.Context) error {
+			if fail[name] {
+				return errors.New("down")
+			}
+			return nil
+		}
+	}
+
+	p := New(WithWindow(4), WithDegradedThreshold(0.8))
+	p.Add("db", check("db"), true)
+	p.Add("search", check("search"), false)
+	assert.Equal(t, StatusUnknown, p.Report().Status)
+
+	p.Check(context.Background())
+	r := p.Report()
+	assert.Equal(t, StatusOperational, r.Status)
+	assert.Equal(t, 1.0, r.Dependencies[0].Availability)
+
+	fail["search"] = true
+	p.Check(context.Background())
+	r = p.Report()
+	assert.Equal(t, StatusPartialOutage, r.Status)
+	assert.Equal(t, StatusOutage, r.Dependencies[1].Status)
+
+	fail["search"] = false
+	p.Check(context.Background())
+	r = p.Report()
+	assert.Equal(t, StatusDegraded, r.Status)
+	assert.Equal(t, 2.0/3, r.Dependencies[1].Availability)
+
+	fail["db"] = true
+	p.Check(context.Background())
+	assert.Equal(t, StatusMajorOutage, p.Report().Status)
+
+	// Window drops the oldest result
+	fail["db"] = false
+	p.Check(context.Background())
+	r = p.Report()
+	assert.Len(t, r.Dependencies[1].History, 4)
+	assert.Equal(t, []bool{false, true, true, true}, oks(r.Dependencies[1].History))
+	assert.Equal(t, StatusDegraded, r.Status)
+}
+
+func oks(samples []Sample) (out []bool) {
+	for _, s := range samples {
+		out = append(out, s.OK)
+	}
+	return out
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := New(WithTitle("Acme Status"))
+	p.Add("db", func(ctx context.Context) error { return errors.New("secret dsn") }, true)
+	p.Check(context.Background())
+
+	r := gin.New()
+	r.GET("/status", p.Handler())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusMajorOutage, report.Status)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(w.Body.String(), "<title>Acme Status</title>"))
+	assert.Contains(t, w.Body.String(), "Major outage")
+	assert.NotContains(t, w.Body.String(), "secret")
+}
@@ -0,0 +1,318 @@
+// Threat intelligence enrichment
+//
+// Evaluates pluggable providers against the client IP of each request, tagging the request with any
+// matching threat indicators (known-bad IPs, TOR exit nodes, anonymising networks) and an aggregate score.
+//
+// The result is attached to the gin context for use in handlers and policy middleware, and the
+// request-scoped zlog logger is extended with the score so every subsequent log line carries it.
+// Optionally, requests scoring at or above a threshold are rejected outright.
+package threatintel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/internal/ipnets"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Common indicator types
+const (
+	TypeKnownBad   = "known_bad_ip"
+	TypeTorExit    = "tor_exit"
+	TypeAnonymiser = "anonymiser"
+)
+
+const resultKey = "threatintel"
+
+var (
+	defaultTimeout = 50 * time.Millisecond
+	defaultCode    = http.StatusForbidden
+)
+
+// Indicator is a single threat signal about a client
+type Indicator struct {
+	Type   string `json:"type"`             // Indicator category, e.g. TypeTorExit
+	Source string `json:"source"`           // Feed or provider name
+	Score  int    `json:"score"`            // Severity contribution, conventionally 0-100
+	Detail string `json:"detail,omitempty"` // Optional provider specific detail
+}
+
+// Result holds all indicators matched for a request
+type Result struct {
+	Indicators []Indicator `json:"indicators"`
+	Score      int         `json:"score"` // Sum of indicator scores
+}
+
+// Has returns whether an indicator of the given type was matched
+func (r *Result) Has(typ string) bool {
+	for _, i := range r.Indicators {
+		if i.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider looks up indicators for a client IP
+type Provider interface {
+	Lookup(ctx context.Context, ip net.IP) ([]Indicator, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, ip net.IP) ([]Indicator, error)
+
+// Lookup implements Provider
+func (f ProviderFunc) Lookup(ctx context.Context, ip net.IP) ([]Indicator, error) {
+	return f(ctx, ip)
+}
+
+type enrichOpts struct {
+	providers  []Provider    // Providers evaluated in order
+	timeout    time.Duration // Total lookup budget per request
+	blockScore int           // Reject requests scoring at or above this, disabled if zero
+	code       int           // HTTP status code when rejecting
+}
+
+// Modifier function for customising enrichment behaviour
+type EnrichOpts func(*enrichOpts) *enrichOpts
+
+// Enrich evaluates all providers for the client IP and attaches the Result to the context
+func Enrich(opts ...EnrichOpts) gin.HandlerFunc {
+	eo := &enrichOpts{
+		timeout: defaultTimeout,
+		code:    defaultCode,
+	}
+	for _, f := range opts {
+		eo = f(eo)
+	}
+
+	return func(ctx *gin.Context) {
+		ip := net.ParseIP(ctx.ClientIP())
+		if ip == nil {
+			return
+		}
+
+		lctx, cancel := context.WithTimeout(ctx.Request.Context(), eo.timeout)
+		defer cancel()
+
+		res := &Result{}
+		for _, p := range eo.providers {
+			found, err := p.Lookup(lctx, ip)
+			if err != nil {
+				// Enrichment is best effort, never fail the request on provider errors
+				zlog.GetLogger(ctx).Debug().Err(err).Msg("threat intel lookup failed")
+				continue
+			}
+			for _, i := range found {
+				res.Indicators = append(res.Indicators, i)
+				res.Score += i.Score
+			}
+		}
+		ctx.Set(resultKey, res)
+
+		if len(res.Indicators) == 0 {
+			return
+		}
+
+		// Carry the score on every subsequent log line for this request
+		types := make([]string, 0, len(res.Indicators))
+		for _, i := range res.Indicators {
+			types = append(types, i.Type)
+		}
+		logger := zlog.GetLogger(ctx).With().
+			Int("threat_score", res.Score).
+			Strs("threat_types", types).
+			Logger()
+		ctx.Request = ctx.Request.WithContext(zlog.WithLogger(ctx.Request.Context(), &logger))
+
+		logger.Info().Str("ip", ip.String()).Msg("threat indicators matched")
+
+		if eo.blockScore > 0 && res.Score >= eo.blockScore {
+			errors.AbortWith(ctx, eo.code, "forbidden")
+		}
+	}
+}
+
+// Get returns the enrichment result for the current request, or an empty result if not enriched
+func Get(ctx *gin.Context) *Result {
+	if v, ok := ctx.Get(resultKey); ok {
+		return v.(*Result)
+	}
+	return &Result{}
+}
+
+// WithProvider adds a provider to evaluate
+func WithProvider(p Provider) EnrichOpts {
+	return func(eo *enrichOpts) *enrichOpts {
+		eo.providers = append(eo.providers, p)
+		return eo
+	}
+}
+
+// WithTimeout sets the total lookup budget per request
+func WithTimeout(d time.Duration) EnrichOpts {
+	return func(eo *enrichOpts) *enrichOpts {
+		eo.timeout = d
+		return eo
+	}
+}
+
+// WithBlockScore rejects requests with an aggregate score at or above the threshold
+func WithBlockScore(score int) EnrichOpts {
+	return func(eo *enrichOpts) *enrichOpts {
+		eo.blockScore = score
+		return eo
+	}
+}
+
+// WithCode sets the status code used when rejecting requests
+func WithCode(code int) EnrichOpts {
+	return func(eo *enrichOpts) *enrichOpts {
+		eo.code = code
+		return eo
+	}
+}
+
+// IPSet is a provider backed by a local list of IPs and CIDR ranges, e.g. a TOR exit node feed.
+// The contents can be atomically replaced at runtime with Load.
+type IPSet struct {
+	indicator Indicator
+
+	mu   sync.RWMutex
+	nets []*net.IPNet
+}
+
+// NewIPSet creates an empty set which reports the given indicator on match
+func NewIPSet(typ, source string, score int) *IPSet {
+	return &IPSet{
+		indicator: Indicator{
+			Type:   typ,
+			Source: source,
+			Score:  score,
+		},
+	}
+}
+
+// Add adds IPs or CIDR ranges to the set
+func (s *IPSet) Add(entries ...string) error {
+	nets, err := parseNets(entries)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nets = append(s.nets, nets...)
+	return nil
+}
+
+// Load replaces the set contents from a feed of one IP or CIDR per line.
+// Blank lines and lines starting with # are ignored.
+func (s *IPSet) Load(r io.Reader) error {
+	var entries []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	nets, err := parseNets(entries)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nets = nets
+	return nil
+}
+
+// Lookup implements Provider
+func (s *IPSet) Lookup(_ context.Context, ip net.IP) ([]Indicator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, n := range s.nets {
+		if n.Contains(ip) {
+			return []Indicator{s.indicator}, nil
+		}
+	}
+	return nil, nil
+}
+
+func parseNets(entries []string) ([]*net.IPNet, error) {
+	nets, err := ipnets.Parse(entries...)
+	if err != nil {
+		return nil, fmt.Errorf("threatintel: %w", err)
+	}
+	return nets, nil
+}
+
+type cacheEntry struct {
+	indicators []Indicator
+	expires    time.Time
+}
+
+type cached struct {
+	p   Provider
+	ttl time.Duration
+	max int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// Cache wraps a provider, typically a remote lookup, caching results per IP for ttl.
+// At most max entries are held; when full, an arbitrary entry is evicted to add a new one.
+func Cache(p Provider, ttl time.Duration, max int) Provider {
+	return &cached{
+		p:       p,
+		ttl:     ttl,
+		max:     max,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+func (c *cached) Lookup(ctx context.Context, ip net.IP) ([]Indicator, error) {
+	key := ip.String()
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !now.Before(e.expires) {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.indicators, nil
+	}
+
+	found, err := c.p.Lookup(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cacheEntry{found, now.Add(c.ttl)}
+	return found, nil
+}
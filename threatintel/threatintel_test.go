@@ -0,0 +1,89 @@
+package threatintel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPSet(t *testing.T) {
+	set := NewIPSet(TypeTorExit, "tor", 50)
+	assert.NoError(t, set.Load(strings.NewReader("# exit nodes\n10.0.0.1\n\n192.168.0.0/16\n")))
+
+	found, _ := set.Lookup(context.Background(), net.ParseIP("192.168.4.2"))
+	assert.Len(t, found, 1)
+	assert.Equal(t, TypeTorExit, found[0].Type)
+
+	found, _ = set.Lookup(context.Background(), net.ParseIP("10.0.0.2"))
+	assert.Empty(t, found)
+
+	assert.Error(t, set.Add("not an ip"))
+}
+
+func TestEnrich(t *testing.T) {
+	tor := NewIPSet(TypeTorExit, "tor", 50)
+	tor.Add("192.0.2.1")
+	bad := NewIPSet(TypeKnownBad, "feed", 60)
+	bad.Add("192.0.2.0/24")
+
+	e := gin.New()
+	e.GET("", Enrich(WithProvider(tor)), func(ctx *gin.Context) {
+		res := Get(ctx)
+		assert.True(t, res.Has(TypeTorExit))
+		assert.Equal(t, 50, res.Score)
+		ctx.Status(http.StatusOK)
+	})
+	e.GET("/block", Enrich(WithProvider(tor), WithProvider(bad), WithBlockScore(100)), func(ctx *gin.Context) {
+		t.Fail()
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/block", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Result().StatusCode)
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	p := Cache(ProviderFunc(func(ctx context.Context, ip net.IP) ([]Indicator, error) {
+		calls++
+		return []Indicator{{Type: TypeAnonymiser}}, nil
+	}), time.Minute, 10)
+
+	p.Lookup(context.Background(), net.ParseIP("192.0.2.1"))
+	found, _ := p.Lookup(context.Background(), net.ParseIP("192.0.2.1"))
+
+	assert.Equal(t, 1, calls)
+	assert.Len(t, found, 1)
+}
+
+func TestCacheFull(t *testing.T) {
+	calls := 0
+	p := Cache(ProviderFunc(func(ctx context.Context, ip net.IP) ([]Indicator, error) {
+		calls++
+		return nil, nil
+	}), time.Minute, 2)
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		p.Lookup(context.Background(), net.ParseIP(ip))
+	}
+	assert.Len(t, p.(*cached).entries, 2)
+
+	// The newest entry is cached although nothing had expired
+	p.Lookup(context.Background(), net.ParseIP("192.0.2.3"))
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,196 @@
+// TLS certificate reloading
+//
+// Serves a certificate loaded from files or a provider, checking for a new certificate periodically and swapping it
+// in without a restart. New handshakes get the new certificate while existing connections are unaffected. Rotations
+// are logged, and a warning is logged ahead of expiry so a failed rotation is noticed before clients are.
+//
+//	r := tlsreload.New(tlsreload.Files("/etc/tls/tls.crt", "/etc/tls/tls.key"))
+//	if err := r.Load(ctx); err != nil { ... }
+//	go r.Run(ctx)
+//	r.Server(":443", e).ListenAndServeTLS("", "")
+//
+// Reload can also be called directly, e.g. on SIGHUP. Certificates from a secrets manager implement Provider.
+package tlsreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/redmapletech/ginx/fips"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// ErrNoCertificate is returned by GetCertificate before a certificate has been loaded
+var ErrNoCertificate = errors.New("tlsreload: no certificate loaded")
+
+// Provider returns the current certificate
+type Provider interface {
+	Certificate(ctx context.Context) (*tls.Certificate, error)
+}
+
+// ProviderFunc adapts a function to a Provider
+type ProviderFunc func(ctx context.Context) (*tls.Certificate, error)
+
+// Certificate calls f
+func (f ProviderFunc) Certificate(ctx context.Context) (*tls.Certificate, error) {
+	return f(ctx)
+}
+
+// Files returns a provider loading a PEM certificate chain and key from files
+func Files(certFile, keyFile string) Provider {
+	return ProviderFunc(func(ctx context.Context) (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	})
+}
+
+type reloaderOpts struct {
+	interval time.Duration
+	warn     time.Duration
+	clock    func() time.Time
+}
+
+// Modifier function for customising certificate reloading
+type ReloaderOpts func(*reloaderOpts) *reloaderOpts
+
+// WithInterval sets how often Run checks for a new certificate (default 1 minute)
+func WithInterval(d time.Duration) ReloaderOpts {
+	return func(ro *reloaderOpts) *reloaderOpts {
+		ro.interval = d
+		return ro
+	}
+}
+
+// WithExpiryWarning sets how long before expiry a warning is logged on each check (default 14 days)
+func WithExpiryWarning(d time.Duration) ReloaderOpts {
+	return func(ro *reloaderOpts) *reloaderOpts {
+		ro.warn = d
+		return ro
+	}
+}
+
+// Reloader serves the current certificate of a provider
+type Reloader struct {
+	provider Provider
+	opts     *reloaderOpts
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// New creates a reloader for the provider
+func New(provider Provider, opts ...ReloaderOpts) *Reloader {
+	ro := &reloaderOpts{interval: time.Minute, warn: 14 * 24 * time.Hour, clock: time.Now}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+	return &Reloader{provider: provider, opts: ro}
+}
+
+// Load loads the initial certificate, returning an error if it can't be loaded
+func (r *Reloader) Load(ctx context.Context) error {
+	_, err := r.Reload(ctx)
+	return err
+}
+
+// Reload loads the certificate from the provider, swapping it in if it has changed, and returns whether it did. On
+// error the current certificate is kept.
+func (r *Reloader) Reload(ctx context.Context) (bool, error) {
+	cert, err := r.provider.Certificate(ctx)
+	if err == nil && cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if err == nil && cert.Leaf == nil {
+		err = errors.New("tlsreload: empty certificate chain")
+	}
+	if err == nil {
+		err = fips.CheckKey("tlsreload", cert.Leaf.PublicKey)
+	}
+	if err != nil {
+		zlog.GetLogger(ctx).Error().Err(err).Msg("Failed to load certificate")
+		return false, err
+	}
+
+	prev := r.cert.Load()
+	changed := prev == nil || !bytes.Equal(prev.Certificate[0], cert.Certificate[0])
+	if changed {
+		r.cert.Store(cert)
+		event := zlog.GetLogger(ctx).Info().
+			Str("subject", cert.Leaf.Subject.String()).
+			Str("serial", cert.Leaf.SerialNumber.String()).
+			Time("expires", cert.Leaf.NotAfter)
+		if prev != nil {
+			event.Str("previous", prev.Leaf.SerialNumber.String()).Msg("Certificate rotated")
+		} else {
+			event.Msg("Certificate loaded")
+		}
+	}
+	r.checkExpiry(ctx, cert.Leaf)
+	return changed, nil
+}
+
+func (r *Reloader) checkExpiry(ctx context.Context, leaf *x509.Certificate) {
+	left := leaf.NotAfter.Sub(r.opts.clock())
+	switch {
+	case left <= 0:
+		zlog.GetLogger(ctx).Error().Str("serial", leaf.SerialNumber.String()).Time("expires", leaf.NotAfter).
+			Msg("Certificate expired")
+	case left < r.opts.warn:
+		zlog.GetLogger(ctx).Warn().Str("serial", leaf.SerialNumber.String()).Time("expires", leaf.NotAfter).
+			Dur("remaining", left).Msg("Certificate expiring soon")
+	}
+}
+
+// Run reloads the certificate on the interval until the context is cancelled. Errors are logged, and the current
+// certificate kept.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = r.Reload(ctx)
+		}
+	}
+}
+
+// Certificate returns the current certificate, or nil if not loaded
+func (r *Reloader) Certificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// GetCertificate returns the current certificate for the handshake, for use in tls.Config
+func (r *Reloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := r.cert.Load(); cert != nil {
+		return cert, nil
+	}
+	return nil, ErrNoCertificate
+}
+
+// TLSConfig returns a TLS configuration serving the current certificate, restricted in FIPS mode
+func (r *Reloader) TLSConfig() *tls.Config {
+	return fips.TLSConfig("tlsreload", &tls.Config{
+		GetCertificate: r.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     tls.VersionTLS12,
+	})
+}
+
+// Server returns an HTTP server using the current certificate. Start it with ListenAndServeTLS("", "").
+func (r *Reloader) Server(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		TLSConfig:         r.TLSConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,92 @@
+package tlsreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Write a self-signed certificate and key to the files
+func writeCert(t *testing.T, certFile, keyFile string, serial int64, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	kder, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0o600))
+}
+
+func TestReload(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	now := time.Now()
+	writeCert(t, certFile, keyFile, 1, now.Add(90*24*time.Hour))
+
+	r := New(Files(certFile, keyFile))
+	_, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	assert.ErrorIs(t, err, ErrNoCertificate)
+	require.NoError(t, r.Load(context.Background()))
+	assert.Contains(t, buf.String(), "Certificate loaded")
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = r.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(1), serial())
+
+	// Unchanged files aren't swapped
+	changed, err := r.Reload(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	buf.Reset()
+	writeCert(t, certFile, keyFile, 2, now.Add(7*24*time.Hour))
+	changed, err = r.Reload(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, int64(2), serial())
+	assert.Contains(t, buf.String(), "Certificate rotated")
+	assert.Contains(t, buf.String(), "Certificate expiring soon")
+
+	// Broken files keep the current certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	_, err = r.Reload(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), serial())
+}
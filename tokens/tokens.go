@@ -0,0 +1,455 @@
+// Opaque tokens
+//
+// Issuance, storage and revocation of opaque API and refresh tokens.
+//
+// Tokens are random strings returned to the client exactly once; only their SHA-256 hash is persisted,
+// via a pluggable Store. The Auth middleware authenticates bearer tokens and attaches the token and
+// its principal to the context, and management handlers allow principals to list and revoke their tokens:
+//
+//	m := tokens.New(tokens.NewMemoryStore())
+//	api := e.Group("/api", m.Auth())
+//	api.GET("/tokens", m.ListHandler())
+//	api.DELETE("/tokens/:id", m.RevokeHandler())
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginxerrors "github.com/redmapletech/ginx/errors"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Token kinds
+const (
+	KindAccess  = "access"
+	KindRefresh = "refresh"
+)
+
+// Context keys set by the Auth middleware
+const (
+	TokenKey     = "tokens.token"
+	PrincipalKey = "tokens.principal"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("tokens: not found")
+	ErrRevoked  = fmt.Errorf("tokens: revoked")
+	ErrExpired  = fmt.Errorf("tokens: expired")
+	ErrKind     = fmt.Errorf("tokens: wrong token kind")
+)
+
+// Token is the stored record of an issued token
+type Token struct {
+	ID        string    `json:"id"`
+	Principal string    `json:"principal"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name,omitempty"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	Hash      string    `json:"-"` // Hex SHA-256 of the raw token
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero for no expiry
+	Revoked   bool      `json:"revoked"`
+}
+
+// HasScope returns whether the token was issued with the scope
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists token records. Implementations must be safe for concurrent use.
+type Store interface {
+	Create(ctx context.Context, t *Token) error
+	// GetByHash returns ErrNotFound if no token has the hash
+	GetByHash(ctx context.Context, hash string) (*Token, error)
+	// Get returns ErrNotFound if no token has the ID
+	Get(ctx context.Context, id string) (*Token, error)
+	List(ctx context.Context, principal string) ([]*Token, error)
+	Revoke(ctx context.Context, id string) error
+	// RevokeActive atomically revokes the token if it isn't already revoked, returning false if it was, so only one
+	// of concurrent callers succeeds. Returns ErrNotFound if no token has the ID.
+	RevokeActive(ctx context.Context, id string) (bool, error)
+}
+
+type managerOpts struct {
+	prefix     string        // Prefix of raw tokens, aids secret scanning
+	accessTTL  time.Duration // Default access token lifetime, zero for no expiry
+	refreshTTL time.Duration // Refresh token lifetime
+}
+
+// Modifier function for customising manager behaviour
+type ManagerOpts func(*managerOpts) *managerOpts
+
+// Manager issues, authenticates and revokes tokens
+type Manager struct {
+	store Store
+	opts  *managerOpts
+}
+
+// New creates a token manager backed by the store
+func New(store Store, opts ...ManagerOpts) *Manager {
+	mo := &managerOpts{
+		prefix:     "gx_",
+		accessTTL:  time.Hour,
+		refreshTTL: 30 * 24 * time.Hour,
+	}
+	for _, f := range opts {
+		mo = f(mo)
+	}
+	return &Manager{
+		store: store,
+		opts:  mo,
+	}
+}
+
+// IssueOpts customise a single issued token
+type IssueOpts func(*Token) *Token
+
+// Issue creates and stores a new token, returning the raw value to hand to the client
+func (m *Manager) Issue(ctx context.Context, principal, kind string, opts ...IssueOpts) (string, *Token, error) {
+	raw, err := m.random(32)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	raw = m.opts.prefix + raw
+	t := &Token{
+		ID:        id,
+		Principal: principal,
+		Kind:      kind,
+		Hash:      Hash(raw),
+		CreatedAt: time.Now(),
+	}
+	ttl := m.opts.accessTTL
+	if kind == KindRefresh {
+		ttl = m.opts.refreshTTL
+	}
+	if ttl > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(ttl)
+	}
+	for _, f := range opts {
+		t = f(t)
+	}
+
+	if err := m.store.Create(ctx, t); err != nil {
+		return "", nil, err
+	}
+	return raw, t, nil
+}
+
+// Authenticate looks up a raw token, checking it has not expired or been revoked
+func (m *Manager) Authenticate(ctx context.Context, raw string) (*Token, error) {
+	if !strings.HasPrefix(raw, m.opts.prefix) {
+		return nil, ErrNotFound
+	}
+	t, err := m.store.GetByHash(ctx, Hash(raw))
+	if err != nil {
+		return nil, err
+	}
+	if t.Revoked {
+		return nil, ErrRevoked
+	}
+	if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return t, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+// The presented refresh token is revoked, so each can only be used once.
+func (m *Manager) Refresh(ctx context.Context, raw string) (access, refresh string, err error) {
+	t, err := m.Authenticate(ctx, raw)
+	if err != nil {
+		return "", "", err
+	}
+	if t.Kind != KindRefresh {
+		return "", "", ErrKind
+	}
+	// Revoke atomically, so concurrent requests presenting the same refresh token can't both succeed
+	if ok, err := m.store.RevokeActive(ctx, t.ID); err != nil {
+		return "", "", err
+	} else if !ok {
+		return "", "", ErrRevoked
+	}
+
+	scopes := WithScopes(t.Scopes...)
+	if access, _, err = m.Issue(ctx, t.Principal, KindAccess, scopes); err != nil {
+		return "", "", err
+	}
+	if refresh, _, err = m.Issue(ctx, t.Principal, KindRefresh, scopes); err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// Revoke revokes a token by ID
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	return m.store.Revoke(ctx, id)
+}
+
+// Auth returns a middleware authenticating access tokens from the Authorization bearer header.
+// Requests without a valid token are aborted with 401, and if the store fails with 503 "auth_unavailable".
+func (m *Manager) Auth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw, ok := bearer(ctx)
+		if !ok {
+			ginxerrors.AbortWith(ctx, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		t, err := m.Authenticate(ctx, raw)
+		if err == nil && t.Kind != KindAccess {
+			err = ErrKind
+		}
+		switch {
+		case isInvalid(err):
+			zlog.GetLogger(ctx).Info().Err(err).Msg("token authentication failed")
+			ginxerrors.AbortWith(ctx, http.StatusUnauthorized, "unauthorized")
+			return
+		case err != nil:
+			zlog.GetLogger(ctx).Error().Err(err).Msg("token lookup failed")
+			ginxerrors.AbortWithError(ctx, err, http.StatusServiceUnavailable, "auth_unavailable")
+			return
+		}
+
+		ctx.Set(TokenKey, t)
+		ctx.Set(PrincipalKey, t.Principal)
+	}
+}
+
+// Report whether the error is a rejection of the token, rather than a failure to look it up
+func isInvalid(err error) bool {
+	for _, target := range []error{ErrNotFound, ErrRevoked, ErrExpired, ErrKind} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListHandler returns a handler listing the tokens of the authenticated principal
+func (m *Manager) ListHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		list, err := m.store.List(ctx, ctx.GetString(PrincipalKey))
+		if ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "internal_error") {
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"tokens": list})
+	}
+}
+
+// RevokeHandler returns a handler revoking the token identified by the :id path parameter,
+// if it belongs to the authenticated principal
+func (m *Manager) RevokeHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t, err := m.store.Get(ctx, ctx.Param("id"))
+		if err == nil && t.Principal != ctx.GetString(PrincipalKey) {
+			// Don't reveal the existence of other principals' tokens
+			err = ErrNotFound
+		}
+		if errors.Is(err, ErrNotFound) {
+			ginxerrors.AbortWith(ctx, http.StatusNotFound, "not_found")
+			return
+		}
+		if ginxerrors.AbortWithError(ctx, err, http.StatusInternalServerError, "internal_error") {
+			return
+		}
+		if ginxerrors.AbortWithError(ctx, m.store.Revoke(ctx, t.ID), http.StatusInternalServerError, "internal_error") {
+			return
+		}
+		zlog.GetLogger(ctx).Info().Str("token_id", t.ID).Msg("token revoked")
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// Get returns the authenticated token from the context, or nil
+func Get(ctx *gin.Context) *Token {
+	if v, ok := ctx.Get(TokenKey); ok {
+		return v.(*Token)
+	}
+	return nil
+}
+
+// Hash returns the stored hash of a raw token
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func bearer(ctx *gin.Context) (string, bool) {
+	h := ctx.GetHeader("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(h[7:]), true
+}
+
+func (m *Manager) random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// WithName sets a descriptive name on an issued token
+func WithName(name string) IssueOpts {
+	return func(t *Token) *Token {
+		t.Name = name
+		return t
+	}
+}
+
+// WithScopes sets the scopes of an issued token
+func WithScopes(scopes ...string) IssueOpts {
+	return func(t *Token) *Token {
+		t.Scopes = scopes
+		return t
+	}
+}
+
+// WithExpiry overrides the lifetime of an issued token, zero for no expiry
+func WithExpiry(ttl time.Duration) IssueOpts {
+	return func(t *Token) *Token {
+		t.ExpiresAt = time.Time{}
+		if ttl > 0 {
+			t.ExpiresAt = t.CreatedAt.Add(ttl)
+		}
+		return t
+	}
+}
+
+// WithPrefix sets the prefix of raw tokens, default "gx_"
+func WithPrefix(prefix string) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.prefix = prefix
+		return mo
+	}
+}
+
+// WithTTL sets the default lifetimes of access and refresh tokens
+func WithTTL(access, refresh time.Duration) ManagerOpts {
+	return func(mo *managerOpts) *managerOpts {
+		mo.accessTTL = access
+		mo.refreshTTL = refresh
+		return mo
+	}
+}
+
+// MemoryStore is an in-memory Store for tests and single instance deployments
+type MemoryStore struct {
+	mu     sync.RWMutex
+	byID   map[string]*Token
+	byHash map[string]*Token
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byID:   map[string]*Token{},
+		byHash: map[string]*Token{},
+	}
+}
+
+// Create implements Store
+func (s *MemoryStore) Create(_ context.Context, t *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID[t.ID] = t
+	s.byHash[t.Hash] = t
+	return nil
+}
+
+// GetByHash implements Store
+func (s *MemoryStore) GetByHash(_ context.Context, hash string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if t, ok := s.byHash[hash]; ok {
+		c := *t
+		return &c, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, id string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if t, ok := s.byID[id]; ok {
+		c := *t
+		return &c, nil
+	}
+	return nil, ErrNotFound
+}
+
+// List implements Store
+func (s *MemoryStore) List(_ context.Context, principal string) ([]*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []*Token{}
+	for _, t := range s.byID {
+		if t.Principal == principal {
+			c := *t
+			res = append(res, &c)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+// Revoke implements Store
+func (s *MemoryStore) Revoke(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	t.Revoked = true
+	return nil
+}
+
+// RevokeActive implements Store
+func (s *MemoryStore) RevokeActive(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.byID[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if t.Revoked {
+		return false, nil
+	}
+	t.Revoked = true
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAuthenticate(t *testing.T) {
+	m := New(NewMemoryStore())
+	ctx := context.Background()
+
+	raw, tok, err := m.Issue(ctx, "alice", KindAccess, WithScopes("read"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "gx_"))
+	assert.NotContains(t, tok.Hash, raw)
+
+	res, err := m.Authenticate(ctx, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", res.Principal)
+	assert.True(t, res.HasScope("read"))
+
+	assert.NoError(t, m.Revoke(ctx, tok.ID))
+	_, err = m.Authenticate(ctx, raw)
+	assert.ErrorIs(t, err, ErrRevoked)
+
+	_, err = m.Authenticate(ctx, "gx_unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestExpired(t *testing.T) {
+	m := New(NewMemoryStore())
+	raw, _, _ := m.Issue(context.Background(), "alice", KindAccess, WithExpiry(time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	_, err := m.Authenticate(context.Background(), raw)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestRefresh(t *testing.T) {
+	m := New(NewMemoryStore())
+	ctx := context.Background()
+
+	refresh, _, _ := m.Issue(ctx, "alice", KindRefresh)
+	access, refresh2, err := m.Refresh(ctx, refresh)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, access)
+	assert.NotEmpty(t, refresh2)
+
+	// Refresh tokens are single use
+	_, _, err = m.Refresh(ctx, refresh)
+	assert.ErrorIs(t, err, ErrRevoked)
+
+	// Access tokens can't be used to refresh
+	_, _, err = m.Refresh(ctx, access)
+	assert.ErrorIs(t, err, ErrKind)
+
+	// Only one of concurrent refreshes succeeds
+	var wg sync.WaitGroup
+	var succeeded int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := m.Refresh(ctx, refresh2); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded)
+}
+
+type failingStore struct {
+	*MemoryStore
+}
+
+func (failingStore) Get(context.Context, string) (*Token, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+type outageStore struct {
+	*MemoryStore
+}
+
+func (outageStore) GetByHash(context.Context, string) (*Token, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestHandlers(t *testing.T) {
+	m := New(NewMemoryStore())
+	ctx := context.Background()
+	raw, own, _ := m.Issue(ctx, "alice", KindAccess)
+	_, other, _ := m.Issue(ctx, "bob", KindAccess)
+
+	e := gin.New()
+	api := e.Group("", m.Auth())
+	api.GET("/tokens", m.ListHandler())
+	api.DELETE("/tokens/:id", m.RevokeHandler())
+
+	// Unauthenticated
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/tokens", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Result().StatusCode)
+
+	// List own tokens only
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	var res struct {
+		Tokens []*Token `json:"tokens"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	assert.Len(t, res.Tokens, 1)
+	assert.Equal(t, own.ID, res.Tokens[0].ID)
+
+	// Can't revoke another principal's token
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/tokens/"+other.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Result().StatusCode)
+
+	// Revoke own token
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/tokens/"+own.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Result().StatusCode)
+
+	// Store failures aren't reported as not found
+	m = New(failingStore{NewMemoryStore()})
+	raw, _, _ = m.Issue(ctx, "alice", KindAccess)
+	e = gin.New()
+	e.DELETE("/tokens/:id", m.Auth(), m.RevokeHandler())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/tokens/x", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Result().StatusCode)
+	// Store outages aren't reported as invalid credentials
+	m = New(outageStore{NewMemoryStore()})
+	raw, _, _ = m.Issue(ctx, "alice", KindAccess)
+	e = gin.New()
+	e.GET("/tokens", m.Auth(), m.ListHandler())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 503, w.Result().StatusCode)
+	assert.Contains(t, w.Body.String(), "auth_unavailable")
+}
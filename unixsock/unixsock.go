@@ -0,0 +1,259 @@
+//go:build unix
+
+// Unix sockets and socket activation
+//
+// Listeners for serving on Unix domain sockets, and for receiving listeners from systemd socket activation or
+// a parent process, so services can restart without dropping connections.
+//
+// Unix sockets are created with the given permissions, replacing any stale socket left by a previous process,
+// and removed when the listener is closed:
+//
+//	l, err := unixsock.Listen("/run/app/app.sock", unixsock.WithMode(0660))
+//	http.Serve(l, e)
+//
+// Activated listeners are looked up by name (FileDescriptorName= in the systemd socket unit), falling back to
+// creating a listener when not activated:
+//
+//	l, err := unixsock.Named("http", func() (net.Listener, error) { return net.Listen("tcp", ":8080") })
+//
+// For zero-downtime restarts, Handoff passes listeners to a replacement process, which receives them through
+// the same activation API.
+package unixsock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Environment variables of the systemd socket activation protocol, plus LISTEN_PPID used by Handoff
+const (
+	envPID   = "LISTEN_PID"
+	envPPID  = "LISTEN_PPID"
+	envFDs   = "LISTEN_FDS"
+	envNames = "LISTEN_FDNAMES"
+)
+
+// First file descriptor passed by socket activation
+const listenFDStart = 3
+
+type listenOpts struct {
+	mode  fs.FileMode // Socket file permissions
+	uid   int         // Socket owner, -1 to leave unchanged
+	gid   int         // Socket group, -1 to leave unchanged
+	clean bool        // Remove stale socket files
+}
+
+// Modifier function for customising unix socket creation
+type ListenOpts func(*listenOpts) *listenOpts
+
+// Listen creates a Unix domain socket listener at path. An existing socket file is removed if no process
+// is accepting connections on it. The socket file is removed when the listener is closed.
+func Listen(path string, opts ...ListenOpts) (net.Listener, error) {
+	lo := &listenOpts{
+		mode:  0660,
+		uid:   -1,
+		gid:   -1,
+		clean: true,
+	}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+
+	if lo.clean {
+		if err := removeStale(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	l.SetUnlinkOnClose(true)
+
+	if err := os.Chmod(path, lo.mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if lo.uid >= 0 || lo.gid >= 0 {
+		if err := os.Chown(path, lo.uid, lo.gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Remove a socket file if nothing is listening on it. Files which aren't sockets are left in place.
+func removeStale(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("unixsock: %s exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unixsock: %s is in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// WithMode sets the socket file permissions, defaults to 0660
+func WithMode(mode fs.FileMode) ListenOpts {
+	return func(lo *listenOpts) *listenOpts {
+		lo.mode = mode
+		return lo
+	}
+}
+
+// WithOwner sets the socket file owner and group, -1 leaves either unchanged
+func WithOwner(uid, gid int) ListenOpts {
+	return func(lo *listenOpts) *listenOpts {
+		lo.uid = uid
+		lo.gid = gid
+		return lo
+	}
+}
+
+// WithCleanup enables or disables removal of stale socket files, enabled by default
+func WithCleanup(clean bool) ListenOpts {
+	return func(lo *listenOpts) *listenOpts {
+		lo.clean = clean
+		return lo
+	}
+}
+
+var (
+	activatedOnce   sync.Once
+	activatedMu     sync.Mutex
+	activatedErr    error
+	activatedByName map[string][]net.Listener
+)
+
+// Activated returns the listeners passed by systemd socket activation or Handoff, keyed by name. Sockets
+// without a name are keyed "unknown", as systemd does. The environment variables are cleared so that child
+// processes don't inherit them, and repeated calls return the remaining unclaimed listeners.
+func Activated() (map[string][]net.Listener, error) {
+	activatedOnce.Do(func() {
+		activatedByName, activatedErr = activated(os.Getenv, listenFDStart)
+		for _, k := range []string{envPID, envPPID, envFDs, envNames} {
+			os.Unsetenv(k)
+		}
+	})
+
+	activatedMu.Lock()
+	defer activatedMu.Unlock()
+	res := map[string][]net.Listener{}
+	for k, v := range activatedByName {
+		res[k] = v
+	}
+	return res, activatedErr
+}
+
+// Named returns the first activated listener with the name, claiming it so it isn't returned again.
+// If there is none, fallback is called to create the listener.
+func Named(name string, fallback func() (net.Listener, error)) (net.Listener, error) {
+	if _, err := Activated(); err != nil {
+		return nil, err
+	}
+
+	activatedMu.Lock()
+	ls := activatedByName[name]
+	if len(ls) > 0 {
+		activatedByName[name] = ls[1:]
+	}
+	activatedMu.Unlock()
+
+	if len(ls) > 0 {
+		return ls[0], nil
+	}
+	return fallback()
+}
+
+func activated(getenv func(string) string, start int) (map[string][]net.Listener, error) {
+	pid := strconv.Itoa(os.Getpid())
+	ppid := strconv.Itoa(os.Getppid())
+	if getenv(envPID) != pid && getenv(envPPID) != ppid {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(getenv(envFDs))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("unixsock: invalid %s", envFDs)
+	}
+	var names []string
+	if v := getenv(envNames); v != "" {
+		names = strings.Split(v, ":")
+	}
+
+	res := map[string][]net.Listener{}
+	for i := 0; i < n; i++ {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unixsock: fd %d (%s): %w", fd, name, err)
+		}
+		res[name] = append(res[name], l)
+	}
+	return res, nil
+}
+
+// Handoff configures cmd to receive the named listeners, for starting a replacement process which takes over
+// serving. The new process receives them through Activated or Named. Once it is ready, the old process should
+// stop accepting and shut down gracefully; Unix socket listeners passed this way must not unlink on close.
+func Handoff(cmd *exec.Cmd, listeners map[string]net.Listener) error {
+	names := make([]string, 0, len(listeners))
+	for name, l := range listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("unixsock: listener %s of type %T can't be passed", name, l)
+		}
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+		names = append(names, name)
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// ExtraFiles start at fd 3, matching socket activation
+	cmd.Env = append(cmd.Env,
+		envPPID+"="+strconv.Itoa(os.Getpid()),
+		envFDs+"="+strconv.Itoa(len(names)),
+		envNames+"="+strings.Join(names, ":"),
+	)
+	return nil
+}
@@ -0,0 +1,134 @@
+//go:build unix
+
+package unixsock
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	l, err := Listen(path, WithMode(0600))
+	assert.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// In use
+	_, err = Listen(path)
+	assert.ErrorContains(t, err, "in use")
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", path) },
+	}}
+	res, err := client.Get("http://unix/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	res.Body.Close()
+
+	l.Close()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// Leave a socket file behind without a listener
+	l, _ := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	l.SetUnlinkOnClose(false)
+	l.Close()
+
+	_, err := Listen(path, WithCleanup(false))
+	assert.Error(t, err)
+
+	l2, err := Listen(path)
+	assert.NoError(t, err)
+	l2.Close()
+}
+
+func TestListenNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, []byte("data"), 0600)
+
+	_, err := Listen(path)
+
+	assert.ErrorContains(t, err, "not a socket")
+}
+
+func TestActivated(t *testing.T) {
+	l1, _ := net.Listen("tcp", "127.0.0.1:0")
+	l2, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l1.Close()
+	defer l2.Close()
+	f1, _ := l1.(*net.TCPListener).File()
+	f2, _ := l2.(*net.TCPListener).File()
+
+	// Activation passes consecutive descriptors
+	start := int(f1.Fd())
+	if int(f2.Fd()) != start+1 {
+		t.Skip("descriptors not consecutive")
+	}
+
+	env := map[string]string{
+		envPID:   strconv.Itoa(os.Getpid()),
+		envFDs:   "2",
+		envNames: "http:",
+	}
+	res, err := activated(func(k string) string { return env[k] }, start)
+
+	assert.NoError(t, err)
+	assert.Len(t, res["http"], 1)
+	assert.Len(t, res["unknown"], 1)
+	assert.Equal(t, l1.Addr().String(), res["http"][0].Addr().String())
+}
+
+func TestActivatedOtherProcess(t *testing.T) {
+	env := map[string]string{envPID: "1", envFDs: "1"}
+
+	res, err := activated(func(k string) string { return env[k] }, listenFDStart)
+
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
+
+func TestNamedFallback(t *testing.T) {
+	called := false
+	l, err := Named("missing", func() (net.Listener, error) {
+		called = true
+		return net.Listen("tcp", "127.0.0.1:0")
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, called)
+	l.Close()
+}
+
+func TestHandoff(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	cmd := exec.Command("true")
+	cmd.Env = []string{}
+	assert.NoError(t, Handoff(cmd, map[string]net.Listener{"http": l}))
+
+	assert.Len(t, cmd.ExtraFiles, 1)
+	assert.Equal(t, []string{
+		envPPID + "=" + strconv.Itoa(os.Getpid()),
+		envFDs + "=1",
+		envNames + "=http",
+	}, cmd.Env)
+}
@@ -0,0 +1,185 @@
+// Runtime stats
+//
+// Admin endpoint reporting process uptime, goroutine count, memory and GC statistics, and per-route request totals,
+// for quick checks without a metrics stack.
+//
+//	counter := uptime.NewCounter()
+//	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithMetrics(counter)))
+//	admin.GET("/stats", uptime.Handler(counter))
+//
+// The counter can forward to another recorder, e.g. uptime.NewCounter(zlog.NewPrometheus()), so both are fed from
+// the same middleware.
+package uptime
+
+import (
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+var started = time.Now()
+
+// Counter is a zlog.Recorder keeping request totals by route
+type Counter struct {
+	next []zlog.Recorder
+
+	mu     sync.Mutex
+	routes map[string]*RouteStats
+}
+
+// RouteStats are the request totals of a route
+type RouteStats struct {
+	Method    string            `json:"method"`
+	Route     string            `json:"route"`
+	Requests  uint64            `json:"requests"`
+	InFlight  int64             `json:"in_flight"`
+	Statuses  map[string]uint64 `json:"statuses"` // By status class, e.g. 2xx
+	TotalTime time.Duration     `json:"-"`
+	MeanMs    float64           `json:"mean_ms"`
+}
+
+// NewCounter creates a counter, forwarding to the next recorders
+func NewCounter(next ...zlog.Recorder) *Counter {
+	return &Counter{next: next, routes: map[string]*RouteStats{}}
+}
+
+func (c *Counter) get(method, route string) *RouteStats {
+	k := method + " " + route
+	rs, ok := c.routes[k]
+	if !ok {
+		rs = &RouteStats{Method: method, Route: route, Statuses: map[string]uint64{}}
+		c.routes[k] = rs
+	}
+	return rs
+}
+
+// Start implements zlog.Recorder
+func (c *Counter) Start(method, route string) {
+	c.mu.Lock()
+	c.get(method, route).InFlight++
+	c.mu.Unlock()
+
+	for _, r := range c.next {
+		r.Start(method, route)
+	}
+}
+
+// Done implements zlog.Recorder
+func (c *Counter) Done(method, route string, status int, elapsed time.Duration) {
+	c.mu.Lock()
+	rs := c.get(method, route)
+	rs.InFlight--
+	rs.Requests++
+	rs.Statuses[strconv.Itoa(status/100)+"xx"]++
+	rs.TotalTime += elapsed
+	c.mu.Unlock()
+
+	for _, r := range c.next {
+		r.Done(method, route, status, elapsed)
+	}
+}
+
+// Routes returns a copy of the route totals, sorted by route then method
+func (c *Counter) Routes() []RouteStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make([]RouteStats, 0, len(c.routes))
+	for _, rs := range c.routes {
+		cp := *rs
+		cp.Statuses = map[string]uint64{}
+		for k, v := range rs.Statuses {
+			cp.Statuses[k] = v
+		}
+		if cp.Requests > 0 {
+			cp.MeanMs = float64(cp.TotalTime) / float64(cp.Requests) / float64(time.Millisecond)
+		}
+		res = append(res, cp)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Route != res[j].Route {
+			return res[i].Route < res[j].Route
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// Stats is the response of Handler
+type Stats struct {
+	Started    time.Time    `json:"started"`
+	Uptime     string       `json:"uptime"`
+	UptimeSecs int64        `json:"uptime_seconds"`
+	Goroutines int          `json:"goroutines"`
+	CPUs       int          `json:"cpus"`
+	Memory     Memory       `json:"memory"`
+	GC         GC           `json:"gc"`
+	Routes     []RouteStats `json:"routes,omitempty"`
+}
+
+// Memory statistics in bytes
+type Memory struct {
+	Alloc      uint64 `json:"alloc"`       // Live heap objects
+	TotalAlloc uint64 `json:"total_alloc"` // Cumulative heap allocations
+	Sys        uint64 `json:"sys"`         // Obtained from the OS
+	HeapInuse  uint64 `json:"heap_inuse"`
+	StackInuse uint64 `json:"stack_inuse"`
+}
+
+// GC statistics
+type GC struct {
+	Count       uint32    `json:"count"`
+	PauseTotal  string    `json:"pause_total"`
+	LastPause   string    `json:"last_pause"`
+	Last        time.Time `json:"last,omitempty"`
+	CPUFraction float64   `json:"cpu_fraction"`
+}
+
+// Collect returns the current stats. The counter may be nil to omit route totals.
+// Reading memory statistics briefly stops the world, so this shouldn't be polled at a high rate.
+func Collect(c *Counter) Stats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	up := time.Since(started)
+	s := Stats{
+		Started:    started,
+		Uptime:     up.Round(time.Second).String(),
+		UptimeSecs: int64(up.Seconds()),
+		Goroutines: runtime.NumGoroutine(),
+		CPUs:       runtime.NumCPU(),
+		Memory: Memory{
+			Alloc:      ms.Alloc,
+			TotalAlloc: ms.TotalAlloc,
+			Sys:        ms.Sys,
+			HeapInuse:  ms.HeapInuse,
+			StackInuse: ms.StackInuse,
+		},
+		GC: GC{
+			Count:       ms.NumGC,
+			PauseTotal:  time.Duration(ms.PauseTotalNs).String(),
+			CPUFraction: ms.GCCPUFraction,
+		},
+	}
+	if ms.NumGC > 0 {
+		s.GC.LastPause = time.Duration(ms.PauseNs[(ms.NumGC+255)%256]).String()
+		s.GC.Last = time.Unix(0, int64(ms.LastGC))
+	}
+	if c != nil {
+		s.Routes = c.Routes()
+	}
+	return s
+}
+
+// Handler serves the current stats as JSON. The counter may be nil to omit route totals.
+func Handler(c *Counter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Collect(c))
+	}
+}
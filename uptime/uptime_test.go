@@ -0,0 +1,59 @@
+package uptime
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.New(io.Discard)
+
+	prom := zlog.NewPrometheus()
+	counter := NewCounter(prom)
+
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel, zlog.WithMetrics(counter), zlog.WithSkipPaths("/stats")))
+	e.GET("/users/:id", func(ctx *gin.Context) {
+		if ctx.Param("id") == "0" {
+			ctx.Status(http.StatusNotFound)
+		}
+	})
+	e.GET("/stats", Handler(counter))
+
+	for _, p := range []string{"/users/1", "/users/2", "/users/0"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var s Stats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+	assert.Greater(t, s.Goroutines, 0)
+	assert.Greater(t, s.Memory.Sys, uint64(0))
+
+	assert.Len(t, s.Routes, 2)
+	assert.Equal(t, "/stats", s.Routes[0].Route)
+	assert.EqualValues(t, 1, s.Routes[0].InFlight)
+	assert.Equal(t, "/users/:id", s.Routes[1].Route)
+	assert.EqualValues(t, 3, s.Routes[1].Requests)
+	assert.Equal(t, map[string]uint64{"2xx": 2, "4xx": 1}, s.Routes[1].Statuses)
+
+	out := &bytes.Buffer{}
+	_, _ = prom.WriteTo(out)
+	assert.Contains(t, out.String(), `http_requests_total{method="GET",route="/users/:id",status="200"} 2`)
+}
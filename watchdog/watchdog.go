@@ -0,0 +1,237 @@
+// Stuck request watchdog
+//
+// Flags requests running far longer than any handler should, which are likely deadlocked or blocked on a call
+// without a timeout. Handler goroutines are labelled with a watchdog ID, so the stacks of stuck requests can be found
+// in the goroutine profile and logged through the request logger, once per request.
+//
+//	w := watchdog.New(time.Minute)
+//	go w.Run(ctx)
+//	e.Use(zlog.Logger(zerolog.InfoLevel), w.Middleware())
+//	admin.GET("/stuck", w.Handler())
+//
+// Goroutines started by a handler inherit its label, so their stacks are included too.
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+)
+
+// Goroutine label key
+const labelKey = "watchdog"
+
+// Request is an in-flight request exceeding the threshold
+type Request struct {
+	RequestID string        `json:"request_id,omitempty"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Route     string        `json:"route,omitempty"`
+	Start     time.Time     `json:"start"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Stacks    []string      `json:"stacks"` // Goroutine profile entries of the handler and goroutines it started
+}
+
+type watchdogOpts struct {
+	interval time.Duration
+	alert    func(Request)
+}
+
+// Modifier function for customising watchdog behaviour
+type WatchdogOpts func(*watchdogOpts) *watchdogOpts
+
+// WithInterval sets how often Run checks for stuck requests (default a quarter of the threshold)
+func WithInterval(d time.Duration) WatchdogOpts {
+	return func(wo *watchdogOpts) *watchdogOpts {
+		wo.interval = d
+		return wo
+	}
+}
+
+// WithAlertFunc sets a function called once for each newly stuck request, in addition to logging
+func WithAlertFunc(fn func(Request)) WatchdogOpts {
+	return func(wo *watchdogOpts) *watchdogOpts {
+		wo.alert = fn
+		return wo
+	}
+}
+
+// Watchdog tracks in-flight requests
+type Watchdog struct {
+	threshold time.Duration
+	opts      *watchdogOpts
+
+	seq    uint64
+	mu     sync.Mutex
+	active map[string]*entry
+}
+
+type entry struct {
+	req     Request
+	logger  *zerolog.Logger
+	flagged bool
+}
+
+// New creates a watchdog flagging requests running longer than the threshold
+func New(threshold time.Duration, opts ...WatchdogOpts) *Watchdog {
+	wo := &watchdogOpts{interval: threshold / 4}
+	for _, f := range opts {
+		wo = f(wo)
+	}
+	return &Watchdog{threshold: threshold, opts: wo, active: map[string]*entry{}}
+}
+
+// Middleware tracks each request and labels its goroutine. Use after zlog.Logger() so stuck requests are logged
+// with the request ID.
+func (w *Watchdog) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := strconv.FormatUint(atomic.AddUint64(&w.seq, 1), 10)
+		w.mu.Lock()
+		w.active[id] = &entry{
+			req: Request{
+				RequestID: zlog.GetRequestID(ctx),
+				Method:    ctx.Request.Method,
+				Path:      ctx.Request.URL.Path,
+				Route:     ctx.FullPath(),
+				Start:     time.Now(),
+			},
+			logger: zlog.GetLogger(ctx),
+		}
+		w.mu.Unlock()
+
+		defer func() {
+			w.mu.Lock()
+			e := w.active[id]
+			delete(w.active, id)
+			w.mu.Unlock()
+			if e.flagged {
+				e.logger.Warn().Dur("elapsed", time.Since(e.req.Start)).Msg("Stuck request completed")
+			}
+		}()
+
+		pprof.Do(ctx.Request.Context(), pprof.Labels(labelKey, id), func(context.Context) {
+			ctx.Next()
+		})
+	}
+}
+
+// Run checks for stuck requests at the interval until the context is cancelled
+func (w *Watchdog) Run(ctx context.Context) {
+	t := time.NewTicker(w.opts.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.Check()
+		}
+	}
+}
+
+// Check logs requests which have become stuck since the last check, and returns them
+func (w *Watchdog) Check() []Request {
+	var res []Request
+	for _, e := range w.stuck(true) {
+		event := e.logger.Error().
+			Str("method", e.req.Method).
+			Str("route", e.req.Route).
+			Dur("elapsed", e.req.Elapsed)
+		if len(e.req.Stacks) > 0 {
+			event.Strs("stacks", e.req.Stacks)
+		}
+		event.Msg("Request stuck")
+		if w.opts.alert != nil {
+			w.opts.alert(e.req)
+		}
+		res = append(res, e.req)
+	}
+	return res
+}
+
+// Stuck returns all requests currently exceeding the threshold, longest running first
+func (w *Watchdog) Stuck() []Request {
+	var res []Request
+	for _, e := range w.stuck(false) {
+		res = append(res, e.req)
+	}
+	return res
+}
+
+// Handler responds with the currently stuck requests
+func (w *Watchdog) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		stuck := w.Stuck()
+		if stuck == nil {
+			stuck = []Request{}
+		}
+		ctx.JSON(http.StatusOK, gin.H{"threshold": w.threshold.String(), "stuck": stuck})
+	}
+}
+
+// Find stuck requests, optionally only those not yet flagged, and attach their stacks. Returns copies.
+func (w *Watchdog) stuck(onlyNew bool) []entry {
+	now := time.Now()
+	var res []entry
+	ids := map[string]int{}
+
+	w.mu.Lock()
+	for id, e := range w.active {
+		elapsed := now.Sub(e.req.Start)
+		if elapsed < w.threshold || (onlyNew && e.flagged) {
+			continue
+		}
+		if onlyNew {
+			e.flagged = true
+		}
+		c := *e
+		c.req.Elapsed = elapsed
+		ids[id] = len(res)
+		res = append(res, c)
+	}
+	w.mu.Unlock()
+
+	if len(res) == 0 {
+		return nil
+	}
+	for id, stacks := range labelledStacks() {
+		if i, ok := ids[id]; ok {
+			res[i].req.Stacks = stacks
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].req.Elapsed > res[j].req.Elapsed })
+	return res
+}
+
+// Return goroutine profile entries by watchdog label
+func labelledStacks() map[string][]string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+	prefix := `"` + labelKey + `":"`
+	res := map[string][]string{}
+	for _, block := range strings.Split(buf.String(), "\n\n") {
+		i := strings.Index(block, prefix)
+		if i < 0 {
+			continue
+		}
+		id := block[i+len(prefix):]
+		if j := strings.IndexByte(id, '"'); j >= 0 {
+			id = id[:j]
+		}
+		res[id] = append(res[id], strings.TrimSpace(block))
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package watchdog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func blockedHandler(release chan struct{}) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		<-release
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &syncBuffer{}
+	log.Logger = zerolog.New(buf)
+
+	var alerts []Request
+	w := New(20*time.Millisecond, WithAlertFunc(func(r Request) {
+		alerts = append(alerts, r)
+	}))
+
+	release := make(chan struct{})
+	e := gin.New()
+	e.Use(zlog.Logger(zerolog.InfoLevel), w.Middleware())
+	e.GET("/stuck/:id", blockedHandler(release))
+	e.GET("/fast", func(ctx *gin.Context) {})
+	e.GET("/admin/stuck", w.Handler())
+
+	done := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("GET", "/stuck/1", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	req, _ := http.NewRequest("GET", "/fast", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, w.Check())
+	time.Sleep(30 * time.Millisecond)
+
+	stuck := w.Check()
+	assert.Len(t, stuck, 1)
+	assert.Equal(t, "/stuck/:id", stuck[0].Route)
+	assert.Len(t, stuck[0].Stacks, 1)
+	assert.Contains(t, stuck[0].Stacks[0], "watchdog.blockedHandler")
+	assert.Len(t, alerts, 1)
+	assert.Contains(t, buf.String(), `"message":"Request stuck"`)
+
+	// Only flagged once
+	assert.Empty(t, w.Check())
+
+	rec := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/stuck", nil)
+	e.ServeHTTP(rec, req)
+	var res struct {
+		Stuck []Request `json:"stuck"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Len(t, res.Stuck, 1)
+	assert.Equal(t, "/stuck/1", res.Stuck[0].Path)
+
+	close(release)
+	<-done
+	assert.Empty(t, w.Stuck())
+	assert.Contains(t, buf.String(), `"message":"Stuck request completed"`)
+}
+
+// Buffer safe for the concurrent request logging above
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,137 @@
+package wellknown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/zlog"
+)
+
+// Timeout of upstream fetches, which aren't bound to the request that started them
+const openIDTimeout = 10 * time.Second
+
+// Minimum time between upstream fetches after a failure
+const openIDRetry = 30 * time.Second
+
+// OpenIDProxy serves an upstream identity provider's openid-configuration document, so clients can discover
+// it from the application's own origin. The document is cached, and a stale copy is served if a refresh fails.
+// One fetch runs at a time, without holding the lock, and concurrent requests share its result.
+type OpenIDProxy struct {
+	Issuer    string            // Upstream issuer URL, the document is fetched from Issuer + /.well-known/openid-configuration
+	TTL       time.Duration     // Cache duration, defaults to one hour
+	Overrides map[string]string // Top level string fields to replace in the document, e.g. a proxied token_endpoint
+	Client    *http.Client      // Defaults to a client with a 10 second timeout
+
+	mu       sync.Mutex
+	body     []byte
+	expires  time.Time
+	err      error         // Error of the last fetch
+	tried    time.Time     // Last fetch attempt
+	fetching chan struct{} // Closed when the fetch in progress completes, nil if none
+}
+
+// Handler returns a handler serving the proxied document
+func (p *OpenIDProxy) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body, err := p.Document(ctx)
+		if err != nil {
+			zlog.GetLogger(ctx).Error().Err(err).Msg("Failed to fetch openid-configuration")
+			if body == nil {
+				ctx.AbortWithStatus(http.StatusBadGateway)
+				return
+			}
+		}
+		ctx.Data(http.StatusOK, "application/json", body)
+	}
+}
+
+// Document returns the cached document, refreshing it if expired. On refresh failure any stale copy is
+// returned along with the error, and the upstream isn't retried for 30 seconds.
+func (p *OpenIDProxy) Document(ctx context.Context) ([]byte, error) {
+	p.mu.Lock()
+	if p.body != nil && time.Now().Before(p.expires) {
+		body := p.body
+		p.mu.Unlock()
+		return body, nil
+	}
+	wait := p.fetching
+	if wait == nil && (p.err == nil || time.Since(p.tried) >= openIDRetry) {
+		wait = make(chan struct{})
+		p.fetching = wait
+		p.tried = time.Now()
+		go p.refresh(wait)
+	}
+	body, err := p.body, p.err
+	p.mu.Unlock()
+
+	if wait == nil {
+		return body, err
+	}
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		return body, ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.body, p.err
+}
+
+// Fetch the document and replace the cache, then close done. Not bound to a request context, as requests share the
+// result.
+func (p *OpenIDProxy) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), openIDTimeout)
+	defer cancel()
+	body, err := p.fetch(ctx)
+
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	p.mu.Lock()
+	if err == nil {
+		p.body = body
+		p.expires = time.Now().Add(ttl)
+	}
+	p.err = err
+	p.fetching = nil
+	p.mu.Unlock()
+	close(done)
+}
+
+func (p *OpenIDProxy) fetch(ctx context.Context) ([]byte, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: openIDTimeout}
+	}
+
+	url := strings.TrimSuffix(p.Issuer, "/") + Prefix + "/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wellknown: upstream returned %d", res.StatusCode)
+	}
+
+	doc := map[string]interface{}{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("wellknown: invalid openid-configuration: %w", err)
+	}
+	for k, v := range p.Overrides {
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
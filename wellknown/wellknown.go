@@ -0,0 +1,168 @@
+// Well-known discovery documents
+//
+// Handlers for common documents served under /.well-known/, generated from configuration at startup:
+//   - security.txt (RFC 9116)
+//   - change-password redirect (W3C well-known URL for changing passwords)
+//   - openid-configuration, proxied and cached from an upstream identity provider
+//   - apple-app-site-association for iOS universal links and shared credentials
+//   - assetlinks.json for Android app links
+//
+// Only documents with configuration are mounted:
+//
+//	wellknown.Mount(e, wellknown.Config{
+//		SecurityTxt:       &wellknown.SecurityTxt{Contact: []string{"mailto:security@example.com"}, Expires: expiry},
+//		ChangePasswordURL: "/account/password",
+//		AssetLinks:        []wellknown.AssetLink{wellknown.AndroidApp("com.example.app", fingerprint)},
+//	})
+package wellknown
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prefix is the path prefix of all well-known documents
+const Prefix = "/.well-known"
+
+// Config describes the documents to serve. Nil or empty fields are not mounted.
+type Config struct {
+	SecurityTxt       *SecurityTxt
+	ChangePasswordURL string            // Redirect target for /.well-known/change-password
+	OpenID            *OpenIDProxy      // Upstream openid-configuration to proxy
+	AppleAssociation  *AppleAssociation // apple-app-site-association document
+	AssetLinks        []AssetLink       // assetlinks.json statements
+}
+
+// SecurityTxt holds the fields of a security.txt file. Contact and Expires are required.
+type SecurityTxt struct {
+	Contact            []string  // mailto:, tel: or https: URIs
+	Expires            time.Time // Should be less than a year in the future
+	Encryption         []string  // URIs of encryption keys
+	Acknowledgments    []string
+	PreferredLanguages []string
+	Canonical          []string
+	Policy             []string
+	Hiring             []string
+}
+
+// AppleAssociation is an apple-app-site-association document
+type AppleAssociation struct {
+	AppLinks       *AppleAppLinks `json:"applinks,omitempty"`
+	WebCredentials *AppleApps     `json:"webcredentials,omitempty"`
+	AppClips       *AppleApps     `json:"appclips,omitempty"`
+}
+
+// AppleAppLinks describes the universal links handled by apps
+type AppleAppLinks struct {
+	Details []AppleAppLinkDetail `json:"details"`
+}
+
+// AppleAppLinkDetail maps app IDs to URL components
+type AppleAppLinkDetail struct {
+	AppIDs     []string            `json:"appIDs"`
+	Components []map[string]string `json:"components,omitempty"` // e.g. {"/": "/orders/*"}
+}
+
+// AppleApps lists app IDs for web credentials or app clips
+type AppleApps struct {
+	Apps []string `json:"apps"`
+}
+
+// AssetLink is a single Digital Asset Links statement
+type AssetLink struct {
+	Relation []string    `json:"relation"`
+	Target   AssetTarget `json:"target"`
+}
+
+// AssetTarget identifies the app or site a statement applies to
+type AssetTarget struct {
+	Namespace              string   `json:"namespace"`
+	PackageName            string   `json:"package_name,omitempty"`
+	SHA256CertFingerprints []string `json:"sha256_cert_fingerprints,omitempty"`
+	Site                   string   `json:"site,omitempty"`
+}
+
+// AndroidApp returns a statement allowing the app to handle all URLs of the site
+func AndroidApp(pkg string, fingerprints ...string) AssetLink {
+	return AssetLink{
+		Relation: []string{"delegate_permission/common.handle_all_urls"},
+		Target: AssetTarget{
+			Namespace:              "android_app",
+			PackageName:            pkg,
+			SHA256CertFingerprints: fingerprints,
+		},
+	}
+}
+
+// Mount registers handlers for all configured documents on the router
+func Mount(r gin.IRoutes, cfg Config) error {
+	if cfg.SecurityTxt != nil {
+		body, err := cfg.SecurityTxt.Render()
+		if err != nil {
+			return err
+		}
+		r.GET(Prefix+"/security.txt", static("text/plain; charset=utf-8", body))
+	}
+	if cfg.ChangePasswordURL != "" {
+		r.GET(Prefix+"/change-password", func(ctx *gin.Context) {
+			ctx.Redirect(http.StatusFound, cfg.ChangePasswordURL)
+		})
+	}
+	if cfg.OpenID != nil {
+		r.GET(Prefix+"/openid-configuration", cfg.OpenID.Handler())
+	}
+	if cfg.AppleAssociation != nil {
+		body, err := json.Marshal(cfg.AppleAssociation)
+		if err != nil {
+			return err
+		}
+		r.GET(Prefix+"/apple-app-site-association", static("application/json", body))
+	}
+	if len(cfg.AssetLinks) > 0 {
+		body, err := json.Marshal(cfg.AssetLinks)
+		if err != nil {
+			return err
+		}
+		r.GET(Prefix+"/assetlinks.json", static("application/json", body))
+	}
+	return nil
+}
+
+func static(contentType string, body []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// Render returns the security.txt file contents
+func (s *SecurityTxt) Render() ([]byte, error) {
+	if len(s.Contact) == 0 {
+		return nil, fmt.Errorf("wellknown: security.txt requires at least one contact")
+	}
+	if s.Expires.IsZero() {
+		return nil, fmt.Errorf("wellknown: security.txt requires an expiry")
+	}
+
+	var b strings.Builder
+	write := func(field string, values ...string) {
+		for _, v := range values {
+			b.WriteString(field + ": " + v + "\n")
+		}
+	}
+	write("Contact", s.Contact...)
+	write("Expires", s.Expires.UTC().Format(time.RFC3339))
+	write("Encryption", s.Encryption...)
+	write("Acknowledgments", s.Acknowledgments...)
+	if len(s.PreferredLanguages) > 0 {
+		write("Preferred-Languages", strings.Join(s.PreferredLanguages, ", "))
+	}
+	write("Canonical", s.Canonical...)
+	write("Policy", s.Policy...)
+	write("Hiring", s.Hiring...)
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,158 @@
+package wellknown
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(e *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestSecurityTxt(t *testing.T) {
+	e := gin.New()
+	err := Mount(e, Config{
+		SecurityTxt: &SecurityTxt{
+			Contact:            []string{"mailto:security@example.com", "https://example.com/security"},
+			Expires:            time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			PreferredLanguages: []string{"en", "fr"},
+		},
+	})
+	assert.NoError(t, err)
+
+	w := get(e, "/.well-known/security.txt")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Contact: mailto:security@example.com\n"+
+		"Contact: https://example.com/security\n"+
+		"Expires: 2030-01-01T00:00:00Z\n"+
+		"Preferred-Languages: en, fr\n", w.Body.String())
+}
+
+func TestSecurityTxtInvalid(t *testing.T) {
+	assert.Error(t, Mount(gin.New(), Config{SecurityTxt: &SecurityTxt{Expires: time.Now()}}))
+	assert.Error(t, Mount(gin.New(), Config{SecurityTxt: &SecurityTxt{Contact: []string{"mailto:a@b"}}}))
+}
+
+func TestChangePasswordAndApps(t *testing.T) {
+	e := gin.New()
+	err := Mount(e, Config{
+		ChangePasswordURL: "/account/password",
+		AppleAssociation: &AppleAssociation{
+			WebCredentials: &AppleApps{Apps: []string{"TEAM.com.example.app"}},
+		},
+		AssetLinks: []AssetLink{AndroidApp("com.example.app", "AB:CD")},
+	})
+	assert.NoError(t, err)
+
+	w := get(e, "/.well-known/change-password")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/account/password", w.Header().Get("Location"))
+
+	w = get(e, "/.well-known/apple-app-site-association")
+	assert.JSONEq(t, `{"webcredentials":{"apps":["TEAM.com.example.app"]}}`, w.Body.String())
+
+	w = get(e, "/.well-known/assetlinks.json")
+	assert.JSONEq(t, `[{"relation":["delegate_permission/common.handle_all_urls"],
+		"target":{"namespace":"android_app","package_name":"com.example.app","sha256_cert_fingerprints":["AB:CD"]}}]`, w.Body.String())
+
+	w = get(e, "/.well-known/security.txt")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOpenIDProxy(t *testing.T) {
+	var calls, fail int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "/realm/.well-known/openid-configuration", r.URL.Path)
+		w.Write([]byte(`{"issuer":"https://idp","token_endpoint":"https://idp/token"}`))
+	}))
+	defer upstream.Close()
+
+	proxy := &OpenIDProxy{
+		Issuer:    upstream.URL + "/realm/",
+		Overrides: map[string]string{"token_endpoint": "https://app/token"},
+	}
+	e := gin.New()
+	assert.NoError(t, Mount(e, Config{OpenID: proxy}))
+
+	w := get(e, "/.well-known/openid-configuration")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"issuer":"https://idp","token_endpoint":"https://app/token"}`, w.Body.String())
+
+	get(e, "/.well-known/openid-configuration")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Stale copy served when the refresh fails
+	proxy.expires = time.Time{}
+	atomic.StoreInt32(&fail, 1)
+	w = get(e, "/.well-known/openid-configuration")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Not retried until the back-off passes
+	w = get(e, "/.well-known/openid-configuration")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestOpenIDProxyShared(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write([]byte(`{"issuer":"https://idp"}`))
+	}))
+	defer upstream.Close()
+
+	proxy := &OpenIDProxy{Issuer: upstream.URL}
+
+	// A cancelled caller doesn't cancel the shared fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := proxy.Document(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := proxy.Document(context.Background())
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"issuer":"https://idp"}`, string(body))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestOpenIDProxyUnavailable(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+
+	e := gin.New()
+	assert.NoError(t, Mount(e, Config{OpenID: &OpenIDProxy{Issuer: upstream.URL}}))
+
+	w := get(e, "/.well-known/openid-configuration")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
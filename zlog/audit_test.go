@@ -0,0 +1,83 @@
+package zlog
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+	audit := &bytes.Buffer{}
+	chain := NewHashChain(audit, "")
+
+	e := gin.New()
+	e.Use(Logger(zerolog.ErrorLevel))
+	e.DELETE("/users/:id", func(ctx *gin.Context) {
+		Audit(ctx).Action("user.delete").Actor("admin").Target(ctx.Param("id")).Msg("Deleted user")
+	})
+
+	// Without an audit writer, events go through the request logger regardless of level
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/users/1", nil)
+	e.ServeHTTP(w, req)
+	assert.Contains(t, buf.String(), `"audit":true,"action":"user.delete","actor":"admin","target":"1","message":"Deleted user"`)
+
+	SetAuditWriter(chain)
+	defer SetAuditWriter(nil)
+
+	buf.Reset()
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	Audit(req.Context()).Action("system.start").Err(errors.New("failed")).Send()
+
+	assert.NotContains(t, buf.String(), "user.delete")
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"id":"`+w.Header().Get("X-Request-ID")+`"`)
+	assert.Contains(t, lines[0], `"method":"DELETE","route":"/users/:id"`)
+	assert.Contains(t, lines[0], `"target":"1"`)
+	assert.Contains(t, lines[0], `"time":`)
+	assert.Contains(t, lines[0], `"prev_hash":""`)
+	assert.Contains(t, lines[1], `"error":"failed"`)
+
+	last, err := VerifyHashChain(strings.NewReader(audit.String()), "")
+	assert.NoError(t, err)
+	assert.Equal(t, chain.LastHash(), last)
+}
+
+func TestHashChainTampered(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(NewHashChain(buf, ""))
+	for _, msg := range []string{"a", "b", "c"} {
+		logger.Log().Msg(msg)
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+
+	_, err := VerifyHashChain(strings.NewReader(strings.Replace(buf.String(), `"b"`, `"x"`, 1)), "")
+	assert.EqualError(t, err, "zlog: line 2 has been modified")
+
+	_, err = VerifyHashChain(strings.NewReader(lines[0]+lines[2]), "")
+	assert.EqualError(t, err, "zlog: line 2 doesn't follow the previous line")
+
+	// Continue an existing chain
+	last, err := VerifyHashChain(strings.NewReader(buf.String()), "")
+	assert.NoError(t, err)
+	more := &bytes.Buffer{}
+	logger = zerolog.New(NewHashChain(more, last))
+	logger.Log().Msg("d")
+	_, err = VerifyHashChain(strings.NewReader(buf.String()+more.String()), "")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,273 @@
+package zlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// DefaultBodyContentTypes are the content types captured by body logging unless overridden
+	DefaultBodyContentTypes = []string{"application/json", "application/xml", "application/x-www-form-urlencoded", "text/*"}
+
+	// DefaultRedactFields are the JSON and form fields redacted by body logging unless overridden
+	DefaultRedactFields = []string{"password", "token", "access_token", "refresh_token", "id_token", "secret", "client_secret", "api_key", "authorization"}
+)
+
+type bodyOpts struct {
+	limit        int             // Maximum bytes captured per body
+	contentTypes []string        // Content types to capture, "type/*" matches any subtype
+	redact       map[string]bool // Lower case field names to redact
+	redactRegex  *regexp.Regexp  // Fallback for truncated JSON bodies which can't be parsed
+	sampleRate   uint32          // Capture one in every n requests
+	counter      uint32
+}
+
+// WithBodies enables trace level logging of request and response bodies, capturing up to limit bytes of each.
+//
+// Bodies are logged as separate TRC lines after the handler chain completes, and only when the request logger
+// is at trace level, so this can be left enabled and switched on per route with SetLevel. Only the configured
+// content types are captured, and JSON and form fields with sensitive names are redacted.
+func WithBodies(limit int) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.body = bodyOptsOf(lo)
+		lo.body.limit = limit
+		return lo
+	}
+}
+
+// WithBodyContentTypes sets the content types captured by body logging, e.g. "application/json" or "text/*".
+// Structured syntax suffixes are matched, so "application/json" also captures "application/problem+json".
+func WithBodyContentTypes(types ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.body = bodyOptsOf(lo)
+		lo.body.contentTypes = types
+		return lo
+	}
+}
+
+// WithRedactFields sets the field names redacted from logged JSON and form bodies, matched case insensitively
+// at any depth. This replaces the defaults in DefaultRedactFields.
+func WithRedactFields(fields ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.body = bodyOptsOf(lo)
+		lo.body.setRedact(fields)
+		return lo
+	}
+}
+
+// WithBodySampleRate captures bodies for one in every n requests, reducing overhead on high volume routes
+func WithBodySampleRate(n uint32) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.body = bodyOptsOf(lo)
+		lo.body.sampleRate = n
+		return lo
+	}
+}
+
+func bodyOptsOf(lo *loggerOpts) *bodyOpts {
+	if lo.body != nil {
+		return lo.body
+	}
+	bo := &bodyOpts{
+		limit:        4096,
+		contentTypes: DefaultBodyContentTypes,
+		sampleRate:   1,
+	}
+	bo.setRedact(DefaultRedactFields)
+	return bo
+}
+
+func (bo *bodyOpts) setRedact(fields []string) {
+	bo.redact = map[string]bool{}
+	var quoted []string
+	for _, f := range fields {
+		bo.redact[strings.ToLower(f)] = true
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	bo.redactRegex = nil
+	if len(quoted) > 0 {
+		bo.redactRegex = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	}
+}
+
+// Start capturing the request and response bodies, returning nil if not enabled for this request
+func (bo *bodyOpts) capture(c *gin.Context) *bodyCapture {
+	if bo == nil {
+		return nil
+	}
+	if bo.sampleRate > 1 && atomic.AddUint32(&bo.counter, 1)%bo.sampleRate != 0 {
+		return nil
+	}
+
+	bc := &bodyCapture{opts: bo}
+	if c.Request.Body != nil && bo.matchType(c.ContentType()) {
+		bc.req = &cappedBuffer{limit: bo.limit}
+		c.Request.Body = &teeReadCloser{ReadCloser: c.Request.Body, w: bc.req}
+	}
+	bc.res = &captureWriter{ResponseWriter: c.Writer, buf: cappedBuffer{limit: bo.limit}}
+	c.Writer = bc.res
+	return bc
+}
+
+func (bo *bodyOpts) matchType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range bo.contentTypes {
+		switch {
+		case mt == t:
+			return true
+		case strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1]):
+			return true
+		case strings.HasPrefix(t, "application/") && strings.HasSuffix(mt, "+"+t[len("application/"):]):
+			return true
+		}
+	}
+	return false
+}
+
+type bodyCapture struct {
+	opts *bodyOpts
+	req  *cappedBuffer
+	res  *captureWriter
+}
+
+func (bc *bodyCapture) log(c *gin.Context) {
+	if bc == nil {
+		return
+	}
+	logger := GetLogger(c)
+	if logger.GetLevel() > zerolog.TraceLevel || zerolog.GlobalLevel() > zerolog.TraceLevel {
+		return
+	}
+
+	if bc.req != nil && bc.req.Len() > 0 {
+		logger.Trace().
+			Str("body", bc.opts.redactBody(c.ContentType(), bc.req)).
+			Bool("truncated", bc.req.truncated).
+			Msg("REQ BODY")
+	}
+
+	ct := bc.res.Header().Get("Content-Type")
+	if bc.res.buf.Len() > 0 && bc.opts.matchType(ct) {
+		logger.Trace().
+			Str("body", bc.opts.redactBody(ct, &bc.res.buf)).
+			Bool("truncated", bc.res.buf.truncated).
+			Msg("RES BODY")
+	}
+}
+
+func (bo *bodyOpts) redactBody(contentType string, buf *cappedBuffer) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	body := buf.String()
+	if len(bo.redact) == 0 {
+		return body
+	}
+
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		if !buf.truncated {
+			var v interface{}
+			if err := json.Unmarshal(buf.Bytes(), &v); err == nil {
+				if b, err := json.Marshal(bo.redactValue(v)); err == nil {
+					return string(b)
+				}
+			}
+		}
+		return bo.redactRegex.ReplaceAllString(body, `$1"`+redacted+`"`)
+	case mt == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return body
+		}
+		for k := range values {
+			if bo.redact[strings.ToLower(k)] {
+				values[k] = []string{redacted}
+			}
+		}
+		return values.Encode()
+	}
+	return body
+}
+
+func (bo *bodyOpts) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if bo.redact[strings.ToLower(k)] {
+				t[k] = redacted
+			} else {
+				t[k] = bo.redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = bo.redactValue(e)
+		}
+	}
+	return v
+}
+
+// cappedBuffer keeps the first limit bytes written to it, discarding the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *cappedBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// Copies request body bytes as they are read by the handlers, so the body isn't buffered up front
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n])
+	}
+	return n, err
+}
+
+// Copies response body bytes as they are written
+type captureWriter struct {
+	gin.ResponseWriter
+	buf cappedBuffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
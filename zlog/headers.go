@@ -0,0 +1,110 @@
+package zlog
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultSensitiveHeaders are redacted when logged with WithRequestHeaders or WithResponseHeaders, unless permitted
+// with WithUnredactedHeaders
+var DefaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+type headerOpts struct {
+	request    []string        // Canonical request header names logged on the REQ line
+	response   []string        // Canonical response header names logged on the RES line
+	unredacted map[string]bool // Sensitive headers explicitly permitted
+}
+
+// WithRequestHeaders adds the named request headers to the REQ line as a headers field, e.g. "X-Api-Version" or
+// "Accept". Headers not present are omitted, and sensitive headers are redacted, see DefaultSensitiveHeaders.
+func WithRequestHeaders(names ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.headers = headerOptsOf(lo)
+		lo.headers.request = appendCanonical(lo.headers.request, names)
+		return lo
+	}
+}
+
+// WithResponseHeaders adds the named response headers to the RES line as a headers field, redacting sensitive
+// headers as for WithRequestHeaders
+func WithResponseHeaders(names ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.headers = headerOptsOf(lo)
+		lo.headers.response = appendCanonical(lo.headers.response, names)
+		return lo
+	}
+}
+
+// WithUnredactedHeaders logs the values of the named sensitive headers rather than redacting them
+func WithUnredactedHeaders(names ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.headers = headerOptsOf(lo)
+		for _, n := range names {
+			lo.headers.unredacted[http.CanonicalHeaderKey(n)] = true
+		}
+		return lo
+	}
+}
+
+func headerOptsOf(lo *loggerOpts) *headerOpts {
+	if lo.headers != nil {
+		return lo.headers
+	}
+	return &headerOpts{unredacted: map[string]bool{}}
+}
+
+func appendCanonical(dst, names []string) []string {
+	for _, n := range names {
+		dst = append(dst, http.CanonicalHeaderKey(n))
+	}
+	return dst
+}
+
+// Return the selected headers as a dictionary with lower case keys, or nil if none are present
+func (ho *headerOpts) dict(h http.Header, names []string) *zerolog.Event {
+	var dict *zerolog.Event
+	for _, name := range names {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if dict == nil {
+			dict = zerolog.Dict()
+		}
+		v := strings.Join(values, ", ")
+		if ho.sensitive(name) {
+			v = redacted
+		}
+		dict.Str(strings.ToLower(name), v)
+	}
+	return dict
+}
+
+func (ho *headerOpts) sensitive(name string) bool {
+	if ho.unredacted[name] {
+		return false
+	}
+	for _, s := range DefaultSensitiveHeaders {
+		if http.CanonicalHeaderKey(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the selected request or response headers to the REQ or RES line
+func (ho *headerOpts) add(e *zerolog.Event, h http.Header, response bool) *zerolog.Event {
+	if ho == nil {
+		return e
+	}
+	names := ho.request
+	if response {
+		names = ho.response
+	}
+	if dict := ho.dict(h, names); dict != nil {
+		e.Dict("headers", dict)
+	}
+	return e
+}
@@ -0,0 +1,118 @@
+package zlog
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type atomicLevel struct {
+	v atomic.Int32
+}
+
+func newAtomicLevel(lvl zerolog.Level) *atomicLevel {
+	a := &atomicLevel{}
+	a.set(lvl)
+	return a
+}
+
+func (a *atomicLevel) get() zerolog.Level {
+	return zerolog.Level(a.v.Load())
+}
+
+func (a *atomicLevel) set(lvl zerolog.Level) {
+	a.v.Store(int32(lvl))
+}
+
+// Levels reported and updated by LevelHandler. Fields omitted from a PUT are left unchanged.
+type Levels struct {
+	Request  *string `json:"request,omitempty"`  // REQ line level
+	Response *string `json:"response,omitempty"` // Minimum RES line level
+	Global   *string `json:"global,omitempty"`   // zerolog global level, overriding all loggers
+
+	// Request logger levels by route pattern, see SetRouteLevel. On PUT, an empty level clears the pattern.
+	Routes map[string]string `json:"routes,omitempty"`
+}
+
+// LevelHandler reports the current levels on GET, and updates them from a JSON Levels body on PUT, so verbosity can
+// be changed during an incident without restarting. It must be protected by authentication middleware:
+//
+//	admin := e.Group("/admin", requireAdmin)
+//	admin.GET("/log-levels", zlog.LevelHandler())
+//	admin.PUT("/log-levels", zlog.LevelHandler())
+func LevelHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method == http.MethodPut {
+			var req Levels
+			if abortInvalid(ctx, ctx.ShouldBindJSON(&req), "invalid_levels") {
+				return
+			}
+			var parsed [3]zerolog.Level
+			for i, v := range []*string{req.Request, req.Response, req.Global} {
+				if v == nil {
+					continue
+				}
+				lvl, err := zerolog.ParseLevel(*v)
+				if abortInvalid(ctx, err, "invalid_level") {
+					return
+				}
+				parsed[i] = lvl
+			}
+
+			routes := map[string]zerolog.Level{}
+			for pattern, v := range req.Routes {
+				if v == "" {
+					continue
+				}
+				lvl, err := zerolog.ParseLevel(v)
+				if abortInvalid(ctx, err, "invalid_level") {
+					return
+				}
+				routes[pattern] = lvl
+			}
+
+			// Only apply once all levels are valid
+			if req.Request != nil {
+				globalRequestLevel.set(parsed[0])
+			}
+			if req.Response != nil {
+				globalResponseLevel.set(parsed[1])
+			}
+			if req.Global != nil {
+				zerolog.SetGlobalLevel(parsed[2])
+			}
+			for pattern, v := range req.Routes {
+				if v == "" {
+					ClearRouteLevel(pattern)
+				} else {
+					SetRouteLevel(pattern, routes[pattern])
+				}
+			}
+			GetLogger(ctx).Info().Interface("levels", currentLevels()).Msg("Log levels changed")
+		}
+		ctx.JSON(http.StatusOK, currentLevels())
+	}
+}
+
+// Respond 400 with the code if err is not nil. The errors package isn't used, as it depends on this package.
+func abortInvalid(ctx *gin.Context, err error, code string) bool {
+	if err == nil {
+		return false
+	}
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": code})
+	return true
+}
+
+func currentLevels() Levels {
+	req, res, global := globalRequestLevel.get().String(), globalResponseLevel.get().String(), zerolog.GlobalLevel().String()
+	levels := Levels{Request: &req, Response: &res, Global: &global}
+	for pattern, lvl := range RouteLevels() {
+		if levels.Routes == nil {
+			levels.Routes = map[string]string{}
+		}
+		levels.Routes[pattern] = lvl.String()
+	}
+	return levels
+}
@@ -0,0 +1,189 @@
+package zlog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recorder receives request metrics from the logger middleware. Metrics are recorded for all requests, including
+// those skipped or sampled out of the logs. Route is the gin route pattern, empty for unmatched requests. Status is
+// StatusClientClosedRequest if the client disconnected before the response completed.
+type Recorder interface {
+	Start(method, route string)
+	Done(method, route string, status int, elapsed time.Duration)
+}
+
+// WithMetrics records request metrics alongside logging, see NewPrometheus
+func WithMetrics(r Recorder) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.metrics = r
+		return lo
+	}
+}
+
+// DefaultBuckets are the default duration histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus is a Recorder exposing metrics in the Prometheus text format, without depending on the client library:
+//   - http_requests_total counter by method, route and status
+//   - http_request_duration_seconds histogram by method, route and status
+//   - http_requests_in_flight gauge by method and route
+type Prometheus struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	inFlight map[routeLabels]int64
+	series   map[statusLabels]*histogram
+}
+
+type routeLabels struct {
+	method, route string
+}
+
+type statusLabels struct {
+	routeLabels
+	status int
+}
+
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // Non-cumulative counts per bucket
+}
+
+// NewPrometheus creates a Prometheus recorder with the given histogram buckets in seconds, or DefaultBuckets
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	return &Prometheus{
+		buckets:  b,
+		inFlight: map[routeLabels]int64{},
+		series:   map[statusLabels]*histogram{},
+	}
+}
+
+// Start increments the in-flight gauge
+func (p *Prometheus) Start(method, route string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight[routeLabels{method, route}]++
+}
+
+// Done decrements the in-flight gauge and records the request count and duration
+func (p *Prometheus) Done(method, route string, status int, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rl := routeLabels{method, route}
+	p.inFlight[rl]--
+
+	sl := statusLabels{rl, status}
+	h, ok := p.series[sl]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(p.buckets))}
+		p.series[sl] = h
+	}
+	secs := elapsed.Seconds()
+	h.count++
+	h.sum += secs
+	if i := sort.SearchFloat64s(p.buckets, secs); i < len(h.buckets) {
+		h.buckets[i]++
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+
+	keys := make([]statusLabels, 0, len(p.series))
+	for k := range p.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].routeLabels != keys[j].routeLabels {
+			return keys[i].routeLabels.less(keys[j].routeLabels)
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	b.WriteString("# HELP http_requests_total Total HTTP requests.\n# TYPE http_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "http_requests_total{%s} %d\n", k.labels(), p.series[k].count)
+	}
+
+	b.WriteString("# HELP http_request_duration_seconds HTTP request duration.\n# TYPE http_request_duration_seconds histogram\n")
+	for _, k := range keys {
+		h := p.series[k]
+		labels := k.labels()
+		var cum uint64
+		for i, le := range p.buckets {
+			cum += h.buckets[i]
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(le), cum)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	routes := make([]routeLabels, 0, len(p.inFlight))
+	for k := range p.inFlight {
+		routes = append(routes, k)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+
+	b.WriteString("# HELP http_requests_in_flight HTTP requests currently being served.\n# TYPE http_requests_in_flight gauge\n")
+	for _, k := range routes {
+		fmt.Fprintf(&b, "http_requests_in_flight{%s} %d\n", k.labels(), p.inFlight[k])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the metrics for scraping
+func (p *Prometheus) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		ctx.Status(http.StatusOK)
+		_, _ = p.WriteTo(ctx.Writer)
+	}
+}
+
+func (r routeLabels) less(o routeLabels) bool {
+	if r.route != o.route {
+		return r.route < o.route
+	}
+	return r.method < o.method
+}
+
+func (r routeLabels) labels() string {
+	return `method="` + escapeLabel(r.method) + `",route="` + escapeLabel(r.route) + `"`
+}
+
+func (s statusLabels) labels() string {
+	return s.routeLabels.labels() + `,status="` + strconv.Itoa(s.status) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
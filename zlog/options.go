@@ -0,0 +1,226 @@
+package zlog
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/requestid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type loggerOpts struct {
+	base        *zerolog.Logger         // Base of the request logger, the global logger if nil
+	level       zerolog.Level           // Default level of the request logger
+	fields      map[string]interface{}  // Static fields added to the request logger
+	fieldsFunc  FieldsFunc              // Per-request fields added to the request logger
+	resFunc     FieldsFunc              // Per-request fields added to the RES line
+	clock       func() time.Time        // Time source for request duration
+	body        *bodyOpts               // Request/response body capture, nil if disabled
+	headers     *headerOpts             // Request/response headers logged, nil if disabled
+	statusLevel func(int) zerolog.Level // Response level by status code
+
+	slowThreshold time.Duration           // Responses at least this slow are escalated, 0 to disable
+	slowLevel     zerolog.Level           // Minimum RES level for slow responses
+	skipPaths     map[string]bool         // Exact paths not logged
+	skipGlobs     []string                // Path patterns not logged
+	skipFunc      func(*gin.Context) bool // Custom skip condition
+
+	sampler       zerolog.Sampler            // Samples REQ/RES lines, nil to log all
+	routeSamplers map[string]zerolog.Sampler // Samplers by route pattern
+
+	requestID []requestid.IDOpts // Request ID options, applied when building the assigner
+	assigner  *requestid.Assigner
+
+	traceContext bool   // Extract W3C trace context into the logger
+	tracer       Tracer // Starts a span per request, nil if disabled
+
+	metrics      Recorder // Records request metrics, nil if disabled
+	serverTiming string   // Server-Timing metric name, empty if disabled
+	compact      bool     // Static REQ/RES messages
+	wireBytes    bool     // Count bytes written to the connection
+}
+
+// Modifier function for customising logger middleware behaviour
+type LoggerOpts func(*loggerOpts) *loggerOpts
+
+func getLoggerOpts(opts ...LoggerOpts) *loggerOpts {
+	lo := &loggerOpts{
+		level:       zerolog.InfoLevel,
+		clock:       time.Now,
+		statusLevel: defaultStatusLevel,
+	}
+	for _, f := range opts {
+		lo = f(lo)
+	}
+	lo.assigner = requestid.NewAssigner(lo.requestID...)
+	return lo
+}
+
+// WithLevel sets the default level of the request logger
+func WithLevel(lvl zerolog.Level) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.level = lvl
+		return lo
+	}
+}
+
+// WithBaseLogger sets the logger the request logger is derived from, instead of the global log.Logger.
+// Allows engines in the same process to log to different sinks.
+func WithBaseLogger(logger zerolog.Logger) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.base = &logger
+		return lo
+	}
+}
+
+// The global logger is read per request, so changes after the middleware is created still apply
+func (lo *loggerOpts) baseLogger() *zerolog.Logger {
+	if lo.base != nil {
+		return lo.base
+	}
+	return &log.Logger
+}
+
+// WithFields adds static fields to the request logger, e.g. the service name or version.
+// Fields are added to every log line written through the request logger, including the REQ and RES lines.
+func WithFields(fields map[string]interface{}) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		if lo.fields == nil {
+			lo.fields = map[string]interface{}{}
+		}
+		for k, v := range fields {
+			lo.fields[k] = v
+		}
+		return lo
+	}
+}
+
+// FieldsFunc returns extra fields for a request
+type FieldsFunc func(*gin.Context) map[string]interface{}
+
+// WithFieldsFunc adds fields to the request logger for each request, e.g. region or API version from a header.
+// The function is called before the REQ line, so the fields appear on every log line for the request, but
+// values set by later middleware such as authentication aren't yet available; see WithResponseFieldsFunc.
+func WithFieldsFunc(fn FieldsFunc) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.fieldsFunc = fn
+		return lo
+	}
+}
+
+// WithResponseFieldsFunc adds fields to the RES line, e.g. the tenant or user set by authentication middleware.
+// The function is called after the handler chain completes.
+func WithResponseFieldsFunc(fn FieldsFunc) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.resFunc = fn
+		return lo
+	}
+}
+
+func (lo *loggerOpts) requestFields(c *gin.Context) map[string]interface{} {
+	if lo.fieldsFunc == nil {
+		return nil
+	}
+	return lo.fieldsFunc(c)
+}
+
+func (lo *loggerOpts) responseFields(c *gin.Context) map[string]interface{} {
+	if lo.resFunc == nil {
+		return nil
+	}
+	return lo.resFunc(c)
+}
+
+// WithCompactMessages uses the static messages "request" and "response" for the REQ and RES lines, rather than
+// repeating the method, path, status, duration and IP already present as fields. Suited to JSON log sinks.
+func WithCompactMessages() LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.compact = true
+		return lo
+	}
+}
+
+func (lo *loggerOpts) requestMessage(c *gin.Context) string {
+	if lo.compact {
+		return "request"
+	}
+	return fmt.Sprintf("REQ %s %s %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+}
+
+func (lo *loggerOpts) responseMessage(c *gin.Context, elapsed time.Duration) string {
+	if lo.compact {
+		return "response"
+	}
+	return fmt.Sprintf("RES %s %s %d %s %s", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), elapsed, c.ClientIP())
+}
+
+// WithClock sets the time source used to measure request duration, for testing
+func WithClock(clock func() time.Time) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.clock = clock
+		return lo
+	}
+}
+
+// WithStatusLevel overrides the global status level map for the handler, returning the RES line level for a status.
+// As with SetStatusLevelMap, the level is never lowered below the global response level.
+func WithStatusLevel(fn func(status int) zerolog.Level) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.statusLevel = fn
+		return lo
+	}
+}
+
+// WithSlowThreshold logs responses taking at least d at lvl or above, with a slow=true field. Slow responses are
+// always logged, regardless of sampling.
+func WithSlowThreshold(d time.Duration, lvl zerolog.Level) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.slowThreshold = d
+		lo.slowLevel = lvl
+		return lo
+	}
+}
+
+// WithSkipPaths disables the REQ and RES lines for requests matching any of the paths, e.g. health checks.
+// Paths may contain path.Match patterns, such as "/static/*". The logger is still attached to the context.
+func WithSkipPaths(paths ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		if lo.skipPaths == nil {
+			lo.skipPaths = map[string]bool{}
+		}
+		for _, p := range paths {
+			if strings.ContainsAny(p, "*?[\\") {
+				lo.skipGlobs = append(lo.skipGlobs, p)
+			} else {
+				lo.skipPaths[p] = true
+			}
+		}
+		return lo
+	}
+}
+
+// WithSkipFunc disables the REQ and RES lines for requests where fn returns true.
+// The logger is still attached to the context.
+func WithSkipFunc(fn func(*gin.Context) bool) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.skipFunc = fn
+		return lo
+	}
+}
+
+func (lo *loggerOpts) skipped(c *gin.Context) bool {
+	p := c.Request.URL.Path
+	if lo.skipPaths[p] {
+		return true
+	}
+	for _, g := range lo.skipGlobs {
+		if ok, _ := path.Match(g, p); ok {
+			return true
+		}
+	}
+	return lo.skipFunc != nil && lo.skipFunc(c)
+}
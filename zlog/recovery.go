@@ -0,0 +1,120 @@
+package zlog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maximum stack frames logged on panic
+const maxStackFrames = 32
+
+// PanicHook is called with the recovered value after a panic is logged, from the panicking goroutine
+type PanicHook func(ctx *gin.Context, v interface{})
+
+type recoveryOpts struct {
+	hooks []PanicHook
+}
+
+// Modifier function for customising recovery behaviour
+type RecoveryOpts func(*recoveryOpts) *recoveryOpts
+
+// WithPanicHook adds a hook called on each recovered panic, e.g. to capture diagnostics. Hooks aren't called for
+// broken client connections.
+func WithPanicHook(hook PanicHook) RecoveryOpts {
+	return func(ro *recoveryOpts) *recoveryOpts {
+		ro.hooks = append(ro.hooks, hook)
+		return ro
+	}
+}
+
+// Recovery middleware recovers from panics in later handlers, logging the panic value and a trimmed stack trace
+// through the request logger at error level, and responding with 500 if nothing has been written yet.
+// Use in place of gin.Recovery(), after Logger() so the request ID and other fields are included.
+func Recovery(opts ...RecoveryOpts) gin.HandlerFunc {
+	ro := &recoveryOpts{}
+	for _, f := range opts {
+		ro = f(ro)
+	}
+
+	return func(ctx *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if brokenConnection(v) {
+				// Nothing can be written to the client, and the stack isn't useful
+				GetLogger(ctx).Warn().Str("panic", fmt.Sprint(v)).Msg("Client connection lost")
+				ctx.Abort()
+				return
+			}
+
+			event := GetLogger(ctx).Error().Strs("stack", stack(3))
+			if err, ok := v.(error); ok {
+				event.Err(err)
+			} else {
+				event.Str("panic", fmt.Sprint(v))
+			}
+			event.Msg("Recovered from panic")
+
+			for _, hook := range ro.hooks {
+				hook(ctx, v)
+			}
+
+			if ctx.Writer.Written() {
+				ctx.Abort()
+			} else {
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		ctx.Next()
+	}
+}
+
+func brokenConnection(v interface{}) bool {
+	err, ok := v.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(err, http.ErrAbortHandler) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var se *os.SyscallError
+	if errors.As(err, &se) {
+		msg := strings.ToLower(se.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
+
+// Return the stack of the panicking goroutine as "function file:line" entries, skipping the runtime panic
+// frames and stopping before the gin handler chain internals
+func stack(skip int) []string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var res []string
+	for {
+		f, more := frames.Next()
+		switch {
+		case strings.HasPrefix(f.Function, "runtime."):
+		case strings.HasPrefix(f.Function, "github.com/gin-gonic/gin.(*Context).Next"):
+			// Everything below is the router and server
+			return res
+		default:
+			res = append(res, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+		}
+		if !more || len(res) >= maxStackFrames {
+			return res
+		}
+	}
+}
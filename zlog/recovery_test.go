@@ -0,0 +1,114 @@
+package zlog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func panicker() {
+	panic("boom")
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(Logger(zerolog.InfoLevel), Recovery())
+	e.GET("", func(ctx *gin.Context) {
+		panicker()
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+
+	lines := strings.Split(buf.String(), "\n")
+	id := w.Header().Get("X-Request-ID")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[0], `"level":"error"`)
+	assert.Contains(t, lines[0], `"panic":"boom"`)
+	assert.Contains(t, lines[0], `"id":"`+id+`"`)
+	assert.Contains(t, lines[0], `"stack":["github.com/redmapletech/ginx/zlog.panicker `)
+	assert.NotContains(t, lines[0], "runtime.gopanic")
+	assert.NotContains(t, lines[0], "ServeHTTP")
+	assert.Contains(t, lines[1], `"response":500`)
+}
+
+func TestRecoveryError(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Recovery(), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "partial")
+		panic(fmt.Errorf("failed: %w", syscall.EINVAL))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, buf.String(), `"error":"failed: invalid argument"`)
+}
+
+func TestRecoveryBrokenPipe(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Recovery(), func(ctx *gin.Context) {
+		panic(fmt.Errorf("write: %w", syscall.EPIPE))
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+	assert.NotContains(t, buf.String(), "stack")
+}
+
+func TestRecoveryHook(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.Nop()
+
+	var got []interface{}
+	hook := func(ctx *gin.Context, v interface{}) {
+		got = append(got, v)
+	}
+
+	e := gin.New()
+	e.Use(Recovery(WithPanicHook(hook)))
+	e.GET("/boom", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	e.GET("/pipe", func(ctx *gin.Context) {
+		panic(fmt.Errorf("write: %w", syscall.EPIPE))
+	})
+
+	for _, p := range []string{"/boom", "/pipe"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, []interface{}{"boom"}, got)
+}
@@ -0,0 +1,43 @@
+package zlog
+
+import (
+	"context"
+
+	"github.com/redmapletech/ginx/requestid"
+)
+
+// WithIDGenerator sets the function generating request IDs when no trusted incoming ID is present, e.g. to use
+// UUIDs or ULIDs. Defaults to 8 random bytes, base64 encoded.
+func WithIDGenerator(fn func() string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.requestID = append(lo.requestID, requestid.WithGenerator(fn))
+		return lo
+	}
+}
+
+// GetRequestID returns the request ID assigned by the logger or requestid middleware, or an empty string if not set
+func GetRequestID(ctx context.Context) string {
+	return requestid.Get(ctx)
+}
+
+// WithRequestIDHeader reuses a request ID sent by the client or load balancer in any of the headers, checked in
+// order, instead of generating one. For a traceparent header the trace ID is used. The ID is returned in the first
+// non-traceparent header, X-Request-ID by default, so IDs can be correlated end to end.
+//
+// Incoming IDs are only accepted from the addresses given to WithTrustedProxies if set, and must be at most 128
+// printable ASCII characters.
+func WithRequestIDHeader(names ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.requestID = append(lo.requestID, requestid.WithIncomingHeaders(names...))
+		return lo
+	}
+}
+
+// WithTrustedProxies restricts incoming request IDs to requests whose immediate peer is within the IPs or CIDR
+// ranges, e.g. the load balancer. Panics if an entry isn't an IP or CIDR range.
+func WithTrustedProxies(entries ...string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.requestID = append(lo.requestID, requestid.WithTrustedProxies(entries...))
+		return lo
+	}
+}
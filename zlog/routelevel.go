@@ -0,0 +1,96 @@
+package zlog
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type routeLevel struct {
+	pattern string
+	level   zerolog.Level
+}
+
+var (
+	routeLevelsMu sync.Mutex
+	routeLevels   atomic.Pointer[[]routeLevel] // Sorted longest pattern first, read without locking
+)
+
+// SetRouteLevel sets the request logger level for routes matching the pattern, overriding the middleware level.
+// Patterns are matched against the route pattern, e.g. /users/:id, or the request path for unmatched requests.
+// A pattern ending in /* matches everything below the prefix, otherwise path.Match syntax is used. The longest
+// matching pattern wins.
+func SetRouteLevel(pattern string, lvl zerolog.Level) {
+	updateRouteLevels(func(m map[string]zerolog.Level) {
+		m[pattern] = lvl
+	})
+}
+
+// ClearRouteLevel removes the level set for the pattern
+func ClearRouteLevel(pattern string) {
+	updateRouteLevels(func(m map[string]zerolog.Level) {
+		delete(m, pattern)
+	})
+}
+
+// RouteLevels returns the levels set by route pattern
+func RouteLevels() map[string]zerolog.Level {
+	res := map[string]zerolog.Level{}
+	if rl := routeLevels.Load(); rl != nil {
+		for _, r := range *rl {
+			res[r.pattern] = r.level
+		}
+	}
+	return res
+}
+
+func updateRouteLevels(fn func(map[string]zerolog.Level)) {
+	routeLevelsMu.Lock()
+	defer routeLevelsMu.Unlock()
+
+	m := RouteLevels()
+	fn(m)
+	rl := make([]routeLevel, 0, len(m))
+	for p, l := range m {
+		rl = append(rl, routeLevel{p, l})
+	}
+	sort.Slice(rl, func(i, j int) bool {
+		if len(rl[i].pattern) != len(rl[j].pattern) {
+			return len(rl[i].pattern) > len(rl[j].pattern)
+		}
+		return rl[i].pattern < rl[j].pattern
+	})
+	routeLevels.Store(&rl)
+}
+
+// Level of the request logger, from the route level registry if matched
+func (lo *loggerOpts) requestLevel(c *gin.Context) zerolog.Level {
+	rl := routeLevels.Load()
+	if rl == nil || len(*rl) == 0 {
+		return lo.level
+	}
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	for _, r := range *rl {
+		if matchRoute(r.pattern, route) {
+			return r.level
+		}
+	}
+	return lo.level
+}
+
+func matchRoute(pattern, route string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		prefix := strings.TrimSuffix(pattern, "/*")
+		return route == prefix || strings.HasPrefix(route, prefix+"/")
+	}
+	ok, _ := path.Match(pattern, route)
+	return ok
+}
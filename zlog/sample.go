@@ -0,0 +1,47 @@
+package zlog
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// WithSampler samples the REQ and RES lines, e.g. &zerolog.BasicSampler{N: 100} to log every 100th request.
+// The decision is made once per request so REQ and RES lines are kept or dropped together. RES lines at warn or
+// above are always logged, as are explicit log calls through the request logger.
+func WithSampler(s zerolog.Sampler) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.sampler = s
+		return lo
+	}
+}
+
+// WithSampleRate logs the REQ and RES lines for one in every n requests, see WithSampler
+func WithSampleRate(n uint32) LoggerOpts {
+	return WithSampler(&zerolog.BasicSampler{N: n})
+}
+
+// WithRouteSampler samples the REQ and RES lines of a single route pattern, e.g. "/users/:id", overriding any
+// sampler set by WithSampler. Routes are matched against ctx.FullPath().
+func WithRouteSampler(route string, s zerolog.Sampler) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		if lo.routeSamplers == nil {
+			lo.routeSamplers = map[string]zerolog.Sampler{}
+		}
+		lo.routeSamplers[route] = s
+		return lo
+	}
+}
+
+// WithRouteSampleRate logs the REQ and RES lines for one in every n requests to a route, see WithRouteSampler
+func WithRouteSampleRate(route string, n uint32) LoggerOpts {
+	return WithRouteSampler(route, &zerolog.BasicSampler{N: n})
+}
+
+// Decide whether the access log lines of the request are sampled in
+func (lo *loggerOpts) sampled(c *gin.Context) bool {
+	s := lo.sampler
+	if rs, ok := lo.routeSamplers[c.FullPath()]; ok {
+		s = rs
+	}
+	return s == nil || s.Sample(globalRequestLevel.get())
+}
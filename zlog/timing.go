@@ -0,0 +1,73 @@
+package zlog
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithServerTiming adds a Server-Timing response header with the request duration under the given metric name,
+// e.g. "Server-Timing: app;dur=12.3". The duration is measured when the response headers are written, so for
+// responses with a body it covers processing up to the first byte rather than the full RES line duration.
+func WithServerTiming(name string) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.serverTiming = name
+		return lo
+	}
+}
+
+// Wrap the response writer to add the Server-Timing header when the headers are written
+func (lo *loggerOpts) timing(c *gin.Context, start time.Time) *timingWriter {
+	if lo.serverTiming == "" {
+		return nil
+	}
+	tw := &timingWriter{ResponseWriter: c.Writer, name: lo.serverTiming, start: start, clock: lo.clock}
+	c.Writer = tw
+	return tw
+}
+
+type timingWriter struct {
+	gin.ResponseWriter
+	name  string
+	start time.Time
+	clock func() time.Time
+	done  bool
+}
+
+// Set the header if not already written. Responses without a body are written by gin after the middleware returns,
+// so finish is called with the RES elapsed time.
+func (w *timingWriter) finish(elapsed time.Duration) {
+	if w == nil || w.done || w.Written() {
+		return
+	}
+	w.done = true
+	ms := float64(elapsed) / float64(time.Millisecond)
+	w.Header().Add("Server-Timing", w.name+";dur="+strconv.FormatFloat(ms, 'f', 1, 64))
+}
+
+func (w *timingWriter) before() {
+	if !w.done && !w.Written() {
+		w.finish(w.clock().Sub(w.start))
+	}
+}
+
+func (w *timingWriter) Write(p []byte) (int, error) {
+	w.before()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.before()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.before()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Flush() {
+	w.before()
+	w.ResponseWriter.Flush()
+}
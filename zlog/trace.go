@@ -0,0 +1,154 @@
+package zlog
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const (
+	traceparentHeader = "Traceparent"
+	tracestateHeader  = "Tracestate"
+)
+
+type traceKey struct{}
+
+// TraceContext is a parsed W3C trace context
+type TraceContext struct {
+	TraceID string // 32 lower case hex characters
+	SpanID  string // 16 lower case hex characters, the parent span of the request
+	Flags   string // 2 hex characters, 01 if sampled
+	State   string // Vendor specific tracestate, passed through unparsed
+}
+
+// Valid returns true if the trace context was parsed successfully
+func (tc TraceContext) Valid() bool {
+	return tc.TraceID != ""
+}
+
+// Traceparent formats the trace context as a traceparent header value, for propagation to outgoing requests
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+// ParseTraceparent parses traceparent and tracestate header values, returning an invalid TraceContext on error
+func ParseTraceparent(traceparent, tracestate string) TraceContext {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}
+	}
+	if !isHex(parts[0]) || !isHex(parts[3]) || len(parts[3]) != 2 {
+		return TraceContext{}
+	}
+	if len(parts[1]) != 32 || !isHex(parts[1]) || strings.Trim(parts[1], "0") == "" {
+		return TraceContext{}
+	}
+	if len(parts[2]) != 16 || !isHex(parts[2]) || strings.Trim(parts[2], "0") == "" {
+		return TraceContext{}
+	}
+	return TraceContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Flags:   parts[3],
+		State:   strings.TrimSpace(tracestate),
+	}
+}
+
+func isHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// GetTraceContext returns the incoming trace context of the request, if WithTraceContext is enabled
+func GetTraceContext(ctx context.Context) TraceContext {
+	if gctx, ok := ctx.(*gin.Context); ok && gctx.Request != nil {
+		ctx = gctx.Request.Context()
+	}
+	tc, _ := ctx.Value(traceKey{}).(TraceContext)
+	return tc
+}
+
+// Tracer starts a span around the handler chain, e.g. an adapter for an OpenTelemetry tracer:
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string, tc zlog.TraceContext) (context.Context, zlog.Span) {
+//		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": tc.Traceparent(), "tracestate": tc.State})
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	// StartSpan starts a server span as a child of the incoming trace context, which may be invalid if none
+	// was sent. The returned context is attached to the request.
+	StartSpan(ctx context.Context, name string, parent TraceContext) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	TraceID() string
+	SpanID() string
+	// End ends the span, recording the response status
+	End(status int)
+}
+
+// WithTraceContext extracts the W3C traceparent and tracestate headers, adding trace_id and span_id fields
+// to the request logger. The parsed trace context is available from GetTraceContext.
+func WithTraceContext() LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.traceContext = true
+		return lo
+	}
+}
+
+// WithTracer starts a span around the handler chain using the tracer, implying WithTraceContext.
+// The logger trace_id and span_id fields are those of the new span.
+func WithTracer(t Tracer) LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.traceContext = true
+		lo.tracer = t
+		return lo
+	}
+}
+
+type requestTrace struct {
+	tc   TraceContext
+	span Span
+}
+
+func (lo *loggerOpts) startTrace(c *gin.Context) *requestTrace {
+	if !lo.traceContext {
+		return nil
+	}
+
+	rt := &requestTrace{
+		tc: ParseTraceparent(c.GetHeader(traceparentHeader), c.GetHeader(tracestateHeader)),
+	}
+	ctx := context.WithValue(c.Request.Context(), traceKey{}, rt.tc)
+
+	if lo.tracer != nil {
+		name := c.FullPath()
+		if name == "" {
+			name = c.Request.URL.Path
+		}
+		ctx, rt.span = lo.tracer.StartSpan(ctx, c.Request.Method+" "+name, rt.tc)
+	}
+	c.Request = c.Request.WithContext(ctx)
+	return rt
+}
+
+func (rt *requestTrace) fields(zc zerolog.Context) zerolog.Context {
+	switch {
+	case rt == nil:
+		return zc
+	case rt.span != nil:
+		return zc.Str("trace_id", rt.span.TraceID()).Str("span_id", rt.span.SpanID())
+	case rt.tc.Valid():
+		return zc.Str("trace_id", rt.tc.TraceID).Str("span_id", rt.tc.SpanID)
+	}
+	return zc
+}
+
+func (rt *requestTrace) end(c *gin.Context) {
+	if rt != nil && rt.span != nil {
+		rt.span.End(c.Writer.Status())
+	}
+}
@@ -0,0 +1,109 @@
+package zlog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tc := ParseTraceparent(testTraceparent, "congo=t61rcWkgMzE")
+
+	assert.True(t, tc.Valid())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", tc.SpanID)
+	assert.Equal(t, "01", tc.Flags)
+	assert.Equal(t, "congo=t61rcWkgMzE", tc.State)
+	assert.Equal(t, testTraceparent, tc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		assert.False(t, ParseTraceparent(invalid, "").Valid(), invalid)
+	}
+
+	// Future versions may append fields
+	assert.True(t, ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "").Valid())
+}
+
+func TestTraceContextFields(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel, WithTraceContext()), func(ctx *gin.Context) {
+		assert.Equal(t, "congo=1", GetTraceContext(ctx).State)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	req.Header.Set("tracestate", "congo=1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"`)))
+}
+
+type testTracer struct {
+	parent TraceContext
+	name   string
+	span   *testSpan
+}
+
+type testSpan struct {
+	status int
+}
+
+type testSpanKey struct{}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string, parent TraceContext) (context.Context, Span) {
+	t.parent = parent
+	t.name = name
+	t.span = &testSpan{}
+	return context.WithValue(ctx, testSpanKey{}, t.span), t.span
+}
+
+func (s *testSpan) TraceID() string { return "trace" }
+func (s *testSpan) SpanID() string  { return "span" }
+func (s *testSpan) End(status int)  { s.status = status }
+
+func TestTracer(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	tracer := &testTracer{}
+	e := gin.New()
+	e.GET("/users/:id", Logger(zerolog.TraceLevel, WithTracer(tracer)), func(ctx *gin.Context) {
+		assert.Same(t, tracer.span, ctx.Request.Context().Value(testSpanKey{}))
+		GetLogger(ctx).Info().Msg("handler")
+		ctx.Status(http.StatusCreated)
+	})
+
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "GET /users/:id", tracer.name)
+	assert.Equal(t, "00f067aa0ba902b7", tracer.parent.SpanID)
+	assert.Equal(t, http.StatusCreated, tracer.span.status)
+	assert.Equal(t, 3, bytes.Count(buf.Bytes(), []byte(`"trace_id":"trace","span_id":"span"`)))
+}
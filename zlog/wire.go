@@ -0,0 +1,84 @@
+package zlog
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// WithWireBytes adds bytes_sent to the RES line, the body bytes actually written to the connection, and
+// hijacked=true for hijacked connections such as websockets. Unlike the bytes field, this is the compressed size
+// when a compression middleware runs after the logger, and includes bytes written to hijacked connections
+// before the RES line.
+func WithWireBytes() LoggerOpts {
+	return func(lo *loggerOpts) *loggerOpts {
+		lo.wireBytes = true
+		return lo
+	}
+}
+
+// Wrap the response writer to count bytes written beneath any later middleware wrappers
+func (lo *loggerOpts) wire(c *gin.Context) *wireWriter {
+	if !lo.wireBytes {
+		return nil
+	}
+	ww := &wireWriter{ResponseWriter: c.Writer}
+	c.Writer = ww
+	return ww
+}
+
+type wireWriter struct {
+	gin.ResponseWriter
+	sent     int64
+	hijacked bool
+}
+
+func (w *wireWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(&w.sent, int64(n))
+	return n, err
+}
+
+func (w *wireWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	atomic.AddInt64(&w.sent, int64(n))
+	return n, err
+}
+
+func (w *wireWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+	w.hijacked = true
+	cc := &countingConn{Conn: conn, sent: &w.sent}
+	// Writes through the buffered writer must also reach the counting conn
+	if rw != nil && rw.Writer.Buffered() == 0 {
+		rw.Writer.Reset(cc)
+	}
+	return cc, rw, nil
+}
+
+func (w *wireWriter) fields(e *zerolog.Event) {
+	if w == nil {
+		return
+	}
+	e.Int64("bytes_sent", atomic.LoadInt64(&w.sent))
+	if w.hijacked {
+		e.Bool("hijacked", true)
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	sent *int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(c.sent, int64(n))
+	return n, err
+}
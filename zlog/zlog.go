@@ -1,18 +1,22 @@
 // Zerolog middleware
 //
 // Adds request/response logging middleware, and adds the logger to the underlying context.
+// The response line is escalated to warn for 4xx and error for 5xx responses, see SetStatusLevelMap.
+// Recovery() replaces gin.Recovery(), logging panics through the request logger.
+// Request and response bodies can additionally be logged at trace level, with sensitive fields redacted.
+// Access log lines of high-volume routes can be sampled, see WithSampler and WithRouteSampler.
+// Request count, duration and in-flight metrics can be recorded in the same pass, see WithMetrics.
+// Audit events can be written to a separate tamper-evident sink, see Audit and HashChain.
+// Request IDs are assigned as by the requestid package, reusing the ID if its middleware runs first.
 //
 // Warning: zerolog.SetGlobalLevel will override all log level settings in this package.
-// This should usually be left unset (Trace), and the default level specified in Logger().
+// This should usually be left unset (Trace), and the default level specified in Logger() or WithLevel().
 package zlog
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
-	"fmt"
-	"io"
-	"time"
+	"errors"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -20,54 +24,157 @@ import (
 )
 
 var (
-	globalRequestLevel  = zerolog.TraceLevel
-	globalResponseLevel = zerolog.DebugLevel
+	// REQ and RES line levels, which can be changed at runtime, see LevelHandler
+	globalRequestLevel  = newAtomicLevel(zerolog.TraceLevel)
+	globalResponseLevel = newAtomicLevel(zerolog.DebugLevel)
+
+	// Response levels by exact status code or status class (1-5)
+	statusLevels = map[int]zerolog.Level{
+		4: zerolog.WarnLevel,
+		5: zerolog.ErrorLevel,
+	}
 )
 
 type loggerKey struct{}
 
-// Logger middleare
-func Logger(lvl zerolog.Level) gin.HandlerFunc {
+// Holds the context logger. The holder of a request is owned by it, so the logger can be replaced without cloning
+// the request for each update; holders added by WithLogger may be shared and are never modified.
+type loggerHolder struct {
+	logger atomic.Pointer[zerolog.Logger]
+	owned  bool
+}
+
+func newLoggerHolder(logger *zerolog.Logger, owned bool) *loggerHolder {
+	h := &loggerHolder{owned: owned}
+	h.logger.Store(logger)
+	return h
+}
+
+// StatusClientClosedRequest is reported to the metrics recorder for requests where the client disconnected before
+// the response completed, following the nginx convention
+const StatusClientClosedRequest = 499
+
+// Logger middleware, logging at the specified default level. Equivalent to New(WithLevel(lvl), opts...).
+func Logger(lvl zerolog.Level, opts ...LoggerOpts) gin.HandlerFunc {
+	return New(append([]LoggerOpts{WithLevel(lvl)}, opts...)...)
+}
+
+// New creates the logger middleware from options, logging at info level unless WithLevel is given
+func New(opts ...LoggerOpts) gin.HandlerFunc {
+	lo := getLoggerOpts(opts...)
+
 	return func(c *gin.Context) {
 		// Start tracking request duration
-		start := time.Now()
+		start := lo.clock()
+
+		// Record metrics for every request, whether or not it is logged
+		if lo.metrics != nil {
+			method, route := c.Request.Method, c.FullPath()
+			lo.metrics.Start(method, route)
+			defer func() {
+				status := c.Writer.Status()
+				if clientGone(c) {
+					status = StatusClientClosedRequest
+				}
+				lo.metrics.Done(method, route, status, lo.clock().Sub(start))
+			}()
+		}
+
+		// Reuse the ID assigned by the requestid middleware or a trusted incoming request ID, or generate one
+		requestID := lo.assigner.Assign(c)
 
-		// Generate a random string to use as the request ID
-		requestIDBytes := make([]byte, 8)
-		io.ReadFull(rand.Reader, requestIDBytes)
-		requestID := base64.RawURLEncoding.EncodeToString(requestIDBytes)
-		c.Header("X-Request-ID", requestID)
+		// Extract the incoming trace context and start a span if enabled
+		trace := lo.startTrace(c)
+		defer trace.end(c)
 
 		// Create a sublogger at the specified level to carry through the request chain
-		logger := log.With().
+		logger := trace.fields(lo.baseLogger().With().
+			Fields(lo.fields).
 			Str("id", requestID).
 			Str("agent", c.GetHeader("User-Agent")).
-			Str("path", c.Request.URL.Path).
+			Str("path", c.Request.URL.Path)).
+			Fields(lo.requestFields(c)).
 			Logger().
-			Level(lvl)
-		setLogger(c, &logger)
+			Level(lo.requestLevel(c))
+		attachLogger(c, &logger)
 
-		// Log request start
-		logger.WithLevel(globalRequestLevel).
-			Str("method", c.Request.Method).
-			Str("origin", c.GetHeader("Origin")).
-			Str("ip", c.ClientIP()).
-			Msg(fmt.Sprintf("REQ %s %s %s", c.Request.Method, c.Request.URL.Path, c.ClientIP()))
+		// Count bytes written to the connection, beneath any later wrappers, if enabled
+		wire := lo.wire(c)
+
+		// Add the Server-Timing header when the response is written, if enabled
+		timing := lo.timing(c, start)
+
+		// Skipped requests still have a logger attached for use by handlers
+		if lo.skipped(c) {
+			c.Next()
+			timing.finish(lo.clock().Sub(start))
+			return
+		}
+
+		// Log request start, unless sampled out
+		sampled := lo.sampled(c)
+		if sampled {
+			req := logger.WithLevel(globalRequestLevel.get())
+			lo.headers.add(req, c.Request.Header, false).
+				Str("method", c.Request.Method).
+				Str("origin", c.GetHeader("Origin")).
+				Str("ip", c.ClientIP()).
+				Msg(lo.requestMessage(c))
+		}
+
+		// Wrap the request body and response writer to capture bodies if enabled
+		capture := lo.body.capture(c)
 
 		// Process remaining handlers
 		c.Next()
 
+		capture.log(c)
+
 		// Calculate elapsed and decide severity
-		elapsed := time.Since(start)
+		elapsed := lo.clock().Sub(start)
+		timing.finish(elapsed)
+		resLevel := globalResponseLevel.get()
+		if l := lo.statusLevel(c.Writer.Status()); l > resLevel {
+			resLevel = l
+		}
+		if len(c.Errors) > 0 && resLevel < zerolog.ErrorLevel {
+			resLevel = zerolog.ErrorLevel
+		}
+		gone := clientGone(c)
+		if gone && resLevel < zerolog.InfoLevel {
+			resLevel = zerolog.InfoLevel
+		}
+		slow := lo.slowThreshold > 0 && elapsed >= lo.slowThreshold
+		if slow && lo.slowLevel > resLevel {
+			resLevel = lo.slowLevel
+		}
+
+		// Sampled out responses are still logged if escalated or slow
+		if !sampled && !slow && resLevel < zerolog.WarnLevel {
+			return
+		}
 
 		// Log response
-		GetLogger(c).WithLevel(globalResponseLevel).
+		res := GetLogger(c).WithLevel(resLevel)
+		if len(c.Errors) > 0 {
+			res.Strs("errors", c.Errors.Errors())
+		}
+		if slow {
+			res.Bool("slow", true)
+		}
+		if gone {
+			res.Bool("client_gone", true)
+		}
+		lo.headers.add(res, c.Writer.Header(), true)
+		wire.fields(res)
+		res.Fields(lo.responseFields(c)).
 			Str("method", c.Request.Method).
+			Str("route", c.FullPath()).
 			Str("ip", c.ClientIP()).
 			Int("response", c.Writer.Status()).
 			Int("bytes", c.Writer.Size()).
 			Dur("time", elapsed).
-			Msg(fmt.Sprintf("RES %s %s %d %s %s", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), elapsed, c.ClientIP()))
+			Msg(lo.responseMessage(c, elapsed))
 	}
 }
 
@@ -77,8 +184,8 @@ func GetLogger(ctx context.Context) *zerolog.Logger {
 	if gctx, ok := ctx.(*gin.Context); ok && gctx.Request != nil {
 		ictx = gctx.Request.Context()
 	}
-	if logger := ictx.Value(loggerKey{}); logger != nil {
-		return logger.(*zerolog.Logger)
+	if h, ok := ictx.Value(loggerKey{}).(*loggerHolder); ok {
+		return h.logger.Load()
 	}
 	return &log.Logger
 }
@@ -93,19 +200,69 @@ func SetLevel(lvl zerolog.Level) gin.HandlerFunc {
 
 // WithLogger adds a logger to a context
 func WithLogger(parent context.Context, logger *zerolog.Logger) context.Context {
-	return context.WithValue(parent, loggerKey{}, logger)
+	return context.WithValue(parent, loggerKey{}, newLoggerHolder(logger, false))
+}
+
+// With returns a child of the context logger with extra fields, without changing the context logger
+//
+//	logger := zlog.With(ctx, func(c zerolog.Context) zerolog.Context {
+//		return c.Str("job", id)
+//	})
+func With(ctx context.Context, fn func(zerolog.Context) zerolog.Context) *zerolog.Logger {
+	logger := fn(GetLogger(ctx).With()).Logger()
+	return &logger
+}
+
+// Update adds fields to the request logger, so they appear on all subsequent log lines for the request including
+// the RES line, e.g. the user ID once authenticated. Returns the updated logger.
+func Update(ctx *gin.Context, fn func(zerolog.Context) zerolog.Context) *zerolog.Logger {
+	logger := With(ctx, fn)
+	setLogger(ctx, logger)
+	return logger
 }
 
 // SetGlobalRequestLevel sets the log level for the REQ http trace log line
 func SetGlobalRequestLevel(lvl zerolog.Level) {
-	globalRequestLevel = lvl
+	globalRequestLevel.set(lvl)
 }
 
 // SetGlobalResponseLevel sets the log level for the RES http trace log line
 func SetGlobalResponseLevel(lvl zerolog.Level) {
-	globalResponseLevel = lvl
+	globalResponseLevel.set(lvl)
+}
+
+// SetStatusLevelMap sets the RES line level by response status. Keys are either exact status codes, or status
+// classes 1-5 (e.g. 4 for all 4xx responses), with exact codes taking precedence. The level is never lowered
+// below the global response level. Defaults to warn for 4xx and error for 5xx responses.
+func SetStatusLevelMap(levels map[int]zerolog.Level) {
+	statusLevels = levels
 }
 
+func defaultStatusLevel(status int) zerolog.Level {
+	if lvl, ok := statusLevels[status]; ok {
+		return lvl
+	}
+	if lvl, ok := statusLevels[status/100]; ok {
+		return lvl
+	}
+	return zerolog.TraceLevel
+}
+
+// The request context is cancelled when the client disconnects, as opposed to a deadline set by a timeout middleware
+func clientGone(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.Canceled)
+}
+
+// Replace the request logger in place if the request owns the nearest holder, so contexts derived from the request
+// also see the update, otherwise attach a new holder owned by the request
 func setLogger(c *gin.Context, logger *zerolog.Logger) {
-	c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), logger))
+	if h, ok := c.Request.Context().Value(loggerKey{}).(*loggerHolder); ok && h.owned {
+		h.logger.Store(logger)
+		return
+	}
+	attachLogger(c, logger)
+}
+
+func attachLogger(c *gin.Context, logger *zerolog.Logger) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), loggerKey{}, newLoggerHolder(logger, true)))
 }
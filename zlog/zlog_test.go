@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redmapletech/ginx/requestid"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -164,3 +165,851 @@ func TestLogAgent(t *testing.T) {
 	assert.Contains(t, lines[2], id)
 	assert.Contains(t, lines[2], "agent=test-agent")
 }
+
+func TestLogBodies(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.POST("", Logger(zerolog.TraceLevel, WithBodies(1024)), func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		assert.Equal(t, `{"user":"a","password":"hunter2"}`, string(body))
+		ctx.JSON(http.StatusOK, gin.H{"token": "abc", "nested": gin.H{"Secret": "x", "ok": 1}})
+	})
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"user":"a","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.Equal(t, 5, len(lines))
+	assert.Contains(t, lines[1], `"message":"REQ BODY"`)
+	assert.Contains(t, lines[1], `{\"password\":\"[REDACTED]\",\"user\":\"a\"}`)
+	assert.Contains(t, lines[2], `"message":"RES BODY"`)
+	assert.Contains(t, lines[2], `{\"nested\":{\"Secret\":\"[REDACTED]\",\"ok\":1},\"token\":\"[REDACTED]\"}`)
+	assert.NotContains(t, buf.String(), "hunter2")
+}
+
+func TestLogBodiesTruncated(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	w := httptest.NewRecorder()
+	e := gin.New()
+
+	e.POST("", Logger(zerolog.TraceLevel, WithBodies(30)), func(ctx *gin.Context) {
+		io.ReadAll(ctx.Request.Body)
+		ctx.Status(http.StatusNoContent)
+	})
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"token":"abcdef","data":"0123456789"}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.Equal(t, 4, len(lines))
+	assert.Contains(t, lines[1], `{\"token\":\"[REDACTED]\",\"data\":\"01`)
+	assert.Contains(t, lines[1], `"truncated":true`)
+}
+
+func TestLogBodiesLevelAndType(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(Logger(zerolog.DebugLevel, WithBodies(1024), WithBodyContentTypes("application/json")))
+	e.POST("/debug", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	e.POST("/trace", SetLevel(zerolog.TraceLevel), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "text")
+	})
+
+	req, _ := http.NewRequest("POST", "/debug", strings.NewReader(`{}`))
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NotContains(t, buf.String(), "BODY")
+
+	req, _ = http.NewRequest("POST", "/trace", strings.NewReader(`a=1&password=x`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NotContains(t, buf.String(), "BODY")
+}
+
+func TestRedactForm(t *testing.T) {
+	bo := bodyOptsOf(&loggerOpts{})
+	b := &cappedBuffer{limit: 100}
+	b.WriteString("user=a&Password=b")
+
+	assert.Equal(t, "Password=%5BREDACTED%5D&user=a", bo.redactBody("application/x-www-form-urlencoded", b))
+}
+
+func TestLogStatusLevel(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf).Output(zerolog.ConsoleWriter{Out: buf, NoColor: true})
+
+	e := gin.New()
+	e.Use(Logger(zerolog.DebugLevel))
+	e.GET("/:status", func(ctx *gin.Context) {
+		var status int
+		fmt.Sscan(ctx.Param("status"), &status)
+		ctx.Status(status)
+	})
+
+	for status, level := range map[int]string{200: "DBG", 302: "DBG", 404: "WRN", 503: "ERR"} {
+		buf.Reset()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/%d", status), nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Contains(t, buf.String(), fmt.Sprintf("%s RES GET /%d %d", level, status, status))
+	}
+}
+
+func TestLogStatusLevelMap(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf).Output(zerolog.ConsoleWriter{Out: buf, NoColor: true})
+
+	SetStatusLevelMap(map[int]zerolog.Level{4: zerolog.InfoLevel, 429: zerolog.ErrorLevel})
+	defer SetStatusLevelMap(map[int]zerolog.Level{4: zerolog.WarnLevel, 5: zerolog.ErrorLevel})
+
+	e := gin.New()
+	e.GET("/global/:status", Logger(zerolog.DebugLevel), func(ctx *gin.Context) {
+		var status int
+		fmt.Sscan(ctx.Param("status"), &status)
+		ctx.Status(status)
+	})
+	e.GET("/handler", Logger(zerolog.DebugLevel, WithStatusLevel(func(int) zerolog.Level {
+		return zerolog.WarnLevel
+	})), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	for path, expected := range map[string]string{
+		"/global/404": "INF RES GET /global/404",
+		"/global/429": "ERR RES GET /global/429",
+		"/global/500": "DBG RES GET /global/500",
+		"/handler":    "WRN RES GET /handler",
+	} {
+		buf.Reset()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Contains(t, buf.String(), expected)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+
+	e := gin.New()
+	e.GET("", New(
+		WithLevel(zerolog.DebugLevel),
+		WithFields(map[string]interface{}{"service": "api"}),
+		WithClock(clock),
+	), func(ctx *gin.Context) {
+		GetLogger(ctx).Trace().Msg("hidden")
+		GetLogger(ctx).Debug().Msg("TEST")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[0], `"service":"api"`)
+	assert.Contains(t, lines[0], `"message":"TEST"`)
+	assert.Contains(t, lines[1], `"service":"api"`)
+	assert.Contains(t, lines[1], `"time":250`)
+}
+
+func TestNewDefaultLevel(t *testing.T) {
+	lo := getLoggerOpts()
+
+	assert.Equal(t, zerolog.InfoLevel, lo.level)
+}
+
+func TestSkipPaths(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf).Output(zerolog.ConsoleWriter{Out: buf, NoColor: true})
+
+	e := gin.New()
+	e.Use(Logger(zerolog.TraceLevel,
+		WithSkipPaths("/healthz", "/static/*"),
+		WithSkipFunc(func(ctx *gin.Context) bool {
+			return ctx.GetHeader("X-Probe") != ""
+		}),
+	))
+	e.GET("/*path", func(ctx *gin.Context) {
+		GetLogger(ctx).Info().Msg("handler")
+	})
+
+	for path, logged := range map[string]bool{
+		"/healthz":          false,
+		"/static/app.js":    false,
+		"/static/js/app.js": true,
+		"/probe":            false,
+		"/users":            true,
+	} {
+		buf.Reset()
+		req, _ := http.NewRequest("GET", path, nil)
+		if path == "/probe" {
+			req.Header.Set("X-Probe", "1")
+		}
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Contains(t, buf.String(), "INF handler", path)
+		assert.Equal(t, logged, strings.Contains(buf.String(), "REQ GET"), path)
+		assert.Equal(t, logged, strings.Contains(buf.String(), "RES GET"), path)
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel, WithRequestIDHeader("X-Request-ID", "traceparent")))
+
+	tests := []struct {
+		headers map[string]string
+		id      string
+	}{
+		{map[string]string{"X-Request-ID": "upstream-1"}, "upstream-1"},
+		{map[string]string{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{map[string]string{"X-Request-ID": "bad id\n", "Traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}, ""},
+	}
+	for _, tc := range tests {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		e.ServeHTTP(w, req)
+
+		id := w.Header().Get("X-Request-ID")
+		if tc.id == "" {
+			assert.Len(t, id, 11)
+		} else {
+			assert.Equal(t, tc.id, id)
+		}
+		assert.Equal(t, 2, strings.Count(buf.String(), `"id":"`+id+`"`))
+	}
+}
+
+func TestRequestIDTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel,
+		WithRequestIDHeader("X-Correlation-ID"),
+		WithTrustedProxies("10.0.0.0/8", "192.168.1.1"),
+	))
+
+	for addr, trusted := range map[string]bool{
+		"10.1.2.3:1234":    true,
+		"192.168.1.1:1234": true,
+		"192.168.1.2:1234": false,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("X-Correlation-ID", "upstream")
+		e.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("X-Request-ID"))
+		assert.Equal(t, trusted, w.Header().Get("X-Correlation-ID") == "upstream", addr)
+	}
+
+	assert.Panics(t, func() { WithTrustedProxies("invalid")(&loggerOpts{}) })
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(requestid.New(requestid.WithGenerator(func() string { return "from-requestid" })))
+	e.GET("", Logger(zerolog.TraceLevel, WithIDGenerator(func() string { return "from-zlog" })))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "from-requestid", w.Header().Get("X-Request-ID"))
+	assert.Contains(t, buf.String(), `"id":"from-requestid"`)
+}
+
+func TestLogContextErrors(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel), func(ctx *gin.Context) {
+		ctx.Error(fmt.Errorf("first"))
+		ctx.Error(fmt.Errorf("second"))
+		ctx.AbortWithStatus(http.StatusBadRequest)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.Contains(t, lines[1], `"level":"error"`)
+	assert.Contains(t, lines[1], `"errors":["first","second"]`)
+	assert.NotContains(t, lines[0], "errors")
+}
+
+func TestLogRoute(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(Logger(zerolog.TraceLevel))
+	e.GET("/users/:id", func(ctx *gin.Context) {})
+
+	req, _ := http.NewRequest("GET", "/users/123", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.NotContains(t, lines[0], "route")
+	assert.Contains(t, lines[1], `"route":"/users/:id"`)
+	assert.Contains(t, lines[1], `"path":"/users/123"`)
+
+	buf.Reset()
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), `"route":""`)
+}
+
+func TestFieldsFunc(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel,
+		WithFieldsFunc(func(ctx *gin.Context) map[string]interface{} {
+			return map[string]interface{}{"region": ctx.GetHeader("X-Region")}
+		}),
+		WithResponseFieldsFunc(func(ctx *gin.Context) map[string]interface{} {
+			return map[string]interface{}{"user": ctx.GetString("user")}
+		}),
+	), func(ctx *gin.Context) {
+		ctx.Set("user", "alice")
+		GetLogger(ctx).Info().Msg("handler")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Region", "eu")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(buf.String(), "\n")
+
+	assert.Equal(t, 4, len(lines))
+	for _, l := range lines[:3] {
+		assert.Contains(t, l, `"region":"eu"`)
+	}
+	assert.NotContains(t, lines[1], "alice")
+	assert.Contains(t, lines[2], `"user":"alice"`)
+}
+
+func TestSampling(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(Logger(zerolog.TraceLevel, WithRouteSampleRate("/hot/:id", 10)))
+	e.GET("/hot/:id", func(ctx *gin.Context) {
+		if ctx.Param("id") == "bad" {
+			ctx.Status(http.StatusInternalServerError)
+		}
+	})
+	e.GET("/cold", func(ctx *gin.Context) {
+		GetLogger(ctx).Info().Msg("handler")
+	})
+
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest("GET", "/hot/1", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), "REQ GET /hot/1"))
+	assert.Equal(t, 2, strings.Count(buf.String(), "RES GET /hot/1"))
+
+	// Escalated responses are always logged
+	buf.Reset()
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "/hot/bad", nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 5, strings.Count(buf.String(), "RES GET /hot/bad 500"))
+
+	buf.Reset()
+	req, _ := http.NewRequest("GET", "/cold", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.New(io.Discard)
+
+	now := time.Unix(0, 0)
+	clock := func() time.Time {
+		now = now.Add(20 * time.Millisecond)
+		return now
+	}
+
+	p := NewPrometheus(0.01, 0.05, 0.1)
+	e := gin.New()
+	e.Use(Logger(zerolog.InfoLevel, WithClock(clock), WithMetrics(p), WithSkipPaths("/metrics")))
+	e.GET("/users/:id", func(ctx *gin.Context) {})
+	e.GET("/metrics", p.Handler())
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	e.ServeHTTP(w, req)
+	out := w.Body.String()
+
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="",status="404"} 1`)
+	assert.Contains(t, out, `http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.01"} 0`)
+	assert.Contains(t, out, `http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.05"} 2`)
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, out, `http_requests_in_flight{method="GET",route="/metrics"} 1`)
+	assert.Contains(t, out, `http_requests_in_flight{method="GET",route="/users/:id"} 0`)
+}
+
+func TestServerTiming(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.New(io.Discard)
+
+	now := time.Unix(0, 0)
+	clock := func() time.Time {
+		now = now.Add(12300 * time.Microsecond)
+		return now
+	}
+
+	e := gin.New()
+	e.Use(Logger(zerolog.InfoLevel, WithClock(clock), WithServerTiming("app")))
+	e.GET("/body", func(ctx *gin.Context) {
+		ctx.Header("Server-Timing", "db;dur=1")
+		ctx.String(http.StatusOK, "ok")
+	})
+	e.GET("/empty", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/body", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, []string{"db;dur=1", "app;dur=12.3"}, w.Header().Values("Server-Timing"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/empty", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "app;dur=12.3", w.Header().Get("Server-Timing"))
+}
+
+func TestWithAndUpdate(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.TraceLevel), func(ctx *gin.Context) {
+		With(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("child", "yes")
+		}).Info().Msg("child")
+		Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("user_id", "42")
+		})
+		GetLogger(ctx).Info().Msg("handler")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[1], `"child":"yes"`)
+	assert.NotContains(t, lines[2], `"child"`)
+	assert.Contains(t, lines[2], `"user_id":"42"`)
+	assert.Contains(t, lines[3], `"user_id":"42"`)
+	assert.Contains(t, lines[3], "RES GET")
+}
+
+func TestCompactMessages(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.GET("/users/:id", Logger(zerolog.TraceLevel, WithCompactMessages()), func(ctx *gin.Context) {})
+
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"message":"request"`)
+	assert.Contains(t, lines[1], `"message":"response"`)
+	assert.Contains(t, lines[1], `"route":"/users/:id"`)
+	assert.NotContains(t, buf.String(), "REQ GET")
+}
+
+func TestBaseLogger(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	global := &bytes.Buffer{}
+	log.Logger = zerolog.New(global)
+
+	bufA, bufB := &bytes.Buffer{}, &bytes.Buffer{}
+	a, b := gin.New(), gin.New()
+	a.GET("", Logger(zerolog.TraceLevel, WithBaseLogger(zerolog.New(bufA))), func(ctx *gin.Context) {
+		GetLogger(ctx).Info().Msg("engine a")
+	})
+	b.GET("", Logger(zerolog.TraceLevel, WithBaseLogger(zerolog.New(bufB))), func(ctx *gin.Context) {
+		GetLogger(ctx).Info().Msg("engine b")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	a.ServeHTTP(httptest.NewRecorder(), req)
+	b.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 3, strings.Count(bufA.String(), "\n"))
+	assert.Contains(t, bufA.String(), "engine a")
+	assert.Equal(t, 3, strings.Count(bufB.String(), "\n"))
+	assert.Contains(t, bufB.String(), "engine b")
+	assert.Empty(t, global.String())
+}
+
+func TestIDGenerator(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log.Logger = zerolog.New(io.Discard)
+
+	var got string
+	e := gin.New()
+	e.GET("", Logger(zerolog.InfoLevel, WithIDGenerator(func() string { return "custom-id" })), func(ctx *gin.Context) {
+		got = GetRequestID(ctx)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, "custom-id", got)
+	assert.Equal(t, "custom-id", w.Header().Get("X-Request-ID"))
+	assert.Empty(t, GetRequestID(context.Background()))
+}
+
+func TestSlowThreshold(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	now := time.Unix(0, 0)
+	step := time.Millisecond
+	clock := func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+
+	e := gin.New()
+	e.GET("", Logger(zerolog.InfoLevel, WithClock(clock), WithSlowThreshold(time.Second, zerolog.WarnLevel)), func(ctx *gin.Context) {})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, buf.String())
+
+	step = 2 * time.Second
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+	assert.Contains(t, buf.String(), `"slow":true`)
+}
+
+func TestClientGone(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	p := NewPrometheus()
+	e := gin.New()
+	e.GET("", Logger(zerolog.InfoLevel, WithMetrics(p)), func(ctx *gin.Context) {})
+
+	rctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(rctx, "GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), `"level":"info"`)
+	assert.Contains(t, buf.String(), `"client_gone":true`)
+
+	out := &bytes.Buffer{}
+	_, _ = p.WriteTo(out)
+	assert.Contains(t, out.String(), `http_requests_total{method="GET",route="/",status="499"} 1`)
+}
+
+func TestLevelHandler(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	log.Logger = zerolog.New(io.Discard)
+	defer SetGlobalResponseLevel(zerolog.DebugLevel)
+
+	e := gin.New()
+	e.GET("/levels", LevelHandler())
+	e.PUT("/levels", LevelHandler())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/levels", nil)
+	e.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"request":"trace","response":"debug","global":"trace"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/levels", strings.NewReader(`{"response":"info"}`))
+	e.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"request":"trace","response":"info","global":"trace"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/levels", strings.NewReader(`{"request":"debug","response":"loud"}`))
+	e.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, zerolog.TraceLevel, globalRequestLevel.get())
+}
+
+func TestRouteLevel(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	handler := func(ctx *gin.Context) {
+		GetLogger(ctx).Debug().Msg("debug " + ctx.FullPath())
+	}
+	e := gin.New()
+	e.Use(Logger(zerolog.InfoLevel))
+	e.GET("/api/v1/payments/:id", handler)
+	e.GET("/api/v1/users/:id", handler)
+
+	SetRouteLevel("/api/v1/payments/*", zerolog.DebugLevel)
+	defer ClearRouteLevel("/api/v1/payments/*")
+
+	for _, p := range []string{"/api/v1/payments/1", "/api/v1/users/1"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Contains(t, buf.String(), "debug /api/v1/payments/:id")
+	assert.NotContains(t, buf.String(), "debug /api/v1/users/:id")
+
+	// Managed through the level handler
+	e.PUT("/levels", LevelHandler())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/levels", strings.NewReader(`{"routes":{"/api/v1/users/:id":"debug","/api/v1/payments/*":""}}`))
+	e.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"routes":{"/api/v1/users/:id":"debug"}`)
+	defer ClearRouteLevel("/api/v1/users/:id")
+
+	buf.Reset()
+	for _, p := range []string{"/api/v1/payments/1", "/api/v1/users/1"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.NotContains(t, buf.String(), "debug /api/v1/payments/:id")
+	assert.Contains(t, buf.String(), "debug /api/v1/users/:id")
+}
+
+func TestHeaders(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	e := gin.New()
+	e.Use(Logger(zerolog.TraceLevel,
+		WithRequestHeaders("x-api-version", "Accept", "Authorization", "Cookie", "X-Missing"),
+		WithResponseHeaders("Content-Type", "Set-Cookie"),
+		WithUnredactedHeaders("cookie"),
+	))
+	e.GET("", func(ctx *gin.Context) {
+		ctx.SetCookie("session", "secret", 0, "/", "", true, true)
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Api-Version", "2")
+	req.Header.Add("Accept", "text/plain")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "theme=dark")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Contains(t, lines[0], `"headers":{"x-api-version":"2","accept":"text/plain, application/json","authorization":"[REDACTED]","cookie":"theme=dark"}`)
+	assert.Contains(t, lines[1], `"headers":{"content-type":"text/plain; charset=utf-8","set-cookie":"[REDACTED]"}`)
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestSetLoggerInPlace(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	shared := zerolog.New(io.Discard)
+	e := gin.New()
+	e.Use(func(ctx *gin.Context) {
+		// A logger shared by all requests, e.g. from the server base context, is never modified
+		ctx.Request = ctx.Request.WithContext(WithLogger(ctx.Request.Context(), &shared))
+	})
+	e.Use(Logger(zerolog.InfoLevel))
+	e.GET("", func(ctx *gin.Context) {
+		req := ctx.Request
+		reqCtx := req.Context()
+		SetLevel(zerolog.WarnLevel)(ctx)
+		Update(ctx, func(c zerolog.Context) zerolog.Context {
+			return c.Str("user", "u1")
+		})
+		assert.Same(t, req, ctx.Request)
+		assert.Equal(t, zerolog.WarnLevel, GetLogger(reqCtx).GetLevel())
+		GetLogger(reqCtx).Warn().Msg("updated")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, buf.String(), `"user":"u1","message":"updated"`)
+	assert.Equal(t, zerolog.TraceLevel, shared.GetLevel())
+}
+
+func TestWireBytes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf)
+
+	// Compresses the response after the logger, as a gzip middleware would
+	compress := func(ctx *gin.Context) {
+		ctx.Writer = &halvingWriter{ResponseWriter: ctx.Writer}
+	}
+
+	// Signals when the hijacked request has been logged
+	done := make(chan struct{})
+	e := gin.New()
+	e.Use(func(ctx *gin.Context) {
+		ctx.Next()
+		if ctx.FullPath() == "/hijack" {
+			close(done)
+		}
+	})
+	e.Use(Logger(zerolog.TraceLevel, WithWireBytes()), compress)
+	e.GET("/body", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "0123456789")
+	})
+	e.GET("/hijack", func(ctx *gin.Context) {
+		conn, rw, err := ctx.Writer.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+		rw.Flush()
+	})
+
+	req, _ := http.NewRequest("GET", "/body", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, buf.String(), `"bytes":10,`)
+	assert.Contains(t, buf.String(), `"bytes_sent":5`)
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	buf.Reset()
+	res, err := http.Get(srv.URL + "/hijack")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "hi", string(body))
+	<-done
+	assert.Contains(t, buf.String(), `"bytes_sent":59,"hijacked":true`)
+}
+
+// Reports the uncompressed size, as some compression middleware does
+type halvingWriter struct {
+	gin.ResponseWriter
+	size int
+}
+
+func (w *halvingWriter) Write(p []byte) (int, error) {
+	_, err := w.ResponseWriter.Write(p[:len(p)/2])
+	w.size += len(p)
+	return len(p), err
+}
+
+func (w *halvingWriter) Size() int {
+	return w.size
+}